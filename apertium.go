@@ -0,0 +1,192 @@
+package gtrans
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Apertium translates texts with the rule-based Apertium through its free API
+// (or APERTIUM_URL), or with the apertium command if it's installed, for the
+// language pairs it covers. The source language is given by APERTIUM_FROM or
+// detected by the API.
+// https://wiki.apertium.org/wiki/Apertium-apy
+type Apertium struct {
+	baseURL string
+	from    string // ISO 639-3 code of the source language if it's given
+	command string // path of the apertium command if it's installed
+	client  *http.Client
+}
+
+func newApertiumEngine(o *engineOptions) (Engine, error) {
+	a := &Apertium{
+		baseURL: strings.TrimRight(o.endpointOr(os.Getenv("APERTIUM_URL")), "/"),
+		client:  o.client(),
+	}
+	if a.baseURL == "" {
+		a.baseURL = "https://www.apertium.org/apy"
+	}
+	if from := os.Getenv("APERTIUM_FROM"); from != "" {
+		a.from = apertiumLang(from)
+	}
+	if path, err := lookCommand("apertium"); err == nil {
+		a.command = path
+	}
+	return a, nil
+}
+
+func (a *Apertium) Name() string { return "apertium" }
+
+func (a *Apertium) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	from := a.from
+	if from == "" {
+		var err error
+		if from, err = a.identify(ctx, text); err != nil {
+			return nil, err
+		}
+	}
+	to := apertiumLang(target)
+	if from == to {
+		return &Translation{Text: text, SourceLang: isoLang(from)}, nil
+	}
+	var translated string
+	var err error
+	if a.command != "" {
+		translated, err = a.translateByCommand(ctx, text, from, to)
+	} else {
+		translated, err = a.translate(ctx, text, from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Translation{Text: translated, SourceLang: isoLang(from)}, nil
+}
+
+func (a *Apertium) Detect(ctx context.Context, text string) (string, error) {
+	lang, err := a.identify(ctx, text)
+	if err != nil {
+		return "", err
+	}
+	return isoLang(lang), nil
+}
+
+// identify returns the ISO 639-3 code of the language of text.
+func (a *Apertium) identify(ctx context.Context, text string) (string, error) {
+	var scores map[string]float64
+	if err := a.call(ctx, "identifyLang", url.Values{"q": {text}}, &scores); err != nil {
+		return "", err
+	}
+	lang, max := "", 0.0
+	for l, s := range scores {
+		if lang == "" || s > max {
+			lang, max = l, s
+		}
+	}
+	if lang == "" {
+		return "", errors.New("Apertium API can't identify the language")
+	}
+	return lang, nil
+}
+
+func (a *Apertium) translate(ctx context.Context, text, from, to string) (string, error) {
+	var result struct {
+		ResponseData struct {
+			TranslatedText string `json:"translatedText"`
+		} `json:"responseData"`
+	}
+	params := url.Values{"q": {text}, "langpair": {from + "|" + to}, "markUnknown": {"no"}}
+	if err := a.call(ctx, "translate", params, &result); err != nil {
+		return "", err
+	}
+	return result.ResponseData.TranslatedText, nil
+}
+
+// call calls the API method with params and decodes the JSON response into v.
+func (a *Apertium) call(ctx context.Context, method string, params url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/"+method, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fail to call Apertium API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Explanation string `json:"explanation"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Explanation != "" {
+			return fmt.Errorf("fail to call Apertium API: %s: %s", resp.Status, e.Explanation)
+		}
+		return fmt.Errorf("fail to call Apertium API: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("fail to decode Apertium API response: %v", err)
+	}
+	return nil
+}
+
+// translateByCommand translates text with the installed apertium command.
+// Unknown words are written as they are. Older language pairs are named by
+// ISO 639-1 codes, e.g. en-es, which are tried next.
+func (a *Apertium) translateByCommand(ctx context.Context, text, from, to string) (string, error) {
+	var err error
+	var stdout, stderr bytes.Buffer
+	for _, pair := range []string{from + "-" + to, isoLang(from) + "-" + isoLang(to)} {
+		stdout.Reset()
+		stderr.Reset()
+		if err = runCommand(ctx, a.command, []string{"-u", pair}, strings.NewReader(text), &stdout, &stderr); err == nil {
+			return strings.TrimSuffix(stdout.String(), "\n"), nil
+		}
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return "", fmt.Errorf("engine apertium: %v: %s", err, msg)
+	}
+	return "", fmt.Errorf("engine apertium: %v", err)
+}
+
+// apertiumLangs maps ISO 639-1 codes to ISO 639-3 codes used by Apertium for
+// the languages it supports.
+var apertiumLangs = map[string]string{
+	"af": "afr", "an": "arg", "ar": "ara", "be": "bel", "bg": "bul",
+	"br": "bre", "bs": "bos", "ca": "cat", "cs": "ces", "cy": "cym",
+	"da": "dan", "de": "deu", "en": "eng", "eo": "epo", "es": "spa",
+	"eu": "eus", "fr": "fra", "ga": "gle", "gl": "glg", "hi": "hin",
+	"hr": "hrv", "id": "ind", "is": "isl", "it": "ita", "kk": "kaz",
+	"ky": "kir", "mk": "mkd", "ms": "msa", "mt": "mlt", "nb": "nob",
+	"nl": "nld", "nn": "nno", "no": "nob", "oc": "oci", "pl": "pol",
+	"pt": "por", "ro": "ron", "ru": "rus", "sk": "slk", "sl": "slv",
+	"sq": "sqi", "sr": "srp", "sv": "swe", "tr": "tur", "tt": "tat",
+	"uk": "ukr", "ur": "urd",
+}
+
+// apertiumLang returns the ISO 639-3 code of lang, e.g. spa for es-MX.
+func apertiumLang(lang string) string {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	if l, ok := apertiumLangs[lang]; ok {
+		return l
+	}
+	return lang
+}
+
+// isoLang returns the ISO 639-1 code of the ISO 639-3 code lang if any, as
+// the other engines do.
+func isoLang(lang string) string {
+	for iso, l := range apertiumLangs {
+		if l == lang && iso != "no" {
+			return iso
+		}
+	}
+	return lang
+}
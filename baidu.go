@@ -0,0 +1,151 @@
+package gtrans
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Baidu translates texts with Baidu Fanyi (Baidu Translate) API, which is
+// reachable from networks where Google is not. Requests are signed with the
+// app ID and the secret key. Requests are sent at BAIDU_QPS per second (1 for
+// the standard edition by default).
+// https://fanyi-api.baidu.com/doc/21
+type Baidu struct {
+	appID   string
+	secret  string
+	baseURL string
+	qps     float64
+	client  *http.Client
+}
+
+func newBaiduEngine(o *engineOptions) (Engine, error) {
+	id, secret := pairCredential(o, "baidu", "BAIDU_API_KEY", "BAIDU_APP_ID", "BAIDU_SECRET_KEY")
+	if id == "" || secret == "" {
+		return nil, errors.New("BAIDU_APP_ID and BAIDU_SECRET_KEY are not set. Export them or run 'gtrans auth baidu' with '<app id>:<secret key>'")
+	}
+	qps := 1.0
+	if s := os.Getenv("BAIDU_QPS"); s != "" {
+		var err error
+		if qps, err = strconv.ParseFloat(s, 64); err != nil || qps <= 0 {
+			return nil, fmt.Errorf("invalid BAIDU_QPS %q", s)
+		}
+	}
+	return &Baidu{
+		appID:   id,
+		secret:  secret,
+		baseURL: strings.TrimRight(o.endpointOr("https://fanyi-api.baidu.com/api/trans/vip"), "/"),
+		qps:     qps,
+		client:  o.client(),
+	}, nil
+}
+
+func (b *Baidu) Name() string { return "baidu" }
+
+func (b *Baidu) Limits() Limits { return Limits{PerSecond: b.qps} }
+
+// sign returns the signature of a request of q, the MD5 of the app ID, q, the
+// salt and the secret key.
+func (b *Baidu) sign(q, salt string) string {
+	h := md5.Sum([]byte(b.appID + q + salt + b.secret))
+	return hex.EncodeToString(h[:])
+}
+
+func (b *Baidu) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	salt := strconv.Itoa(rand.Int())
+	form := url.Values{
+		"q":     {text},
+		"from":  {"auto"},
+		"to":    {baiduLang(target)},
+		"appid": {b.appID},
+		"salt":  {salt},
+		"sign":  {b.sign(text, salt)},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/translate", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to call Baidu API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fail to call Baidu API: %s", resp.Status)
+	}
+	// Errors are also returned with 200 OK.
+	var result struct {
+		From        string `json:"from"`
+		TransResult []struct {
+			Dst string `json:"dst"`
+		} `json:"trans_result"`
+		ErrorCode string `json:"error_code"`
+		ErrorMsg  string `json:"error_msg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("fail to decode Baidu API response: %v", err)
+	}
+	if result.ErrorCode != "" && result.ErrorCode != "52000" {
+		return nil, fmt.Errorf("fail to call Baidu API: %s %s", result.ErrorCode, result.ErrorMsg)
+	}
+	// A result is returned per line of the text.
+	lines := make([]string, len(result.TransResult))
+	for i, r := range result.TransResult {
+		lines[i] = r.Dst
+	}
+	return &Translation{Text: strings.Join(lines, "\n"), SourceLang: isoFromBaidu(result.From)}, nil
+}
+
+// baiduLangs maps language codes used by Google Translate to Baidu language
+// codes which differ from them.
+var baiduLangs = map[string]string{
+	"ja":    "jp",
+	"ko":    "kor",
+	"fr":    "fra",
+	"es":    "spa",
+	"ar":    "ara",
+	"bg":    "bul",
+	"et":    "est",
+	"da":    "dan",
+	"fi":    "fin",
+	"ro":    "rom",
+	"sl":    "slo",
+	"sv":    "swe",
+	"vi":    "vie",
+	"zh-cn": "zh",
+	"zh-tw": "cht",
+}
+
+// baiduLang converts a language code used by Google Translate into a Baidu
+// language code.
+func baiduLang(lang string) string {
+	lang = strings.ToLower(lang)
+	if l, ok := baiduLangs[lang]; ok {
+		return l
+	}
+	return lang
+}
+
+// isoFromBaidu converts a Baidu language code into the code used by Google
+// Translate.
+func isoFromBaidu(lang string) string {
+	for iso, l := range baiduLangs {
+		if l == lang && !strings.HasPrefix(iso, "zh") {
+			return iso
+		}
+	}
+	if lang == "cht" {
+		return "zh-TW"
+	}
+	return lang
+}
@@ -0,0 +1,116 @@
+package gtrans
+
+import (
+	"context"
+	"strings"
+)
+
+// Request is a text to translate by Client.TranslateBatch.
+type Request struct {
+	Text       string
+	TargetLang string
+}
+
+// TranslateBatch translates the texts of reqs and returns the results in the
+// same order. Each result has its own error in Err instead of failing the
+// whole batch. Texts into the same language are sent in as few requests as
+// the engine allows.
+//
+// Unlike Translate, texts already written in the target language are detected
+// from the translations, so that no detection request is needed.
+func (c *Client) TranslateBatch(ctx context.Context, reqs []Request) []*Result {
+	results := make([]*Result, len(reqs))
+	fail := func(i int, target string, err error) {
+		results[i] = &Result{Source: reqs[i].Text, TargetLang: target, Err: err}
+	}
+	var pending []int
+	for i, req := range reqs {
+		if strings.TrimSpace(req.Text) == "" {
+			results[i] = blankResult(req.Text, req.TargetLang)
+			continue
+		}
+		if u := c.matchTM(req.Text, req.TargetLang); u != nil {
+			results[i] = tmResult(req.Text, u)
+			continue
+		}
+		if !c.allowSecrets {
+			if err := CheckSecrets(req.Text); err != nil {
+				fail(i, req.TargetLang, err)
+				continue
+			}
+		}
+		if c.onSameLang != "" && c.localDetect && c.secondLang == "" {
+			if lang, confidence := detectLocal(req.Text); confidence >= localDetectMinConfidence && sameLang(lang, req.TargetLang) {
+				results[i] = c.sameLangResult(req.Text, lang, req.TargetLang)
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return results
+	}
+
+	// Requests are grouped by the engines routed for them, in the order of
+	// their first requests.
+	var groups [][]int
+	engines := map[Engine]int{}
+	var order []Engine
+	for _, i := range pending {
+		engine, err := c.route(ctx, reqs[i].Text, reqs[i].TargetLang)
+		if err != nil {
+			fail(i, reqs[i].TargetLang, err)
+			continue
+		}
+		g, ok := engines[engine]
+		if !ok {
+			g = len(groups)
+			engines[engine] = g
+			groups = append(groups, nil)
+			order = append(order, engine)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	for g, pending := range groups {
+		c.translateGroup(ctx, order[g], reqs, pending, results)
+	}
+	return results
+}
+
+// translateGroup translates the requests of reqs at indices pending with
+// engine into results.
+func (c *Client) translateGroup(ctx context.Context, engine Engine, reqs []Request, pending []int, results []*Result) {
+	fail := func(i int, target string, err error) {
+		results[i] = &Result{Source: reqs[i].Text, TargetLang: target, Err: err}
+	}
+	hrs := make([]*HookRequest, len(pending))
+	for k, i := range pending {
+		hrs[k] = &HookRequest{Text: reqs[i].Text, TargetLang: reqs[i].TargetLang}
+	}
+	c.run(ctx, engine, hrs)
+	if c.secondLang != "" {
+		var again []*HookRequest
+		for k, hr := range hrs {
+			if hr.Err == nil && hr.Translation.SourceLang == hr.TargetLang {
+				hrs[k] = &HookRequest{Text: reqs[pending[k]].Text, TargetLang: c.secondLang}
+				again = append(again, hrs[k])
+			}
+		}
+		if len(again) > 0 {
+			c.run(ctx, engine, again)
+		}
+	}
+	for k, i := range pending {
+		hr := hrs[k]
+		if hr.Err != nil {
+			fail(i, hr.TargetLang, hr.Err)
+			continue
+		}
+		r, err := c.finish(ctx, engine, reqs[i].Text, hr)
+		if err != nil {
+			fail(i, hr.TargetLang, err)
+			continue
+		}
+		results[i] = r
+	}
+}
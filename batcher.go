@@ -0,0 +1,74 @@
+package gtrans
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// PackBatches packs texts into as few batches within l as possible, and
+// returns the indices of texts of each batch. Texts are placed into the first
+// batch they fit in from the largest one, and a text exceeding l by itself is
+// sent alone. Indices in a batch, and the batches by their first indices, are
+// in the order of texts.
+func PackBatches(texts []string, l Limits) [][]int {
+	type batch struct {
+		texts        []int
+		chars, bytes int
+	}
+	chars := make([]int, len(texts))
+	order := make([]int, len(texts))
+	for i, t := range texts {
+		chars[i] = utf8.RuneCountInString(t)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return len(texts[order[i]]) > len(texts[order[j]]) })
+	fits := func(b *batch, i int) bool {
+		return (l.MaxTexts <= 0 || len(b.texts) < l.MaxTexts) &&
+			(l.MaxChars <= 0 || b.chars+chars[i] <= l.MaxChars) &&
+			(l.MaxBytes <= 0 || b.bytes+len(texts[i]) <= l.MaxBytes)
+	}
+	var batches []*batch
+	for _, i := range order {
+		var b *batch
+		for _, c := range batches {
+			if fits(c, i) {
+				b = c
+				break
+			}
+		}
+		if b == nil {
+			b = &batch{}
+			batches = append(batches, b)
+		}
+		b.texts = append(b.texts, i)
+		b.chars += chars[i]
+		b.bytes += len(texts[i])
+	}
+	packed := make([][]int, len(batches))
+	for k, b := range batches {
+		sort.Ints(b.texts)
+		packed[k] = b.texts
+	}
+	sort.Slice(packed, func(i, j int) bool { return packed[i][0] < packed[j][0] })
+	return packed
+}
+
+// engineRate returns the rate limiter of requests to engine by its limits,
+// or nil if it's unlimited.
+func (c *Client) engineRate(engine Engine) *RateLimiter {
+	l, ok := engine.(Limiter)
+	if !ok || l.Limits().PerSecond <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.rates[engine.Name()]
+	if !ok {
+		r = NewRateLimiter(l.Limits().PerSecond, 1)
+		if c.rates == nil {
+			c.rates = map[string]*RateLimiter{}
+		}
+		c.rates[engine.Name()] = r
+	}
+	return r
+}
@@ -0,0 +1,394 @@
+package gtrans
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// cacheEntry is a cached response of an engine.
+type cacheEntry struct {
+	Engine      string    `json:"engine"`
+	TargetLang  string    `json:"target_lang"`
+	Source      string    `json:"source"`
+	Translation string    `json:"translation"`
+	SourceLang  string    `json:"source_lang,omitempty"`
+	Confidence  float64   `json:"confidence,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+// responseCache caches responses of engines.
+type responseCache interface {
+	// Get returns the cached response of engine translating text into
+	// target, or nil.
+	Get(engine, text, target string) (*cacheEntry, error)
+	// Put caches e, which expires after ttl if it's positive.
+	Put(e *cacheEntry, ttl time.Duration) error
+	// Clear removes all cached responses.
+	Clear() error
+	// Prune removes expired entries and the least recently used ones while
+	// the cache is larger than maxSize bytes if it's positive.
+	Prune(ttl time.Duration, maxSize int64) error
+	// Count adds the numbers of hits and misses to the statistics.
+	Count(hits, misses int64) error
+	// Stats returns the statistics.
+	Stats() (*CacheStats, error)
+	// Each calls f with every cached response.
+	Each(f func(e *cacheEntry) error) error
+	// String returns the location of the cache.
+	String() string
+}
+
+// CacheStats are statistics of a cache.
+type CacheStats struct {
+	Entries int
+	Bytes   int64
+	Hits    int64
+	Misses  int64
+}
+
+// pruneInterval is the minimum interval to prune the cache, which may take a
+// while for a large cache.
+const pruneInterval = time.Minute
+
+// openCache returns the cache in location, a directory or a redis:// URL.
+func openCache(location string) (responseCache, error) {
+	if strings.HasPrefix(location, "redis://") || strings.HasPrefix(location, "rediss://") {
+		return newRedisCache(location)
+	}
+	return &fileCache{dir: location}, nil
+}
+
+// fileCache caches responses of engines in a directory, a file per response.
+// Unlike the translation memory, responses are cached per engine with
+// placeholders as they are, before any post-processing.
+type fileCache struct {
+	dir string
+}
+
+func cacheKey(engine, text, target string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", engine, target, text)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CacheStatsFile is the file of the statistics in the cache directory.
+const CacheStatsFile = "stats.json"
+
+func (fc *fileCache) path(key string) string {
+	return filepath.Join(fc.dir, key[:2], key+".json")
+}
+
+// Get returns the cached response of engine translating text into target, or
+// nil.
+func (fc *fileCache) Get(engine, text, target string) (*cacheEntry, error) {
+	b, err := ioutil.ReadFile(fc.path(cacheKey(engine, text, target)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		// A broken entry is a cache miss; it's overwritten by Put.
+		return nil, nil
+	}
+	// The modification time is the last use for LRU eviction by Prune.
+	now := time.Now()
+	os.Chtimes(fc.path(cacheKey(engine, text, target)), now, now)
+	return &e, nil
+}
+
+// Put caches e. Expired entries are removed by Prune.
+func (fc *fileCache) Put(e *cacheEntry, ttl time.Duration) error {
+	if e.Created.IsZero() {
+		e.Created = time.Now().UTC()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeCacheFile(fc.path(cacheKey(e.Engine, e.Source, e.TargetLang)), b)
+}
+
+// writeCacheFile writes b to path through a temporary file, as other
+// processes may read it concurrently.
+func writeCacheFile(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".entry")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Clear removes all cached responses.
+func (fc *fileCache) Clear() error {
+	return os.RemoveAll(fc.dir)
+}
+
+// cacheFile is an entry file in the cache directory.
+type cacheFile struct {
+	path string
+	size int64
+	used time.Time
+}
+
+// files returns the entry files in the cache directory.
+func (fc *fileCache) files() ([]cacheFile, error) {
+	var files []cacheFile
+	err := filepath.Walk(fc.dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Entries are in subdirectories, and temporary files start with a
+		// dot.
+		if info.IsDir() || filepath.Dir(path) == fc.dir || !strings.HasSuffix(path, ".json") || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		files = append(files, cacheFile{path: path, size: info.Size(), used: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// Prune removes entries unused for ttl, which have expired since they were
+// created even earlier, and then the least recently used ones.
+func (fc *fileCache) Prune(ttl time.Duration, maxSize int64) error {
+	if ttl <= 0 && maxSize <= 0 {
+		return nil
+	}
+	files, err := fc.files()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].used.Before(files[j].used) })
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	now := time.Now()
+	for _, f := range files {
+		expired := ttl > 0 && now.Sub(f.used) > ttl
+		if !expired && (maxSize <= 0 || size <= maxSize) {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= f.size
+	}
+	return nil
+}
+
+// counts reads the numbers of hits and misses in the statistics file.
+func (fc *fileCache) counts() (*CacheStats, error) {
+	var s CacheStats
+	b, err := ioutil.ReadFile(filepath.Join(fc.dir, CacheStatsFile))
+	if os.IsNotExist(err) {
+		return &s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Broken statistics are reset.
+	json.Unmarshal(b, &s)
+	return &s, nil
+}
+
+// Count adds the numbers to the statistics file. Counts of processes writing
+// at the same time may be lost.
+func (fc *fileCache) Count(hits, misses int64) error {
+	if hits == 0 && misses == 0 {
+		return nil
+	}
+	s, err := fc.counts()
+	if err != nil {
+		return err
+	}
+	s.Hits += hits
+	s.Misses += misses
+	b, err := json.Marshal(struct{ Hits, Misses int64 }{s.Hits, s.Misses})
+	if err != nil {
+		return err
+	}
+	return writeCacheFile(filepath.Join(fc.dir, CacheStatsFile), b)
+}
+
+func (fc *fileCache) Stats() (*CacheStats, error) {
+	s, err := fc.counts()
+	if err != nil {
+		return nil, err
+	}
+	files, err := fc.files()
+	if err != nil {
+		return nil, err
+	}
+	s.Entries = len(files)
+	for _, f := range files {
+		s.Bytes += f.size
+	}
+	return s, nil
+}
+
+// Each calls f with every entry. Broken entries are skipped.
+func (fc *fileCache) Each(f func(e *cacheEntry) error) error {
+	files, err := fc.files()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file.path)
+		if os.IsNotExist(err) {
+			// Removed by another process.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var e cacheEntry
+		if json.Unmarshal(b, &e) != nil {
+			continue
+		}
+		if err := f(&e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fc *fileCache) String() string { return fc.dir }
+
+// closeCache records the numbers of cache hits and misses since the last
+// call, and prunes the cache if it's time to.
+func (c *Client) closeCache() error {
+	if c.cache == nil {
+		return nil
+	}
+	hits, misses := atomic.SwapInt64(&c.cacheHits, 0), atomic.SwapInt64(&c.cacheMisses, 0)
+	if err := c.cache.Count(hits, misses); err != nil {
+		return err
+	}
+	if (c.cacheTTL <= 0 && c.cacheMaxSize <= 0) || time.Since(c.lastPrune) < pruneInterval {
+		return nil
+	}
+	c.lastPrune = time.Now()
+	return c.cache.Prune(c.cacheTTL, c.cacheMaxSize)
+}
+
+// errNoCache is returned for managing the cache of a Client without one.
+var errNoCache = errors.New("cache is disabled")
+
+// CacheLocation returns the directory or the URL of the cache given by
+// WithCache or WithCacheDir, or "" if responses aren't cached.
+func (c *Client) CacheLocation() string {
+	if c.cache == nil {
+		return ""
+	}
+	return c.cache.String()
+}
+
+// ClearCache removes all cached responses.
+func (c *Client) ClearCache() error {
+	if c.cache == nil {
+		return errNoCache
+	}
+	return c.cache.Clear()
+}
+
+// CacheStats returns the statistics of the cache, whose hits and misses are
+// recorded when Clients are closed.
+func (c *Client) CacheStats() (*CacheStats, error) {
+	if c.cache == nil {
+		return nil, errNoCache
+	}
+	return c.cache.Stats()
+}
+
+// ExportCache writes the cached responses to w as JSON Lines, and returns the
+// number of them.
+func (c *Client) ExportCache(w io.Writer) (int, error) {
+	if c.cache == nil {
+		return 0, errNoCache
+	}
+	return exportCache(w, c.cache)
+}
+
+// ImportCache puts the responses read from r as JSON Lines, e.g. written by
+// ExportCache, into the cache, and returns the number of them. Responses which
+// have expired by the TTL of WithCacheLimits are skipped.
+func (c *Client) ImportCache(r io.Reader) (int, error) {
+	if c.cache == nil {
+		return 0, errNoCache
+	}
+	return importCache(r, c.cache, c.cacheTTL)
+}
+
+// exportCache writes the entries of cache to w as JSON Lines.
+func exportCache(w io.Writer, cache responseCache) (int, error) {
+	n := 0
+	enc := json.NewEncoder(w)
+	err := cache.Each(func(e *cacheEntry) error {
+		n++
+		return enc.Encode(e)
+	})
+	return n, err
+}
+
+// importCache puts the entries read from r as JSON Lines into cache. Entries
+// which have expired by ttl are skipped, and the others expire at the same
+// time as they would have.
+func importCache(r io.Reader, cache responseCache, ttl time.Duration) (int, error) {
+	n := 0
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var e cacheEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return n, fmt.Errorf("line %d: %v", line, err)
+		}
+		if e.Engine == "" || e.TargetLang == "" || e.Source == "" {
+			return n, fmt.Errorf("line %d: engine, target_lang and source are required", line)
+		}
+		left := ttl
+		if ttl > 0 && !e.Created.IsZero() {
+			if left = ttl - time.Since(e.Created); left <= 0 {
+				continue
+			}
+		}
+		if err := cache.Put(&e, left); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, s.Err()
+}
@@ -0,0 +1,114 @@
+package gtrans
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WithPreserveCase restores ALL CAPS, Title Case and leading capitals of the
+// texts in their translations.
+func WithPreserveCase() Option {
+	return func(c *Client) error {
+		c.preserveCase = true
+		return nil
+	}
+}
+
+// casing is a casing pattern of a line.
+type casing int
+
+const (
+	caseNone        casing = iota
+	caseUpper              // ALL CAPS
+	caseTitle              // Title Case
+	caseCapitalized        // Leading capital
+)
+
+// detectCasing returns the casing pattern of s. Words without cased letters
+// (numbers, symbols, CJK, ...) are ignored.
+func detectCasing(s string) casing {
+	var (
+		words, titled, letters, upper int
+		first                         = true
+		leading                       bool
+	)
+	for _, w := range strings.Fields(s) {
+		cased := false
+		for _, r := range w {
+			if !unicode.IsUpper(r) && !unicode.IsLower(r) {
+				continue
+			}
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+			if !cased {
+				cased = true
+				words++
+				if unicode.IsUpper(r) {
+					titled++
+				}
+				if first {
+					leading = unicode.IsUpper(r)
+					first = false
+				}
+			}
+		}
+	}
+	switch {
+	case letters >= 2 && upper == letters:
+		return caseUpper
+	case words >= 2 && titled == words:
+		return caseTitle
+	case leading:
+		return caseCapitalized
+	}
+	return caseNone
+}
+
+// applyCasing converts s to casing c. Letters other than the first one of each
+// word are left alone for Title Case, so that acronyms survive.
+func applyCasing(c casing, s string) string {
+	switch c {
+	case caseUpper:
+		return strings.ToUpper(s)
+	case caseTitle:
+		var b strings.Builder
+		inWord := false
+		for _, r := range s {
+			if unicode.IsSpace(r) {
+				inWord = false
+			} else if !inWord && unicode.IsLetter(r) {
+				r = unicode.ToTitle(r)
+				inWord = true
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	case caseCapitalized:
+		i := strings.IndexFunc(s, unicode.IsLetter)
+		if i == -1 {
+			return s
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		return s[:i] + string(unicode.ToTitle(r)) + s[i+size:]
+	}
+	return s
+}
+
+// preserveCasing restores the casing pattern of each line of src on the
+// corresponding line of dst. Engines often normalize the case of headings and
+// UI strings. If the number of lines differs, the pattern of the whole text
+// is used.
+func preserveCasing(src, dst string) string {
+	srcLines := strings.Split(src, "\n")
+	dstLines := strings.Split(dst, "\n")
+	if len(srcLines) != len(dstLines) {
+		return applyCasing(detectCasing(src), dst)
+	}
+	for i, line := range srcLines {
+		dstLines[i] = applyCasing(detectCasing(line), dstLines[i])
+	}
+	return strings.Join(dstLines, "\n")
+}
@@ -0,0 +1,99 @@
+package gtrans
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// circuitBreaker skips requests to an engine for openFor after threshold
+// consecutive errors, instead of sending more requests to an API which is
+// down and stalling the run. After that, requests are sent again as a trial,
+// and the circuit is closed by a success or opened again by an error.
+type circuitBreaker struct {
+	name      string
+	threshold int // 0 never opens
+	openFor   time.Duration
+
+	mu       sync.Mutex
+	failures int // consecutive
+	openedAt time.Time
+	lastErr  error
+}
+
+// allow returns an error if the circuit is open.
+func (b *circuitBreaker) allow() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	if left := b.openFor - time.Since(b.openedAt); left > 0 {
+		left = (left + time.Second - 1).Truncate(time.Second)
+		return fmt.Errorf("engine %s is skipped for %v after %d consecutive errors: %v", b.name, left, b.failures, b.lastErr)
+	}
+	return nil
+}
+
+// isOpen returns true if requests to the engine are skipped.
+func (b *circuitBreaker) isOpen() bool {
+	return b.allow() != nil
+}
+
+// record records the result of a request to the engine. Canceled requests
+// don't count.
+func (b *circuitBreaker) record(err error) {
+	if b == nil || b.threshold <= 0 || errors.Is(err, context.Canceled) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	b.lastErr = err
+	if b.failures >= b.threshold {
+		// An error of a trial opens the circuit again.
+		b.openedAt = time.Now()
+	}
+}
+
+// breaker returns the circuit breaker of the engine named name, or nil if
+// circuits are never opened.
+func (c *Client) breaker(name string) *circuitBreaker {
+	if c.circuitErrors <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[name]
+	if !ok {
+		b = &circuitBreaker{name: name, threshold: c.circuitErrors, openFor: c.circuitOpenFor}
+		if c.breakers == nil {
+			c.breakers = map[string]*circuitBreaker{}
+		}
+		c.breakers[name] = b
+	}
+	return b
+}
+
+// circuitMiddleware fails requests to engine while its circuit is open.
+func (c *Client) circuitMiddleware(engine Engine, next Handler) Handler {
+	b := c.breaker(engine.Name())
+	return func(ctx context.Context, reqs []*HookRequest) {
+		if err := b.allow(); err != nil {
+			for _, req := range reqs {
+				req.Err = err
+			}
+			return
+		}
+		next(ctx, reqs)
+	}
+}
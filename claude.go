@@ -0,0 +1,219 @@
+package gtrans
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Claude translates texts with Anthropic Messages API. The system prompt and
+// the user message are templates given by ANTHROPIC_SYSTEM_PROMPT and
+// ANTHROPIC_PROMPT. A translation longer than the maximum number of output
+// tokens is continued by another request, so that a large document can be
+// translated in one pass with the long context.
+// https://docs.anthropic.com/en/api/messages
+type Claude struct {
+	apiKey        string
+	baseURL       string
+	model         string
+	maxTokens     int
+	system        *template.Template
+	prompt        *template.Template
+	client        *http.Client
+	maskProfanity bool
+}
+
+// claudeMaxChunk is the maximum number of bytes of a chunk of a stream sent
+// at once, which is much larger than the default for the long context.
+const claudeMaxChunk = 100 * 1024
+
+// claudeMaxContinuations is the maximum number of requests continuing a
+// translation cut by the maximum number of output tokens.
+const claudeMaxContinuations = 16
+
+func newClaudeEngine(opts *engineOptions) (Engine, error) {
+	apiKey := opts.credential("claude", "ANTHROPIC_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("ANTHROPIC_API_KEY is not set. Export it or run 'gtrans auth claude'")
+	}
+	c := &Claude{
+		apiKey:    apiKey,
+		baseURL:   "https://api.anthropic.com/v1",
+		model:     "claude-3-5-haiku-latest",
+		maxTokens: 8192,
+		client:    opts.client(),
+	}
+	if u := opts.endpointOr(os.Getenv("ANTHROPIC_BASE_URL")); u != "" {
+		c.baseURL = strings.TrimRight(u, "/")
+	}
+	if m := os.Getenv("ANTHROPIC_MODEL"); m != "" {
+		c.model = m
+	}
+	if s := os.Getenv("ANTHROPIC_MAX_TOKENS"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid ANTHROPIC_MAX_TOKENS %q", s)
+		}
+		c.maxTokens = n
+	}
+	for _, p := range []struct {
+		env string
+		t   **template.Template
+	}{{"ANTHROPIC_SYSTEM_PROMPT", &c.system}, {"ANTHROPIC_PROMPT", &c.prompt}} {
+		if s := os.Getenv(p.env); s != "" {
+			t, err := parsePrompt(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", p.env, err)
+			}
+			*p.t = t
+		}
+	}
+	return c, nil
+}
+
+func (c *Claude) Name() string { return "claude" }
+
+// MaskProfanity makes the model mask profanity in translations.
+func (c *Claude) MaskProfanity() { c.maskProfanity = true }
+
+// MaxChunkSize returns the size of chunks of a stream for the long context.
+func (c *Claude) MaxChunkSize() int { return claudeMaxChunk }
+
+func (c *Claude) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	return c.TranslateStream(ctx, text, target, func(string) {})
+}
+
+// TranslateStream translates text, calling onChunk with each piece of the
+// translated text as it is generated. The response is a stream of server-sent
+// events.
+func (c *Claude) TranslateStream(ctx context.Context, text, target string, onChunk func(string)) (*Translation, error) {
+	system, err := systemPrompt(c.system, target)
+	if err != nil {
+		return nil, err
+	}
+	if c.maskProfanity {
+		system += " Replace all but the first letter of profane words with asterisks."
+	}
+	user := text
+	if c.prompt != nil {
+		if user, err = executePrompt(c.prompt, target, text); err != nil {
+			return nil, err
+		}
+	}
+	var b strings.Builder
+	for i := 0; ; i++ {
+		messages := []openAIMessage{{Role: "user", Content: user}}
+		if b.Len() > 0 {
+			// Continue the translation, which must not end with white
+			// spaces.
+			messages = append(messages, openAIMessage{Role: "assistant", Content: strings.TrimRightFunc(b.String(), unicode.IsSpace)})
+		}
+		stop, err := c.stream(ctx, system, messages, func(chunk string) {
+			if b.Len() == 0 {
+				// Skip leading white spaces, as the translation is trimmed.
+				if chunk = strings.TrimLeftFunc(chunk, unicode.IsSpace); chunk == "" {
+					return
+				}
+			}
+			b.WriteString(chunk)
+			onChunk(chunk)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if stop != "max_tokens" {
+			break
+		}
+		if i == claudeMaxContinuations {
+			return nil, errors.New("Anthropic API: translation is too long")
+		}
+	}
+	return &Translation{Text: strings.TrimRightFunc(b.String(), unicode.IsSpace)}, nil
+}
+
+// stream sends a streaming request of messages, calling onText with each piece
+// of the generated text, and returns the stop reason.
+func (c *Claude) stream(ctx context.Context, system string, messages []openAIMessage, onText func(string)) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":      c.model,
+		"max_tokens": c.maxTokens,
+		"system":     system,
+		"messages":   messages,
+		"stream":     true,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fail to call Anthropic API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e claudeEvent
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != nil {
+			return "", fmt.Errorf("fail to call Anthropic API: %s: %s", resp.Status, e.Error.Message)
+		}
+		return "", fmt.Errorf("fail to call Anthropic API: %s", resp.Status)
+	}
+	stop := ""
+	s := bufio.NewScanner(resp.Body)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		data := strings.TrimPrefix(s.Text(), "data: ")
+		if data == s.Text() || data == "" {
+			continue
+		}
+		var e claudeEvent
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return "", fmt.Errorf("fail to decode Anthropic API response: %v", err)
+		}
+		switch e.Type {
+		case "content_block_delta":
+			if e.Delta.Text != "" {
+				onText(e.Delta.Text)
+			}
+		case "message_delta":
+			stop = e.Delta.StopReason
+		case "error":
+			if e.Error != nil {
+				return "", errors.New("Anthropic API: " + e.Error.Message)
+			}
+		case "message_stop":
+			return stop, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", fmt.Errorf("fail to read Anthropic API response: %v", err)
+	}
+	return stop, nil
+}
+
+// claudeEvent is a server-sent event of a streaming response, or an error
+// response.
+type claudeEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
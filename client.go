@@ -0,0 +1,524 @@
+// Package gtrans translates texts with Google Translate and other machine
+// translation engines, protecting markup, caching responses and reusing a
+// translation memory. The gtrans command is built on it.
+package gtrans
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// Client translates texts through the translation memory, the protection
+// rules and the engine configured by options. It is safe for concurrent use.
+type Client struct {
+	mu          sync.Mutex // guards engine, routed, rates and tm
+	engine      Engine
+	engineName  string
+	routes      *router
+	routed      map[string]Engine // engines selected by routes
+	engineOpts  engineOptions
+	limiter     *RateLimiter
+	rates       map[string]*RateLimiter // by engine names with Limits
+	middlewares []Middleware
+	logger      *log.Logger
+	tm          *TranslationMemory
+	tmThreshold float64
+	tmDirty     bool
+	cache       responseCache
+	cacheTTL    time.Duration
+	// cacheMaxSize is the maximum size of the cache in bytes.
+	cacheMaxSize int64
+	cacheHits    int64 // accessed atomically
+	cacheMisses  int64 // accessed atomically
+	lastPrune    time.Time
+	protector    protector
+	offline      bool
+	passThrough  bool // texts missed in offline mode
+	secondLang   string
+	localDetect  bool // detect source languages locally if sure
+	// onSameLang is "skip" or "notice" to echo texts already written in the
+	// target language, or empty to translate them.
+	onSameLang string
+	// confirmSource is called with the detected source language less
+	// confident than confirmBelow, and returns the confirmed one.
+	confirmSource func(text, guess string, confidence float64) (string, error)
+	confirmBelow  float64
+	// quota is called with the texts sent to an engine, which fail if it
+	// returns an error, or is nil.
+	quota func(engine string, texts []string) error
+	// stats are the statistics of the run written by Close to statsOut in
+	// statsFormat and recorded in the usage log at usageLog, or nil.
+	stats       *runStats
+	statsOut    io.Writer
+	statsFormat string
+	usageLog    string
+	// circuitErrors are the consecutive errors of an engine opening its
+	// circuit for circuitOpenFor, or 0 never to open it.
+	circuitErrors  int
+	circuitOpenFor time.Duration
+	breakers       map[string]*circuitBreaker // by engine names, guarded by mu
+	// flights are the texts in flight by engine, target and text.
+	flightMu sync.Mutex
+	flights  map[string]*flight
+	// allowSecrets sends texts which look like they contain secrets.
+	allowSecrets bool
+	// quality estimates the quality of translations, which are low below
+	// minQuality.
+	quality    bool
+	minQuality float64
+	// maskProfanity masks profanity in translations with the words of the
+	// built-in lists and of profanityList if it's set.
+	maskProfanity bool
+	profanityList string
+	// filters are the profanity filters by target language.
+	filterMu     sync.Mutex
+	filters      map[string]*profanityFilter
+	preserveCase bool
+
+	// stream is called with each piece of translated text as it arrives if
+	// it's set and the engine supports streaming.
+	stream func(string)
+	// preHook is the shell command replacing each text before it's
+	// translated, or empty.
+	preHook string
+	// postHook and postFileHook are the shell commands replacing each
+	// translated text and each translated file, or empty.
+	postHook     string
+	postFileHook string
+}
+
+// NewClient returns a Client configured by opts. The engine is created when
+// it is needed first, so that texts found in the translation memory can be
+// translated without credentials.
+func NewClient(opts ...Option) (*Client, error) {
+	c := &Client{engineName: "google"}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Client) getEngine() (Engine, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.engine != nil {
+		return c.engine, nil
+	}
+	if c.offline {
+		c.engine = &offlineEngine{name: c.engineName}
+		return c.engine, nil
+	}
+	engine, err := newEngine(c.engineName, &c.engineOpts)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := engine.(ProfanityMasker); ok && c.maskProfanity {
+		m.MaskProfanity()
+	}
+	c.engine = engine
+	return engine, nil
+}
+
+// Translate translates text into targetLang, or into the second language if
+// text is already written in targetLang. ctx cancels the requests to the
+// engine.
+func (c *Client) Translate(ctx context.Context, text, targetLang string) (*Result, error) {
+	return c.translate(ctx, text, targetLang, nil)
+}
+
+// ProtectRule protects the matches of Pattern from translation, which are
+// replaced with placeholders of the class Kind, e.g. "markup".
+type ProtectRule struct {
+	Kind    string
+	Pattern *regexp.Regexp
+}
+
+// TranslateProtected translates text as Translate, protecting the matches of
+// rules as well, e.g. inline markup of a document format.
+func (c *Client) TranslateProtected(ctx context.Context, text, targetLang string, rules []ProtectRule) (*Result, error) {
+	extra := make([]protectRule, len(rules))
+	for i, r := range rules {
+		extra[i] = protectRule{kind: r.Kind, re: r.Pattern}
+	}
+	return c.translate(ctx, text, targetLang, extra)
+}
+
+// Detect detects the language of text with the engine.
+func (c *Client) Detect(ctx context.Context, text string) (string, error) {
+	lang, _, err := c.detect(ctx, text)
+	return lang, err
+}
+
+// detect detects the language of text with the engine, and returns it with
+// the confidence, which is -1 if the engine doesn't tell it.
+func (c *Client) detect(ctx context.Context, text string) (string, float64, error) {
+	if !c.allowSecrets {
+		if err := CheckSecrets(text); err != nil {
+			return "", 0, err
+		}
+	}
+	engine, err := c.getEngine()
+	if err != nil {
+		return "", 0, err
+	}
+	d, ok := engine.(Detector)
+	if !ok {
+		return "", 0, fmt.Errorf("engine %s doesn't support language detection", engine.Name())
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return "", 0, err
+	}
+	c.logf("engine %s: detect %d chars", engine.Name(), utf8.RuneCountInString(text))
+	if cd, ok := engine.(ConfidenceDetector); ok {
+		return cd.DetectConfidence(ctx, text)
+	}
+	lang, err := d.Detect(ctx, text)
+	return lang, -1, err
+}
+
+// detectSource detects the source language of text locally, or with the
+// default engine if it's unsure and the engine can detect languages. It
+// returns false if the language is unknown. Languages detected with low
+// confidence are confirmed by confirmSource if it's set.
+func (c *Client) detectSource(ctx context.Context, text string) (string, bool, error) {
+	guess, confidence := "", 0.0
+	if c.localDetect {
+		if guess, confidence = detectLocal(text); confidence >= localDetectMinConfidence {
+			c.logf("detect %s locally with confidence %.2f", guess, confidence)
+			return guess, true, nil
+		}
+	}
+	engine, err := c.getEngine()
+	if err != nil {
+		return "", false, err
+	}
+	if _, ok := engine.(Detector); ok {
+		lang, conf, err := c.detect(ctx, text)
+		if err != nil {
+			return "", false, err
+		}
+		// The engine is trusted unless it tells the low confidence.
+		if conf < 0 || c.confirmSource == nil || conf >= c.confirmBelow {
+			return lang, true, nil
+		}
+		guess, confidence = lang, conf
+	} else if guess == "" || c.confirmSource == nil || confidence >= c.confirmBelow {
+		return "", false, nil
+	}
+	lang, err := c.confirmSource(text, guess, confidence)
+	if err != nil {
+		return "", false, err
+	}
+	return lang, lang != "", nil
+}
+
+// translate translates text, protecting the matches of extra rules as well,
+// e.g. inline markup of a document format.
+func (c *Client) translate(ctx context.Context, text, targetLang string, extra []protectRule) (*Result, error) {
+	if strings.TrimSpace(text) == "" {
+		return blankResult(text, targetLang), nil
+	}
+	if u := c.matchTM(text, targetLang); u != nil {
+		return tmResult(text, u), nil
+	}
+
+	if !c.allowSecrets {
+		if err := CheckSecrets(text); err != nil {
+			return nil, err
+		}
+	}
+
+	sourceLang, detected := "", false
+	if c.secondLang != "" || c.routes.needsSource(targetLang) {
+		var err error
+		if sourceLang, detected, err = c.detectSource(ctx, text); err != nil {
+			return nil, err
+		}
+		if detected && c.secondLang != "" && sameLang(sourceLang, targetLang) {
+			targetLang = c.secondLang
+		}
+	} else if c.onSameLang != "" && c.localDetect {
+		// Only local detection is worth it, as a detection request may cost
+		// as much as the translation.
+		if lang, confidence := detectLocal(text); confidence >= localDetectMinConfidence {
+			sourceLang, detected = lang, true
+		}
+	}
+	if detected && c.onSameLang != "" && sameLang(sourceLang, targetLang) {
+		return c.sameLangResult(text, sourceLang, targetLang), nil
+	}
+	engine, err := c.routeEngine(ctx, sourceLang, targetLang)
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := engine.(StreamTranslator); ok && c.stream != nil {
+		p := c.protector
+		if len(extra) > 0 {
+			p.rules = append(append([]protectRule(nil), p.rules...), extra...)
+		}
+		protected, ps := p.Protect(text)
+		return c.translateStream(ctx, s, engine, text, protected, ps, targetLang)
+	}
+	req := &HookRequest{Text: text, TargetLang: targetLang, rules: extra}
+	c.run(ctx, engine, []*HookRequest{req})
+	if req.Err != nil {
+		return nil, req.Err
+	}
+	if !detected && c.secondLang != "" && req.Translation.SourceLang == targetLang {
+		// The engine can't detect the language beforehand, so translate
+		// again if the text turned out to be written in the target language.
+		if engine, err = c.routeEngine(ctx, req.Translation.SourceLang, c.secondLang); err != nil {
+			return nil, err
+		}
+		req = &HookRequest{Text: text, TargetLang: c.secondLang, rules: extra}
+		if c.run(ctx, engine, []*HookRequest{req}); req.Err != nil {
+			return nil, req.Err
+		}
+	}
+	return c.finish(ctx, engine, text, req)
+}
+
+// blankResult returns the result of empty or whitespace-only text, which is
+// never sent to the engine.
+func blankResult(text, targetLang string) *Result {
+	return &Result{Source: text, Translation: text, TargetLang: targetLang, Engine: "none"}
+}
+
+// sameLangResult returns the result of text already written in targetLang,
+// which is echoed without calling the engine.
+func (c *Client) sameLangResult(text, sourceLang, targetLang string) *Result {
+	if c.onSameLang == "notice" {
+		fmt.Fprintf(os.Stderr, "gtrans: the input is already written in %s, so it's not translated\n", sourceLang)
+	}
+	return &Result{
+		Source:      text,
+		Translation: text,
+		SourceLang:  sourceLang,
+		TargetLang:  targetLang,
+		Engine:      "none",
+	}
+}
+
+// tmResult returns the result of text found in the translation memory.
+func tmResult(text string, u *TranslationUnit) *Result {
+	return &Result{
+		Source:      text,
+		Translation: u.Target,
+		SourceLang:  u.SourceLang,
+		TargetLang:  u.TargetLang,
+		Engine:      "tm",
+	}
+}
+
+// finish returns the result of translating text by req, which is recorded in
+// the translation memory.
+func (c *Client) finish(ctx context.Context, engine Engine, text string, req *HookRequest) (*Result, error) {
+	translated := req.Translation
+	r := &Result{
+		Source:      text,
+		Translation: translated.Text,
+		SourceLang:  translated.SourceLang,
+		TargetLang:  req.TargetLang,
+		Engine:      engine.Name(),
+		raw:         req.raw,
+		ps:          req.ps,
+	}
+	if req.passed {
+		// The text isn't translated, nor recorded.
+		r.Engine = "offline"
+		return r, nil
+	}
+	if err := c.estimateQuality(ctx, engine, r, translated); err != nil {
+		return nil, err
+	}
+	c.addTM(r)
+	return r, nil
+}
+
+// translateStream translates protected text with streaming. Casing is not
+// preserved since the translated text has already been written when it is
+// complete, and the middlewares are bypassed for the same reason.
+func (c *Client) translateStream(ctx context.Context, s StreamTranslator, engine Engine, text, protected string, ps placeholders, targetLang string) (*Result, error) {
+	sr := &streamRestorer{ps: ps, emit: c.stream}
+	if c.quota != nil {
+		if err := c.quota(engine.Name(), []string{protected}); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	c.stats.translate([]*HookRequest{{Text: text}})
+	start := time.Now()
+	translated, err := s.TranslateStream(ctx, protected, targetLang, sr.Write)
+	c.stats.call(engine.Name(), []string{protected}, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	c.stats.translated([]*HookRequest{{TargetLang: targetLang, Translation: translated}})
+	sr.Flush()
+	r := &Result{
+		Source:      text,
+		Translation: ps.Restore(translated.Text),
+		SourceLang:  translated.SourceLang,
+		TargetLang:  targetLang,
+		Engine:      engine.Name(),
+	}
+	translated.Text = r.Translation
+	if err := c.estimateQuality(ctx, engine, r, translated); err != nil {
+		return nil, err
+	}
+	c.addTM(r)
+	return r, nil
+}
+
+// estimateQuality sets the quality of r if it is requested by WithQuality.
+func (c *Client) estimateQuality(ctx context.Context, engine Engine, r *Result, translated *Translation) error {
+	if !c.quality {
+		return nil
+	}
+	if translated.Confidence == 0 {
+		// The text is translated back.
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	q, err := estimateQuality(ctx, engine, r.Source, translated)
+	if err != nil {
+		return err
+	}
+	r.Quality = &q
+	r.LowQuality = q < c.minQuality
+	return nil
+}
+
+// LookupTM returns the translation of text into targetLang reused from the
+// translation memory, or nil if there is none. If text is already written in
+// targetLang, a translation into the second language is looked up instead, as
+// Translate switches the target language in that case. Fuzzy matches are
+// reported to STDERR.
+func (c *Client) LookupTM(text, targetLang string) *TranslationUnit {
+	return c.matchTM(text, targetLang)
+}
+
+func (c *Client) matchTM(text, targetLang string) *TranslationUnit {
+	if c.tm == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u, score := c.tm.Match(text, targetLang, c.tmThreshold)
+	if u != nil && u.SourceLang == targetLang {
+		u = nil
+	}
+	if u == nil && c.secondLang != "" {
+		u, score = c.tm.Match(text, c.secondLang, c.tmThreshold)
+		if u != nil && u.SourceLang != targetLang {
+			u = nil
+		}
+	}
+	if u == nil {
+		return nil
+	}
+	if score < 1 {
+		fmt.Fprintf(os.Stderr, "gtrans: reusing fuzzy translation memory match (%.0f%%): %s\n", score*100, u.Source)
+	}
+	c.stats.tmHit()
+	return u
+}
+
+// addTM records the result in the translation memory.
+func (c *Client) addTM(r *Result) {
+	if c.tm == nil || r.LowQuality {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tm.Add(&TranslationUnit{
+		SourceLang: r.SourceLang,
+		TargetLang: r.TargetLang,
+		Source:     r.Source,
+		Target:     r.Translation,
+	})
+	c.tmDirty = true
+}
+
+// Close ends the run of the Client: it writes the statistics given by
+// WithStats, records the usage of the run given by WithUsageLog, updates the
+// cache, and saves the translation memory if new translations are added.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.statsOut != nil {
+		// The numbers of cache hits and misses are reset by closeCache.
+		if err := c.stats.write(c.statsOut, c.statsFormat, atomic.LoadInt64(&c.cacheHits), atomic.LoadInt64(&c.cacheMisses)); err != nil {
+			return err
+		}
+	}
+	if c.usageLog != "" {
+		if err := recordUsage(c.usageLog, c.stats, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "gtrans: fail to record usage: %v\n", err)
+		}
+	}
+	if err := c.closeCache(); err != nil {
+		fmt.Fprintf(os.Stderr, "gtrans: fail to update cache: %v\n", err)
+	}
+	return c.saveTM()
+}
+
+// saveTM saves the translation memory if new translations are added. c.mu
+// must be held.
+func (c *Client) saveTM() error {
+	if c.tm == nil || !c.tmDirty {
+		return nil
+	}
+	if err := c.tm.Save(); err != nil {
+		return err
+	}
+	c.tmDirty = false
+	return nil
+}
+
+// SaveTMEvery saves the translation memory every d if new translations are
+// added, until the returned function is called, so that servers and bots
+// don't rewrite the whole memory after each request. Close saves the rest.
+func (c *Client) SaveTMEvery(d time.Duration) (stop func()) {
+	t := time.NewTicker(d)
+	done := make(chan struct{})
+	go func() {
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+			c.mu.Lock()
+			err := c.saveTM()
+			c.mu.Unlock()
+			if err != nil {
+				log.Printf("fail to save translation memory: %v", err)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// logf logs to the logger given by WithLogger if any.
+func (c *Client) logf(format string, a ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, a...)
+	}
+}
@@ -0,0 +1,115 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command gtrans-wasm exposes the translation pipeline of the gtrans package to
+// JavaScript when it's built for GOOS=js GOARCH=wasm.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"syscall/js"
+
+	"github.com/minodisk/gtrans"
+)
+
+// main exposes the translation pipeline to JavaScript as the global object
+// gtrans:
+//
+//	gtrans.setCredential("deepl", key)
+//	const r = await gtrans.translate("Hello", "ja", {engine: "deepl"})
+//
+// Results have the same fields as gtrans -output-format json. HTTP requests are
+// sent with the Fetch API, which net/http uses on js/wasm.
+func main() {
+	js.Global().Set("gtrans", js.ValueOf(map[string]interface{}{
+		"setCredential": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) == 2 {
+				setCredential(args[0].String(), args[1].String())
+			}
+			return nil
+		}),
+		"translate": js.FuncOf(jsTranslate),
+	}))
+	select {}
+}
+
+var (
+	credentialsMu sync.Mutex
+	credentials   = map[string]string{}
+)
+
+// setCredential sets the API key of engine, since browsers have no
+// environment variables.
+func setCredential(engine, key string) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+	credentials[engine] = key
+}
+
+// credentialOptions returns the options giving the engines the API keys set
+// by setCredential.
+func credentialOptions() []gtrans.Option {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+	var opts []gtrans.Option
+	for engine, key := range credentials {
+		opts = append(opts, gtrans.WithCredential(engine, key))
+	}
+	return opts
+}
+
+// jsTranslate implements gtrans.translate(text, target[, options]), which
+// returns a Promise of the result.
+func jsTranslate(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return jsPromise(func() (js.Value, error) {
+			return js.Undefined(), errors.New("usage: gtrans.translate(text, target[, {engine}])")
+		})
+	}
+	text, target := args[0].String(), args[1].String()
+	name := "google"
+	if len(args) > 2 && args[2].Type() == js.TypeObject {
+		if e := args[2].Get("engine"); e.Type() == js.TypeString {
+			name = e.String()
+		}
+	}
+	return jsPromise(func() (js.Value, error) {
+		c, err := gtrans.NewClient(append(credentialOptions(), gtrans.WithEngineName(name))...)
+		if err != nil {
+			return js.Undefined(), err
+		}
+		defer c.Close()
+		r, err := c.Translate(context.Background(), text, target)
+		if err != nil {
+			return js.Undefined(), err
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			return js.Undefined(), err
+		}
+		return js.Global().Get("JSON").Call("parse", string(b)), nil
+	})
+}
+
+// jsPromise returns a Promise resolved with the result of f, which is called
+// in a goroutine as it may block on HTTP requests.
+func jsPromise(f func() (js.Value, error)) js.Value {
+	var executor js.Func
+	executor = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		resolve, reject := args[0], args[1]
+		go func() {
+			defer executor.Release()
+			v, err := f()
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			resolve.Invoke(v)
+		}()
+		return nil
+	})
+	return js.Global().Get("Promise").New(executor)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// AsciiDoc inline markup which must not be translated: monospace and
+// passthrough text, cross references, anchors, attribute references, URLs and
+// the targets of inline macros, whose link texts are translated.
+var asciidocInlineRules = []gtrans.ProtectRule{
+	{Kind: "markup", Pattern: regexp.MustCompile("`[^`\n]+`|\\+\\+\\+.+?\\+\\+\\+|\\+[^+\\s][^+\n]*\\+|pass:[a-z,]*\\[[^\\]]*\\]")},
+	{Kind: "markup", Pattern: regexp.MustCompile(`<<[^>\n]+>>|\[\[[^\]\n]+\]\]|\{[A-Za-z0-9_-]+\}`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`\b(?:https?|ftp|mailto|irc|link|xref|image|kbd|btn|menu|footnote|icon|anchor|include|stem|latexmath|asciimath):[^\s\[]*\[`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`https?://[^\s<>\[\]]+`)},
+}
+
+var (
+	adocTitleRe      = regexp.MustCompile(`^(=+|#+)(\s+)(.*?)(\s*)$`)
+	adocBlockTitleRe = regexp.MustCompile(`^\.([^.\s].*)$`)
+	adocAttrRe       = regexp.MustCompile(`^:!?[A-Za-z0-9_][A-Za-z0-9_-]*!?:(?:\s|$)`)
+	adocBlockAttrRe  = regexp.MustCompile(`^\[.*\]$`)
+	adocMacroRe      = regexp.MustCompile(`^[a-z0-9_-]+::\S*\[.*\]$`)
+	adocListRe       = regexp.MustCompile(`^(\s*(?:\*+|-|\.+|\d+\.|[a-zA-Z]\.|<\d+>)\s+(?:\[[ xX*]\]\s+)?)(.*)$`)
+	// adocTermRe matches a term of a description list, which has no
+	// punctuation ending a sentence before its delimiter.
+	adocTermRe       = regexp.MustCompile(`^(\S(?:[^.!?:;]|[.!?][^\s:;]|:[^:\s]|;[^;\s])*?)(::+|;;)(\s+|$)(.*)$`)
+	adocAdmonitionRe = regexp.MustCompile(`^((?:NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s+)(.*)$`)
+	// adocDelimiterRe matches the delimiters of blocks.
+	adocDelimiterRe = regexp.MustCompile(`^(?:-{4,}|\.{4,}|\+{4,}|/{4,}|={4,}|\*{4,}|_{4,}|--|\|===|,===|:===)$`)
+)
+
+// adocRawDelimiters are the delimiters of listing, literal, passthrough and
+// comment blocks, whose contents are kept as is.
+var adocRawDelimiters = map[byte]bool{'-': true, '.': true, '+': true, '/': true}
+
+// asciidocFormat translates titles, paragraphs, list items and table cells of
+// AsciiDoc (e.g. Antora documents), keeping attributes, block attributes,
+// block macros, listing, literal and source blocks, comments and cross
+// references as is.
+var asciidocFormat = &docFormat{
+	name:    "asciidoc",
+	exts:    []string{".adoc", ".asciidoc", ".asc"},
+	protect: asciidocInlineRules,
+	comment: func(s string) string { return "// " + s },
+	translate: func(src []byte, tr segmentTranslator) ([]byte, error) {
+		return translateParts(asciidocParts(string(src)), tr)
+	},
+}
+
+func asciidocParts(src string) []docPart {
+	var (
+		parts    []docPart
+		para     []string // lines of the current paragraph
+		prefix   string   // list marker or admonition label of the paragraph
+		rawBlock string   // delimiter of the block kept as is
+		rawPara  bool     // whether the next paragraph is kept as is, e.g. of [source]
+		inRaw    bool     // whether in a paragraph kept as is
+		table    bool     // whether in a table
+		term     bool     // whether the definition of a term follows
+		header   bool     // whether in the header after the document title
+		newline  string   // line ending of the last line of the paragraph
+	)
+	raw := func(s string) { parts = append(parts, docPart{text: s}) }
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		raw(prefix)
+		parts = append(parts, docPart{text: strings.Join(para, " "), translate: true})
+		raw(newline)
+		para, prefix = nil, ""
+	}
+	for _, line := range strings.SplitAfter(src, "\n") {
+		body := strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimSpace(body)
+		nl := line[len(body):]
+		if rawBlock != "" {
+			raw(line)
+			if trimmed == rawBlock {
+				rawBlock = ""
+			}
+			continue
+		}
+		if trimmed == "" {
+			flush()
+			inRaw, header = false, false
+			raw(line)
+			continue
+		}
+		if inRaw || header {
+			raw(line)
+			continue
+		}
+		if term {
+			// The definition of a term, which may be indented
+			term = false
+			prefix, para = body[:len(body)-len(strings.TrimLeft(body, " \t"))], []string{trimmed}
+			newline = nl
+			continue
+		}
+		switch {
+		case adocDelimiterRe.MatchString(trimmed):
+			flush()
+			raw(line)
+			switch {
+			case trimmed == "|===", trimmed == ",===", trimmed == ":===":
+				table = !table
+			case adocRawDelimiters[trimmed[0]] && trimmed != "--" || rawPara:
+				rawBlock = trimmed
+			}
+			rawPara = false
+		case strings.HasPrefix(trimmed, "//"):
+			// Line comments
+			flush()
+			raw(line)
+		case adocAttrRe.MatchString(body), adocMacroRe.MatchString(trimmed), trimmed == "+":
+			flush()
+			raw(line)
+		case adocBlockAttrRe.MatchString(trimmed):
+			flush()
+			raw(line)
+			attr := strings.TrimLeft(trimmed, "[")
+			if strings.HasPrefix(attr, "source") || strings.HasPrefix(attr, "listing") ||
+				strings.HasPrefix(attr, "literal") || strings.HasPrefix(attr, "pass") ||
+				strings.HasPrefix(attr, "stem") || strings.HasPrefix(attr, "latexmath") {
+				rawPara = true
+			}
+		case rawPara:
+			// A paragraph of a block style kept as is
+			raw(line)
+			inRaw, rawPara = true, false
+		case table && strings.Contains(body, "|"):
+			flush()
+			parts = append(parts, tableCells(line)...)
+		case len(para) == 0 && adocTitleRe.MatchString(body):
+			m := adocTitleRe.FindStringSubmatchIndex(body)
+			raw(body[:m[5]])
+			parts = append(parts, docPart{text: body[m[6]:m[7]], translate: true})
+			raw(body[m[7]:] + line[len(body):])
+			// The document title is followed by the author and
+			// revision lines.
+			header = body[m[2]:m[3]] == "="
+		case len(para) == 0 && adocBlockTitleRe.MatchString(body):
+			raw(".")
+			parts = append(parts, docPart{text: body[1:], translate: true})
+			raw(line[len(body):])
+		case len(para) == 0 && (body[0] == ' ' || body[0] == '\t') && !adocListRe.MatchString(body):
+			// Literal paragraph
+			raw(line)
+			inRaw = true
+		case adocAdmonitionRe.MatchString(body):
+			flush()
+			m := adocAdmonitionRe.FindStringSubmatch(body)
+			prefix, para, newline = m[1], []string{m[2]}, nl
+		case adocListRe.MatchString(body):
+			flush()
+			m := adocListRe.FindStringSubmatch(body)
+			prefix, para, newline = m[1], []string{m[2]}, nl
+		case adocTermRe.MatchString(body) && !strings.Contains(body, "://"):
+			// Description list
+			flush()
+			m := adocTermRe.FindStringSubmatch(body)
+			parts = append(parts, docPart{text: m[1], translate: true})
+			raw(m[2] + m[3])
+			if m[4] != "" {
+				para, newline = []string{m[4]}, nl
+			} else {
+				raw(nl)
+				term = true
+			}
+		default:
+			para, newline = append(para, trimmed), nl
+		}
+	}
+	flush()
+	return parts
+}
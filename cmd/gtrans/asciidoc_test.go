@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestAsciiDocFormat(t *testing.T) {
+	checkFormats(t, asciidocFormat, []formatTest{
+		{
+			name:     "titles and paragraphs",
+			src:      "= Document\nAuthor Name\n\n== Section\n\nA paragraph.\n",
+			segments: []string{"Document", "Section", "A paragraph."},
+		},
+		{
+			name:     "listing block",
+			src:      "Run it:\n\n----\nmake html\n----\n",
+			segments: []string{"Run it:"},
+		},
+		{
+			name:     "description list",
+			src:      "CPU:: The processor.\nMemory::\n  Where data lives.\n",
+			segments: []string{"CPU", "The processor.", "Memory", "Where data lives."},
+		},
+		{
+			name:     "double colon in prose",
+			src:      "Call it. Then write foo:: bar in the file.\n",
+			segments: []string{"Call it. Then write foo:: bar in the file."},
+		},
+		{
+			name:     "lists",
+			src:      "* First\n* Second\n",
+			segments: []string{"First", "Second"},
+		},
+		{
+			name:     "no trailing newline",
+			src:      "== Title\n\nLast line.",
+			segments: []string{"Title", "Last line."},
+		},
+		{
+			name:     "crlf",
+			src:      "Hello.\r\n\r\nWorld.\r\n",
+			segments: []string{"Hello.", "World."},
+		},
+	})
+}
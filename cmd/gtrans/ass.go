@@ -0,0 +1,81 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// Advanced SubStation markup which must not be translated: override blocks,
+// e.g. {\an8}, {\i1} and karaoke tags {\k20}, and the hard line breaks and
+// spaces.
+var assInlineRules = []gtrans.ProtectRule{
+	{Kind: "markup", Pattern: regexp.MustCompile(`\{[^}]*\}|\\[Nnh]`)},
+}
+
+var assOverrideRe = regexp.MustCompile(`\{[^}]*\}`)
+
+// assFormat translates the texts of dialogue events of Advanced SubStation
+// subtitles (.ass and .ssa), keeping the script info, the styles, the timing
+// and the other fields of events, and the override tags in the texts as is.
+var assFormat = &docFormat{
+	name:    "ass",
+	exts:    []string{".ass", ".ssa"},
+	protect: assInlineRules,
+	comment: func(s string) string { return "; " + s },
+	translate: func(src []byte, tr segmentTranslator) ([]byte, error) {
+		return translateParts(assParts(string(src)), tr)
+	},
+}
+
+func assParts(src string) []docPart {
+	var (
+		parts  []docPart
+		events bool // whether in the [Events] section
+		fields = 10 // the number of fields of events, the last one of which is the text
+	)
+	raw := func(s string) { parts = append(parts, docPart{text: s}) }
+	for _, line := range strings.SplitAfter(src, "\n") {
+		body := strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimSpace(body)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			events = strings.EqualFold(trimmed, "[Events]")
+			raw(line)
+			continue
+		}
+		if !events {
+			raw(line)
+			continue
+		}
+		if strings.HasPrefix(trimmed, "Format:") {
+			fields = len(strings.Split(trimmed, ","))
+			raw(line)
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "Dialogue:") {
+			// Comment events and others
+			raw(line)
+			continue
+		}
+		// The text is after the commas of the other fields, and may contain
+		// commas.
+		start := 0
+		for i := 0; i < fields-1; i++ {
+			j := strings.IndexByte(body[start:], ',')
+			if j < 0 {
+				start = -1
+				break
+			}
+			start += j + 1
+		}
+		if start < 0 || strings.TrimSpace(assOverrideRe.ReplaceAllString(body[start:], "")) == "" {
+			raw(line)
+			continue
+		}
+		raw(body[:start])
+		parts = append(parts, docPart{text: body[start:], translate: true})
+		raw(line[len(body):])
+	}
+	return parts
+}
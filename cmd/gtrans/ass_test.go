@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestASSFormat(t *testing.T) {
+	checkFormats(t, assFormat, []formatTest{
+		{
+			name: "events",
+			src: "[Script Info]\n" +
+				"Title: Dialogue: not an event\n" +
+				"\n" +
+				"[Events]\n" +
+				"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n" +
+				"Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,{\\an8}Hello, world!\\NBye.\n" +
+				"Comment: 0,0:00:02.00,0:00:03.00,Default,,0,0,0,,A comment\n" +
+				"Dialogue: 0,0:00:03.00,0:00:04.00,Default,,0,0,0,,{\\i1}\n",
+			segments: []string{"{\\an8}Hello, world!\\NBye."},
+		},
+		{
+			name: "ssa",
+			src: "[Events]\r\n" +
+				"Format: Marked, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\r\n" +
+				"Dialogue: Marked=0,0:00:01.00,0:00:02.00,Default,,0,0,0,,Hi.",
+			segments: []string{"Hi."},
+		},
+	})
+}
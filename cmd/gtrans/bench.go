@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/minodisk/gtrans"
+)
+
+const benchUsageMessage = "" +
+	`Usage:	gtrans bench [flags]
+	gtrans bench measures the latency and the throughput of each engine with a
+	sample corpus, or the lines of -corpus.
+`
+
+// benchCorpus is the sample corpus of 'gtrans bench', from a word to a
+// paragraph.
+var benchCorpus = []string{
+	"Hello",
+	"Thank you for your help.",
+	"The meeting has been moved to Thursday afternoon.",
+	"Please restart the application after installing the update.",
+	"Golang is an open source programming language that makes it simple to build secure, scalable systems.",
+	"If the file cannot be opened, check that you have permission to read it and that it is not used by another program.",
+	"Our team reviewed the proposal last week and agreed to start with a small pilot in two regions before rolling it out to every customer.",
+	"Machine translation has improved a lot in recent years, but names, numbers and domain-specific terms still need a careful review, especially in legal and medical documents where a single mistranslated word can change the meaning of a whole sentence.",
+}
+
+// benchResult is the measurement of an engine by 'gtrans bench'.
+type benchResult struct {
+	Engine   string `json:"engine"`
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"`
+	// P50MS and P95MS are the percentiles of the latency of a text in
+	// milliseconds.
+	P50MS int64 `json:"p50_ms"`
+	P95MS int64 `json:"p95_ms"`
+	// CharsPerSec is the throughput translating the whole corpus, in batches
+	// if the engine supports them.
+	CharsPerSec float64 `json:"chars_per_sec"`
+	Batch       bool    `json:"batch"`
+	Error       string  `json:"error,omitempty"`
+}
+
+func runBench(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	to := fs.String("to", targetLang, "target language (default: ja for the sample corpus)")
+	names := fs.String("engines", "", "comma separated engines to measure (default: all configured engines)")
+	corpus := fs.String("corpus", "", "file of texts to translate, one per line (default: a sample corpus in English)")
+	rounds := fs.Int("rounds", 3, "number of times to translate the corpus")
+	format := fs.String("format", "table", "output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), benchUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid -format %q: must be table or json", *format)
+	}
+	if *rounds < 1 {
+		return fmt.Errorf("invalid -rounds %d: must be 1 or more", *rounds)
+	}
+
+	texts := benchCorpus
+	target := *to
+	if *corpus != "" {
+		b, err := ioutil.ReadFile(*corpus)
+		if err != nil {
+			return err
+		}
+		texts = nil
+		for _, line := range strings.Split(string(b), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				texts = append(texts, line)
+			}
+		}
+		if len(texts) == 0 {
+			return fmt.Errorf("no text in %s", *corpus)
+		}
+		if !allowSecrets {
+			if err := gtrans.CheckSecrets(strings.Join(texts, "\n")); err != nil {
+				return err
+			}
+		}
+		if target == "" {
+			if target, err = detectTargetLang(); err != nil {
+				return err
+			}
+		}
+	} else if target == "" {
+		target = "ja"
+	}
+
+	var selected []gtrans.Engine
+	if *names == "" {
+		for _, name := range gtrans.EngineNames() {
+			if e, err := gtrans.NewEngine(name, flagEngineOptions()...); err == nil {
+				selected = append(selected, e)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("no engine is configured. Available engines: %s", strings.Join(gtrans.EngineNames(), ", "))
+		}
+	} else {
+		for _, name := range strings.Split(*names, ",") {
+			e, err := gtrans.NewEngine(strings.TrimSpace(name), flagEngineOptions()...)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, e)
+		}
+	}
+
+	// The corpus is sent twice a round: text by text, and as a whole.
+	guard := &usageGuard{}
+	if !assumeYes {
+		guard.confirmAt = confirmChars
+		if confirmChars > 0 {
+			guard.ask = askTerminal()
+		}
+	}
+	ctx := context.Background()
+	// Engines are measured one by one so that they don't compete for the
+	// network.
+	results := make([]*benchResult, len(selected))
+	for i, e := range selected {
+		if results[i], err = benchEngine(ctx, e, guard, texts, target, *rounds); err != nil {
+			return err
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENGINE\tREQUESTS\tERRORS\tP50\tP95\tTHROUGHPUT")
+	var fastest, busiest *benchResult
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t-\t-\terror: %s\n", r.Engine, r.Requests, r.Errors, r.Error)
+			continue
+		}
+		throughput := fmt.Sprintf("%.0f chars/s", r.CharsPerSec)
+		if r.Batch {
+			throughput += " (batch)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\t%s\n", r.Engine, r.Requests, r.Errors,
+			time.Duration(r.P50MS)*time.Millisecond, time.Duration(r.P95MS)*time.Millisecond, throughput)
+		if fastest == nil || r.P50MS < fastest.P50MS {
+			fastest = r
+		}
+		if busiest == nil || r.CharsPerSec > busiest.CharsPerSec {
+			busiest = r
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if fastest != nil {
+		fmt.Fprintf(w, "\nlowest latency for interactive use: %s (p50 %v)\n", fastest.Engine, time.Duration(fastest.P50MS)*time.Millisecond)
+		fmt.Fprintf(w, "highest throughput for batches: %s (%.0f chars/s)\n", busiest.Engine, busiest.CharsPerSec)
+	}
+	return nil
+}
+
+// benchEngine measures the latency of translating each of texts into target
+// with e, and the throughput of translating all of them, rounds times. It
+// fails only if guard doesn't allow sending texts.
+func benchEngine(ctx context.Context, e gtrans.Engine, guard *usageGuard, texts []string, target string, rounds int) (*benchResult, error) {
+	r := &benchResult{Engine: e.Name()}
+	var limits gtrans.Limits
+	if l, ok := e.(gtrans.Limiter); ok {
+		limits = l.Limits()
+	}
+	var rate *gtrans.RateLimiter
+	if limits.PerSecond > 0 {
+		rate = gtrans.NewRateLimiter(limits.PerSecond, 1)
+	}
+	chars := 0
+	for _, text := range texts {
+		chars += utf8.RuneCountInString(text)
+	}
+	b, batch := e.(gtrans.BatchTranslator)
+	r.Batch = batch && len(texts) > 1
+	var latencies []time.Duration
+	var elapsed time.Duration
+	var translated, latencyChars int
+	var lastErr error
+	// send returns the time taken by f sending texts, or false if it fails.
+	send := func(texts []string, f func() error) (time.Duration, bool, error) {
+		if err := guard.allow(e.Name(), texts); err != nil {
+			return 0, false, err
+		}
+		r.Requests++
+		err := rate.Wait(ctx)
+		var d time.Duration
+		if err == nil {
+			start := time.Now()
+			err = f()
+			d = time.Since(start)
+		}
+		if err != nil {
+			r.Errors++
+			lastErr = err
+			return 0, false, nil
+		}
+		return d, true, nil
+	}
+	for i := 0; i < rounds; i++ {
+		for _, text := range texts {
+			text := text
+			d, ok, err := send([]string{text}, func() error {
+				_, err := e.Translate(ctx, text, target)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				latencies = append(latencies, d)
+				latencyChars += utf8.RuneCountInString(text)
+			}
+		}
+		if len(latencies) == 0 {
+			// The engine fails every text, e.g. by a wrong API key.
+			r.Error = lastErr.Error()
+			return r, nil
+		}
+		if !r.Batch {
+			continue
+		}
+		var round time.Duration
+		ok := true
+		for _, k := range gtrans.PackBatches(texts, limits) {
+			group := make([]string, len(k))
+			for j, t := range k {
+				group[j] = texts[t]
+			}
+			d, sent, err := send(group, func() error {
+				_, err := b.TranslateBatch(ctx, group, target)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			round += d
+			ok = ok && sent
+		}
+		if ok {
+			elapsed += round
+			translated += chars
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.P50MS = percentile(latencies, 50).Milliseconds()
+	r.P95MS = percentile(latencies, 95).Milliseconds()
+	if !r.Batch || translated == 0 {
+		// The throughput of translating text by text.
+		r.Batch = false
+		elapsed, translated = 0, latencyChars
+		for _, d := range latencies {
+			elapsed += d
+		}
+	}
+	if elapsed > 0 {
+		r.CharsPerSec = float64(translated) / elapsed.Seconds()
+	}
+	return r, nil
+}
+
+// percentile returns the p-th percentile of sorted by the nearest rank.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	k := int(math.Ceil(float64(len(sorted))*p/100)) - 1
+	if k < 0 {
+		k = 0
+	}
+	return sorted[k]
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/minodisk/gtrans"
+)
+
+// chatBot is the part of the chat bots common to the services: a Client
+// configured by flags, which translates messages keeping their markup.
+type chatBot struct {
+	c          *client
+	targetLang string
+	// http is the client of the API of the service.
+	http *http.Client
+
+	mu sync.Mutex
+	// translated are the times messages were translated by message and
+	// target language, which are forgotten after botOnceTTL or when there
+	// are botOnceMax of them, and order are their keys from the oldest.
+	translated map[string]time.Time
+	order      []string
+}
+
+// botOnceTTL and botOnceMax are how long and how many messages a bot
+// remembers it translated, e.g. in busy Discord servers.
+const (
+	botOnceTTL = 24 * time.Hour
+	botOnceMax = 10000
+)
+
+// botTimeout is the timeout of a request to the API of a service.
+const botTimeout = 30 * time.Second
+
+// newChatBot returns a chatBot translating into -to by default.
+func newChatBot() (*chatBot, error) {
+	target := targetLang
+	if target == "" {
+		var err error
+		if target, err = detectTargetLang(); err != nil {
+			return nil, err
+		}
+	}
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	// Nobody is there to confirm large input.
+	c.usage.confirmAt = 0
+	return &chatBot{
+		c:          c,
+		targetLang: target,
+		http:       &http.Client{Transport: gtrans.SharedTransport(), Timeout: botTimeout},
+		translated: map[string]time.Time{},
+	}, nil
+}
+
+// translate translates text of a message into to, or into the default target
+// language if to is empty, protecting the markup of chat messages.
+func (b *chatBot) translate(ctx context.Context, text, to string) (string, error) {
+	if to == "" {
+		to = b.targetLang
+	}
+	res, err := b.c.TranslateProtected(ctx, text, to, chatInlineRules)
+	if err != nil {
+		return "", err
+	}
+	if err := checkQuality(res); err != nil {
+		return "", err
+	}
+	return res.Translation, nil
+}
+
+// parseBotTargets parses comma separated names, e.g. of emojis or channels,
+// each optionally followed by = and the target language. Names without one
+// have the empty language, which is the default.
+func parseBotTargets(s string) map[string]string {
+	m := map[string]string{}
+	for _, r := range strings.Split(s, ",") {
+		name, lang := r, ""
+		if i := strings.Index(r, "="); i >= 0 {
+			name, lang = r[:i], strings.TrimSpace(r[i+1:])
+		}
+		if name = strings.Trim(strings.TrimSpace(name), ":"); name != "" {
+			m[name] = lang
+		}
+	}
+	return m
+}
+
+// splitMessage splits text into messages of up to max characters, at the
+// ends of lines if possible.
+func splitMessage(text string, max int) []string {
+	var msgs []string
+	for utf8.RuneCountInString(text) > max {
+		// end is the byte offset of the max-th character.
+		end, n := 0, 0
+		for i := range text {
+			if n == max {
+				end = i
+				break
+			}
+			n++
+		}
+		cut := end
+		if i := strings.LastIndex(text[:end], "\n"); i > 0 {
+			cut = i + 1
+		}
+		msgs = append(msgs, text[:cut])
+		text = text[cut:]
+	}
+	return append(msgs, text)
+}
+
+// once returns true the first time it's called with key in botOnceTTL, so
+// that a message reacted to by many users is translated once.
+func (b *chatBot) once(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	for len(b.order) > 0 && now.Sub(b.translated[b.order[0]]) > botOnceTTL {
+		b.forgetOldest()
+	}
+	if _, ok := b.translated[key]; ok {
+		return false
+	}
+	for len(b.order) >= botOnceMax {
+		b.forgetOldest()
+	}
+	b.translated[key] = now
+	b.order = append(b.order, key)
+	return true
+}
+
+// forgetOldest forgets the message translated first. b.mu must be held.
+func (b *chatBot) forgetOldest() {
+	delete(b.translated, b.order[0])
+	b.order = b.order[1:]
+}
+
+// run calls connect until SIGTERM or SIGINT, reconnecting after errors with
+// backoff, and then closes the Client, which saves the caches. The
+// translation memory is saved every tmSaveInterval meanwhile.
+func (b *chatBot) run(name string, connect func(ctx context.Context) error) error {
+	defer b.c.SaveTMEvery(tmSaveInterval)()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sig)
+	go func() {
+		s := <-sig
+		fmt.Fprintf(os.Stderr, "gtrans: %v received. Disconnecting from %s\n", s, name)
+		cancel()
+	}()
+	backoff := time.Second
+	for ctx.Err() == nil {
+		start := time.Now()
+		err := connect(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("%s: %v. Reconnecting in %v", name, err, backoff)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+	return b.c.Close()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// usageGuard counts the characters sent to paid engines in a run, asks before
+// sending more than confirmAt of them, and stops sending more than maxChars.
+type usageGuard struct {
+	mu        sync.Mutex
+	used      charUsage // of texts sent to paid engines
+	confirmAt int       // 0 never asks
+	confirmed bool
+	maxChars  int       // 0 is unlimited
+	skipped   charUsage // of texts not sent over maxChars
+	// ask asks the user whether to continue with msg, or is nil if gtrans
+	// is not run interactively.
+	ask func(msg string) (bool, error)
+}
+
+// allow counts texts sent to the engine named name, or fails if the user
+// doesn't confirm sending them. Free engines are not counted.
+func (g *usageGuard) allow(name string, texts []string) error {
+	p, ok := pricingOf(name)
+	if ok && p.free != "" {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	used := g.used
+	for _, text := range texts {
+		used.add(text)
+	}
+	if g.maxChars > 0 && (g.skipped.Segments > 0 || used.Chars > g.maxChars) {
+		// Once the budget is reached, the rest of the run is not sent even
+		// if it fits, so that what is translated is a prefix of the input.
+		for _, text := range texts {
+			g.skipped.add(text)
+		}
+		return fmt.Errorf("-max-chars %d is reached", g.maxChars)
+	}
+	if g.confirmAt > 0 && !g.confirmed && used.Chars > g.confirmAt {
+		cost := "at an unknown cost"
+		if ok {
+			cost = "about " + formatCost(p.cost(used))
+		}
+		msg := fmt.Sprintf("about to send %d characters to %s (%s), more than -confirm-chars %d", used.Chars, name, cost, g.confirmAt)
+		if g.ask == nil {
+			return fmt.Errorf("%s. Give -yes to send them", msg)
+		}
+		yes, err := g.ask(msg)
+		if err != nil {
+			return err
+		}
+		if !yes {
+			return fmt.Errorf("canceled sending %d characters to %s", used.Chars, name)
+		}
+		g.confirmed = true
+	}
+	g.used = used
+	return nil
+}
+
+// report writes how much is not translated over -max-chars to w.
+func (g *usageGuard) report(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.skipped.Segments > 0 {
+		fmt.Fprintf(w, "gtrans: -max-chars %d is reached after %d characters. %d texts (%d characters) are not translated\n",
+			g.maxChars, g.used.Chars, g.skipped.Segments, g.skipped.Chars)
+	}
+}
+
+// askTerminal returns the ask of usageGuard asking on the terminal, which is
+// opened even if STDIN is piped, or nil if there is no terminal.
+func askTerminal() func(msg string) (bool, error) {
+	if !isTerminal(os.Stderr) {
+		return nil
+	}
+	var r io.Reader = os.Stdin
+	if !isTerminal(os.Stdin) {
+		name := "/dev/tty"
+		if runtime.GOOS == "windows" {
+			name = "CONIN$"
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return nil
+		}
+		r = f
+	}
+	br := bufio.NewReader(r)
+	return func(msg string) (bool, error) {
+		fmt.Fprintf(os.Stderr, "gtrans: %s. Continue? [y/N] ", msg)
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		answer := strings.ToLower(strings.TrimSpace(line))
+		return answer == "y" || answer == "yes", nil
+	}
+}
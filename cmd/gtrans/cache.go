@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+const cacheUsageMessage = "" +
+	`Usage:	gtrans cache path
+	gtrans cache clear
+	gtrans cache stats
+	gtrans cache export [file]
+	gtrans cache import [file]
+	gtrans cache manages the cache of engine responses (see -cache), in a
+	directory or a Redis server. stats reports the number of entries, their
+	size and the hit rate. export writes the entries to file (or STDOUT) as
+	JSON Lines, which import reads from file (or STDIN) into the cache, e.g.
+	to seed the cache of a CI machine.
+`
+
+// defaultCacheDir returns $XDG_CACHE_HOME/gtrans (or ~/.cache/gtrans).
+func defaultCacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "gtrans")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cache", "gtrans")
+}
+
+// byteSize is a flag of a number of bytes, which may be suffixed with K, M or
+// G for KiB, MiB or GiB.
+type byteSize int64
+
+func (s *byteSize) String() string {
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}} {
+		if *s != 0 && int64(*s)%u.size == 0 {
+			return strconv.FormatInt(int64(*s)/u.size, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(v string) error {
+	n := strings.TrimSuffix(strings.ToUpper(v), "B")
+	unit := int64(1)
+	switch {
+	case strings.HasSuffix(n, "K"):
+		unit = 1 << 10
+	case strings.HasSuffix(n, "M"):
+		unit = 1 << 20
+	case strings.HasSuffix(n, "G"):
+		unit = 1 << 30
+	}
+	if unit > 1 {
+		n = n[:len(n)-1]
+	}
+	i, err := strconv.ParseInt(n, 10, 64)
+	if err != nil || i < 0 {
+		return fmt.Errorf("invalid size %q", v)
+	}
+	*s = byteSize(i * unit)
+	return nil
+}
+
+func runCache(r io.Reader, w io.Writer, args []string) error {
+	if cacheLocation == "" {
+		return errors.New("cache is disabled. Please specify -cache")
+	}
+	c, err := gtrans.NewClient(gtrans.WithCache(cacheLocation), gtrans.WithCacheLimits(cacheTTL, 0))
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(args) == 1 && args[0] == "path":
+		fmt.Fprintln(w, c.CacheLocation())
+		return nil
+	case len(args) == 1 && args[0] == "clear":
+		return c.ClearCache()
+	case len(args) == 1 && args[0] == "stats":
+		s, err := c.CacheStats()
+		if err != nil {
+			return err
+		}
+		rate := 0.0
+		if s.Hits+s.Misses > 0 {
+			rate = float64(s.Hits) / float64(s.Hits+s.Misses) * 100
+		}
+		fmt.Fprintf(w, "location\t%s\n", c.CacheLocation())
+		fmt.Fprintf(w, "entries\t%d\n", s.Entries)
+		fmt.Fprintf(w, "bytes\t%d\n", s.Bytes)
+		fmt.Fprintf(w, "hits\t%d\n", s.Hits)
+		fmt.Fprintf(w, "misses\t%d\n", s.Misses)
+		fmt.Fprintf(w, "hit rate\t%.1f%%\n", rate)
+		return nil
+	case len(args) > 0 && len(args) <= 2 && args[0] == "export":
+		if len(args) == 2 && args[1] != "-" {
+			f, err := os.Create(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		n, err := c.ExportCache(w)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "gtrans: exported %d entries\n", n)
+		return nil
+	case len(args) > 0 && len(args) <= 2 && args[0] == "import":
+		if len(args) == 2 && args[1] != "-" {
+			f, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		n, err := c.ImportCache(r)
+		fmt.Fprintf(os.Stderr, "gtrans: imported %d entries\n", n)
+		return err
+	}
+	return errors.New(cacheUsageMessage)
+}
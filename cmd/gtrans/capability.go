@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minodisk/gtrans"
+)
+
+// probeTimeout is the timeout of probing an engine.
+const probeTimeout = 10 * time.Second
+
+// engineLimits are the documented limits of the APIs of the built-in engines.
+var engineLimits = map[string]string{
+	"google": "128 texts and 204,800 bytes per request",
+	"deepl":  "50 texts and 128 KiB per request",
+	"openai": "context window of the model",
+	"ollama": "context window of the model",
+	"claude": "100 KiB per chunk, ANTHROPIC_MAX_TOKENS output tokens per request",
+	"gemini": "context window of the model",
+	"papago": "5,000 characters per request",
+	"yandex": "10,000 characters per request",
+	"baidu":  "6,000 bytes per request, 1 request per second (standard edition)",
+}
+
+// capability is what an engine can do, probed by 'gtrans engines'.
+type capability struct {
+	Engine string `json:"engine"`
+	// Location is "built-in" or the path of the plugin.
+	Location string `json:"location"`
+	// Status is "ok" if the probe succeeds, "unchecked" without probing,
+	// "not configured" if the engine can't be created, e.g. without its
+	// credentials, or "error" if the probe fails, e.g. with invalid ones.
+	Status    string   `json:"status"`
+	Error     string   `json:"error,omitempty"`
+	Latency   int64    `json:"latency_ms,omitempty"`
+	Languages int      `json:"languages,omitempty"`
+	Features  []string `json:"features,omitempty"`
+	Limits    string   `json:"limits,omitempty"`
+}
+
+// features returns the optional features implemented by e.
+func features(e gtrans.Engine) []string {
+	fs := []string{"translate"}
+	if _, ok := e.(gtrans.Detector); ok {
+		fs = append(fs, "detect")
+	}
+	if _, ok := e.(gtrans.BatchTranslator); ok {
+		fs = append(fs, "batch")
+	}
+	if _, ok := e.(gtrans.StreamTranslator); ok {
+		fs = append(fs, "stream")
+	}
+	if _, ok := e.(gtrans.LanguageLister); ok {
+		fs = append(fs, "languages")
+	}
+	if _, ok := e.(gtrans.ProfanityMasker); ok {
+		fs = append(fs, "mask-profanity")
+	}
+	if c, ok := e.(gtrans.ChunkSizer); ok {
+		fs = append(fs, "chunk-size="+strconv.Itoa(c.MaxChunkSize()))
+	}
+	return fs
+}
+
+// probe checks the engine by the cheapest request it supports: listing the
+// languages, detecting or translating a word.
+func probe(ctx context.Context, e gtrans.Engine, c *capability) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	start := time.Now()
+	var err error
+	switch e := e.(type) {
+	case gtrans.LanguageLister:
+		var langs []gtrans.Language
+		if langs, err = e.Languages(ctx, "en"); err == nil {
+			c.Languages = len(langs)
+		}
+	case gtrans.Detector:
+		_, err = e.Detect(ctx, "Hello")
+	default:
+		_, err = e.Translate(ctx, "Hello", "ja")
+	}
+	c.Latency = time.Since(start).Milliseconds()
+	if err != nil {
+		c.Status, c.Error = "error", err.Error()
+		return
+	}
+	c.Status = "ok"
+}
+
+func runEngineCapabilities(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("engines", flag.ExitOnError)
+	format := fs.String("format", "table", "output format: table or json")
+	doProbe := fs.Bool("probe", true, "check credentials and list languages by calling the APIs")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), enginesUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	names, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid -format %q: must be table or json", *format)
+	}
+	plugins := gtrans.PluginEngines()
+	if len(names) == 0 {
+		names = gtrans.EngineNames()
+	}
+	for _, name := range names {
+		if _, ok := plugins[name]; !ok && !isBuiltinEngine(name) {
+			return fmt.Errorf("unknown engine %q. Available engines: %s", name, strings.Join(gtrans.EngineNames(), ", "))
+		}
+	}
+
+	ctx := context.Background()
+	caps := make([]capability, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		c := &caps[i]
+		c.Engine, c.Location, c.Limits = name, "built-in", engineLimits[name]
+		if path, ok := plugins[name]; ok && !isBuiltinEngine(name) {
+			c.Location = path
+		}
+		e, err := gtrans.NewEngine(name, flagEngineOptions()...)
+		if err != nil {
+			c.Status, c.Error = "not configured", err.Error()
+			continue
+		}
+		c.Features = features(e)
+		if !*doProbe {
+			c.Status = "unchecked"
+			continue
+		}
+		wg.Add(1)
+		go func(e gtrans.Engine) {
+			defer wg.Done()
+			probe(ctx, e, c)
+		}(e)
+	}
+	wg.Wait()
+
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(caps)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENGINE\tSTATUS\tLANGUAGES\tFEATURES\tLIMITS")
+	for _, c := range caps {
+		status := c.Status
+		if c.Status == "ok" {
+			status = fmt.Sprintf("ok (%dms)", c.Latency)
+		}
+		langs := "-"
+		if c.Languages > 0 {
+			langs = strconv.Itoa(c.Languages)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Engine, status, langs, orDash(strings.Join(c.Features, ",")), orDash(c.Limits))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	// Errors are too long for the table.
+	for _, c := range caps {
+		if c.Status == "error" {
+			fmt.Fprintf(w, "%s: %s\n", c.Engine, c.Error)
+		}
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
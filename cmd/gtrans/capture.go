@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const captureUsageMessage = "" +
+	`Usage:	gtrans capture [flags]
+	gtrans capture lets you select a region of the screen, recognizes the text in it with OCR and
+	translates it, e.g. of an error dialog. It takes the screenshot with screencapture on macOS, or
+	grim and slurp, gnome-screenshot, spectacle, maim, scrot or import of ImageMagick on Linux.
+`
+
+// screenCapturer takes a screenshot of a region selected by the user.
+type screenCapturer struct {
+	name    string
+	wayland bool // whether it works only on Wayland
+	capture func(ctx context.Context, bin, path string) error
+}
+
+// screenCapturers are the capturers tried in order on each OS.
+var screenCapturers = map[string][]screenCapturer{
+	"darwin": {
+		{name: "screencapture", capture: captureArgs("-i", "-x")},
+	},
+	"linux": {
+		{name: "grim", wayland: true, capture: captureGrim},
+		{name: "gnome-screenshot", capture: captureArgs("-a", "-f")},
+		{name: "spectacle", capture: captureArgs("-r", "-b", "-n", "-o")},
+		{name: "maim", capture: captureArgs("-s")},
+		{name: "scrot", capture: captureArgs("-s")},
+		{name: "import", capture: captureArgs()},
+	},
+}
+
+// captureArgs returns the capture running a command with args followed by the
+// path of the screenshot.
+func captureArgs(args ...string) func(ctx context.Context, bin, path string) error {
+	return func(ctx context.Context, bin, path string) error {
+		var stderr bytes.Buffer
+		if err := runCommand(ctx, bin, append(args, path), nil, ioutil.Discard, &stderr); err != nil {
+			return fmt.Errorf("fail to run %s: %v: %s", filepath.Base(bin), err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+}
+
+// captureGrim captures the region selected with slurp by grim.
+func captureGrim(ctx context.Context, bin, path string) error {
+	slurp, err := lookCommand("slurp")
+	if err != nil {
+		return errors.New("slurp is required with grim to select a region")
+	}
+	var region, stderr bytes.Buffer
+	if err := runCommand(ctx, slurp, nil, nil, &region, &stderr); err != nil {
+		return fmt.Errorf("fail to run slurp: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return captureArgs("-g", strings.TrimSpace(region.String()))(ctx, bin, path)
+}
+
+func runCapture(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	var o ocrOptions
+	o.register(fs)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), captureUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "gtrans-capture")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "capture.png")
+	ctx := context.Background()
+	if err := captureScreen(ctx, path); err != nil {
+		return err
+	}
+	return o.translateImage(ctx, w, path)
+}
+
+// captureScreen saves the screenshot of a region selected by the user to path
+// with the first capturer found.
+func captureScreen(ctx context.Context, path string) error {
+	capturers, ok := screenCapturers[runtime.GOOS]
+	if !ok {
+		return fmt.Errorf("capturing the screen is not supported on %s. Save a screenshot and run 'gtrans image <path>'", runtime.GOOS)
+	}
+	wayland := os.Getenv("WAYLAND_DISPLAY") != ""
+	var names []string
+	for _, c := range capturers {
+		names = append(names, c.name)
+		if c.wayland && !wayland {
+			continue
+		}
+		bin, err := lookCommand(c.name)
+		if err != nil {
+			continue
+		}
+		if err := c.capture(ctx, bin, path); err != nil {
+			return err
+		}
+		// The selection may be canceled by Escape.
+		if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+			return errors.New("no region is captured")
+		}
+		return nil
+	}
+	return fmt.Errorf("no screenshot tool is found. Install one of %s", strings.Join(names, ", "))
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// Markup of Slack and Discord messages which must not be translated: code,
+// mentions of users, channels and roles, links, custom emojis, timestamps,
+// emoji codes and URLs.
+var chatInlineRules = []gtrans.ProtectRule{
+	{Kind: "code", Pattern: regexp.MustCompile("(?s)```.*?```|`[^`\n]+`")},
+	{Kind: "markup", Pattern: regexp.MustCompile(`<(?:[@#!]|a?:|t:)[^<>\s]*>|<(?:https?|mailto):[^<>]*>`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`:[a-z0-9_+-]+:|https?://[^\s<>]+`)},
+}
+
+// chatFormat translates the texts of messages of Slack exports (the files of
+// the days of channels) and of Discord exports of DiscordChatExporter, keeping
+// the other fields, e.g. users, timestamps, threads and reactions, as they are
+// written.
+var chatFormat = &docFormat{
+	name:      "chat",
+	exts:      []string{".json"},
+	protect:   chatInlineRules,
+	sniff:     func(src []byte) bool { return chatFields(src) != nil },
+	translate: translateChat,
+}
+
+var (
+	// slackFields are the paths of the fields of Slack messages to translate.
+	slackFields = [][]string{
+		{"*", "text"},
+		{"*", "attachments", "*", "pretext"},
+		{"*", "attachments", "*", "title"},
+		{"*", "attachments", "*", "text"},
+	}
+	// discordFields are the paths of the fields of Discord messages to
+	// translate.
+	discordFields = [][]string{
+		{"messages", "*", "content"},
+		{"messages", "*", "embeds", "*", "title"},
+		{"messages", "*", "embeds", "*", "description"},
+	}
+)
+
+// chatFields returns the paths of the fields to translate of the export src,
+// or nil if src isn't an export of Slack or Discord.
+func chatFields(src []byte) [][]string {
+	var slack []struct {
+		Type string `json:"type"`
+		TS   string `json:"ts"`
+	}
+	if json.Unmarshal(src, &slack) == nil {
+		if len(slack) > 0 && slack[0].Type == "message" && slack[0].TS != "" {
+			return slackFields
+		}
+		return nil
+	}
+	var discord struct {
+		Channel  *json.RawMessage  `json:"channel"`
+		Messages []json.RawMessage `json:"messages"`
+	}
+	if json.Unmarshal(src, &discord) == nil && discord.Channel != nil && discord.Messages != nil {
+		return discordFields
+	}
+	return nil
+}
+
+func translateChat(src []byte, tr segmentTranslator) ([]byte, error) {
+	fields := chatFields(src)
+	if fields == nil {
+		return nil, errors.New("not an export of Slack or Discord")
+	}
+	parts, err := jsonFieldParts(src, fields)
+	if err != nil {
+		return nil, err
+	}
+	return translateParts(parts, tr)
+}
+
+// jsonFieldParts splits src into the string values at the paths to translate
+// and the rest of the JSON kept as it's written. "*" in the paths matches any
+// element of an array.
+func jsonFieldParts(src []byte, fields [][]string) ([]docPart, error) {
+	type frame struct {
+		array   bool
+		wantKey bool
+		key     string
+	}
+	var (
+		parts []docPart
+		stack []frame
+		last  int // the end of the last part
+	)
+	path := func() []string {
+		p := make([]string, len(stack))
+		for i, f := range stack {
+			p[i] = f.key
+			if f.array {
+				p[i] = "*"
+			}
+		}
+		return p
+	}
+	done := func() {
+		if n := len(stack); n > 0 && !stack[n-1].array {
+			stack[n-1].wantKey = true
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(src))
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				stack = append(stack, frame{array: t == '[', wantKey: t == '{'})
+			default:
+				stack = stack[:len(stack)-1]
+				done()
+			}
+		case string:
+			if n := len(stack); n > 0 && stack[n-1].wantKey {
+				stack[n-1].key, stack[n-1].wantKey = t, false
+				continue
+			}
+			if t != "" && matchJSONField(path(), fields) {
+				// The token starts after the separators.
+				start += bytes.IndexByte(src[start:], '"')
+				parts = append(parts,
+					docPart{text: string(src[last:start])},
+					docPart{text: t, translate: true, quote: jsonString})
+				last = int(dec.InputOffset())
+			}
+			done()
+		default:
+			done()
+		}
+	}
+	return append(parts, docPart{text: string(src[last:])}), nil
+}
+
+func matchJSONField(path []string, fields [][]string) bool {
+Fields:
+	for _, f := range fields {
+		if len(f) != len(path) {
+			continue
+		}
+		for i := range f {
+			if f[i] != path[i] && f[i] != "*" {
+				continue Fields
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// jsonString returns s as a JSON string, without escaping HTML characters.
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
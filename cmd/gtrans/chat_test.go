@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestChatFormat(t *testing.T) {
+	checkFormats(t, chatFormat, []formatTest{
+		{
+			name: "slack",
+			src: `[
+    {
+        "type": "message",
+        "user": "U1",
+        "text": "Hello <@U2>, see <https://example.com|this>",
+        "ts": "1700000000.000100",
+        "attachments": [{"title": "A title", "text": ""}]
+    }
+]
+`,
+			segments: []string{"Hello <@U2>, see <https://example.com|this>", "A title"},
+		},
+		{
+			name:     "discord",
+			src:      `{"channel": {"name": "general"}, "messages": [{"author": {"name": "a"}, "content": "Good <b>morning</b>", "embeds": [{"description": "An embed"}]}]}`,
+			segments: []string{"Good <b>morning</b>", "An embed"},
+		},
+		{
+			name:     "escaped",
+			src:      `[{"type": "message", "ts": "1", "text": "Line\nbreak \"quoted\" é"}]`,
+			segments: []string{"Line\nbreak \"quoted\" é"},
+		},
+	})
+}
+
+func TestChatFields(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{"slack", `[{"type": "message", "ts": "1"}]`, true},
+		{"discord", `{"channel": {}, "messages": []}`, true},
+		{"other array", `[{"type": "other"}]`, false},
+		{"other object", `{"name": "package"}`, false},
+	}
+	for _, tt := range tests {
+		if got := chatFields([]byte(tt.src)) != nil; got != tt.want {
+			t.Errorf("%s: chatFields(%s) = %v, want %v", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/minodisk/gtrans"
+)
+
+// tmSaveInterval is how often long running commands save the translation
+// memory.
+const tmSaveInterval = 30 * time.Second
+
+// client is a gtrans.Client configured by the flags, which also reports the
+// translated files and guards the usage of paid engines.
+type client struct {
+	*gtrans.Client
+	report *report
+	usage  *usageGuard
+}
+
+// newClient returns a client configured by the flags and the config, with
+// opts applied last.
+func newClient(opts ...gtrans.Option) (*client, error) {
+	if onLowQuality != "warn" && onLowQuality != "fail" {
+		return nil, fmt.Errorf("invalid -on-low-quality %q: must be warn or fail", onLowQuality)
+	}
+	if onSameLang != "skip" && onSameLang != "notice" && onSameLang != "translate" {
+		return nil, fmt.Errorf("invalid -on-same-lang %q: must be skip, notice or translate", onSameLang)
+	}
+	if onOfflineMiss != "fail" && onOfflineMiss != "pass" {
+		return nil, fmt.Errorf("invalid -on-offline-miss %q: must be fail or pass", onOfflineMiss)
+	}
+	rp, err := newReport()
+	if err != nil {
+		return nil, err
+	}
+	if statsFormat != "" && statsFormat != "text" && statsFormat != "json" {
+		return nil, fmt.Errorf("invalid -stats %q: must be text or json", statsFormat)
+	}
+	c := &client{report: rp, usage: &usageGuard{maxChars: maxChars}}
+	if !assumeYes {
+		c.usage.confirmAt = confirmChars
+		if confirmChars > 0 {
+			c.usage.ask = askTerminal()
+		}
+	}
+	all := append([]gtrans.Option{gtrans.WithEngineName(engineName)}, flagEngineOptions()...)
+	if offline {
+		all = append(all, gtrans.WithOffline(onOfflineMiss == "pass"))
+	}
+	if cacheLocation != "" {
+		all = append(all, gtrans.WithCache(cacheLocation), gtrans.WithCacheLimits(cacheTTL, int64(cacheMaxSize)))
+	}
+	if circuitErrors > 0 {
+		all = append(all, gtrans.WithCircuitBreaker(circuitErrors, circuitOpen))
+	}
+	if preHook != "" {
+		all = append(all, gtrans.WithPreHook(preHook))
+	}
+	if postHook != "" {
+		all = append(all, gtrans.WithPostHook(postHook))
+	}
+	if postFileHook != "" {
+		all = append(all, gtrans.WithPostFileHook(postFileHook))
+	}
+	if len(userConfig.Routes) > 0 && !isFlagSet("engine") {
+		// -engine overrides the routes.
+		all = append(all, gtrans.WithRoutes(userConfig.Routes))
+	}
+	all = append(all,
+		gtrans.WithSecondLang(os.Getenv("GOOGLE_TRANSLATE_SECOND_LANG")),
+		gtrans.WithOnSameLang(onSameLang),
+		gtrans.WithQuota(c.usage.allow),
+	)
+	if localDetect {
+		all = append(all, gtrans.WithLocalDetect())
+	}
+	if allowSecrets {
+		all = append(all, gtrans.WithAllowSecrets())
+	}
+	if withQuality || minQuality > 0 {
+		all = append(all, gtrans.WithQuality(minQuality))
+	}
+	if maskProfanity {
+		all = append(all, gtrans.WithProfanityMask(profanityList))
+	}
+	if preserveCase {
+		all = append(all, gtrans.WithPreserveCase())
+	}
+	if statsFormat != "" {
+		all = append(all, gtrans.WithStats(os.Stderr, statsFormat))
+	}
+	if usageLog {
+		all = append(all, gtrans.WithUsageLog(usagePath()))
+	}
+	if tmPath != "" {
+		tm, err := gtrans.LoadTranslationMemory(tmPath)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, gtrans.WithTranslationMemory(tm, tmThreshold))
+	}
+	// Patterns of the config are added first, so that they win over the
+	// others.
+	for _, p := range userConfig.Protect {
+		all = append(all, gtrans.WithProtect(p.Kind, p.Pattern))
+	}
+	if redact != "" {
+		all = append(all, gtrans.WithRedaction(redact))
+	}
+	if glossaryPath != "" {
+		entries, err := gtrans.LoadGlossary(glossaryPath)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, gtrans.WithGlossary(entries))
+	}
+	gc, err := gtrans.NewClient(append(all, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	c.Client = gc
+	return c, nil
+}
+
+// Close closes the gtrans.Client and reports the usage of paid engines.
+func (c *client) Close() error {
+	err := c.Client.Close()
+	c.usage.report(os.Stderr)
+	return err
+}
+
+// checkQuality returns an error for a low quality result if -on-low-quality
+// is fail, or warns about it on STDERR.
+func checkQuality(r *gtrans.Result) error {
+	if !r.LowQuality {
+		return nil
+	}
+	msg := fmt.Sprintf("low quality translation (%.2f < %.2f)", *r.Quality, minQuality)
+	if onLowQuality == "fail" {
+		return errors.New(msg)
+	}
+	fmt.Fprintln(os.Stderr, "gtrans: "+msg)
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ANSI escape sequences. Attributes are turned off individually so that
+// highlighted terms don't reset the style of the surrounding text.
+const (
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiNormal    = "\x1b[22m"
+	ansiYellow    = "\x1b[33m"
+	ansiCyan      = "\x1b[36m"
+	ansiDefaultFg = "\x1b[39m"
+)
+
+// colorizer styles output written to a terminal. The zero value writes plain
+// text.
+type colorizer struct {
+	enabled bool
+}
+
+// newColorizer returns a colorizer for w according to mode, which is one of
+// "auto", "always" and "never". In auto mode, colors are enabled only if w is
+// a terminal and $NO_COLOR is not set.
+func newColorizer(mode string, w io.Writer) (*colorizer, error) {
+	switch mode {
+	case "always":
+		return &colorizer{enabled: true}, nil
+	case "never":
+		return &colorizer{}, nil
+	case "auto", "":
+		return &colorizer{enabled: os.Getenv("NO_COLOR") == "" && isTerminal(w)}, nil
+	}
+	return nil, fmt.Errorf("invalid -color %q: must be auto, always or never", mode)
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *colorizer) style(attr, off, s string) string {
+	if !c.enabled || s == "" {
+		return s
+	}
+	return attr + s + off
+}
+
+// translation styles translated text.
+func (c *colorizer) translation(s string) string {
+	return c.style(ansiBold, ansiNormal, s)
+}
+
+// original styles the input text shown along with its translation.
+func (c *colorizer) original(s string) string {
+	return c.style(ansiDim, ansiNormal, s)
+}
+
+// protected highlights a value restored from a placeholder.
+func (c *colorizer) protected(kind, value string) string {
+	if kind == "glossary" {
+		return c.style(ansiYellow, ansiDefaultFg, value)
+	}
+	return c.style(ansiCyan, ansiDefaultFg, value)
+}
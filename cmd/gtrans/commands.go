@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// command is a subcommand of gtrans.
+type command struct {
+	name  string
+	usage string // arguments shown in the usage
+	run   func(args []string) error
+}
+
+// commands are the subcommands in the order shown in the usage. Bare
+// arguments which are not a command name are translated as text.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"translate", "[flags] [input text]", runTranslate},
+		{"detect", "[input text]", func(args []string) error { return runDetect(os.Stdin, os.Stdout, args) }},
+		{"file", "[flags] <path>", func(args []string) error { return runFileCommand(&filePath, args) }},
+		{"dir", "[flags] <path>", func(args []string) error { return runFileCommand(&dirPath, args) }},
+		{"image", "[flags] <path>", func(args []string) error { return runImage(os.Stdout, args) }},
+		{"capture", "[flags]", func(args []string) error { return runCapture(os.Stdout, args) }},
+		{"url", "[flags] <url>", func(args []string) error { return runURL(os.Stdout, args) }},
+		{"site", "[flags] <sitemap URL>", runSite},
+		{"man", "[flags] [section] <name>", func(args []string) error { return runMan(os.Stdout, args) }},
+		{"help-of", "[flags] -- <command> [args]", func(args []string) error { return runHelpOf(os.Stdout, args) }},
+		{"compare", "[flags] [input text]", func(args []string) error { return runCompare(os.Stdin, os.Stdout, args) }},
+		{"cost", "[flags] [input text]", func(args []string) error { return runCost(os.Stdin, os.Stdout, args) }},
+		{"bench", "[flags]", func(args []string) error { return runBench(os.Stdout, args) }},
+		{"stats", "[-since 30d] [-format table|json]", func(args []string) error { return runStatsCommand(os.Stdout, args) }},
+		{"resume", "[job-id]", func(args []string) error { return runResume(os.Stdout, args) }},
+		{"undo", "[flags] [run-id|list]", func(args []string) error { return runUndo(os.Stdout, args) }},
+		{"engines", "[engine...]|list", func(args []string) error { return runEngines(os.Stdout, args) }},
+		{"serve", "[flags]", runServe},
+		{"slack-bot", "[flags]", runSlackBot},
+		{"discord-bot", "[flags]", runDiscordBot},
+		{"telegram-bot", "[flags]", runTelegramBot},
+		{"languages", "[flags]", func(args []string) error { return runLanguages(os.Stdout, args) }},
+		{"cache", "path|clear|stats|export|import", func(args []string) error { return runCache(os.Stdin, os.Stdout, args) }},
+		{"auth", "[-delete] [engine]", func(args []string) error { return runAuth(os.Stdin, os.Stderr, args) }},
+		{"config", "list|get|set|unset|path|route", func(args []string) error { return runConfig(os.Stdout, args) }},
+	}
+}
+
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// commandUsage returns the usage lines of the commands.
+func commandUsage() string {
+	var b strings.Builder
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\tgtrans %s %s\n", cmd.name, cmd.usage)
+	}
+	return b.String()
+}
+
+// runTranslate translates the input text, or runs the batch mode selected by
+// flags, which may be given after the text.
+func runTranslate(args []string) error {
+	args, err := parseInterspersed(flag.CommandLine, args)
+	if err != nil {
+		return err
+	}
+	return translateArgs(args)
+}
+
+// translateArgs translates args, or runs the mode selected by flags.
+func translateArgs(args []string) error {
+	if tmImport != "" || tmExport != "" {
+		return transferTM(os.Stderr, tmPath, tmImport, tmExport)
+	}
+	return Main(os.Stdin, os.Stdout, args, targetLang, doOpenBrowser)
+}
+
+// runFileCommand sets path to the only argument and translates it, which is
+// a file or a directory according to path.
+func runFileCommand(path *string, args []string) error {
+	args, err := parseInterspersed(flag.CommandLine, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("a path is required")
+	}
+	*path = args[0]
+	return Main(os.Stdin, os.Stdout, nil, targetLang, false)
+}
+
+// runDetect writes the language of the input text detected by the engine.
+func runDetect(r io.Reader, w io.Writer, args []string) error {
+	args, err := parseInterspersed(flag.CommandLine, args)
+	if err != nil {
+		return err
+	}
+	text, err := readInput(r, args)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	lang, err := c.Detect(context.Background(), text)
+	if err != nil {
+		return err
+	}
+	if outputFormat == "json" {
+		return json.NewEncoder(w).Encode(map[string]string{"source": text, "source_lang": lang})
+	}
+	_, err = fmt.Fprintln(w, lang)
+	return err
+}
+
+// runLanguages writes the languages supported by the engine with their names
+// in the target language.
+func runLanguages(w io.Writer, args []string) error {
+	if _, err := parseInterspersed(flag.CommandLine, args); err != nil {
+		return err
+	}
+	display := targetLang
+	if display == "" {
+		var err error
+		if display, err = detectTargetLang(); err != nil {
+			return err
+		}
+	}
+	engine, err := gtrans.NewEngine(engineName, flagEngineOptions()...)
+	if err != nil {
+		return err
+	}
+	l, ok := engine.(gtrans.LanguageLister)
+	if !ok {
+		return fmt.Errorf("engine %s doesn't support listing languages", engine.Name())
+	}
+	langs, err := l.Languages(context.Background(), display)
+	if err != nil {
+		return err
+	}
+	if outputFormat == "json" {
+		return json.NewEncoder(w).Encode(langs)
+	}
+	for _, lang := range langs {
+		fmt.Fprintf(w, "%s\t%s\n", lang.Code, lang.Name)
+	}
+	return nil
+}
+
+// runAuth stores the API key of an engine read from r in the config, or
+// deletes it with -delete. Without an engine, it lists where the key of each
+// engine comes from.
+func runAuth(r io.Reader, w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("auth", flag.ExitOnError)
+	del := fs.Bool("delete", false, "delete the API key of the engine from the config")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	cfg := userConfig
+	if len(args) == 0 {
+		for _, name := range sortedKeys(gtrans.EngineKeyEnvs) {
+			env := gtrans.EngineKeyEnvs[name]
+			switch {
+			case os.Getenv(env) != "":
+				fmt.Fprintf(w, "%s\t$%s\n", name, env)
+			case cfg.Keys[name] != "":
+				fmt.Fprintf(w, "%s\t%s\n", name, cfg.path)
+			default:
+				fmt.Fprintf(w, "%s\tnot configured\n", name)
+			}
+		}
+		return nil
+	}
+	name := args[0]
+	if _, ok := gtrans.EngineKeyEnvs[name]; !ok || len(args) > 1 {
+		return fmt.Errorf("usage: gtrans auth [-delete] <engine>, where engine is one of %s", strings.Join(sortedKeys(gtrans.EngineKeyEnvs), ", "))
+	}
+	if *del {
+		delete(cfg.Keys, name)
+		return cfg.Save()
+	}
+	fmt.Fprintf(w, "API key of %s: ", name)
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	key := strings.TrimSpace(line)
+	if key == "" {
+		return errors.New("API key is empty")
+	}
+	if cfg.Keys == nil {
+		cfg.Keys = map[string]string{}
+	}
+	cfg.Keys[name] = key
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "saved to %s\n", cfg.path)
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
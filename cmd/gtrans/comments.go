@@ -0,0 +1,115 @@
+package main
+
+import "strings"
+
+// commentSyntax is the comment syntax of a programming language.
+type commentSyntax struct {
+	line  []string  // line comment markers
+	block [2]string // opening and closing markers of block comments
+}
+
+var (
+	cComments    = &commentSyntax{line: []string{"//"}, block: [2]string{"/*", "*/"}}
+	hashComments = &commentSyntax{line: []string{"#"}}
+	dashComments = &commentSyntax{line: []string{"--"}}
+	lispComments = &commentSyntax{line: []string{";"}}
+	xmlComments  = &commentSyntax{block: [2]string{"<!--", "-->"}}
+)
+
+// fenceCommentSyntaxes are the comment syntaxes by the languages of fenced
+// code blocks.
+var fenceCommentSyntaxes = map[string]*commentSyntax{
+	"go": cComments, "c": cComments, "cpp": cComments, "c++": cComments,
+	"java": cComments, "javascript": cComments, "js": cComments, "jsx": cComments,
+	"typescript": cComments, "ts": cComments, "tsx": cComments, "rust": cComments,
+	"rs": cComments, "swift": cComments, "kotlin": cComments, "kt": cComments,
+	"scala": cComments, "csharp": cComments, "cs": cComments, "php": cComments,
+	"dart": cComments, "groovy": cComments, "css": {block: [2]string{"/*", "*/"}},
+	"python": hashComments, "py": hashComments, "ruby": hashComments, "rb": hashComments,
+	"sh": hashComments, "bash": hashComments, "shell": hashComments, "zsh": hashComments,
+	"perl": hashComments, "r": hashComments, "yaml": hashComments, "yml": hashComments,
+	"toml": hashComments, "dockerfile": hashComments, "makefile": hashComments,
+	"make": hashComments, "powershell": hashComments, "ps1": hashComments,
+	"elixir": hashComments, "nix": hashComments,
+	"sql": dashComments, "lua": dashComments, "haskell": dashComments, "hs": dashComments,
+	"lisp": lispComments, "clojure": lispComments, "scheme": lispComments,
+	"elisp": lispComments, "emacs-lisp": lispComments, "ini": lispComments,
+	"tex": {line: []string{"%"}}, "latex": {line: []string{"%"}}, "erlang": {line: []string{"%"}},
+	"html": xmlComments, "xml": xmlComments, "svg": xmlComments,
+}
+
+// fenceLanguage returns the language in the info string of the opening fence
+// of a code block, e.g. "go" of "```go title=main.go".
+func fenceLanguage(line, fence string) string {
+	info := strings.TrimSpace(line)[len(fence):]
+	info = strings.Trim(strings.TrimSpace(info), "{}.")
+	if i := strings.IndexAny(info, " \t,{"); i >= 0 {
+		info = info[:i]
+	}
+	return strings.ToLower(info)
+}
+
+// commentSplitter splits lines of code into the comments to translate and the
+// code kept as is. Only comments occupying whole lines are translated, not
+// ones following code, which can't be told from strings without parsing the
+// code.
+type commentSplitter struct {
+	syntax  *commentSyntax
+	inBlock bool
+}
+
+// parts returns the parts of a line of code.
+func (c *commentSplitter) parts(line string) []docPart {
+	body := strings.TrimRight(line, "\r\n")
+	trimmed := strings.TrimLeft(body, " \t")
+	indent := body[:len(body)-len(trimmed)]
+	comment := func(marker, text, end string) []docPart {
+		return []docPart{
+			{text: indent + marker},
+			{text: text, translate: true},
+			{text: end + line[len(body):]},
+		}
+	}
+	open, close := c.syntax.block[0], c.syntax.block[1]
+	if c.inBlock {
+		end := ""
+		if i := strings.Index(trimmed, close); i >= 0 {
+			c.inBlock = false
+			trimmed, end = trimmed[:i], trimmed[i:]
+		}
+		// Leading asterisks of block comments in C style
+		marker := ""
+		if strings.HasPrefix(trimmed, "*") && open == "/*" {
+			marker, trimmed = "*", trimmed[1:]
+		}
+		if end != "" && strings.TrimSpace(end[len(close):]) != "" {
+			// Code follows the comment.
+			return []docPart{{text: line}}
+		}
+		return comment(marker, trimmed, end)
+	}
+	if open != "" && strings.HasPrefix(trimmed, open) {
+		rest := trimmed[len(open):]
+		end := ""
+		if i := strings.Index(rest, close); i >= 0 {
+			if strings.TrimSpace(rest[i+len(close):]) != "" {
+				return []docPart{{text: line}}
+			}
+			rest, end = rest[:i], rest[i:]
+		} else {
+			c.inBlock = true
+		}
+		return comment(open, rest, end)
+	}
+	for _, marker := range c.syntax.line {
+		if strings.HasPrefix(trimmed, marker) && !strings.HasPrefix(trimmed, "#!") {
+			// Repeated markers such as "///" and "##"
+			n := len(marker)
+			for n < len(trimmed) && trimmed[n] == marker[len(marker)-1] {
+				n++
+			}
+			return comment(trimmed[:n], trimmed[n:], "")
+		}
+	}
+	return []docPart{{text: line}}
+}
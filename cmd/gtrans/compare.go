@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/minodisk/gtrans"
+)
+
+const compareUsageMessage = "" +
+	`Usage:	gtrans compare [flags] [input text]
+	gtrans compare translates input text with each engine and prints the results side by side.
+`
+
+// comparison is a translation by an engine in compare mode.
+type comparison struct {
+	Engine      string `json:"engine"`
+	Translation string `json:"translation,omitempty"`
+	SourceLang  string `json:"source_lang,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+func runCompare(r io.Reader, w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	to := fs.String("to", targetLang, "target language")
+	names := fs.String("engines", "", "comma separated engines to compare (default: all configured engines)")
+	format := fs.String("format", "table", "output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), compareUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid -format %q: must be table or json", *format)
+	}
+
+	target := *to
+	if target == "" {
+		if target, err = detectTargetLang(); err != nil {
+			return err
+		}
+	}
+	text, err := readInput(r, args)
+	if err != nil {
+		return err
+	}
+	if !allowSecrets {
+		if err := gtrans.CheckSecrets(text); err != nil {
+			return err
+		}
+	}
+
+	var selected []gtrans.Engine
+	if *names == "" {
+		// Compare engines whose credentials are set.
+		for _, name := range gtrans.EngineNames() {
+			if e, err := gtrans.NewEngine(name, flagEngineOptions()...); err == nil {
+				selected = append(selected, e)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("no engine is configured. Available engines: %s", strings.Join(gtrans.EngineNames(), ", "))
+		}
+	} else {
+		for _, name := range strings.Split(*names, ",") {
+			e, err := gtrans.NewEngine(strings.TrimSpace(name), flagEngineOptions()...)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, e)
+		}
+	}
+
+	ctx := context.Background()
+	results := make([]comparison, len(selected))
+	var wg sync.WaitGroup
+	for i, e := range selected {
+		wg.Add(1)
+		go func(i int, e gtrans.Engine) {
+			defer wg.Done()
+			results[i].Engine = e.Name()
+			t, err := e.Translate(ctx, text, target)
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Translation = t.Text
+			results[i].SourceLang = t.SourceLang
+		}(i, e)
+	}
+	wg.Wait()
+
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENGINE\tTRANSLATION")
+	for _, c := range results {
+		translation := c.Translation
+		if c.Error != "" {
+			translation = "error: " + c.Error
+		}
+		// Continuation lines of multi-line translations are indented under
+		// the translation column.
+		for i, line := range strings.Split(strings.TrimRight(translation, "\n"), "\n") {
+			name := c.Engine
+			if i > 0 {
+				name = ""
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", name, line)
+		}
+	}
+	return tw.Flush()
+}
+
+// parseInterspersed parses flags in args, allowing them to appear after
+// positional arguments as in `gtrans compare "text" -engines google,deepl`.
+// It returns the positional arguments. Arguments after "--" are never treated
+// as flags.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
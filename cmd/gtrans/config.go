@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/minodisk/gtrans"
+)
+
+const configUsageMessage = "" +
+	`Usage:	gtrans config list
+	gtrans config get <flag>
+	gtrans config set <flag> <value>
+	gtrans config unset <flag>
+	gtrans config path
+	gtrans config route list
+	gtrans config route set <rule> <engine>
+	gtrans config route unset <rule>
+	gtrans config manages default values of flags (e.g. to, engine) in the config file.
+	Flags given on the command line take precedence over them.
+	Routes select the engine by the language pair of each translation, e.g.
+	'ja<->ko' papago, '*->de' deepl or default google. The most specific
+	route wins, and -engine overrides them.
+`
+
+// config is the user configuration stored at configPath as JSON.
+type config struct {
+	// Flags are default values of flags by name.
+	Flags map[string]string `json:"flags,omitempty"`
+	// Keys are API keys of engines by engine name, which are used if the
+	// environment variable of the engine is not set.
+	Keys map[string]string `json:"keys,omitempty"`
+	// Routes are engine names by language pair rules, e.g. "ja<->ko",
+	// "*->de" or "default", which select engines unless -engine is given.
+	Routes map[string]string `json:"routes,omitempty"`
+	// Prices are USD per million characters by engine name, which override
+	// the list prices in cost estimates.
+	Prices map[string]float64 `json:"prices,omitempty"`
+	// Headers are extra headers of requests to the APIs of engines by name,
+	// which -header replaces.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Protect are patterns of domain-specific tokens kept as they are in
+	// translations, e.g. ticket IDs. Earlier patterns win when matches overlap.
+	Protect []protectPattern `json:"protect,omitempty"`
+
+	path string
+}
+
+// protectPattern is a regular expression of tokens to protect.
+type protectPattern struct {
+	Pattern string `json:"pattern"`
+	// Kind is the class of the placeholders, e.g. "code", which is "custom"
+	// if empty.
+	Kind string `json:"kind,omitempty"`
+}
+
+// userConfig is the config loaded on startup.
+var userConfig = &config{}
+
+// configPath returns $XDG_CONFIG_HOME/gtrans/config.json (or
+// ~/.config/gtrans/config.json).
+func configPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gtrans", "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gtrans", "config.json")
+}
+
+// loadConfig loads the config at path. A missing file is treated as an empty
+// config.
+func loadConfig(path string) (*config, error) {
+	cfg := &config{path: path}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) || path == "" {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("fail to read config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// applyFlags sets the flags of fs to the default values in the config. It's
+// called before parsing the command line, so that flags given there take
+// precedence.
+func (cfg *config) applyFlags(fs *flag.FlagSet) error {
+	for name, value := range cfg.Flags {
+		if fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s in config %s: %v", name, cfg.path, err)
+		}
+	}
+	return nil
+}
+
+// isFlagSet reports whether the flag named name is given on the command line
+// or in the config.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// Save writes the config back to its file. API keys are stored in it, so
+// it's readable only by the user.
+func (cfg *config) Save() error {
+	if cfg.path == "" {
+		return errors.New("cannot locate the config file. Please export $HOME or $XDG_CONFIG_HOME")
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.path), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(cfg.path), ".config.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), cfg.path)
+}
+
+// credential returns the API key of engine set by the environment variable
+// env, or from the config if it's not set.
+func credential(engine, env string) string {
+	if key := os.Getenv(env); key != "" {
+		return key
+	}
+	return userConfig.Keys[engine]
+}
+
+func runConfig(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New(configUsageMessage)
+	}
+	cfg := userConfig
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "list" && len(args) == 0:
+		names := make([]string, 0, len(cfg.Flags))
+		for name := range cfg.Flags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s=%s\n", name, cfg.Flags[name])
+		}
+		return nil
+	case cmd == "get" && len(args) == 1:
+		if v, ok := cfg.Flags[args[0]]; ok {
+			fmt.Fprintln(w, v)
+		}
+		return nil
+	case cmd == "set" && len(args) == 2:
+		f := flag.Lookup(args[0])
+		if f == nil {
+			return fmt.Errorf("unknown flag %q", args[0])
+		}
+		if err := f.Value.Set(args[1]); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %v", args[1], f.Name, err)
+		}
+		if cfg.Flags == nil {
+			cfg.Flags = map[string]string{}
+		}
+		cfg.Flags[args[0]] = args[1]
+		return cfg.Save()
+	case cmd == "unset" && len(args) == 1:
+		delete(cfg.Flags, args[0])
+		return cfg.Save()
+	case cmd == "path" && len(args) == 0:
+		fmt.Fprintln(w, cfg.path)
+		return nil
+	case cmd == "route" && len(args) > 0:
+		return runConfigRoute(w, cfg, args)
+	}
+	return errors.New(configUsageMessage)
+}
+
+func runConfigRoute(w io.Writer, cfg *config, args []string) error {
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "list" && len(args) == 0:
+		rules := make([]string, 0, len(cfg.Routes))
+		for rule := range cfg.Routes {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+		for _, rule := range rules {
+			fmt.Fprintf(w, "%s\t%s\n", rule, cfg.Routes[rule])
+		}
+		return nil
+	case cmd == "set" && len(args) == 2:
+		if _, err := gtrans.NewClient(gtrans.WithRoutes(map[string]string{args[0]: args[1]})); err != nil {
+			return err
+		}
+		if cfg.Routes == nil {
+			cfg.Routes = map[string]string{}
+		}
+		cfg.Routes[args[0]] = args[1]
+		return cfg.Save()
+	case cmd == "unset" && len(args) == 1:
+		delete(cfg.Routes, args[0])
+		return cfg.Save()
+	}
+	return errors.New(configUsageMessage)
+}
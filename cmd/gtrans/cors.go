@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsPolicy allows browsers on origins to call the server, e.g. an internal
+// web app, by Cross-Origin Resource Sharing.
+type corsPolicy struct {
+	origins []string // "*" allows any origin
+	methods string
+	headers string
+}
+
+// newCORSPolicy returns the policy of comma separated origins, methods and
+// headers, or nil if no origin is allowed.
+func newCORSPolicy(origins, methods, headers string) *corsPolicy {
+	p := &corsPolicy{methods: methods, headers: headers}
+	for _, o := range strings.Split(origins, ",") {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			p.origins = append(p.origins, o)
+		}
+	}
+	if len(p.origins) == 0 {
+		return nil
+	}
+	return p
+}
+
+func (p *corsPolicy) allows(origin string) bool {
+	for _, o := range p.origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap returns the handler of h answering preflight requests and adding the
+// headers allowing the origin to responses.
+func (p *corsPolicy) wrap(h http.Handler) http.Handler {
+	if p == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !p.allows(origin) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", p.methods)
+			w.Header().Set("Access-Control-Allow-Headers", p.headers)
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", "Idempotent-Replayed, Retry-After")
+		h.ServeHTTP(w, r)
+	})
+}
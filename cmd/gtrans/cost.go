@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/minodisk/gtrans"
+)
+
+const costUsageMessage = "" +
+	`Usage:	gtrans cost [flags] [input text]
+	gtrans cost estimates the cost of translating input text with each engine by its pricing
+	and reports the cheapest configured one without calling any API. -plan of -file and -dir
+	reports the same for the segments they would translate.
+	Prices are list prices in USD, which can be overridden in the config, e.g.
+	{"prices": {"deepl": 20}} for USD 20 per million characters.
+`
+
+// promptTokens is the estimated number of tokens of the prompt of a model
+// sent with each segment.
+const promptTokens = 60
+
+// pricing is the pricing model of an engine. Translation APIs charge by
+// characters, and models charge by input and output tokens.
+type pricing struct {
+	perMChars float64 // USD per million characters
+	// USD per million input and output tokens of the default model
+	inPerMTokens, outPerMTokens float64
+	free                        string // why it's free
+}
+
+// enginePricing are the list prices of the built-in engines. Plugins and the
+// exec engine have unknown prices.
+var enginePricing = map[string]pricing{
+	"google":   {perMChars: 20},
+	"deepl":    {perMChars: 25},
+	"papago":   {perMChars: 15},
+	"yandex":   {perMChars: 15},
+	"baidu":    {perMChars: 7},
+	"openai":   {inPerMTokens: 0.15, outPerMTokens: 0.6},
+	"claude":   {inPerMTokens: 0.8, outPerMTokens: 4},
+	"gemini":   {inPerMTokens: 0.1, outPerMTokens: 0.4},
+	"local":    {free: "runs locally"},
+	"ollama":   {free: "runs locally"},
+	"apertium": {free: "public API"},
+}
+
+// charUsage is the amount of texts to translate by which engines charge.
+type charUsage struct {
+	Segments int `json:"segments"`
+	Chars    int `json:"chars"`
+	// quarters are quarters of the estimated tokens of the texts for
+	// models, which are a token per 4 ASCII characters or per other
+	// character.
+	quarters int
+}
+
+func (u *charUsage) add(s string) {
+	u.Segments++
+	for _, r := range s {
+		u.Chars++
+		if r < utf8.RuneSelf {
+			u.quarters++
+		} else {
+			u.quarters += 4
+		}
+	}
+}
+
+func (u *charUsage) tokens() int { return (u.quarters + 3) / 4 }
+
+// cost returns the estimated cost of u in USD. The output of a model is
+// assumed to be as long as the input.
+func (p pricing) cost(u charUsage) float64 {
+	if p.perMChars > 0 {
+		return float64(u.Chars) * p.perMChars / 1e6
+	}
+	in := float64(u.tokens() + u.Segments*promptTokens)
+	return (in*p.inPerMTokens + float64(u.tokens())*p.outPerMTokens) / 1e6
+}
+
+func (p pricing) String() string {
+	switch {
+	case p.free != "":
+		return "free (" + p.free + ")"
+	case p.perMChars > 0:
+		return fmt.Sprintf("$%g per 1M chars", p.perMChars)
+	}
+	return fmt.Sprintf("$%g/$%g per 1M input/output tokens", p.inPerMTokens, p.outPerMTokens)
+}
+
+// costEstimate is the estimated cost of translating with an engine.
+type costEstimate struct {
+	Engine     string `json:"engine"`
+	Configured bool   `json:"configured"`
+	// Cost is in USD, or nil if the price of the engine is unknown.
+	Cost    *float64 `json:"cost"`
+	Pricing string   `json:"pricing,omitempty"`
+}
+
+// pricingOf returns the pricing of the engine named name, which is
+// overridden by the config.
+func pricingOf(name string) (pricing, bool) {
+	if price, ok := userConfig.Prices[name]; ok {
+		if price == 0 {
+			return pricing{free: "configured"}, true
+		}
+		return pricing{perMChars: price}, true
+	}
+	p, ok := enginePricing[name]
+	return p, ok
+}
+
+// estimateCosts returns the estimated costs of u with the engines named names,
+// cheapest first. Engines with unknown prices come last.
+func estimateCosts(u charUsage, names []string) []costEstimate {
+	estimates := make([]costEstimate, len(names))
+	for i, name := range names {
+		e := costEstimate{Engine: name}
+		_, err := gtrans.NewEngine(name)
+		e.Configured = err == nil
+		if p, ok := pricingOf(name); ok {
+			cost := p.cost(u)
+			e.Cost, e.Pricing = &cost, p.String()
+		}
+		estimates[i] = e
+	}
+	sort.SliceStable(estimates, func(i, j int) bool {
+		a, b := estimates[i].Cost, estimates[j].Cost
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return *a < *b
+	})
+	return estimates
+}
+
+// cheapest returns the cheapest configured engine of estimates sorted by
+// estimateCosts, or nil if none is.
+func cheapest(estimates []costEstimate) *costEstimate {
+	for i := range estimates {
+		if e := &estimates[i]; e.Configured && e.Cost != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func formatCost(cost float64) string {
+	switch {
+	case cost == 0:
+		return "$0"
+	case cost < 0.0001:
+		return "<$0.0001"
+	}
+	return fmt.Sprintf("$%.4f", math.Round(cost*1e4)/1e4)
+}
+
+// writeCosts writes the table of estimates of u and the cheapest configured
+// engine.
+func writeCosts(w io.Writer, u charUsage, estimates []costEstimate) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENGINE\tCOST\tPRICING\tSTATUS")
+	for _, e := range estimates {
+		cost, price := "unknown", "-"
+		if e.Cost != nil {
+			cost, price = formatCost(*e.Cost), e.Pricing
+		}
+		status := "not configured"
+		if e.Configured {
+			status = "configured"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Engine, cost, price, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%d segments, %d chars, about %d tokens\n", u.Segments, u.Chars, u.tokens())
+	if e := cheapest(estimates); e != nil {
+		fmt.Fprintf(w, "cheapest configured engine: %s (%s)\n", e.Engine, formatCost(*e.Cost))
+	}
+	return nil
+}
+
+// costEngineNames returns the engines of a comma separated list, or all the
+// engines if it's empty.
+func costEngineNames(list string) []string {
+	if list == "" {
+		return gtrans.EngineNames()
+	}
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		names = append(names, strings.TrimSpace(name))
+	}
+	return names
+}
+
+func runCost(r io.Reader, w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("cost", flag.ExitOnError)
+	to := fs.String("to", targetLang, "target language, whose translations in the translation memory are free")
+	names := fs.String("engines", "", "comma separated engines to compare (default: all engines)")
+	format := fs.String("format", "table", "output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), costUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid -format %q: must be table or json", *format)
+	}
+	text, err := readInput(r, args)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	var u charUsage
+	if c.LookupTM(text, *to) == nil {
+		u.add(text)
+	}
+	estimates := estimateCosts(u, costEngineNames(*names))
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			charUsage
+			Tokens    int            `json:"tokens"`
+			Estimates []costEstimate `json:"estimates"`
+			Cheapest  string         `json:"cheapest,omitempty"`
+		}{u, u.tokens(), estimates, cheapestName(estimates)})
+	}
+	return writeCosts(w, u, estimates)
+}
+
+func cheapestName(estimates []costEstimate) string {
+	if e := cheapest(estimates); e != nil {
+		return e.Engine
+	}
+	return ""
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/minodisk/gtrans"
+)
+
+// docFile is a file to translate in file or directory mode.
+type docFile struct {
+	path   string // path of the source file
+	rel    string // path relative to the source directory
+	out    string // path of the translated file, if any
+	format *docFormat
+	skip   string // reason why the file is skipped, if any
+	hash   string // source hash
+}
+
+// checkUnchanged marks files whose translated files are up to date as
+// skipped, unless -force is given.
+func checkUnchanged(files []*docFile, m *hashManifest, targetLang string) error {
+	for _, f := range files {
+		if f.skip != "" || f.out == "" {
+			continue
+		}
+		src, err := ioutil.ReadFile(f.path)
+		if err != nil {
+			return err
+		}
+		f.hash = sourceHash(src, targetLang)
+		if !force && m.outputHash(f.out) == f.hash {
+			f.skip = "unchanged"
+		}
+	}
+	return nil
+}
+
+// collectFiles returns the files under dir. Hidden files and directories are
+// skipped, as well as ones ignored by .gtransignore files or -exclude, and
+// files of unsupported formats.
+func collectFiles(dir string) ([]*docFile, error) {
+	var files []*docFile
+	ignore := &ignoreMatcher{}
+	ignore.add("", excludes)
+	if err := ignore.addFile(dir, "."); err != nil {
+		return nil, err
+	}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			files = append(files, &docFile{path: path, rel: rel, skip: "hidden"})
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ignore.ignored(filepath.ToSlash(rel), info.IsDir()) {
+			files = append(files, &docFile{path: path, rel: rel, skip: "ignored"})
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return ignore.addFile(dir, rel)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f := &docFile{path: path, rel: rel, format: formatOf(path)}
+		if f.format != nil && f.format.sniff != nil {
+			src, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if !f.format.sniff(src) {
+				f.format = nil
+			}
+		}
+		if f.format == nil {
+			f.skip = "unsupported format"
+		}
+		files = append(files, f)
+		return nil
+	})
+	return files, err
+}
+
+// segmentTranslator returns a function translating segments of the document f
+// with c.
+func (c *client) segmentTranslator(ctx context.Context, f *docFile, targetLang string, prog *progress) segmentTranslator {
+	return func(segs []string) ([]string, error) {
+		translated := make([]string, len(segs))
+		for i, seg := range segs {
+			r, err := c.TranslateProtected(ctx, seg, targetLang, f.format.protect)
+			if err != nil {
+				return nil, err
+			}
+			c.report.Add(f.rel, r)
+			if err := checkQuality(r); err != nil {
+				return nil, err
+			}
+			translated[i] = r.Translation
+			prog.Add(1, charsSent(r))
+		}
+		return translated, nil
+	}
+}
+
+// translateFile translates the document and returns the translated document.
+func (c *client) translateFile(ctx context.Context, f *docFile, targetLang string, prog *progress) ([]byte, error) {
+	src, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	prog.SetFile(f.rel)
+	translated, err := f.format.translate(src, c.segmentTranslator(ctx, f, targetLang, prog))
+	if err != nil {
+		return nil, err
+	}
+	if f.format.setLang != nil {
+		translated = f.format.setLang(translated, targetLang)
+	}
+	return c.RunPostFileHook(ctx, translated, targetLang, filepath.ToSlash(f.rel))
+}
+
+// runFile translates a file, keeping its structure according to its format,
+// and writes the result to out, or w if out is empty. Files of unsupported
+// formats are translated as plain text.
+func runFile(w io.Writer, path, out, targetLang string) error {
+	f := &docFile{path: path, rel: filepath.Base(path), out: out, format: formatOf(path)}
+	if f.format == nil {
+		f.format = plainTextFormat
+	}
+	var m *hashManifest
+	if out != "" {
+		var err error
+		if m, err = loadHashManifest(filepath.Dir(out)); err != nil {
+			return err
+		}
+		if err := checkUnchanged([]*docFile{f}, m, targetLang); err != nil {
+			return err
+		}
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	if plan {
+		return writePlan(w, c, []*docFile{f}, targetLang)
+	}
+	if f.skip != "" {
+		fmt.Fprintf(os.Stderr, "gtrans: skip %s (%s). Use -force to translate it again\n", path, f.skip)
+		return nil
+	}
+	prog, err := newProgress(progressMode, os.Stderr, 0)
+	if err != nil {
+		return err
+	}
+	translated, err := c.translateFile(context.Background(), f, targetLang, prog)
+	prog.Finish()
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		if _, err := w.Write(translated); err != nil {
+			return err
+		}
+	} else {
+		if err := writeFile(out, m.embed(f.format, out, translated, f.hash)); err != nil {
+			return err
+		}
+		if err := m.Save(); err != nil {
+			return err
+		}
+	}
+	return c.Close()
+}
+
+// runDir translates the files under dir into the same paths under out,
+// translating -jobs files concurrently.
+func runDir(w io.Writer, dir, out, targetLang string) error {
+	files, err := collectFiles(dir)
+	if err != nil {
+		return err
+	}
+	if out == "" && !plan {
+		return fmt.Errorf("-out is required to translate directory %s", dir)
+	}
+	if out != "" && abs(dir) == abs(out) {
+		return fmt.Errorf("-out must be different from the source directory %s", dir)
+	}
+	var m *hashManifest
+	if out != "" {
+		for _, f := range files {
+			f.out = filepath.Join(out, f.rel)
+		}
+		if m, err = loadHashManifest(out); err != nil {
+			return err
+		}
+		if err := checkUnchanged(files, m, targetLang); err != nil {
+			return err
+		}
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	if plan {
+		return writePlan(w, c, files, targetLang)
+	}
+	prog, err := newProgress(progressMode, os.Stderr, 0)
+	if err != nil {
+		return err
+	}
+	in := make(chan interface{})
+	go func() {
+		defer close(in)
+		for _, f := range files {
+			if f.skip == "" {
+				in <- f
+			}
+		}
+	}()
+	failed := 0
+	err = orderedWorkers(jobs, in, func(item interface{}) interface{} {
+		f := item.(*docFile)
+		translated, err := c.translateFile(context.Background(), f, targetLang, prog)
+		if err == nil {
+			err = writeFile(f.out, m.embed(f.format, f.out, translated, f.hash))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.rel, err)
+		}
+		return nil
+	}, func(result interface{}) error {
+		if err, ok := result.(error); ok {
+			fmt.Fprintf(os.Stderr, "gtrans: %v\n", err)
+			failed++
+		}
+		return nil
+	})
+	prog.Finish()
+	if err != nil {
+		return err
+	}
+	if err := m.Save(); err != nil {
+		return err
+	}
+	if err := c.report.Save(); err != nil {
+		return err
+	}
+	if err := c.Close(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("fail to translate %d files", failed)
+	}
+	return nil
+}
+
+func abs(path string) string {
+	if p, err := filepath.Abs(path); err == nil {
+		return filepath.Clean(p)
+	}
+	return path
+}
+
+func writeFile(path string, b []byte) error {
+	if err := journal.record(path); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return err
+	}
+	return journal.wrote(path, b)
+}
+
+// writePlan writes which files and segments would be translated or skipped,
+// and why, followed by the estimated costs with the engines, without calling
+// any API.
+func writePlan(w io.Writer, c *client, files []*docFile, targetLang string) error {
+	var nfiles, nsegs, nchars int
+	var u charUsage
+	for _, f := range files {
+		if f.skip != "" {
+			fmt.Fprintf(w, "skip      %s (%s)\n", f.rel, f.skip)
+			continue
+		}
+		src, err := ioutil.ReadFile(f.path)
+		if err != nil {
+			return err
+		}
+		var lines []string
+		segs, chars := 0, 0
+		_, err = f.format.translate(src, func(ss []string) ([]string, error) {
+			for _, s := range ss {
+				if c.LookupTM(s, targetLang) != nil {
+					lines = append(lines, "  cached    "+planSnippet(s))
+					continue
+				}
+				lines = append(lines, "  translate "+planSnippet(s))
+				u.add(s)
+				segs++
+				chars += utf8.RuneCountInString(s)
+			}
+			return ss, nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.rel, err)
+		}
+		if segs == 0 {
+			fmt.Fprintf(w, "skip      %s (all %d segments cached)\n", f.rel, len(lines))
+		} else {
+			fmt.Fprintf(w, "translate %s (%d/%d segments, %d chars)\n", f.rel, segs, len(lines), chars)
+			nfiles++
+		}
+		for _, l := range lines {
+			fmt.Fprintln(w, l)
+		}
+		nsegs += segs
+		nchars += chars
+	}
+	fmt.Fprintf(w, "\n%d files, %d segments, %d chars would be translated into %s\n", nfiles, nsegs, nchars, targetLang)
+	if nsegs == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	return writeCosts(w, u, estimateCosts(u, gtrans.EngineNames()))
+}
+
+// planSnippet returns the first line of s for plans, truncated if it's long.
+func planSnippet(s string) string {
+	const max = 60
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		s = s[:i] + "..."
+	}
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max]) + "..."
+	}
+	return fmt.Sprintf("%q", s)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const discordBotUsageMessage = "" +
+	`Usage:	gtrans discord-bot [flags]
+	gtrans discord-bot connects to the Discord gateway, and translates messages
+	with the engine, translation memory and glossary configured by flags.
+
+	Reacting to a message with an emoji of -reactions replies its translation,
+	and so does -command replying to a message, or followed by text.
+	-channels limits the bot to channels, each of which may have its own
+	target language.
+
+	export DISCORD_BOT_TOKEN=<token of a bot with the Message Content intent>
+`
+
+const (
+	discordAPI     = "https://discord.com/api/v10"
+	discordGateway = "wss://gateway.discord.gg/?v=10&encoding=json"
+	// discordIntents are GUILD_MESSAGES, GUILD_MESSAGE_REACTIONS and
+	// MESSAGE_CONTENT.
+	discordIntents = 1<<9 | 1<<10 | 1<<15
+	// discordMaxLen is the maximum length of a message in characters.
+	discordMaxLen = 2000
+)
+
+// discordBot translates Discord messages reacted to with the emojis of
+// reactions, or given to the command.
+type discordBot struct {
+	*chatBot
+	token   string
+	command string
+	// reactions are the target languages by emoji, which are empty for the
+	// language of the channel.
+	reactions map[string]string
+	// channels are the target languages by channel ID, which are empty for
+	// the default target language, or nil to translate in any channel.
+	channels map[string]string
+}
+
+// discordPayload is a message of the gateway.
+type discordPayload struct {
+	Op   int             `json:"op"`
+	D    json.RawMessage `json:"d"`
+	S    *int64          `json:"s,omitempty"`
+	Type string          `json:"t,omitempty"`
+}
+
+// discordMessage is a message of a channel.
+type discordMessage struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	Content   string `json:"content"`
+	Author    struct {
+		Bot bool `json:"bot"`
+	} `json:"author"`
+	ReferencedMessage *discordMessage `json:"referenced_message"`
+}
+
+func runDiscordBot(args []string) error {
+	fs := flagSetWithGlobals("discord-bot")
+	reactions := fs.String("reactions", "🌐", "comma separated emojis replying the translation of the message reacted to with them, each optionally followed by = and the target language, e.g. 🇺🇸=en")
+	command := fs.String("command", "!translate", "command translating the message it replies to, or the text following it")
+	channels := fs.String("channels", "", "comma separated IDs of the channels to translate in, each optionally followed by = and the target language, e.g. 1234567890=ja (default: any channel into -to)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), discordBotUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	b := &discordBot{
+		token:     credential("discord-bot", "DISCORD_BOT_TOKEN"),
+		command:   *command,
+		reactions: parseBotTargets(*reactions),
+	}
+	if b.token == "" {
+		return errors.New("DISCORD_BOT_TOKEN is required")
+	}
+	if *channels != "" {
+		b.channels = parseBotTargets(*channels)
+	}
+	var err error
+	if b.chatBot, err = newChatBot(); err != nil {
+		return err
+	}
+	return b.run("discord", b.connect)
+}
+
+// connect connects to the gateway and handles the events until the
+// connection is closed. Sessions are not resumed, but identified again.
+func (b *discordBot) connect(ctx context.Context) error {
+	ws, err := websocket.Dial(discordGateway, "", "https://discord.com")
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		ws.Close()
+	}()
+	var hello struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	var p discordPayload
+	if err := websocket.JSON.Receive(ws, &p); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(p.D, &hello); err != nil || p.Op != 10 {
+		return fmt.Errorf("unexpected payload of op %d instead of hello", p.Op)
+	}
+	identify := map[string]interface{}{
+		"op": 2,
+		"d": map[string]interface{}{
+			"token":      b.token,
+			"intents":    discordIntents,
+			"properties": map[string]string{"os": "linux", "browser": "gtrans", "device": "gtrans"},
+		},
+	}
+	if err := websocket.JSON.Send(ws, identify); err != nil {
+		return err
+	}
+	var (
+		seqMu sync.Mutex
+		seq   *int64
+	)
+	go func() {
+		t := time.NewTicker(time.Duration(hello.HeartbeatInterval) * time.Millisecond)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+			seqMu.Lock()
+			heartbeat := map[string]interface{}{"op": 1, "d": seq}
+			seqMu.Unlock()
+			if err := websocket.JSON.Send(ws, heartbeat); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		var p discordPayload
+		if err := websocket.JSON.Receive(ws, &p); err != nil {
+			return err
+		}
+		if p.S != nil {
+			seqMu.Lock()
+			seq = p.S
+			seqMu.Unlock()
+		}
+		switch p.Op {
+		case 7:
+			return errors.New("reconnect requested")
+		case 9:
+			return errors.New("invalid session")
+		case 0:
+			switch p.Type {
+			case "MESSAGE_CREATE":
+				var m discordMessage
+				if err := json.Unmarshal(p.D, &m); err == nil {
+					go b.handleMessage(ctx, &m)
+				}
+			case "MESSAGE_REACTION_ADD":
+				var r struct {
+					ChannelID string `json:"channel_id"`
+					MessageID string `json:"message_id"`
+					Emoji     struct {
+						Name string `json:"name"`
+					} `json:"emoji"`
+				}
+				if err := json.Unmarshal(p.D, &r); err == nil {
+					go b.handleReaction(ctx, r.ChannelID, r.MessageID, r.Emoji.Name)
+				}
+			}
+		}
+	}
+}
+
+// target returns the target language in channel, and false if the bot
+// doesn't translate there.
+func (b *discordBot) target(channel string) (string, bool) {
+	if b.channels == nil {
+		return "", true
+	}
+	lang, ok := b.channels[channel]
+	return lang, ok
+}
+
+// handleReaction replies the translation of the message reacted to with an
+// emoji of reactions.
+func (b *discordBot) handleReaction(ctx context.Context, channel, id, emoji string) {
+	to, ok := b.reactions[emoji]
+	if !ok {
+		return
+	}
+	lang, ok := b.target(channel)
+	if !ok {
+		return
+	}
+	if to == "" {
+		to = lang
+	}
+	if !b.once(channel + "/" + id + "/" + to) {
+		return
+	}
+	var m discordMessage
+	if err := b.call(ctx, "GET", "/channels/"+channel+"/messages/"+id, nil, &m); err != nil {
+		log.Printf("discord: fail to get message %s in %s: %v", id, channel, err)
+		return
+	}
+	b.reply(ctx, &m, m.Content, to)
+}
+
+// handleMessage replies the translation of the message the command replies
+// to, or of the text following the command.
+func (b *discordBot) handleMessage(ctx context.Context, m *discordMessage) {
+	if m.Author.Bot || b.command == "" {
+		return
+	}
+	text := strings.TrimSpace(m.Content)
+	if text != b.command && !strings.HasPrefix(text, b.command+" ") && !strings.HasPrefix(text, b.command+"\n") {
+		return
+	}
+	to, ok := b.target(m.ChannelID)
+	if !ok {
+		return
+	}
+	text = strings.TrimSpace(strings.TrimPrefix(text, b.command))
+	if text == "" && m.ReferencedMessage != nil {
+		text = m.ReferencedMessage.Content
+	}
+	b.reply(ctx, m, text, to)
+}
+
+// reply translates text into to, and replies the translation to m.
+func (b *discordBot) reply(ctx context.Context, m *discordMessage, text, to string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	translated, err := b.translate(ctx, text, to)
+	if err != nil {
+		log.Printf("discord: fail to translate message %s in %s: %v", m.ID, m.ChannelID, err)
+		return
+	}
+	for _, content := range splitMessage(translated, discordMaxLen) {
+		body := map[string]interface{}{
+			"content":           content,
+			"message_reference": map[string]string{"message_id": m.ID},
+			// Mentions in translations don't notify anyone again.
+			"allowed_mentions": map[string][]string{"parse": {}},
+		}
+		if err := b.call(ctx, "POST", "/channels/"+m.ChannelID+"/messages", body, nil); err != nil {
+			log.Printf("discord: fail to reply to message %s in %s: %v", m.ID, m.ChannelID, err)
+			return
+		}
+	}
+}
+
+// call calls the REST API, and decodes the response into out if it's not nil.
+func (b *discordBot) call(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, discordAPI+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+b.token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DiscordBot (https://github.com/haya14busa/gtrans, 1)")
+	res, err := b.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s: %s", method, path, res.Status, bytes.TrimSpace(resBody))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resBody, out)
+}
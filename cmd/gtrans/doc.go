@@ -0,0 +1,309 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// segmentTranslator translates segments of a document at once.
+type segmentTranslator func(segments []string) ([]string, error)
+
+// docFormat is a document format whose translatable parts can be translated
+// keeping the structure.
+type docFormat struct {
+	name string
+	exts []string
+	// protect protects inline markup in segments.
+	protect []gtrans.ProtectRule
+	// sniff reports whether src is of the format, for formats of common
+	// extensions such as .json, or is nil if the extensions tell it.
+	sniff func(src []byte) bool
+	// comment returns a comment line of s, or is nil if the format has no
+	// comment syntax.
+	comment func(s string) string
+	// translate translates the document src.
+	translate func(src []byte, tr segmentTranslator) ([]byte, error)
+	// setLang sets the language of the translated document, or is nil if
+	// the format doesn't declare it.
+	setLang func(doc []byte, lang string) []byte
+}
+
+var docFormats = []*docFormat{
+	plainTextFormat,
+	markdownFormat,
+	notebookFormat,
+	latexFormat,
+	rstFormat,
+	asciidocFormat,
+	orgFormat,
+	emailFormat,
+	chatFormat,
+	assFormat,
+	htmlFormat,
+	roffFormat,
+}
+
+// formatOf returns the format of the file at path, or nil if the format is not
+// supported.
+func formatOf(path string) *docFormat {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range docFormats {
+		for _, e := range f.exts {
+			if e == ext {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// docPart is a part of a document, which is either translated or kept as is.
+type docPart struct {
+	text      string
+	translate bool
+	// quote writes the translated text, e.g. quoting it as a string, or
+	// is nil to write it as is.
+	quote func(s string) string
+}
+
+// translateParts translates the translatable parts at once and returns the
+// document joining all the parts. White spaces around translatable parts are
+// kept as is.
+func translateParts(parts []docPart, tr segmentTranslator) ([]byte, error) {
+	texts, err := translatePartTexts(parts, tr)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(texts, "")), nil
+}
+
+// translatePartTexts translates the translatable parts at once like
+// translateParts, and returns the text of each part.
+func translatePartTexts(parts []docPart, tr segmentTranslator) ([]string, error) {
+	var (
+		segs []string
+		idx  []int
+	)
+	for i, p := range parts {
+		if p.translate && strings.TrimSpace(p.text) != "" {
+			segs = append(segs, strings.TrimSpace(p.text))
+			idx = append(idx, i)
+		}
+	}
+	var translated []string
+	if len(segs) > 0 {
+		var err error
+		if translated, err = tr(segs); err != nil {
+			return nil, err
+		}
+	}
+	texts := make([]string, len(parts))
+	for i, p := range parts {
+		if len(idx) > 0 && idx[0] == i {
+			start := len(p.text) - len(strings.TrimLeft(p.text, " \t\r\n"))
+			end := len(strings.TrimRight(p.text, " \t\r\n"))
+			t := p.text[:start] + translated[0] + p.text[end:]
+			if p.quote != nil {
+				t = p.quote(t)
+			}
+			texts[i] = t
+			idx, translated = idx[1:], translated[1:]
+			continue
+		}
+		texts[i] = p.text
+	}
+	return texts, nil
+}
+
+// plainTextFormat translates paragraphs separated by blank lines.
+var plainTextFormat = &docFormat{
+	name: "text",
+	exts: []string{".txt", ".text"},
+	translate: func(src []byte, tr segmentTranslator) ([]byte, error) {
+		return translateParts(plainTextParts(string(src)), tr)
+	},
+}
+
+var paragraphRe = regexp.MustCompile(`\n[ \t]*\n`)
+
+func plainTextParts(src string) []docPart {
+	var parts []docPart
+	last := 0
+	for _, loc := range paragraphRe.FindAllStringIndex(src, -1) {
+		parts = append(parts, docPart{text: src[last:loc[0]], translate: true}, docPart{text: src[loc[0]:loc[1]]})
+		last = loc[1]
+	}
+	return append(parts, docPart{text: src[last:], translate: true})
+}
+
+// Markdown inline markup which must not be translated.
+var markdownInlineRules = []gtrans.ProtectRule{
+	{Kind: "markup", Pattern: regexp.MustCompile("``[^\n]+?``|`[^`\n]+`")},
+	{Kind: "markup", Pattern: regexp.MustCompile(`\]\([^)\s]*(?:\s+"[^"]*")?\)`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`<https?://[^>\s]+>|https?://[^\s<>()]+|</?[A-Za-z][^>\n]*>`)},
+}
+
+var (
+	mdFenceRe    = regexp.MustCompile("^\\s{0,3}(`{3,}|~{3,})")
+	mdHeadingRe  = regexp.MustCompile(`^(\s{0,3}#{1,6}\s+)(.*?)(\s+#+)?\s*$`)
+	mdListRe     = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?)(.*)$`)
+	mdQuoteRe    = regexp.MustCompile(`^(\s{0,3}>\s?)(.*)$`)
+	mdRuleRe     = regexp.MustCompile(`^\s{0,3}(?:[-*_=]\s*){3,}$`)
+	mdRefLinkRe  = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s`)
+	mdHTMLLineRe = regexp.MustCompile(`^\s{0,3}<`)
+	// mdTableSepRe matches the separator row of a table under its header
+	// row, whose colons align the columns.
+	mdTableSepRe = regexp.MustCompile(`^\s{0,3}\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?\s*$`)
+	// htmlCellRe matches a cell of an HTML table in a line.
+	htmlCellRe = regexp.MustCompile(`(?i)(<t[dh](?:\s[^>]*)?>)(.*?)(</t[dh]\s*>)`)
+)
+
+// markdownFormat translates headings, paragraphs, list items, block quotes and
+// table cells, keeping code blocks (except their comments with
+// -translate-code-comments), front matter, HTML blocks and inline code as
+// is. Only the fields of front matter given by -front-matter are translated.
+// The cells of Markdown tables and HTML tables are translated one by one
+// keeping the pipes and separator rows. Paragraphs are written in a line after
+// translation.
+var markdownFormat = &docFormat{
+	name:    "markdown",
+	exts:    []string{".md", ".markdown", ".mdown"},
+	protect: markdownInlineRules,
+	comment: htmlComment,
+	translate: func(src []byte, tr segmentTranslator) ([]byte, error) {
+		return translateParts(markdownParts(string(src)), tr)
+	},
+}
+
+func htmlComment(s string) string {
+	return "<!-- " + s + " -->"
+}
+
+func markdownParts(src string) []docPart {
+	parts, lines := frontMatterParts(strings.SplitAfter(src, "\n"))
+	var (
+		para   []string         // lines of the current paragraph
+		prefix string           // list marker or quote prefix of the paragraph
+		fence  string           // opening fence of the current code block
+		code   *commentSplitter // comments of the code block to translate
+		table  bool             // whether in a table
+		html   bool             // whether in an HTML block
+	)
+	raw := func(s string) { parts = append(parts, docPart{text: s}) }
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		raw(prefix)
+		parts = append(parts, docPart{text: strings.Join(para, " "), translate: true})
+		raw("\n")
+		para, prefix = nil, ""
+	}
+	for i, line := range lines {
+		body := strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(body) == "" || !strings.Contains(body, "|") {
+			table = false
+		}
+		switch {
+		case fence != "":
+			if strings.HasPrefix(strings.TrimSpace(body), fence) {
+				fence, code = "", nil
+				raw(line)
+			} else if code != nil {
+				parts = append(parts, code.parts(line)...)
+			} else {
+				raw(line)
+			}
+		case mdFenceRe.MatchString(body):
+			flush()
+			fence = mdFenceRe.FindStringSubmatch(body)[1]
+			if syntax := fenceCommentSyntaxes[fenceLanguage(body, fence)]; codeComments && syntax != nil {
+				code = &commentSplitter{syntax: syntax}
+			}
+			raw(line)
+		case strings.TrimSpace(body) == "":
+			flush()
+			html = false
+			raw(line)
+		case table && mdTableSepRe.MatchString(body):
+			raw(line)
+		case table:
+			parts = append(parts, tableCells(line)...)
+		case strings.Contains(body, "|") && i+1 < len(lines) && mdTableSepRe.MatchString(strings.TrimRight(lines[i+1], "\r\n")):
+			// Header row followed by the separator row
+			flush()
+			table = true
+			parts = append(parts, tableCells(line)...)
+		case mdHTMLLineRe.MatchString(body), html && strings.HasPrefix(strings.TrimSpace(body), "<"):
+			// HTML block, which lasts until a blank line.
+			flush()
+			html = true
+			parts = append(parts, htmlCells(line)...)
+		case len(para) == 0 && (strings.HasPrefix(body, "    ") || strings.HasPrefix(body, "\t")):
+			// Indented code block
+			raw(line)
+		case mdRuleRe.MatchString(body), mdRefLinkRe.MatchString(body):
+			flush()
+			raw(line)
+		case mdHeadingRe.MatchString(body):
+			flush()
+			m := mdHeadingRe.FindStringSubmatchIndex(body)
+			raw(body[:m[3]])
+			parts = append(parts, docPart{text: body[m[4]:m[5]], translate: true})
+			raw(body[m[5]:] + line[len(body):])
+		case mdListRe.MatchString(body):
+			flush()
+			m := mdListRe.FindStringSubmatch(body)
+			prefix, para = m[1], []string{m[2]}
+		case mdQuoteRe.MatchString(body):
+			flush()
+			m := mdQuoteRe.FindStringSubmatch(body)
+			raw(m[1])
+			parts = append(parts, docPart{text: m[2], translate: true})
+			raw(line[len(body):])
+		default:
+			para = append(para, strings.TrimSpace(body))
+		}
+	}
+	flush()
+	return parts
+}
+
+// tableCells splits a row of a Markdown table into the cells to translate and
+// the pipes between them. Escaped pipes are in cells.
+func tableCells(line string) []docPart {
+	var (
+		parts []docPart
+		last  int
+	)
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '|':
+			parts = append(parts, docPart{text: line[last:i], translate: true}, docPart{text: "|"})
+			last = i + 1
+		}
+	}
+	return append(parts, docPart{text: line[last:], translate: true})
+}
+
+// htmlCells splits a line of an HTML block into the contents of the table
+// cells in it to translate and the rest kept as is.
+func htmlCells(line string) []docPart {
+	var (
+		parts []docPart
+		last  int
+	)
+	for _, m := range htmlCellRe.FindAllStringSubmatchIndex(line, -1) {
+		parts = append(parts,
+			docPart{text: line[last:m[3]]},
+			docPart{text: line[m[4]:m[5]], translate: true},
+		)
+		last = m[5]
+	}
+	return append(parts, docPart{text: line[last:]})
+}
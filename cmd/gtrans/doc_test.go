@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// formatTest is a document translated by a docFormat with the identity
+// translator.
+type formatTest struct {
+	name string
+	src  string
+	// segments are the texts sent for translation.
+	segments []string
+	// want is the translated document, or empty if it's src, i.e. the
+	// document round-trips.
+	want string
+}
+
+// checkFormats runs the tests of the document format f.
+func checkFormats(t *testing.T, f *docFormat, tests []formatTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkFormat(t, f, tt.src, tt.segments, tt.want)
+		})
+	}
+}
+
+// checkFormat translates src by f with the identity translator, and checks the
+// segments sent for translation and the translated document, which is want, or
+// src if want is empty.
+func checkFormat(t *testing.T, f *docFormat, src string, segments []string, want string) {
+	t.Helper()
+	var got []string
+	translated, err := f.translate([]byte(src), func(ss []string) ([]string, error) {
+		got = append(got, ss...)
+		return ss, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, segments) {
+		t.Errorf("segments = %q, want %q", got, segments)
+	}
+	if want == "" {
+		want = src
+	}
+	if string(translated) != want {
+		t.Errorf("translated = %q, want %q", translated, want)
+	}
+}
+
+func TestMarkdownFormat(t *testing.T) {
+	checkFormats(t, markdownFormat, []formatTest{
+		{
+			name:     "paragraphs",
+			src:      "# Title\n\nFirst paragraph.\n\n- An item\n",
+			segments: []string{"Title", "First paragraph.", "An item"},
+		},
+		{
+			name:     "code fence",
+			src:      "Run it:\n\n```sh\nmake html\n```\n",
+			segments: []string{"Run it:"},
+		},
+	})
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// emailFormat translates the subjects and the text bodies of RFC 822 messages
+// (.eml) and mailbox files of them (.mbox), keeping the other headers and
+// attachments as is. Bodies are decoded from quoted-printable or base64 and
+// their charsets, and written in UTF-8 after translation. Quoted lines of
+// plain text bodies and signatures are kept as is.
+var emailFormat = &docFormat{
+	name:      "email",
+	exts:      []string{".eml", ".mbox"},
+	translate: translateEmail,
+}
+
+var (
+	// mboxFromRe matches the separator lines of messages in mbox.
+	mboxFromRe = regexp.MustCompile(`(?m)^From .*\r?\n`)
+	// replyPrefixRe matches the prefixes of subjects of replies and
+	// forwards, which mail clients rely on.
+	replyPrefixRe = regexp.MustCompile(`(?i)^(?:(?:re|fwd?|aw|wg|sv|vs)\s*(?:\[\d+\])?\s*:\s*)+`)
+	mailQuoteRe   = regexp.MustCompile(`^\s*>`)
+)
+
+var mailWordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// mailDoc is a document of messages being split into parts.
+type mailDoc struct {
+	parts []docPart
+	// spans are the ranges of parts which are encoded together after
+	// translation, e.g. the paragraphs of a body.
+	spans []mailSpan
+}
+
+type mailSpan struct {
+	start, end int
+	encode     func(s string) string
+}
+
+func translateEmail(src []byte, tr segmentTranslator) ([]byte, error) {
+	d := &mailDoc{}
+	s := string(src)
+	if locs := mboxFromRe.FindAllStringIndex(s, -1); len(locs) > 0 && locs[0][0] == 0 {
+		for i, loc := range locs {
+			end := len(s)
+			if i+1 < len(locs) {
+				end = locs[i+1][0]
+			}
+			d.raw(s[loc[0]:loc[1]])
+			d.message(s[loc[1]:end])
+		}
+	} else {
+		d.message(s)
+	}
+	texts, err := translatePartTexts(d.parts, tr)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for i := 0; i < len(texts); i++ {
+		if len(d.spans) > 0 && d.spans[0].start == i {
+			span := d.spans[0]
+			b.WriteString(span.encode(strings.Join(texts[span.start:span.end], "")))
+			i, d.spans = span.end-1, d.spans[1:]
+			continue
+		}
+		b.WriteString(texts[i])
+	}
+	return []byte(b.String()), nil
+}
+
+func (d *mailDoc) raw(s string) { d.parts = append(d.parts, docPart{text: s}) }
+
+// mailHeader is the header of a message or a MIME part, whose fields are kept
+// as they are written unless they are rewritten.
+type mailHeader struct {
+	fields []mailField
+	nl     string // line break of the message
+}
+
+type mailField struct {
+	name string
+	raw  string // the field including folded lines and the line break
+}
+
+// splitMailHeader splits an entity into the header and the body.
+func splitMailHeader(s string) (*mailHeader, string) {
+	h := &mailHeader{nl: "\n"}
+	if strings.Contains(s, "\r\n") {
+		h.nl = "\r\n"
+	}
+	for s != "" {
+		i := strings.IndexByte(s, '\n') + 1
+		if i == 0 {
+			i = len(s)
+		}
+		line := s[:i]
+		if strings.TrimRight(line, "\r\n") == "" {
+			return h, s[i:]
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(h.fields) > 0 {
+			h.fields[len(h.fields)-1].raw += line
+		} else {
+			name := line
+			if j := strings.IndexByte(line, ':'); j >= 0 {
+				name = line[:j]
+			}
+			h.fields = append(h.fields, mailField{name: strings.TrimSpace(name), raw: line})
+		}
+		s = s[i:]
+	}
+	return h, ""
+}
+
+// get returns the unfolded value of the field named name.
+func (h *mailHeader) get(name string) string {
+	for _, f := range h.fields {
+		if strings.EqualFold(f.name, name) {
+			v := f.raw[strings.IndexByte(f.raw, ':')+1:]
+			return strings.TrimSpace(strings.NewReplacer("\r\n", "", "\n", "").Replace(v))
+		}
+	}
+	return ""
+}
+
+// set sets the field named name to value, or adds it if it's missing.
+func (h *mailHeader) set(name, value string) {
+	raw := name + ": " + value + h.nl
+	for i, f := range h.fields {
+		if strings.EqualFold(f.name, name) {
+			h.fields[i].raw = raw
+			return
+		}
+	}
+	h.fields = append(h.fields, mailField{name: name, raw: raw})
+}
+
+// message adds the parts of a message.
+func (d *mailDoc) message(s string) {
+	h, body := splitMailHeader(s)
+	if len(h.fields) == 0 {
+		d.raw(s)
+		return
+	}
+	d.entity(h, body, true)
+}
+
+// entity adds the parts of a message or a MIME part with the header h.
+func (d *mailDoc) entity(h *mailHeader, body string, top bool) {
+	mediaType, params, err := "text/plain", map[string]string{}, error(nil)
+	if ct := h.get("Content-Type"); ct != "" {
+		mediaType, params, err = mime.ParseMediaType(ct)
+	}
+	disposition, _, _ := mime.ParseMediaType(h.get("Content-Disposition"))
+	var text func(string) []docPart
+	switch {
+	case err != nil, disposition == "attachment":
+	case strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "":
+		d.header(h, top)
+		d.multipart(body, params["boundary"])
+		return
+	case mediaType == "text/plain":
+		text = mailTextParts
+	case mediaType == "text/html":
+		text = htmlTextParts
+	}
+	var decoded string
+	if text != nil {
+		decoded, err = decodeMailBody(body, h.get("Content-Transfer-Encoding"), params["charset"])
+	}
+	if text == nil || err != nil {
+		d.header(h, top)
+		d.raw(body)
+		return
+	}
+	// The body is written in UTF-8 after translation.
+	params["charset"] = "utf-8"
+	h.set("Content-Type", mime.FormatMediaType(mediaType, params))
+	cte := "quoted-printable"
+	if strings.EqualFold(h.get("Content-Transfer-Encoding"), "base64") {
+		cte = "base64"
+	}
+	h.set("Content-Transfer-Encoding", cte)
+	d.header(h, top)
+	start := len(d.parts)
+	d.parts = append(d.parts, text(strings.Replace(decoded, "\r\n", "\n", -1))...)
+	d.spans = append(d.spans, mailSpan{start: start, end: len(d.parts), encode: func(s string) string {
+		return encodeMailBody(s, cte, h.nl)
+	}})
+}
+
+// header adds the parts of h, translating the subject of a message.
+func (d *mailDoc) header(h *mailHeader, top bool) {
+	for _, f := range h.fields {
+		if !top || !strings.EqualFold(f.name, "Subject") {
+			d.raw(f.raw)
+			continue
+		}
+		subject, err := mailWordDecoder.DecodeHeader(h.get("Subject"))
+		if err != nil {
+			d.raw(f.raw)
+			continue
+		}
+		prefix := replyPrefixRe.FindString(subject)
+		d.raw(f.name + ": " + prefix)
+		d.parts = append(d.parts, docPart{text: subject[len(prefix):], translate: true, quote: func(s string) string {
+			return mime.QEncoding.Encode("utf-8", s)
+		}})
+		d.raw(h.nl)
+	}
+	d.raw(h.nl)
+}
+
+// multipart adds the parts of the body of a multipart entity, keeping the
+// preamble, the boundaries and the epilogue as is.
+func (d *mailDoc) multipart(body, boundary string) {
+	delimiter := "--" + boundary
+	var (
+		part   strings.Builder
+		inPart bool
+	)
+	flush := func() {
+		if inPart {
+			h, b := splitMailHeader(part.String())
+			d.entity(h, b, false)
+		} else {
+			d.raw(part.String())
+		}
+		part.Reset()
+	}
+	for _, line := range strings.SplitAfter(body, "\n") {
+		if t := strings.TrimRight(line, " \t\r\n"); t == delimiter || t == delimiter+"--" {
+			flush()
+			d.raw(line)
+			inPart = t == delimiter
+			continue
+		}
+		part.WriteString(line)
+	}
+	flush()
+}
+
+// decodeMailBody decodes body encoded by the content transfer encoding cte in
+// the charset.
+func decodeMailBody(body, cte, cs string) (string, error) {
+	var r io.Reader = strings.NewReader(body)
+	switch strings.ToLower(cte) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, &mailBase64Reader{r: r})
+	case "", "7bit", "8bit", "binary":
+	default:
+		return "", fmt.Errorf("unknown content transfer encoding %q", cte)
+	}
+	if cs != "" {
+		var err error
+		if r, err = charset.NewReaderLabel(cs, r); err != nil {
+			return "", err
+		}
+	}
+	b, err := ioutil.ReadAll(r)
+	return string(b), err
+}
+
+// mailBase64Reader drops line breaks and spaces in base64 bodies.
+type mailBase64Reader struct{ r io.Reader }
+
+func (m *mailBase64Reader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	k := 0
+	for _, c := range p[:n] {
+		if c != '\r' && c != '\n' && c != ' ' && c != '\t' {
+			p[k] = c
+			k++
+		}
+	}
+	return k, err
+}
+
+// encodeMailBody encodes s in UTF-8 by the content transfer encoding cte with
+// the line break nl.
+func encodeMailBody(s, cte, nl string) string {
+	var b bytes.Buffer
+	if cte == "base64" {
+		enc := base64.StdEncoding.EncodeToString([]byte(s))
+		for len(enc) > 76 {
+			b.WriteString(enc[:76] + nl)
+			enc = enc[76:]
+		}
+		if enc != "" {
+			b.WriteString(enc + nl)
+		}
+		return b.String()
+	}
+	w := quotedprintable.NewWriter(&b)
+	w.Write([]byte(s))
+	w.Close()
+	return strings.Replace(b.String(), "\r\n", nl, -1)
+}
+
+// mailTextParts splits a plain text body into paragraphs to translate, keeping
+// quoted lines and the signature after "-- " as is.
+func mailTextParts(s string) []docPart {
+	var (
+		parts []docPart
+		text  strings.Builder
+	)
+	flush := func() {
+		if text.Len() > 0 {
+			parts = append(parts, plainTextParts(text.String())...)
+			text.Reset()
+		}
+	}
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		body := strings.TrimRight(line, "\r\n")
+		// The trailing space is lost in quoted-printable.
+		if body == "-- " || body == "--" {
+			flush()
+			parts = append(parts, docPart{text: strings.Join(lines[i:], "")})
+			return parts
+		}
+		if mailQuoteRe.MatchString(body) {
+			flush()
+			parts = append(parts, docPart{text: line})
+			continue
+		}
+		text.WriteString(line)
+	}
+	flush()
+	return parts
+}
+
+// htmlTextParts splits an HTML document into its texts to translate and the
+// markup kept as is. Texts of scripts and styles are kept as well.
+func htmlTextParts(s string) []docPart {
+	var (
+		parts []docPart
+		skip  int // depth of script and style elements
+	)
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return parts
+		}
+		raw := string(z.Raw())
+		switch tt {
+		case html.StartTagToken, html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "script" || string(name) == "style" {
+				if tt == html.StartTagToken {
+					skip++
+				} else if skip > 0 {
+					skip--
+				}
+			}
+		case html.TextToken:
+			if skip == 0 {
+				parts = append(parts, docPart{text: html.UnescapeString(raw), translate: true, quote: html.EscapeString})
+				continue
+			}
+		}
+		parts = append(parts, docPart{text: raw})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestEmailFormat(t *testing.T) {
+	checkFormats(t, emailFormat, []formatTest{
+		{
+			name: "plain text",
+			src: "From: a@example.com\n" +
+				"Subject: Re: Lunch\n" +
+				"Content-Type: text/plain; charset=utf-8\n" +
+				"Content-Transfer-Encoding: quoted-printable\n" +
+				"\n" +
+				"See you at noon.\n" +
+				"\n" +
+				"> Are you free?\n" +
+				"\n" +
+				"--=20\n" +
+				"Alice\n",
+			segments: []string{"Lunch", "See you at noon."},
+		},
+		{
+			name: "attachment",
+			src: "Content-Type: multipart/mixed; boundary=b\n" +
+				"\n" +
+				"--b\n" +
+				"Content-Type: text/plain; charset=utf-8\n" +
+				"Content-Transfer-Encoding: quoted-printable\n" +
+				"\n" +
+				"Hello.\n" +
+				"--b\n" +
+				"Content-Type: text/plain\n" +
+				"Content-Disposition: attachment; filename=a.txt\n" +
+				"\n" +
+				"Not translated.\n" +
+				"--b--\n",
+			segments: []string{"Hello."},
+		},
+		{
+			name: "mbox",
+			src: "From a@example.com Mon Jan  1 00:00:00 2024\n" +
+				"Subject: One\n" +
+				"\n" +
+				"First.\n" +
+				"From b@example.com Mon Jan  1 00:00:00 2024\n" +
+				"Subject: Two\n" +
+				"\n" +
+				"Second.\n",
+			segments: []string{"One", "First.", "Two", "Second."},
+			want: "From a@example.com Mon Jan  1 00:00:00 2024\n" +
+				"Subject: One\n" +
+				"Content-Type: text/plain; charset=utf-8\n" +
+				"Content-Transfer-Encoding: quoted-printable\n" +
+				"\n" +
+				"First.\n" +
+				"From b@example.com Mon Jan  1 00:00:00 2024\n" +
+				"Subject: Two\n" +
+				"Content-Type: text/plain; charset=utf-8\n" +
+				"Content-Transfer-Encoding: quoted-printable\n" +
+				"\n" +
+				"Second.\n",
+		},
+	})
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	yamlFieldRe = regexp.MustCompile(`^([A-Za-z0-9_-]+)(\s*:[ \t]+)(.*?)[ \t]*$`)
+	tomlFieldRe = regexp.MustCompile(`^([A-Za-z0-9_-]+)(\s*=\s*)(.*?)[ \t]*$`)
+	// yamlBlockRe matches the header of a literal or folded block scalar.
+	yamlBlockRe = regexp.MustCompile(`^[|>][+-]?[0-9]?$`)
+)
+
+// frontMatterFields returns the fields of front matter to translate given by
+// -front-matter.
+func frontMatterFields() map[string]bool {
+	fields := map[string]bool{}
+	for _, f := range strings.Split(frontMatter, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields[f] = true
+		}
+	}
+	return fields
+}
+
+// frontMatterParts splits YAML (between "---") or TOML (between "+++") front
+// matter at the head of lines of a Markdown document, and returns its parts and
+// the rest of lines. Only the string values of the top-level fields given by
+// -front-matter are translated, and the others such as dates, tags and slugs
+// are kept as is.
+func frontMatterParts(lines []string) ([]docPart, []string) {
+	if len(lines) == 0 {
+		return nil, lines
+	}
+	open := strings.TrimSpace(lines[0])
+	if open != "---" && open != "+++" {
+		return nil, lines
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if t := strings.TrimSpace(lines[i]); t == open || open == "---" && t == "..." {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, lines
+	}
+	fields := frontMatterFields()
+	parts := []docPart{{text: lines[0]}}
+	var (
+		header string   // header of the block scalar of a field to translate
+		block  []string // lines of the block scalar
+	)
+	for _, line := range lines[1:end] {
+		body := strings.TrimRight(line, "\r\n")
+		nl := line[len(body):]
+		if header != "" && (strings.TrimSpace(body) == "" || body[0] == ' ' || body[0] == '\t') {
+			block = append(block, line)
+			continue
+		}
+		if header != "" {
+			parts = append(parts, yamlBlockParts(block, strings.HasPrefix(header, ">"))...)
+			header, block = "", nil
+		}
+		var field []docPart
+		if open == "---" {
+			field, header = yamlField(body, fields)
+		} else {
+			field = tomlField(body, fields)
+		}
+		if field == nil {
+			parts = append(parts, docPart{text: line})
+			continue
+		}
+		parts = append(append(parts, field...), docPart{text: nl})
+	}
+	if header != "" {
+		parts = append(parts, yamlBlockParts(block, strings.HasPrefix(header, ">"))...)
+	}
+	parts = append(parts, docPart{text: lines[end]})
+	return parts, lines[end+1:]
+}
+
+// yamlField returns the parts of a line of YAML if it's a field to translate,
+// and the header of its value, e.g. "|", if it's a block scalar in the
+// following lines.
+func yamlField(line string, fields map[string]bool) ([]docPart, string) {
+	m := yamlFieldRe.FindStringSubmatchIndex(line)
+	if m == nil || !fields[line[m[2]:m[3]]] {
+		return nil, ""
+	}
+	key, value := line[:m[5]], line[m[6]:m[7]]
+	rest := line[m[7]:]
+	switch {
+	case yamlBlockRe.MatchString(value):
+		return []docPart{{text: line}}, value
+	case strings.HasPrefix(value, `"`):
+		i := closingQuote(value)
+		if i < 0 {
+			return nil, ""
+		}
+		s, err := strconv.Unquote(value[:i+1])
+		if err != nil || strings.TrimSpace(s) == "" {
+			return nil, ""
+		}
+		return quotedField(key, s, value[i+1:]+rest, strconv.Quote), ""
+	case strings.HasPrefix(value, "'"):
+		i := strings.Index(strings.ReplaceAll(value[1:], "''", "  "), "'")
+		if i < 0 {
+			return nil, ""
+		}
+		s := strings.ReplaceAll(value[1:i+1], "''", "'")
+		if strings.TrimSpace(s) == "" {
+			return nil, ""
+		}
+		return quotedField(key, s, value[i+2:]+rest, func(s string) string {
+			return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+		}), ""
+	case strings.ContainsAny(value[:1], "[{&*!%@`"):
+		// Flow collections, anchors, aliases, tags and reserved indicators
+		return nil, ""
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value, rest = value[:i], value[i:]+rest
+	}
+	if isYAMLNonString(value) {
+		return nil, ""
+	}
+	return quotedField(key, value, rest, yamlScalar), ""
+}
+
+// yamlBlockParts returns the parts of the lines of a block scalar, which is
+// translated as a segment and indented again, so that sentences broken across
+// lines are translated whole. Lines of a folded scalar are joined with spaces
+// as YAML folds them. Blank lines at the end are kept as is.
+func yamlBlockParts(lines []string, folded bool) []docPart {
+	blank := func(l string) bool { return strings.TrimSpace(l) == "" }
+	start, end := 0, len(lines)
+	for start < end && blank(lines[start]) {
+		start++
+	}
+	for end > start && blank(lines[end-1]) {
+		end--
+	}
+	var parts []docPart
+	for _, l := range lines[:start] {
+		parts = append(parts, docPart{text: l})
+	}
+	if start < end {
+		first := strings.TrimRight(lines[start], "\r\n")
+		nl := lines[start][len(first):]
+		indent := first[:len(first)-len(strings.TrimLeft(first, " \t"))]
+		var b strings.Builder
+		for i, l := range lines[start:end] {
+			l = strings.TrimRight(l, "\r\n")
+			switch {
+			case i == 0:
+			case folded && !blank(l) && !blank(lines[start+i-1]):
+				b.WriteString(" ")
+			default:
+				b.WriteString("\n")
+			}
+			if !blank(l) {
+				b.WriteString(strings.TrimPrefix(l, indent))
+			}
+		}
+		parts = append(parts,
+			docPart{text: indent},
+			docPart{text: b.String(), translate: true, quote: func(s string) string {
+				ls := strings.Split(s, "\n")
+				for i := 1; i < len(ls); i++ {
+					if strings.TrimSpace(ls[i]) != "" {
+						ls[i] = indent + ls[i]
+					}
+				}
+				return strings.Join(ls, nl)
+			}},
+			docPart{text: nl},
+		)
+	}
+	for _, l := range lines[end:] {
+		parts = append(parts, docPart{text: l})
+	}
+	return parts
+}
+
+// tomlField returns the parts of a line of TOML if it's a field of a string to
+// translate.
+func tomlField(line string, fields map[string]bool) []docPart {
+	m := tomlFieldRe.FindStringSubmatchIndex(line)
+	if m == nil || !fields[line[m[2]:m[3]]] {
+		return nil
+	}
+	key, value := line[:m[5]], line[m[6]:m[7]]
+	rest := line[m[7]:]
+	switch {
+	case strings.HasPrefix(value, `"""`), strings.HasPrefix(value, "'''"):
+		// Multi-line strings
+		return nil
+	case strings.HasPrefix(value, `"`):
+		i := closingQuote(value)
+		if i < 0 {
+			return nil
+		}
+		s, err := strconv.Unquote(value[:i+1])
+		if err != nil || strings.TrimSpace(s) == "" {
+			return nil
+		}
+		return quotedField(key, s, value[i+1:]+rest, tomlQuote)
+	case strings.HasPrefix(value, "'"):
+		i := strings.Index(value[1:], "'")
+		if i < 0 || strings.TrimSpace(value[1:i+1]) == "" {
+			return nil
+		}
+		return quotedField(key, value[1:i+1], value[i+2:]+rest, func(s string) string {
+			if strings.ContainsAny(s, "'\n") {
+				return tomlQuote(s)
+			}
+			return "'" + s + "'"
+		})
+	}
+	return nil
+}
+
+// quotedField returns the parts of a field whose value s is written by quote
+// after translation.
+func quotedField(key, s, rest string, quote func(string) string) []docPart {
+	return []docPart{
+		{text: key},
+		{text: s, translate: true, quote: quote},
+		{text: rest},
+	}
+}
+
+// closingQuote returns the index of the double quote closing the string at the
+// head of s, or -1 if it's not closed.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+var yamlNonStringRe = regexp.MustCompile(`^(?:~|null|true|false|yes|no|on|off|[-+]?[0-9][0-9_.:eE+-]*)$`)
+
+// isYAMLNonString reports whether the plain scalar s is not a string, e.g. a
+// number, a boolean or a date.
+func isYAMLNonString(s string) bool {
+	return yamlNonStringRe.MatchString(strings.ToLower(s))
+}
+
+// yamlScalar returns s as a plain scalar of YAML, or double-quoted if it would
+// be read otherwise.
+func yamlScalar(s string) string {
+	if s == "" || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` \t") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") ||
+		strings.HasSuffix(s, ":") || strings.HasSuffix(s, " ") ||
+		strings.ContainsAny(s, "\n\r\t") || isYAMLNonString(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+var tomlEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// tomlQuote returns s as a basic string of TOML.
+func tomlQuote(s string) string {
+	return `"` + tomlEscaper.Replace(s) + `"`
+}
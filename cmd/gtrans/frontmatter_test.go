@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func TestFrontMatter(t *testing.T) {
+	frontMatter = "title,description"
+	checkFormats(t, markdownFormat, []formatTest{
+		{
+			name:     "yaml plain",
+			src:      "---\ntitle: Hello world\ndate: 2020-01-02\ntags: [go]\n---\n# Body\n",
+			segments: []string{"Hello world", "Body"},
+		},
+		{
+			name:     "yaml quoted",
+			src:      "---\ntitle: \"Say \\\"hi\\\"\"\ndescription: 'It''s here' # note\n---\n",
+			segments: []string{`Say "hi"`, "It's here"},
+		},
+		{
+			name:     "yaml comment",
+			src:      "---\ntitle: Hello # greeting\n---\n",
+			segments: []string{"Hello"},
+		},
+		{
+			name: "yaml non-strings",
+			src:  "---\ntitle: 2020\ndescription: true\nslug: hello-world\n---\n",
+		},
+		{
+			name:     "yaml literal block",
+			src:      "---\ndescription: |\n  First line.\n  Second line.\n\ntitle: Hi\n---\n",
+			segments: []string{"First line.\nSecond line.", "Hi"},
+		},
+		{
+			name:     "yaml folded block",
+			src:      "---\ndescription: >-\n  A sentence broken\n  across lines.\n---\n",
+			segments: []string{"A sentence broken across lines."},
+			want:     "---\ndescription: >-\n  A sentence broken across lines.\n---\n",
+		},
+		{
+			name:     "yaml dots",
+			src:      "---\ntitle: Hello\n...\n",
+			segments: []string{"Hello"},
+		},
+		{
+			name:     "toml",
+			src:      "+++\ntitle = \"Hello world\"\ndescription = 'Literal'\ndate = 2020-01-02\n+++\n",
+			segments: []string{"Hello world", "Literal"},
+		},
+		{
+			name: "toml multi-line",
+			src:  "+++\ntitle = \"\"\"\nHello\n\"\"\"\n+++\n",
+		},
+		{
+			name:     "crlf",
+			src:      "---\r\ntitle: Hello\r\n---\r\n",
+			segments: []string{"Hello"},
+		},
+		{
+			// A thematic break
+			name:     "unclosed",
+			src:      "---\ntitle: Hello\n",
+			segments: []string{"title: Hello"},
+		},
+		{
+			name:     "none",
+			src:      "title: Hello\n",
+			segments: []string{"title: Hello"},
+		},
+	})
+}
+
+func TestYAMLScalar(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hello world", "Hello world"},
+		{"Hello: world", `"Hello: world"`},
+		{"# Hello", `"# Hello"`},
+		{"yes", `"yes"`},
+		{"2020", `"2020"`},
+		{"line\nbreak", `"line\nbreak"`},
+		{"", `""`},
+	}
+	for _, tt := range tests {
+		if got := yamlScalar(tt.in); got != tt.want {
+			t.Errorf("yamlScalar(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minodisk/gtrans"
+)
+
+const usageMessage = "" +
+	`	gtrans translates input text specified by argument or STDIN using Google Translate.
+	Source language will be automatically detected.
+
+	export GOOGLE_TRANSLATE_API_KEY=<Your Google Translate API Key>
+
+	[optional]
+	export GOOGLE_TRANSLATE_LANG=<default target language (e.g. en, ja, ...)>
+	export GOOGLE_TRANSLATE_SECOND_LANG=<second language (e.g. en, ja, ...)>
+	export DEEPL_AUTH_KEY=<Your DeepL API Key (for -engine deepl)>
+	export OPENAI_API_KEY=<Your OpenAI API Key (for -engine openai)>
+
+	API keys can also be stored in the config file by 'gtrans auth <engine>',
+	and default values of flags by 'gtrans config set <flag> <value>'.
+
+	If you set both GOOGLE_TRANSLATE_LANG and GOOGLE_TRANSLATE_SECOND_LANG,
+	gtrans automatically switches target langage.
+
+	Translations are recorded in a local translation memory (see -tm), which
+	can be exchanged with CAT tools as TMX using -tm-import and -tm-export.
+
+	Example:
+		$ gtrans "Golang is awesome"
+		Golangは素晴らしいです
+		$ gtrans "Golangは素晴らしいです"
+		Golang is great
+		$ gtrans "Golangは素晴らしいです" | gtrans | gtrans | gtrans ...
+`
+
+var (
+	targetLang     string
+	doOpenBrowser  bool
+	tmPath         string
+	tmImport       string
+	tmExport       string
+	tmThreshold    float64
+	glossaryPath   string
+	preserveCase   bool
+	colorMode      string
+	bilingual      bool
+	engineName     string
+	outputFormat   string
+	withQuality    bool
+	minQuality     float64
+	onLowQuality   string
+	maskProfanity  bool
+	profanityList  string
+	redact         string
+	allowSecrets   bool
+	jsonlMode      bool
+	streamOutput   bool
+	streamInput    bool
+	jobs           int
+	batchSize      int
+	linesMode      bool
+	matchPattern   string
+	matchOnly      bool
+	inPlace        inPlaceFlag
+	undoEnabled    bool
+	excludes       excludeFlag
+	preHook        string
+	postHook       string
+	postFileHook   string
+	resumable      bool
+	progressMode   string
+	filePath       string
+	dirPath        string
+	outPath        string
+	plan           bool
+	force          bool
+	frontMatter    string
+	codeComments   bool
+	outputTemplate string
+	cacheLocation  string
+	cacheTTL       time.Duration
+	cacheMaxSize   byteSize
+	execCommand    string
+	offline        bool
+	localDetect    bool
+	onSameLang     string
+	onEmpty        string
+	onOfflineMiss  string
+	reportFormat   string
+	reportOut      string
+	listenMode     bool
+	audioFile      string
+	listenFor      time.Duration
+	speechEngine   string
+	speechLang     string
+	speakMode      bool
+	ttsEngine      string
+	voiceName      string
+	speechSpeed    float64
+	confirmBelow   float64
+	confirmChars   int
+	assumeYes      bool
+	maxChars       int
+	statsFormat    string
+	usageLog       bool
+	userAgent      string
+	requestHeaders headerFlag
+	compressMin    byteSize
+	maxIdleConns   int
+	connsPerHost   int
+	tlsMinVersion  string
+	dialTimeout    time.Duration
+	circuitErrors  int
+	circuitOpen    time.Duration
+)
+
+func init() {
+	flag.StringVar(&targetLang, "to", "", "target language")
+	flag.BoolVar(&doOpenBrowser, "open", false, "open Google Translate in browser instead of writing translated result to STDOUT")
+	flag.StringVar(&engineName, "engine", "google", "translation engine: "+strings.Join(gtrans.BuiltinEngineNames(), ", ")+" or a plugin (see 'gtrans engines list')")
+	flag.StringVar(&execCommand, "exec-command", "", "shell command of -engine exec, which reads a JSON request from STDIN and writes a JSON response (default: $GTRANS_EXEC_COMMAND)")
+	flag.StringVar(&outputFormat, "output-format", "text", "output format: text, json or tsv (source, detected language, target language and translation)")
+	flag.BoolVar(&withQuality, "quality", false, "estimate quality of the translation by back-translation (costs another API call)")
+	flag.Float64Var(&minQuality, "min-quality", 0, "flag translations whose estimated quality (0-1) is lower than this. Implies -quality")
+	flag.StringVar(&onLowQuality, "on-low-quality", "warn", "what to do with translations below -min-quality: warn or fail")
+	flag.BoolVar(&maskProfanity, "mask-profanity", false, "mask profanity in the translated text")
+	flag.StringVar(&profanityList, "profanity-list", "", "file of additional profane words to mask, one per line")
+	flag.StringVar(&redact, "redact", "", "redact sensitive information before sending the text and restore it afterwards: pii (emails, phone, credit card and national ID numbers)")
+	flag.BoolVar(&allowSecrets, "allow-secrets", false, "send input even if it looks like it contains API keys, private keys or tokens")
+	flag.BoolVar(&jsonlMode, "jsonl", false, `read newline-delimited JSON records ({"id": ..., "text": ..., "to": ...}) from STDIN and write one JSON result per line`)
+	flag.BoolVar(&streamInput, "stream", false, "translate STDIN in chunks as it's read and write each translated chunk, so that large input isn't buffered")
+	flag.BoolVar(&streamOutput, "stream-output", false, "write translated text as it arrives with engines which support streaming (openai, ollama, claude, gemini)")
+	flag.BoolVar(&linesMode, "lines", false, "translate each line of STDIN separately, writing a result per line")
+	flag.StringVar(&matchPattern, "match", "", "translate only the lines of STDIN matching the `regexp`, writing the others as they are. Implies -lines")
+	flag.BoolVar(&matchOnly, "match-only", false, "write only the translations of the lines matching -match, leaving out the others")
+	flag.IntVar(&jobs, "jobs", 1, "number of batches of records translated concurrently in -jsonl and -lines modes, or files in -dir mode")
+	flag.IntVar(&batchSize, "batch-size", 100, "maximum number of records or lines sent in a batch in -jsonl and -lines modes")
+	flag.BoolVar(&resumable, "resumable", false, "save -jsonl input and progress as a job which can be resumed by 'gtrans resume <job-id>' if interrupted")
+	flag.StringVar(&progressMode, "progress", "", "progress report on STDERR in batch modes: none, bar or json (default: bar if STDERR is a terminal)")
+	flag.StringVar(&filePath, "file", "", "translate the file keeping the structure of its format told by the extension, e.g. Markdown or LaTeX (plain text if unsupported)")
+	flag.StringVar(&dirPath, "dir", "", "translate the supported files under the directory into -out")
+	flag.Var(&inPlace, "i", "translate the files given as arguments in place like sed -i, keeping the originals as backups with the suffix if given, e.g. -i.bak (* in it is replaced with the file name, e.g. -i'bak/*')")
+	flag.BoolVar(&undoEnabled, "undo", true, "record the previous contents of the files -file -out, -dir, -i and 'gtrans site' write, which 'gtrans undo' restores")
+	flag.Var(&excludes, "exclude", "skip the files and directories under -dir matching the `pattern` of .gtransignore (the syntax of .gitignore), e.g. vendor/ or *.min.js. Can be given multiple times")
+	flag.StringVar(&preHook, "pre-hook", "", "shell `command` replacing each text before it's translated, e.g. to strip tracking codes, with the text on STDIN and the replacement on STDOUT. $1 is the target language and $2 the engine")
+	flag.StringVar(&postHook, "post-hook", "", "shell `command` replacing each translated text, e.g. a style fixer, with the translation on STDIN and the replacement on STDOUT. $1 is the target language and $2 the engine")
+	flag.StringVar(&postFileHook, "post-file-hook", "", "shell `command` replacing each file translated by -file, -dir or -i before it's written, with the translation on STDIN and the replacement on STDOUT. $1 is the target language and $2 the path of the file")
+	flag.StringVar(&outPath, "out", "", "output file of -file (default: STDOUT) or output directory of -dir")
+	flag.BoolVar(&plan, "plan", false, "list the files and segments -file or -dir would translate or skip without calling any API")
+	flag.StringVar(&frontMatter, "front-matter", "title,description", "comma separated top-level fields of the front matter of Markdown files to translate")
+	flag.BoolVar(&codeComments, "translate-code-comments", false, "translate comment lines in fenced code blocks of Markdown files by the languages of the blocks, keeping the code")
+	flag.BoolVar(&listenMode, "listen", false, "record speech from the microphone, transcribe it and translate the transcript")
+	flag.StringVar(&audioFile, "audio", "", "transcribe the speech in the audio file and translate the transcript, as -listen")
+	flag.DurationVar(&listenFor, "listen-for", 10*time.Second, "maximum `duration` of recording of -listen, which stops at silence after the speech with sox")
+	flag.StringVar(&speechEngine, "stt", "auto", "speech-to-text engine of -listen and -audio: auto (openai if OPENAI_API_KEY is set), google or openai")
+	flag.StringVar(&speechLang, "speech-lang", "", "language of the speech of -listen and -audio, e.g. ja-JP (default: en-US with google, detected with openai)")
+	flag.BoolVar(&speakMode, "speak", false, "read the translated text aloud. Synthesized speech is cached by the text, the voice and the speed")
+	flag.StringVar(&ttsEngine, "tts", "auto", "text-to-speech engine of -speak: auto (openai if OPENAI_API_KEY is set), google or openai")
+	flag.StringVar(&voiceName, "voice", "", "voice of -speak, e.g. ja-JP-Neural2-B with google or nova with openai (default: the default voice of the language, or alloy)")
+	flag.Float64Var(&speechSpeed, "speed", 1, "speed of -speak, from 0.25 to 4")
+	flag.BoolVar(&force, "force", false, "translate files again even if their translated files are up to date")
+	flag.StringVar(&statsFormat, "stats", "", "write statistics of the run (characters, API calls, cache hits, elapsed time and per-engine breakdown) to STDERR at the end: text or json")
+	flag.StringVar(&userAgent, "user-agent", "", "User-Agent of requests to the APIs of engines")
+	requestHeaders = headerFlag{}
+	flag.Var(requestHeaders, "header", "add a header to requests to the APIs of engines, e.g. for an API gateway: `Name: value`. Can be given multiple times")
+	compressMin = 64 << 10
+	flag.Var(&compressMin, "compress-min", "compress request bodies of at least `size` to engines with gzip, e.g. large batches, unless they reject it. Zero disables it")
+	flag.IntVar(&maxIdleConns, "max-idle-conns", 64, "maximum idle connections to an API host kept for reuse")
+	flag.IntVar(&connsPerHost, "max-conns-per-host", 0, "maximum connections to an API host, e.g. for a strict proxy. Zero is unlimited")
+	flag.StringVar(&tlsMinVersion, "tls-min-version", "1.2", "minimum TLS version of connections to APIs: 1.0, 1.1, 1.2 or 1.3")
+	flag.DurationVar(&dialTimeout, "dial-timeout", 30*time.Second, "timeout of connecting to an API host. Zero is no timeout")
+	flag.IntVar(&circuitErrors, "circuit-errors", 5, "skip requests to an engine for -circuit-open after this many consecutive errors of it, falling back to the default engine from routes. Zero never skips them")
+	flag.DurationVar(&circuitOpen, "circuit-open", 30*time.Second, "how long to skip requests to an engine after -circuit-errors")
+	flag.BoolVar(&usageLog, "usage-log", true, "record daily counts of API calls, characters, engines and language pairs locally for 'gtrans stats'. Nothing is sent anywhere")
+	flag.StringVar(&reportFormat, "report", "", "write a summary of the -jsonl, -file or -dir run with a bilingual table per file: markdown")
+	flag.StringVar(&reportOut, "report-out", "", "file to write -report to (default: STDERR)")
+	flag.StringVar(&outputTemplate, "template", "", "Go text/template (or @file) to format the result with fields .Source, .Translation, .SourceLang, .TargetLang, .Engine and .Confidence")
+	flag.StringVar(&cacheLocation, "cache", defaultCacheDir(), "directory or redis:// URL to cache engine responses in. Empty disables the cache")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 0, "expire cached responses after `duration`, e.g. 720h. Zero keeps them forever")
+	flag.Var(&cacheMaxSize, "cache-max-size", "evict the least recently used responses while the cache directory is larger than `size`, e.g. 500M. Zero is unlimited")
+	flag.Float64Var(&confirmBelow, "confirm-below", 0.5, "ask which language the input is written in if its detection is less confident (0-1) than this, when STDIN is a terminal. Zero never asks")
+	flag.IntVar(&confirmChars, "confirm-chars", 100000, "ask before sending more characters than this to paid engines in a run, or fail without a terminal. Zero never asks")
+	flag.BoolVar(&assumeYes, "yes", false, "send any number of characters without asking (see -confirm-chars)")
+	flag.IntVar(&maxChars, "max-chars", 0, "stop sending texts to paid engines once the characters sent in a run would exceed this, reporting what is not translated. Zero is unlimited")
+	flag.BoolVar(&localDetect, "local-detect", true, "detect the source language for GOOGLE_TRANSLATE_SECOND_LANG and routes without calling the engine, unless unsure")
+	flag.StringVar(&onEmpty, "on-empty", "skip", "what to do with empty or whitespace-only input: skip (write nothing) or fail")
+	flag.StringVar(&onSameLang, "on-same-lang", "skip", "what to do with input already written in the target language (detected locally): skip (echo it), notice (echo it with a notice on STDERR) or translate")
+	flag.BoolVar(&offline, "offline", false, "answer only from the cache and the translation memory without calling any API")
+	flag.StringVar(&onOfflineMiss, "on-offline-miss", "fail", "what to do with texts not found in -offline mode: fail or pass (write them untranslated)")
+	flag.StringVar(&tmPath, "tm", defaultTMPath(), "translation memory file (TMX). Empty disables translation memory")
+	flag.StringVar(&tmImport, "tm-import", "", "import TMX file into translation memory and exit")
+	flag.StringVar(&tmExport, "tm-export", "", "export translation memory to TMX file and exit")
+	flag.StringVar(&glossaryPath, "glossary", "", "glossary CSV/TSV file (source,target[,case_sensitive][,regex]) of terms to translate consistently")
+	flag.BoolVar(&preserveCase, "preserve-case", true, "restore ALL CAPS, Title Case and leading capitals of the input in the translated text")
+	flag.StringVar(&colorMode, "color", "auto", "colorize output: auto, always or never")
+	flag.BoolVar(&bilingual, "bilingual", false, "write the input text along with the translated text")
+	flag.Float64Var(&tmThreshold, "tm-threshold", 1, "minimum similarity (0-1) of a translation memory match to reuse instead of calling the API")
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:\tgtrans [flags] [input text]")
+	fmt.Fprint(os.Stderr, commandUsage())
+	fmt.Fprint(os.Stderr, usageMessage, "\n")
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func Main(r io.Reader, w io.Writer, args []string, targetLang string, doOpenBrowser bool) error {
+	if targetLang == "" {
+		var err error
+		targetLang, err = detectTargetLang()
+		if err != nil {
+			return err
+		}
+	}
+
+	if !plan && (inPlace.set || dirPath != "" || filePath != "" && outPath != "") {
+		startUndoJournal()
+	}
+	if inPlace.set {
+		if filePath != "" {
+			args = append([]string{filePath}, args...)
+		}
+		return runInPlace(w, args, targetLang)
+	}
+	if jsonlMode {
+		return runJSONL(r, w, targetLang)
+	}
+	if linesMode || matchPattern != "" {
+		return runLines(r, w, targetLang)
+	}
+	if dirPath != "" {
+		return runDir(w, dirPath, outPath, targetLang)
+	}
+	if filePath != "" {
+		return runFile(w, filePath, outPath, targetLang)
+	}
+
+	if onEmpty != "skip" && onEmpty != "fail" {
+		return fmt.Errorf("invalid -on-empty %q: must be skip or fail", onEmpty)
+	}
+	if listenMode || audioFile != "" {
+		return runListen(w, targetLang)
+	}
+	if f, ok := r.(*os.File); ok && len(args) == 0 && isTerminal(f) {
+		fmt.Fprintln(os.Stderr, "gtrans: type text to translate and press Ctrl-D (Ctrl-Z and Enter on Windows)")
+	}
+	if streamInput {
+		if len(args) > 0 || doOpenBrowser || outputFormat != "text" || outputTemplate != "" || bilingual || streamOutput {
+			return errors.New("-stream translates STDIN into text, and can't be used with input text, -open, -output-format, -template, -bilingual or -stream-output")
+		}
+		return runStreamTranslation(r, w, targetLang)
+	}
+	text, err := readInput(r, args)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(text) == "" {
+		return emptyInput()
+	}
+
+	if doOpenBrowser {
+		return openGoogleTranslate(w, targetLang, text)
+	}
+	return runTranslation(w, targetLang, text)
+}
+
+// errEmptyInput is returned for empty or whitespace-only input with
+// -on-empty fail.
+var errEmptyInput = errors.New("no input text. Give it by arguments or STDIN")
+
+// emptyInput returns what to do with empty or whitespace-only input, which is
+// never sent to the engine.
+func emptyInput() error {
+	if onEmpty == "fail" {
+		return errEmptyInput
+	}
+	return nil
+}
+
+// readInput returns args joined with spaces, or the content of r if there are
+// no args.
+func readInput(r io.Reader, args []string) (string, error) {
+	if text := strings.Join(args, " "); text != "" {
+		return text, nil
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// https://translate.google.com/#auto/{lang}/{input}
+func openGoogleTranslate(w io.Writer, targetLang, text string) error {
+	u := fmt.Sprintf("https://translate.google.com/#auto/%s/%s", targetLang, url.QueryEscape(text))
+	return openURL(u)
+}
+
+func runTranslation(w io.Writer, targetLang, text string) error {
+	color, err := newColorizer(colorMode, w)
+	if err != nil {
+		return err
+	}
+	var opts []gtrans.Option
+	if confirmBelow > 0 && isTerminal(os.Stdin) && isTerminal(os.Stderr) {
+		opts = append(opts, gtrans.WithSourceConfirmation(confirmBelow, promptSourceLang(os.Stdin, os.Stderr)))
+	}
+	streamed := false
+	if streamOutput && outputFormat == "text" {
+		opts = append(opts, gtrans.WithStreamOutput(func(s string) {
+			if !streamed && bilingual {
+				fmt.Fprintln(w, color.original(strings.TrimRight(text, "\n")))
+			}
+			streamed = true
+			fmt.Fprint(w, color.translation(s))
+		}))
+	}
+	c, err := newClient(opts...)
+	if err != nil {
+		return err
+	}
+	r, err := c.Translate(context.Background(), text, targetLang)
+	if err != nil {
+		return err
+	}
+	if streamed {
+		fmt.Fprintln(w)
+	} else if err := writeResult(w, outputFormat, color, r); err != nil {
+		return err
+	}
+	if err := checkQuality(r); err != nil {
+		return err
+	}
+	if speakMode && strings.TrimSpace(r.Translation) != "" {
+		if err := speak(context.Background(), r.Translation, r.TargetLang); err != nil {
+			return err
+		}
+	}
+	return c.Close()
+}
+
+// transferTM imports a TMX file into and/or exports a TMX file from the
+// translation memory at path.
+func transferTM(w io.Writer, path, importFile, exportFile string) error {
+	if path == "" {
+		return errors.New("translation memory is disabled. Please specify -tm")
+	}
+	tm, err := gtrans.LoadTranslationMemory(path)
+	if err != nil {
+		return err
+	}
+	if importFile != "" {
+		f, err := os.Open(importFile)
+		if err != nil {
+			return err
+		}
+		n, err := tm.Import(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("fail to import %s: %v", importFile, err)
+		}
+		if err := tm.Save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "imported %d translation units from %s\n", n, importFile)
+	}
+	if exportFile != "" {
+		f, err := os.Create(exportFile)
+		if err != nil {
+			return err
+		}
+		if err := tm.Export(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "exported %d translation units to %s\n", len(tm.Units), exportFile)
+	}
+	return nil
+}
+
+// defaultTMPath returns $XDG_DATA_HOME/gtrans/memory.tmx (or
+// ~/.local/share/gtrans/memory.tmx).
+func defaultTMPath() string {
+	dir := gtransDataDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "memory.tmx")
+}
+
+func gtransDataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "gtrans")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share", "gtrans")
+}
+
+func detectTargetLang() (string, error) {
+	if code := os.Getenv("GOOGLE_TRANSLATE_LANG"); code != "" {
+		return code, nil
+	}
+	for _, env := range []string{"LANGUAGE", "LC_ALL", "LANG"} {
+		code := langCodeFromLocale(os.Getenv(env))
+		if code != "" {
+			return code, nil
+		}
+	}
+	return "", errors.New("cannot detect language. Please export $LANG or $GOOGLE_TRANSLATE_LANG (e.g. en, ja)")
+}
+
+// https://en.wikipedia.org/wiki/Locale_(computer_software)
+func langCodeFromLocale(locale string) string {
+	if strings.HasPrefix(locale, "zh_CN") || strings.HasPrefix(locale, "zh_SG") {
+		return "zh-CN"
+	}
+
+	// Regions using Chinese Traditional: Taiwan, Hong Kong
+	if strings.HasPrefix(locale, "zh_TW") || strings.HasPrefix(locale, "zh_HK") {
+		return "zh-TW"
+	}
+
+	i := strings.Index(locale, "_")
+	if i == -1 {
+		return ""
+	}
+
+	return locale[:i]
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// Translated files embed the hash of their source in a comment, so that they
+// are not translated again unless the source changes. The hashes of files
+// whose format has no comment syntax are recorded in a manifest file in the
+// output directory instead.
+const hashManifestName = ".gtrans-sums"
+
+var sourceHashRe = regexp.MustCompile(`gtrans-source-sha256: ([0-9a-f]{64})`)
+
+// sourceHash returns the hash of a source translated into targetLang.
+func sourceHash(src []byte, targetLang string) string {
+	h := sha256.New()
+	h.Write([]byte(targetLang))
+	h.Write([]byte{0})
+	h.Write(src)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashManifest records source hashes of translated files under dir.
+type hashManifest struct {
+	mu     sync.Mutex
+	dir    string
+	hashes map[string]string // path relative to dir -> hash
+	dirty  bool
+}
+
+func loadHashManifest(dir string) (*hashManifest, error) {
+	m := &hashManifest{dir: dir, hashes: map[string]string{}}
+	b, err := ioutil.ReadFile(filepath.Join(dir, hashManifestName))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &m.hashes); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *hashManifest) key(path string) string {
+	if rel, err := filepath.Rel(m.dir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// outputHash returns the source hash of the translated file at path, or an
+// empty string if it's unknown.
+func (m *hashManifest) outputHash(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	if sub := sourceHashRe.FindSubmatch(b); sub != nil {
+		return string(sub[1])
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hashes[m.key(path)]
+}
+
+// embed returns the translated document of format f with the hash of its
+// source, or records the hash in m if f has no comment syntax.
+func (m *hashManifest) embed(f *docFormat, path string, b []byte, hash string) []byte {
+	if f.comment != nil {
+		if len(b) > 0 && b[len(b)-1] != '\n' {
+			b = append(b, '\n')
+		}
+		return append(b, f.comment("gtrans-source-sha256: "+hash)+"\n"...)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hashes[m.key(path)] = hash
+	m.dirty = true
+	return b
+}
+
+func (m *hashManifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.dirty {
+		return nil
+	}
+	b, err := json.MarshalIndent(m.hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(m.dir, hashManifestName), append(b, '\n'))
+}
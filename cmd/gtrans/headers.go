@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// headerFlag is a flag of headers which can be given multiple times as
+// "Name: value".
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	var lines []string
+	for name, values := range h {
+		for _, v := range values {
+			lines = append(lines, name+": "+v)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, ", ")
+}
+
+func (h headerFlag) Set(v string) error {
+	i := strings.Index(v, ":")
+	if i <= 0 {
+		return fmt.Errorf("invalid header %q: must be Name: value", v)
+	}
+	http.Header(h).Add(strings.TrimSpace(v[:i]), strings.TrimSpace(v[i+1:]))
+	return nil
+}
+
+// flagHeaders returns the extra headers of requests to engines in the config,
+// replaced by those of -header.
+func flagHeaders() http.Header {
+	h := http.Header{}
+	for name, v := range userConfig.Headers {
+		h.Set(name, v)
+	}
+	for name, values := range requestHeaders {
+		h[name] = values
+	}
+	return h
+}
+
+// flagEngineOptions returns the options of engines given by -user-agent,
+// -header, -compress-min, -exec-command and the config.
+func flagEngineOptions() []gtrans.Option {
+	opts := []gtrans.Option{
+		gtrans.WithFallbackCredentials(userConfig.Keys),
+		gtrans.WithCompression(int64(compressMin)),
+	}
+	if userAgent != "" {
+		opts = append(opts, gtrans.WithUserAgent(userAgent))
+	}
+	for name, values := range flagHeaders() {
+		for _, v := range values {
+			opts = append(opts, gtrans.WithHeader(name, v))
+		}
+	}
+	if execCommand != "" {
+		opts = append(opts, gtrans.WithExecCommand(execCommand))
+	}
+	return opts
+}
+
+// flagHTTPClient returns the client of the engines given by
+// flagEngineOptions, e.g. to call the speech and vision APIs of their
+// providers.
+func flagHTTPClient() *http.Client {
+	hc, err := gtrans.NewHTTPClient(flagEngineOptions()...)
+	if err != nil {
+		// The options given by flags are valid.
+		panic(err)
+	}
+	return hc
+}
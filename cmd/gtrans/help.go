@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+const helpOfUsageMessage = "" +
+	`Usage:	gtrans help-of [flags] -- <command> [args]
+	gtrans help-of runs the command with the arguments and --help, and writes
+	its help translated. The usage lines, the names of flags and commands and
+	examples are kept as they are, and the descriptions are wrapped again at
+	their columns, so that the options stay aligned.
+`
+
+// helpInlineRules protect flags, placeholders, environment variables, quoted
+// values and URLs in help messages.
+var helpInlineRules = []gtrans.ProtectRule{
+	{Kind: "code", Pattern: regexp.MustCompile("`[^`\n]+`")},
+	{Kind: "markup", Pattern: regexp.MustCompile(`\B--?[A-Za-z0-9][A-Za-z0-9_.-]*(?:=\S*)?|<[^<>\s][^<>]*>|\$\{?[A-Za-z_][A-Za-z0-9_]*\}?|\b[A-Z][A-Z0-9_]+\b|"[^"\n]*"|https?://\S+`)},
+}
+
+var (
+	// helpTermRe matches a line of a flag or a command followed by its
+	// description.
+	helpTermRe = regexp.MustCompile(`^([ \t]*)(\S.*?)(\t+|  +)(\S.*)$`)
+	// helpFlagRe matches a line of a flag whose description is on the next
+	// lines, e.g. of the Go flag package.
+	helpFlagRe = regexp.MustCompile(`^[ \t]*--?[A-Za-z0-9]`)
+	// ansiEscapeRe matches terminal escapes and overstrikes.
+	ansiEscapeRe = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]|.\x08`)
+)
+
+// helpTermMaxWidth is the maximum width of the flag or the command of a line
+// with its description.
+const helpTermMaxWidth = 40
+
+func runHelpOf(w io.Writer, args []string) error {
+	fs := flagSetWithGlobals("help-of")
+	helpFlag := fs.String("help-flag", "--help", "argument asking the command for its help")
+	width := fs.Int("width", 0, "width to wrap the descriptions at (default: the widest line of the help, at least 80)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), helpOfUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		return errors.New("a command is required")
+	}
+	target := targetLang
+	if target == "" {
+		var err error
+		if target, err = detectTargetLang(); err != nil {
+			return err
+		}
+	}
+	bin, err := lookCommand(args[0])
+	if err != nil {
+		return err
+	}
+	// Commands write their help to either STDOUT or STDERR, and often exit
+	// with an error.
+	var out bytes.Buffer
+	runErr := runCommand(context.Background(), bin, append(args[1:], *helpFlag), nil, &out, &out)
+	help := ansiEscapeRe.ReplaceAllString(out.String(), "")
+	if strings.TrimSpace(help) == "" {
+		if runErr != nil {
+			return fmt.Errorf("%s %s: %v", args[0], *helpFlag, runErr)
+		}
+		return fmt.Errorf("%s %s writes nothing", args[0], *helpFlag)
+	}
+	if *width <= 0 {
+		*width = 80
+		for _, l := range strings.Split(help, "\n") {
+			if lw := displayWidth(expandTabs(l)); lw > *width {
+				*width = lw
+			}
+		}
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	prog, err := newProgress(progressMode, os.Stderr, 0)
+	if err != nil {
+		return err
+	}
+	f := &docFile{path: args[0], rel: args[0], format: helpFormat}
+	parts := helpParts(help, filepath.Base(args[0]), *width)
+	translated, err := translateParts(parts, c.segmentTranslator(context.Background(), f, target, prog))
+	prog.Finish()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(translated); err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// helpFormat is the format of help messages of commands, which is not
+// selected by extensions.
+var helpFormat = &docFormat{
+	name:    "help",
+	protect: helpInlineRules,
+}
+
+// helpParts splits the help message of the command name into the parts
+// kept as is and the descriptions to translate, which are wrapped at width.
+func helpParts(src, name string, width int) []docPart {
+	var parts []docPart
+	raw := func(s string) { parts = append(parts, docPart{text: s}) }
+	lines := strings.SplitAfter(src, "\n")
+	indentOf := func(l string) string { return l[:len(l)-len(strings.TrimLeft(l, " \t"))] }
+	blank := func(l string) bool { return strings.TrimSpace(l) == "" }
+	// keep returns true if the line is kept as is, e.g. an example.
+	keep := func(l string) bool {
+		t := strings.TrimSpace(l)
+		return strings.HasPrefix(t, "$ ") || strings.HasPrefix(t, "# ") || t == name || strings.HasPrefix(t, name+" ") || helpFlagRe.MatchString(l) && !helpTermRe.MatchString(strings.TrimRight(l, "\r\n"))
+	}
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		body := strings.TrimRight(l, "\r\n")
+		indent := indentOf(body)
+		switch {
+		case blank(l) || keep(l):
+			raw(l)
+		case strings.HasPrefix(strings.ToLower(strings.TrimSpace(l)), "usage:"):
+			// Usage lines, and the ones following them more indented.
+			raw(l)
+			for i+1 < len(lines) && !blank(lines[i+1]) && len(indentOf(lines[i+1])) > len(indent) {
+				i++
+				raw(lines[i])
+			}
+		case indent == "" && strings.HasSuffix(body, ":"):
+			// A section title, e.g. "Options:"
+			parts = append(parts, docPart{text: body, translate: true})
+			raw(l[len(body):])
+		default:
+			prefix, desc, term := helpTerm(body)
+			if !term {
+				prefix, desc = indent, body[len(indent):]
+			}
+			// The description continues on the following lines at the same
+			// indentation for a paragraph, or indented more than the term,
+			// e.g. aligned to the column of the description.
+			cont := indent
+			if term {
+				cont = strings.Repeat(" ", displayWidth(expandTabs(prefix)))
+			}
+			for first := true; i+1 < len(lines) && !blank(lines[i+1]) && !keep(lines[i+1]); first = false {
+				next := strings.TrimRight(lines[i+1], "\r\n")
+				ni := indentOf(next)
+				if term && displayWidth(expandTabs(ni)) <= displayWidth(expandTabs(indent)) {
+					break
+				}
+				if _, _, ok := helpTerm(next); term && (ok || helpFlagRe.MatchString(next)) {
+					break
+				}
+				if !term {
+					// Short lines are broken on purpose.
+					if _, _, ok := helpTerm(next); ok || ni != indent || displayWidth(expandTabs(strings.TrimRight(lines[i], "\r\n"))) < width*2/3 {
+						break
+					}
+				}
+				if term && first {
+					cont = ni
+				}
+				desc += " " + strings.TrimSpace(next)
+				i++
+			}
+			raw(prefix)
+			parts = append(parts, docPart{text: desc, translate: true, quote: helpWrapper(prefix, cont, width)})
+			raw("\n")
+		}
+	}
+	return parts
+}
+
+// helpTerm splits a line of a flag or a command followed by its description.
+func helpTerm(line string) (prefix, desc string, ok bool) {
+	m := helpTermRe.FindStringSubmatch(line)
+	if m == nil || m[1] == "" && !strings.HasPrefix(m[2], "-") || displayWidth(expandTabs(m[1]+m[2])) > helpTermMaxWidth {
+		return "", "", false
+	}
+	return m[1] + m[2] + m[3], m[4], true
+}
+
+// helpWrapper returns the quote of docPart wrapping the translated text at
+// width, starting after prefix and indenting the following lines with cont.
+func helpWrapper(prefix, cont string, width int) func(string) string {
+	return func(s string) string {
+		first := width - displayWidth(expandTabs(prefix))
+		rest := width - displayWidth(expandTabs(cont))
+		if first < 20 {
+			first = 20
+		}
+		if rest < 20 {
+			rest = 20
+		}
+		lines := wrapText(strings.Join(strings.Fields(s), " "), first, rest)
+		return strings.Join(lines, "\n"+cont)
+	}
+}
+
+// wrapText wraps s at first columns for the first line and rest for the
+// others. Lines break at spaces, or around wide characters, e.g. of Japanese
+// written without spaces.
+func wrapText(s string, first, rest int) []string {
+	var (
+		lines []string
+		line  []rune
+		w     int
+		brk   = -1 // the line may break before line[brk]
+	)
+	width := first
+	for _, r := range s {
+		rw := displayWidth(string(r))
+		if rw == 2 {
+			brk = len(line)
+		}
+		if w+rw > width && len(line) > 0 && r != ' ' {
+			cut := brk
+			if cut <= 0 {
+				cut = len(line)
+			}
+			lines = append(lines, strings.TrimRight(string(line[:cut]), " "))
+			line = []rune(strings.TrimLeft(string(line[cut:]), " "))
+			w, brk, width = displayWidth(string(line)), -1, rest
+		}
+		line = append(line, r)
+		w += rw
+		if r == ' ' || rw == 2 {
+			brk = len(line)
+		}
+	}
+	return append(lines, strings.TrimRight(string(line), " "))
+}
+
+// expandTabs expands tabs in s to the next multiples of 8 columns.
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col += displayWidth(string(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/minodisk/gtrans"
+)
+
+// htmlInlineRules protect inline code, tags, comments and character
+// references in the runs of inline content of HTML documents.
+var htmlInlineRules = []gtrans.ProtectRule{
+	{Kind: "code", Pattern: regexp.MustCompile(`(?is)<code\b.*?</code>|<kbd\b.*?</kbd>|<samp\b.*?</samp>`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`(?s)<!--.*?-->|</?[A-Za-z][^>]*>|&(?:[A-Za-z][A-Za-z0-9]*|#[0-9]+|#[xX][0-9A-Fa-f]+);`)},
+}
+
+// htmlFormat translates the text of HTML documents keeping the markup. Each
+// run of inline content, e.g. a paragraph with links in it, is translated as
+// a whole with its tags protected, so that its sentences are translated in
+// context. The lang attribute of <html> is set to the target language.
+var htmlFormat = &docFormat{
+	name:    "html",
+	exts:    []string{".html", ".htm", ".xhtml"},
+	protect: htmlInlineRules,
+	comment: htmlComment,
+	translate: func(src []byte, tr segmentTranslator) ([]byte, error) {
+		return translateParts(htmlParts(string(src)), tr)
+	},
+	setLang: func(src []byte, lang string) []byte {
+		return []byte(rewriteHTMLHead(string(src), lang, ""))
+	},
+}
+
+// htmlRawElements are the elements whose content is kept as is.
+var htmlRawElements = map[string]bool{
+	"script": true, "style": true, "pre": true, "textarea": true, "template": true,
+	"svg": true, "math": true, "noscript": true, "head": true,
+}
+
+// htmlParts splits an HTML document into the runs of inline content to
+// translate and the rest kept as is. The title is translated as well.
+func htmlParts(src string) []docPart {
+	var (
+		parts []docPart
+		run   strings.Builder // inline content since the last block boundary
+		text  bool            // run has non-space text
+		raw   []string        // stack of the raw elements the token is in
+		title bool            // in <title>
+	)
+	flush := func() {
+		if run.Len() > 0 {
+			parts = append(parts, docPart{text: run.String(), translate: text})
+		}
+		run.Reset()
+		text = false
+	}
+	z := html.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			flush()
+			return parts
+		}
+		token := string(z.Raw())
+		name, _ := z.TagName()
+		tag := string(name)
+		if tag == "title" && (tt == html.StartTagToken || tt == html.EndTagToken) {
+			// The title in <head> is translated too.
+			flush()
+			title = tt == html.StartTagToken
+			parts = append(parts, docPart{text: token})
+			continue
+		}
+		if len(raw) > 0 && !title {
+			if tt == html.StartTagToken && htmlRawElements[tag] {
+				raw = append(raw, tag)
+			} else if tt == html.EndTagToken && tag == raw[len(raw)-1] {
+				raw = raw[:len(raw)-1]
+			}
+			parts = append(parts, docPart{text: token})
+			continue
+		}
+		switch tt {
+		case html.TextToken:
+			run.WriteString(token)
+			text = text || strings.TrimSpace(html.UnescapeString(token)) != ""
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			if tt == html.StartTagToken && htmlRawElements[tag] {
+				flush()
+				raw = append(raw, tag)
+				parts = append(parts, docPart{text: token})
+				continue
+			}
+			if blockElements[tag] || tag == "html" {
+				flush()
+				parts = append(parts, docPart{text: token})
+				continue
+			}
+			run.WriteString(token)
+		case html.CommentToken:
+			run.WriteString(token)
+		default:
+			flush()
+			parts = append(parts, docPart{text: token})
+		}
+	}
+}
+
+// rewriteHTMLHead sets the lang attribute of <html> to lang, declares the
+// charset as UTF-8, and adds <base href> of base to <head> unless base is
+// empty or it has one already.
+func rewriteHTMLHead(src, lang, base string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(src))
+	hasBase := strings.Contains(strings.ToLower(src), "<base ")
+	offset := 0 // of the end of the last token in src
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return b.String()
+		}
+		raw := string(z.Raw())
+		offset += len(raw)
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			b.WriteString(raw)
+			if name, _ := z.TagName(); tt == html.EndTagToken && string(name) == "head" {
+				// The rest of the document is written as it is.
+				return b.String() + src[offset:]
+			}
+			continue
+		}
+		t := z.Token()
+		switch t.Data {
+		case "html":
+			if lang != "" {
+				t.Attr = setHTMLAttr(t.Attr, "lang", lang)
+				if hasHTMLAttr(t.Attr, "xml:lang") {
+					t.Attr = setHTMLAttr(t.Attr, "xml:lang", lang)
+				}
+				raw = t.String()
+			}
+		case "meta":
+			if hasHTMLAttr(t.Attr, "charset") {
+				raw = `<meta charset="utf-8">`
+			} else if strings.EqualFold(htmlAttr(t.Attr, "http-equiv"), "content-type") {
+				raw = `<meta http-equiv="Content-Type" content="text/html; charset=utf-8">`
+			}
+		case "head":
+			if base != "" && !hasBase {
+				raw += `<base href="` + html.EscapeString(base) + `">`
+			}
+		}
+		b.WriteString(raw)
+	}
+}
+
+func htmlAttr(attrs []html.Attribute, key string) string {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasHTMLAttr(attrs []html.Attribute, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func setHTMLAttr(attrs []html.Attribute, key, val string) []html.Attribute {
+	for i, a := range attrs {
+		if a.Key == key {
+			attrs[i].Val = val
+			return attrs
+		}
+	}
+	return append(attrs, html.Attribute{Key: key, Val: val})
+}
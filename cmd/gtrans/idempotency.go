@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyStore keeps the responses of requests with an Idempotency-Key
+// header for ttl, so that a client retrying a request gets the response of
+// the first one instead of translating it again, and paying for it twice.
+// Keys are scoped by the API keys of the requests, so that a client can't
+// replay the responses of another. The oldest responses are forgotten when
+// there are idempotencyMaxEntries of them or idempotencyMaxBytes of bodies.
+type idempotencyStore struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse // by API key, method, path and key
+	order     []*idempotentResponse          // from the oldest
+	size      int                            // of the bodies
+}
+
+const (
+	idempotencyMaxEntries = 10000
+	idempotencyMaxBytes   = 64 << 20
+)
+
+// idempotentResponse is the response of a request with an Idempotency-Key.
+// done is closed when it's written.
+type idempotentResponse struct {
+	id      string
+	hash    [sha256.Size]byte // of the request body
+	created time.Time
+	done    chan struct{}
+	status  int
+	body    []byte
+}
+
+// begin returns the response of the earlier request with the key of r if
+// any, or registers r with the key and returns nil. The body of r is read
+// to compare requests, and replaced so that it can be read again.
+func (s *idempotencyStore) begin(r *http.Request, key string) (*idempotentResponse, error) {
+	b, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, 1<<20))
+	if err != nil {
+		return nil, badRequest("invalid request: %v", err)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	hash := sha256.Sum256(b)
+	id := idempotencyID(r, key)
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+	if res, ok := s.responses[id]; ok {
+		if res.hash != hash {
+			return nil, &httpError{status: http.StatusUnprocessableEntity, msg: "Idempotency-Key is already used for another request"}
+		}
+		return res, nil
+	}
+	if s.responses == nil {
+		s.responses = map[string]*idempotentResponse{}
+	}
+	res := &idempotentResponse{id: id, hash: hash, created: now, done: make(chan struct{})}
+	s.responses[id] = res
+	s.order = append(s.order, res)
+	return nil, nil
+}
+
+// idempotencyID returns the ID of the response of r with key.
+func idempotencyID(r *http.Request, key string) string {
+	owner := sha256.Sum256([]byte(apiKey(r)))
+	return hex.EncodeToString(owner[:]) + " " + r.Method + " " + r.URL.Path + " " + key
+}
+
+// prune forgets the responses older than the TTL, and the oldest ones while
+// the store is full. Responses in flight are kept. s.mu must be held.
+func (s *idempotencyStore) prune(now time.Time) {
+	for i := 0; i < len(s.order); {
+		res := s.order[i]
+		full := len(s.responses) >= idempotencyMaxEntries || s.size > idempotencyMaxBytes
+		if now.Sub(res.created) <= s.ttl && !full {
+			return
+		}
+		if !isClosed(res.done) {
+			i++
+			continue
+		}
+		if i == 0 {
+			s.order = s.order[1:]
+		} else {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+		}
+		if s.responses[res.id] == res {
+			delete(s.responses, res.id)
+			s.size -= len(res.body)
+		}
+	}
+}
+
+// finish stores the response of the request with the key of r begun by begin.
+// Responses of server errors aren't stored, so that the request can be
+// retried.
+func (s *idempotencyStore) finish(r *http.Request, key string, status int, body []byte) {
+	id := idempotencyID(r, key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := s.responses[id]
+	if res == nil {
+		return
+	}
+	res.status, res.body = status, body
+	if status >= 500 {
+		delete(s.responses, id)
+	} else {
+		s.size += len(body)
+	}
+	close(res.done)
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFileName is the name of the files listing the files and directories
+// -dir doesn't translate, in the syntax of .gitignore. Patterns in a file
+// under a subdirectory are relative to the subdirectory.
+const ignoreFileName = ".gtransignore"
+
+// excludeFlag is a flag of patterns of .gtransignore which can be given
+// multiple times.
+type excludeFlag []string
+
+func (f *excludeFlag) String() string { return strings.Join(*f, ", ") }
+
+func (f *excludeFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// ignoreRule is a pattern of .gtransignore.
+type ignoreRule struct {
+	base    string // slash-separated directory of the pattern, or "" for the root
+	re      *regexp.Regexp
+	negate  bool // the pattern starts with !
+	dirOnly bool // the pattern ends with /
+}
+
+// ignoreMatcher tells which files are ignored by the patterns of
+// .gtransignore files and -exclude.
+type ignoreMatcher struct {
+	rules []*ignoreRule
+}
+
+// addFile adds the patterns of the .gtransignore file in the directory rel
+// under the root, if any.
+func (m *ignoreMatcher) addFile(root, rel string) error {
+	f, err := os.Open(filepath.Join(root, rel, ignoreFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var patterns []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		patterns = append(patterns, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if rel == "." {
+		rel = ""
+	}
+	m.add(filepath.ToSlash(rel), patterns)
+	return nil
+}
+
+// add adds the patterns relative to the slash-separated directory base.
+func (m *ignoreMatcher) add(base string, patterns []string) {
+	for _, p := range patterns {
+		if r := parseIgnorePattern(p); r != nil {
+			r.base = base
+			m.rules = append(m.rules, r)
+		}
+	}
+}
+
+// ignored returns true if the file or the directory at the slash-separated
+// path rel is ignored. The last matching pattern wins.
+func (m *ignoreMatcher) ignored(rel string, dir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		sub := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			sub = rel[len(r.base)+1:]
+		}
+		if r.dirOnly && !dir || !r.re.MatchString(sub) {
+			continue
+		}
+		ignored = !r.negate
+	}
+	return ignored
+}
+
+// parseIgnorePattern parses a line of .gtransignore, or returns nil for a
+// blank line or a comment.
+func parseIgnorePattern(line string) *ignoreRule {
+	// Trailing spaces are ignored unless escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	r := &ignoreRule{}
+	if strings.HasPrefix(line, "!") {
+		r.negate, line = true, line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	// A pattern with a slash but at the end matches paths relative to its
+	// directory, and one without matches names at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case strings.HasPrefix(line[i:], "**/") && (i == 0 || line[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case line[i:] == "**" && i > 0 && line[i-1] == '/':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += 1 + end
+		case c == '\\' && i+1 < len(line):
+			i++
+			b.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		}
+	}
+	expr := b.String()
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil
+	}
+	r.re = re
+	return r
+}
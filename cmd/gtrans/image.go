@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// ocrBlock is a block of text recognized in an image.
+type ocrBlock struct {
+	Text   string    `json:"text"`
+	Bounds ocrBounds `json:"bounds"`
+}
+
+// ocrBounds is the bounding box of a block in pixels.
+type ocrBounds struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// imageBlock is the translation of a block written by -blocks.
+type imageBlock struct {
+	*gtrans.Result
+	Bounds ocrBounds `json:"bounds"`
+}
+
+const imageUsageMessage = "" +
+	`Usage:	gtrans image [flags] <path>
+	gtrans image recognizes the text in an image with OCR and translates it, with Cloud Vision API
+	if GOOGLE_TRANSLATE_API_KEY is set, or the tesseract command.
+`
+
+// ocrOptions are the flags of OCR of the image and capture commands.
+type ocrOptions struct {
+	engine string
+	langs  string
+	blocks bool
+}
+
+func (o *ocrOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.engine, "ocr", "auto", "OCR engine: auto, vision or tesseract")
+	fs.StringVar(&o.langs, "ocr-lang", "", "comma separated languages of the text as hints for OCR, e.g. ja,en")
+	fs.BoolVar(&o.blocks, "blocks", false, "translate the text blocks one by one and write them with their positions")
+}
+
+// translateImage recognizes the text in the image at path and translates it
+// to w.
+func (o *ocrOptions) translateImage(ctx context.Context, w io.Writer, path string) error {
+	img, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	found, err := recognizeText(ctx, o.engine, path, img, o.langs)
+	if err != nil {
+		return err
+	}
+	return translateBlocks(ctx, w, found, o.blocks)
+}
+
+func runImage(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("image", flag.ExitOnError)
+	var o ocrOptions
+	o.register(fs)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), imageUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("a path of an image is required")
+	}
+	return o.translateImage(context.Background(), w, args[0])
+}
+
+// recognizeText recognizes the text blocks in the image img at path with the
+// OCR engine.
+func recognizeText(ctx context.Context, engine, path string, img []byte, langs string) ([]ocrBlock, error) {
+	if engine == "auto" {
+		engine = "tesseract"
+		if credential("google", "GOOGLE_TRANSLATE_API_KEY") != "" {
+			engine = "vision"
+		}
+	}
+	var hints []string
+	if langs != "" {
+		hints = strings.Split(langs, ",")
+	}
+	switch engine {
+	case "vision":
+		key := credential("google", "GOOGLE_TRANSLATE_API_KEY")
+		if key == "" {
+			return nil, errors.New("GOOGLE_TRANSLATE_API_KEY is not set. Export it or run 'gtrans auth google'")
+		}
+		return visionOCR(ctx, flagHTTPClient(), "https://vision.googleapis.com/v1/images:annotate", key, img, hints)
+	case "tesseract":
+		return tesseractOCR(ctx, path, hints)
+	}
+	return nil, fmt.Errorf("invalid -ocr %q: must be auto, vision or tesseract", engine)
+}
+
+// translateBlocks translates the text of blocks to w, or each block with
+// -blocks.
+func translateBlocks(ctx context.Context, w io.Writer, blocks []ocrBlock, each bool) error {
+	var texts []string
+	for _, b := range blocks {
+		texts = append(texts, b.Text)
+	}
+	if strings.TrimSpace(strings.Join(texts, "")) == "" {
+		if onEmpty == "fail" {
+			return errors.New("no text is recognized in the image")
+		}
+		return nil
+	}
+	target := targetLang
+	if target == "" {
+		var err error
+		if target, err = detectTargetLang(); err != nil {
+			return err
+		}
+	}
+	if !each {
+		return runTranslation(w, target, strings.Join(texts, "\n\n"))
+	}
+	color, err := newColorizer(colorMode, w)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	for i, b := range blocks {
+		r, err := c.Translate(ctx, b.Text, target)
+		if err != nil {
+			return err
+		}
+		switch outputFormat {
+		case "json":
+			err = json.NewEncoder(w).Encode(imageBlock{Result: r, Bounds: b.Bounds})
+		case "text", "":
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "[%d,%d %dx%d]\n", b.Bounds.X, b.Bounds.Y, b.Bounds.Width, b.Bounds.Height)
+			err = writeResult(w, outputFormat, color, r)
+		default:
+			err = writeResult(w, outputFormat, color, r)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return c.Close()
+}
+
+// visionOCR recognizes the text blocks in img with Cloud Vision API.
+// https://cloud.google.com/vision/docs/fulltext-annotations
+func visionOCR(ctx context.Context, hc *http.Client, endpoint, key string, img []byte, hints []string) ([]ocrBlock, error) {
+	type request struct {
+		Image struct {
+			Content string `json:"content"`
+		} `json:"image"`
+		Features     []map[string]string `json:"features"`
+		ImageContext struct {
+			LanguageHints []string `json:"languageHints,omitempty"`
+		} `json:"imageContext"`
+	}
+	var r request
+	r.Image.Content = base64.StdEncoding.EncodeToString(img)
+	r.Features = []map[string]string{{"type": "DOCUMENT_TEXT_DETECTION"}}
+	r.ImageContext.LanguageHints = hints
+	body, err := json.Marshal(map[string][]request{"requests": {r}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint+"?key="+url.QueryEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to call Cloud Vision API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fail to call Cloud Vision API: %s", resp.Status)
+	}
+	type vertex struct{ X, Y int }
+	var result struct {
+		Responses []struct {
+			FullTextAnnotation struct {
+				Pages []struct {
+					Blocks []struct {
+						BoundingBox struct {
+							Vertices []vertex `json:"vertices"`
+						} `json:"boundingBox"`
+						Paragraphs []struct {
+							Words []struct {
+								Symbols []struct {
+									Text     string `json:"text"`
+									Property struct {
+										DetectedBreak struct {
+											Type string `json:"type"`
+										} `json:"detectedBreak"`
+									} `json:"property"`
+								} `json:"symbols"`
+							} `json:"words"`
+						} `json:"paragraphs"`
+					} `json:"blocks"`
+				} `json:"pages"`
+			} `json:"fullTextAnnotation"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"responses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("fail to decode Cloud Vision API response: %v", err)
+	}
+	if len(result.Responses) == 0 {
+		return nil, nil
+	}
+	if e := result.Responses[0].Error; e != nil {
+		return nil, fmt.Errorf("fail to call Cloud Vision API: %s", e.Message)
+	}
+	var blocks []ocrBlock
+	for _, page := range result.Responses[0].FullTextAnnotation.Pages {
+		for _, b := range page.Blocks {
+			var text strings.Builder
+			for _, p := range b.Paragraphs {
+				for _, w := range p.Words {
+					for _, s := range w.Symbols {
+						text.WriteString(s.Text)
+						switch s.Property.DetectedBreak.Type {
+						case "SPACE", "SURE_SPACE":
+							text.WriteString(" ")
+						case "EOL_SURE_SPACE", "LINE_BREAK":
+							text.WriteString("\n")
+						}
+					}
+				}
+			}
+			block := ocrBlock{Text: strings.TrimSpace(text.String())}
+			if vs := b.BoundingBox.Vertices; len(vs) > 0 {
+				// The vertices may be rotated.
+				minX, minY, maxX, maxY := vs[0].X, vs[0].Y, vs[0].X, vs[0].Y
+				for _, v := range vs[1:] {
+					if v.X < minX {
+						minX = v.X
+					}
+					if v.X > maxX {
+						maxX = v.X
+					}
+					if v.Y < minY {
+						minY = v.Y
+					}
+					if v.Y > maxY {
+						maxY = v.Y
+					}
+				}
+				block.Bounds = ocrBounds{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+			}
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+// tesseractLangs maps language codes to the ones of tesseract.
+var tesseractLangs = map[string]string{
+	"en": "eng", "ja": "jpn", "zh": "chi_sim", "zh-CN": "chi_sim", "zh-TW": "chi_tra",
+	"ko": "kor", "de": "deu", "fr": "fra", "es": "spa", "it": "ita", "pt": "por",
+	"ru": "rus", "ar": "ara", "nl": "nld", "pl": "pol", "tr": "tur", "vi": "vie",
+}
+
+// tesseractOCR recognizes the text blocks in the image at path with the
+// tesseract command.
+func tesseractOCR(ctx context.Context, path string, hints []string) ([]ocrBlock, error) {
+	bin, err := lookCommand("tesseract")
+	if err != nil {
+		return nil, errors.New("tesseract is not found. Install it or set GOOGLE_TRANSLATE_API_KEY to use Cloud Vision API")
+	}
+	args := []string{path, "stdout"}
+	if len(hints) > 0 {
+		langs := make([]string, len(hints))
+		for i, h := range hints {
+			langs[i] = h
+			if l, ok := tesseractLangs[h]; ok {
+				langs[i] = l
+			}
+		}
+		args = append(args, "-l", strings.Join(langs, "+"))
+	}
+	var stdout, stderr bytes.Buffer
+	if err := runCommand(ctx, bin, append(args, "tsv"), nil, &stdout, &stderr); err != nil {
+		return nil, fmt.Errorf("fail to run tesseract: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseTesseractTSV(stdout.String()), nil
+}
+
+// parseTesseractTSV returns the blocks in the TSV output of tesseract, whose
+// words are joined by lines.
+func parseTesseractTSV(tsv string) []ocrBlock {
+	type line struct{ par, line int }
+	var (
+		order  []int
+		blocks = map[int]*ocrBlock{}
+		words  = map[int]map[line][]string{}
+	)
+	for _, row := range strings.Split(tsv, "\n") {
+		cols := strings.Split(strings.TrimRight(row, "\r"), "\t")
+		if len(cols) < 12 {
+			continue
+		}
+		n := make([]int, 10)
+		valid := true
+		for i := range n {
+			var err error
+			if n[i], err = strconv.Atoi(cols[i]); err != nil {
+				valid = false
+				break
+			}
+		}
+		if !valid {
+			// The header
+			continue
+		}
+		level, page, block := n[0], n[1], n[2]
+		id := page<<16 | block
+		switch level {
+		case 2:
+			order = append(order, id)
+			blocks[id] = &ocrBlock{Bounds: ocrBounds{X: n[6], Y: n[7], Width: n[8], Height: n[9]}}
+			words[id] = map[line][]string{}
+		case 5:
+			if text := strings.TrimSpace(cols[11]); text != "" && words[id] != nil {
+				l := line{n[3], n[4]}
+				words[id][l] = append(words[id][l], text)
+			}
+		}
+	}
+	var result []ocrBlock
+	for _, id := range order {
+		var lines []line
+		for l := range words[id] {
+			lines = append(lines, l)
+		}
+		sort.Slice(lines, func(i, j int) bool {
+			return lines[i].par < lines[j].par || lines[i].par == lines[j].par && lines[i].line < lines[j].line
+		})
+		var texts []string
+		for _, l := range lines {
+			texts = append(texts, strings.Join(words[id][l], " "))
+		}
+		if len(texts) == 0 {
+			continue
+		}
+		b := blocks[id]
+		b.Text = strings.Join(texts, "\n")
+		result = append(result, *b)
+	}
+	return result
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inPlaceFlag is -i, which translates files in place like sed -i, optionally
+// with the suffix of their backups.
+type inPlaceFlag struct {
+	set    bool
+	suffix string
+}
+
+func (f *inPlaceFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.suffix
+}
+
+func (f *inPlaceFlag) Set(s string) error {
+	switch s {
+	case "true":
+		f.set = true
+	case "false":
+		f.set, f.suffix = false, ""
+	default:
+		f.set, f.suffix = true, s
+	}
+	return nil
+}
+
+// IsBoolFlag lets -i be given without a suffix.
+func (f *inPlaceFlag) IsBoolFlag() bool { return true }
+
+// expandInPlaceFlag rewrites -i followed by a suffix as sed takes it, e.g.
+// -i.bak, into -i=.bak, which the flag package takes. Arguments after "--"
+// are kept as they are.
+func expandInPlaceFlag(args []string) []string {
+	expanded := make([]string, len(args))
+	copy(expanded, args)
+	for i, a := range expanded {
+		if a == "--" {
+			break
+		}
+		for _, prefix := range []string{"--i", "-i"} {
+			if rest := strings.TrimPrefix(a, prefix); rest != a && rest != "" && strings.IndexByte(".~_-*/", rest[0]) >= 0 {
+				expanded[i] = prefix + "=" + rest
+				break
+			}
+		}
+	}
+	return expanded
+}
+
+// backupPath returns the path of the backup of the file at path with suffix,
+// in which * is replaced with the name of the file like sed, e.g. bak/*.
+func backupPath(path, suffix string) string {
+	if strings.Contains(suffix, "*") {
+		return filepath.Join(filepath.Dir(path), strings.Replace(suffix, "*", filepath.Base(path), -1))
+	}
+	return path + suffix
+}
+
+// runInPlace translates the files at paths, keeping the structure of their
+// formats as -file does, and replaces them with the translations. With the
+// suffix of -i, the original files are kept as backups.
+func runInPlace(w io.Writer, paths []string, targetLang string) error {
+	if len(paths) == 0 {
+		return errors.New("-i requires files to translate")
+	}
+	if outPath != "" || dirPath != "" {
+		return errors.New("-i can't be used with -out or -dir")
+	}
+	var files []*docFile
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", path)
+		}
+		f := &docFile{path: path, rel: path, out: path, format: formatOf(path)}
+		if f.format == nil {
+			f.format = plainTextFormat
+		}
+		files = append(files, f)
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	if plan {
+		return writePlan(w, c, files, targetLang)
+	}
+	prog, err := newProgress(progressMode, os.Stderr, 0)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, f := range files {
+		if err := c.translateInPlace(context.Background(), f, targetLang, prog); err != nil {
+			fmt.Fprintf(os.Stderr, "gtrans: %s: %v\n", f.path, err)
+			failed++
+		}
+	}
+	prog.Finish()
+	if err := c.report.Save(); err != nil {
+		return err
+	}
+	if err := c.Close(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("fail to translate %d files", failed)
+	}
+	return nil
+}
+
+// translateInPlace translates the file f and replaces it with the translation
+// atomically, keeping its permissions. The original file is written to the
+// backup first if -i has a suffix.
+func (c *client) translateInPlace(ctx context.Context, f *docFile, targetLang string, prog *progress) error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	translated, err := c.translateFile(ctx, f, targetLang, prog)
+	if err != nil {
+		return err
+	}
+	if inPlace.suffix != "" {
+		src, err := ioutil.ReadFile(f.path)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(backupPath(f.path, inPlace.suffix), src); err != nil {
+			return err
+		}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), "."+filepath.Base(f.path)+".gtrans-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(translated); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := journal.record(f.path); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return err
+	}
+	return journal.wrote(f.path, translated)
+}
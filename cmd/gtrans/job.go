@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// batchJob persists the input and the progress of a -jsonl run so that an
+// interrupted run can be resumed by `gtrans resume <job-id>` without
+// translating completed records again.
+//
+// A job is a directory containing job.json, input.jsonl and done.jsonl, which
+// records a result per line as records are completed.
+type batchJob struct {
+	ID         string    `json:"id"`
+	TargetLang string    `json:"target_lang"`
+	Engine     string    `json:"engine"`
+	Created    time.Time `json:"created"`
+
+	dir  string
+	done map[int]*jsonlResult
+	log  *os.File
+}
+
+type batchJobProgress struct {
+	Index  int          `json:"index"`
+	Result *jsonlResult `json:"result"`
+}
+
+func jobsDir() string {
+	return filepath.Join(gtransDataDir(), "jobs")
+}
+
+// createBatchJob creates a job whose input is read from r.
+func createBatchJob(r io.Reader, targetLang string) (*batchJob, error) {
+	b := make([]byte, 2)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	j := &batchJob{
+		ID:         now.Format("20060102-150405-") + hex.EncodeToString(b),
+		TargetLang: targetLang,
+		Engine:     engineName,
+		Created:    now,
+		done:       map[int]*jsonlResult{},
+	}
+	j.dir = filepath.Join(jobsDir(), j.ID)
+	if err := os.MkdirAll(j.dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filepath.Join(j.dir, "input.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	meta, err := json.Marshal(j)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(j.dir, "job.json"), meta, 0644); err != nil {
+		return nil, err
+	}
+	return j, j.openLog()
+}
+
+// loadBatchJob loads the job and its progress.
+func loadBatchJob(id string) (*batchJob, error) {
+	dir := filepath.Join(jobsDir(), filepath.Base(id))
+	meta, err := ioutil.ReadFile(filepath.Join(dir, "job.json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("job %s is not found. It may have been completed", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	j := &batchJob{dir: dir, done: map[int]*jsonlResult{}}
+	if err := json.Unmarshal(meta, j); err != nil {
+		return nil, fmt.Errorf("broken job %s: %v", id, err)
+	}
+	if f, err := os.Open(filepath.Join(dir, "done.jsonl")); err == nil {
+		s := bufio.NewScanner(f)
+		s.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for s.Scan() {
+			var p batchJobProgress
+			// The last line may be broken if the run is killed while
+			// writing it.
+			if json.Unmarshal(s.Bytes(), &p) == nil && p.Result != nil {
+				j.done[p.Index] = p.Result
+			}
+		}
+		f.Close()
+	}
+	return j, j.openLog()
+}
+
+func (j *batchJob) openLog() error {
+	f, err := os.OpenFile(filepath.Join(j.dir, "done.jsonl"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.log = f
+	return nil
+}
+
+func (j *batchJob) input() (*os.File, error) {
+	return os.Open(filepath.Join(j.dir, "input.jsonl"))
+}
+
+// completed returns the result of the record at index i if it has been
+// translated successfully.
+func (j *batchJob) completed(i int) *jsonlResult {
+	if r := j.done[i]; r != nil && r.Error == "" {
+		return r
+	}
+	return nil
+}
+
+// record persists the result of the record at index i.
+func (j *batchJob) record(i int, r *jsonlResult) error {
+	b, err := json.Marshal(&batchJobProgress{Index: i, Result: r})
+	if err != nil {
+		return err
+	}
+	_, err = j.log.Write(append(b, '\n'))
+	return err
+}
+
+// finish removes the job if all the records are translated successfully, or
+// keeps it for retrying failed records.
+func (j *batchJob) finish(failed int) error {
+	if err := j.log.Close(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "gtrans: %d records failed. Run `gtrans resume %s` to retry them\n", failed, j.ID)
+		return nil
+	}
+	return os.RemoveAll(j.dir)
+}
+
+// runResume resumes the job specified by args, or lists resumable jobs if
+// args is empty.
+func runResume(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return listBatchJobs(w)
+	}
+	if len(args) > 1 {
+		return errors.New("usage: gtrans resume [job-id]")
+	}
+	j, err := loadBatchJob(args[0])
+	if err != nil {
+		return err
+	}
+	if engineName == "google" && j.Engine != "" {
+		engineName = j.Engine
+	}
+	f, err := j.input()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return runBatchJob(f, w, j)
+}
+
+func listBatchJobs(w io.Writer) error {
+	entries, err := ioutil.ReadDir(jobsDir())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var jobs []*batchJob
+	for _, e := range entries {
+		meta, err := ioutil.ReadFile(filepath.Join(jobsDir(), e.Name(), "job.json"))
+		if err != nil {
+			continue
+		}
+		var j batchJob
+		if json.Unmarshal(meta, &j) == nil {
+			jobs = append(jobs, &j)
+		}
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Created.Before(jobs[k].Created) })
+	for _, j := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", j.ID, j.Engine, j.TargetLang)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/minodisk/gtrans"
+)
+
+// jsonlRecord is an input record in -jsonl mode. ID is passed through to the
+// result as is, so it can be any JSON value.
+type jsonlRecord struct {
+	ID   json.RawMessage `json:"id,omitempty"`
+	Text string          `json:"text"`
+	To   string          `json:"to,omitempty"`
+}
+
+// jsonlResult is an output record in -jsonl mode. Error is set if the record
+// can't be translated, or its translation is low quality and -on-low-quality
+// is fail.
+type jsonlResult struct {
+	ID json.RawMessage `json:"id,omitempty"`
+	*gtrans.Result
+	Error string `json:"error,omitempty"`
+}
+
+// runJSONL translates newline-delimited JSON records read from r and writes a
+// result per record to w in the order of the records, translating -jobs
+// records concurrently. Errors of each record are reported in its result
+// instead of aborting the stream.
+//
+// With -resumable, the input is saved as a batch job first, so that the run
+// can be resumed if it is interrupted.
+func runJSONL(r io.Reader, w io.Writer, targetLang string) error {
+	if !resumable {
+		return runBatchJob(r, w, &batchJob{TargetLang: targetLang})
+	}
+	j, err := createBatchJob(r, targetLang)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "gtrans: started job %s. Run `gtrans resume %s` if it is interrupted\n", j.ID, j.ID)
+	f, err := j.input()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return runBatchJob(f, w, j)
+}
+
+// jsonlLine is a line of -jsonl input with its index among non-empty lines.
+type jsonlLine struct {
+	index int
+	line  string
+}
+
+// runBatchJob translates the records read from r. If j is persisted, the
+// records completed before are not translated again and the progress is
+// recorded in j.
+func runBatchJob(r io.Reader, w io.Writer, j *batchJob) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	prog, err := newProgress(progressMode, os.Stderr, countRecords(r))
+	if err != nil {
+		return err
+	}
+	reportName := "STDIN"
+	if j.ID != "" {
+		reportName = "job " + j.ID
+	}
+	lines := make(chan interface{})
+	var readErr error
+	go func() {
+		defer close(lines)
+		br := bufio.NewReader(r)
+		for i := 0; ; {
+			line, err := br.ReadString('\n')
+			if err != nil && err != io.EOF {
+				readErr = err
+				return
+			}
+			if strings.TrimSpace(line) != "" {
+				lines <- &jsonlLine{index: i, line: line}
+				i++
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	}()
+	ctx := context.Background()
+	enc := json.NewEncoder(w)
+	failed := 0
+	// Records are translated in batches, so that short records are sent in
+	// as few requests as the engine allows.
+	err = orderedWorkers(jobs, coalesce(lines, batchSize, coalesceWait), func(item interface{}) interface{} {
+		batch := item.([]interface{})
+		ps := make([]*batchJobProgress, len(batch))
+		var reqs []gtrans.Request
+		var pending []int
+		for k, item := range batch {
+			l := item.(*jsonlLine)
+			if r := j.completed(l.index); r != nil {
+				c.report.Add(reportName, r.Result)
+				prog.Add(1, 0)
+				ps[k] = &batchJobProgress{Index: l.index, Result: r}
+				continue
+			}
+			req, res := parseJSONLRecord(l.line, j.TargetLang)
+			ps[k] = &batchJobProgress{Index: l.index, Result: res}
+			if res.Error != "" {
+				prog.Add(1, 0)
+				continue
+			}
+			reqs = append(reqs, req)
+			pending = append(pending, k)
+		}
+		for n, r := range c.TranslateBatch(ctx, reqs) {
+			res := ps[pending[n]].Result
+			finishJSONLRecord(res, r)
+			c.report.Add(reportName, res.Result)
+			prog.Add(1, charsSent(res.Result))
+		}
+		return ps
+	}, func(item interface{}) error {
+		for _, p := range item.([]*batchJobProgress) {
+			if p.Result.Error != "" {
+				failed++
+			}
+			if err := writeBatchResult(w, enc, p.Result); err != nil {
+				return err
+			}
+			if j.log == nil || j.completed(p.Index) != nil {
+				continue
+			}
+			if err := j.record(p.Index, p.Result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	prog.Finish()
+	if err != nil {
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if err := c.report.Save(); err != nil {
+		return err
+	}
+	if err := c.Close(); err != nil {
+		return err
+	}
+	if j.log != nil {
+		return j.finish(failed)
+	}
+	return nil
+}
+
+// writeBatchResult writes a result of a record as JSON, or as TSV if
+// -output-format is tsv, in which case errors are reported to STDERR.
+func writeBatchResult(w io.Writer, enc *json.Encoder, res *jsonlResult) error {
+	if outputFormat != "tsv" {
+		return enc.Encode(res)
+	}
+	if res.Error != "" {
+		fmt.Fprintf(os.Stderr, "gtrans: record %s: %s\n", res.ID, res.Error)
+		return nil
+	}
+	return writeResult(w, "tsv", nil, res.Result)
+}
+
+// charsSent returns the number of characters of the source text sent to the
+// engine for r.
+func charsSent(r *gtrans.Result) int {
+	if r == nil || r.Engine == "tm" {
+		return 0
+	}
+	return utf8.RuneCountInString(r.Source)
+}
+
+// parseJSONLRecord parses a line of -jsonl input into the request to
+// translate it, and its result without the translation. The result has an
+// error if the record is invalid.
+func parseJSONLRecord(line, targetLang string) (gtrans.Request, *jsonlResult) {
+	var rec jsonlRecord
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		return gtrans.Request{}, &jsonlResult{Error: "invalid record: " + err.Error()}
+	}
+	res := &jsonlResult{ID: rec.ID}
+	if rec.Text == "" {
+		res.Error = "text is empty"
+		return gtrans.Request{}, res
+	}
+	to := targetLang
+	if rec.To != "" {
+		to = rec.To
+	}
+	return gtrans.Request{Text: rec.Text, TargetLang: to}, res
+}
+
+// finishJSONLRecord sets the result of translating a record to res.
+func finishJSONLRecord(res *jsonlResult, r *gtrans.Result) {
+	if r.Err != nil {
+		res.Error = r.Err.Error()
+		return
+	}
+	res.Result = r
+	if err := checkQuality(r); err != nil {
+		res.Error = err.Error()
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// LaTeX inline markup which must not be translated: math, references,
+// citations, command names and escaped characters. The arguments of other
+// commands, e.g. \emph{...}, are translated.
+var latexInlineRules = []gtrans.ProtectRule{
+	{Kind: "math", Pattern: regexp.MustCompile(`(?s)\$\$.+?\$\$|\$(?:\\.|[^$\\])+\$|\\\(.+?\\\)|\\\[.+?\\\]`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`\\(?:label|ref|eqref|pageref|autoref|[cC]ref|nameref|cite[a-zA-Z]*|url|href|includegraphics|input|include)\*?(?:\[[^\]]*\])*\{[^}]*\}`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`\\[A-Za-z]+\*?(?:\[[^\]]*\])?|\\[^A-Za-z\s]|~`)},
+}
+
+var (
+	texBeginRe = regexp.MustCompile(`^\s*\\begin\{([^}]+)\}`)
+	texEndRe   = regexp.MustCompile(`^\s*\\end\{([^}]+)\}`)
+	// texTitleRe matches commands whose argument is a title to translate.
+	texTitleRe = regexp.MustCompile(`^(\s*\\(?:part|chapter|section|subsection|subsubsection|paragraph|subparagraph|caption|title|subtitle)\*?(?:\[[^\]]*\])?\{)`)
+	texItemRe  = regexp.MustCompile(`^(\s*\\item(?:\[[^\]]*\])?\s*)(.*)$`)
+	// texCommandLineRe matches lines of commands without prose.
+	texCommandLineRe = regexp.MustCompile(`^\s*\\(?:documentclass|usepackage|RequirePackage|label|includegraphics|centering|raggedright|raggedleft|maketitle|tableofcontents|listoffigures|listoftables|bibliography|bibliographystyle|printbibliography|addbibresource|newcommand|renewcommand|providecommand|newenvironment|def|let|input|include|vspace|hspace|vfill|hfill|newpage|clearpage|cleardoublepage|pagebreak|appendix|frontmatter|mainmatter|backmatter|setlength|addtolength|setcounter|graphicspath|hypersetup|author|date|thanks)\b`)
+)
+
+// texRawEnvs are the environments kept as is: math, code and drawings.
+var texRawEnvs = map[string]bool{
+	"equation": true, "align": true, "gather": true, "multline": true, "flalign": true,
+	"alignat": true, "eqnarray": true, "displaymath": true, "math": true, "split": true,
+	"verbatim": true, "Verbatim": true, "lstlisting": true, "minted": true, "comment": true,
+	"tikzpicture": true, "tabular": true, "tabularx": true, "array": true,
+}
+
+// latexFormat translates paragraphs, titles of sections and captions, and items
+// of LaTeX documents, keeping the preamble, commands, math, references,
+// citations, comments and verbatim environments as is, so that the translated
+// document compiles.
+var latexFormat = &docFormat{
+	name:    "latex",
+	exts:    []string{".tex", ".ltx"},
+	protect: latexInlineRules,
+	comment: func(s string) string { return "% " + s },
+	translate: func(src []byte, tr segmentTranslator) ([]byte, error) {
+		return translateParts(latexParts(string(src)), tr)
+	},
+}
+
+func latexParts(src string) []docPart {
+	var (
+		parts    []docPart
+		para     []string // lines of the current paragraph
+		prefix   string   // \item of the paragraph
+		env      string   // environment kept as is
+		display  string   // closing delimiter of the current display math
+		newline  string   // line ending of the last line of the paragraph
+		preamble = strings.Contains(src, `\begin{document}`)
+	)
+	raw := func(s string) { parts = append(parts, docPart{text: s}) }
+	// end writes the paragraph followed by tail, e.g. a newline or a comment
+	// at the end of the last line.
+	end := func(tail string) {
+		raw(prefix)
+		if len(para) > 0 {
+			parts = append(parts, docPart{text: strings.Join(para, " "), translate: true})
+		}
+		raw(tail)
+		para, prefix = nil, ""
+	}
+	flush := func() {
+		if len(para) > 0 || prefix != "" {
+			end(newline)
+		}
+	}
+	for _, line := range strings.SplitAfter(src, "\n") {
+		body := strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimSpace(body)
+		switch {
+		case env != "":
+			raw(line)
+			if strings.Contains(body, `\end{`+env+`}`) || strings.Contains(body, `\end{`+env+`*}`) {
+				env = ""
+			}
+		case display != "":
+			raw(line)
+			if strings.Contains(body, display) {
+				display = ""
+			}
+		case texTitleRe.MatchString(body):
+			flush()
+			open := len(texTitleRe.FindString(body))
+			close := braceEnd(body, open)
+			if close < 0 {
+				raw(line)
+				continue
+			}
+			raw(body[:open])
+			parts = append(parts, docPart{text: body[open:close], translate: true})
+			raw(line[close:])
+		case preamble:
+			raw(line)
+			if strings.HasPrefix(trimmed, `\begin{document}`) {
+				preamble = false
+			}
+		case trimmed == "", strings.HasPrefix(trimmed, "%"), texEndRe.MatchString(body), texCommandLineRe.MatchString(body):
+			flush()
+			raw(line)
+		case texBeginRe.MatchString(body):
+			flush()
+			raw(line)
+			name := texBeginRe.FindStringSubmatch(body)[1]
+			if base := strings.TrimSuffix(name, "*"); texRawEnvs[base] && !strings.Contains(body, `\end{`+name+`}`) {
+				env = base
+			}
+		case strings.HasPrefix(trimmed, `\[`), strings.HasPrefix(trimmed, "$$"):
+			flush()
+			raw(line)
+			open, close := `\[`, `\]`
+			if strings.HasPrefix(trimmed, "$$") {
+				open, close = "$$", "$$"
+			}
+			if !strings.Contains(trimmed[len(open):], close) {
+				display = close
+			}
+		case texItemRe.MatchString(body):
+			flush()
+			m := texItemRe.FindStringSubmatch(body)
+			prefix, para, newline = m[1], nil, line[len(body):]
+			addTeXLine(m[2], newline, &para, end)
+		default:
+			newline = line[len(body):]
+			addTeXLine(trimmed, newline, &para, end)
+		}
+	}
+	flush()
+	return parts
+}
+
+// addTeXLine adds a line of a paragraph to para. The paragraph ends at a
+// comment in the line, which would comment out the following lines if the
+// lines were joined.
+func addTeXLine(s, newline string, para *[]string, end func(tail string)) {
+	if i := texCommentIndex(s); i >= 0 {
+		text := strings.TrimRight(s[:i], " \t")
+		if text != "" {
+			*para = append(*para, text)
+		}
+		end(s[len(text):] + newline)
+		return
+	}
+	if s != "" {
+		*para = append(*para, s)
+	}
+}
+
+// texCommentIndex returns the index of the comment in s, or -1 if there is
+// none.
+func texCommentIndex(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '%':
+			return i
+		}
+	}
+	return -1
+}
+
+// braceEnd returns the index of the brace closing the group opened right
+// before s[start], or -1 if it's not closed.
+func braceEnd(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestLaTeXFormat(t *testing.T) {
+	checkFormats(t, latexFormat, []formatTest{
+		{
+			name:     "paragraphs",
+			src:      "First paragraph.\n\nSecond paragraph.\n",
+			segments: []string{"First paragraph.", "Second paragraph."},
+		},
+		{
+			name:     "lines joined",
+			src:      "A sentence broken\nacross lines.\n",
+			segments: []string{"A sentence broken across lines."},
+			want:     "A sentence broken across lines.\n",
+		},
+		{
+			name:     "preamble",
+			src:      "\\documentclass{article}\n\\usepackage{amsmath}\n\\title{On Things}\n\\begin{document}\nHello.\n\\end{document}\n",
+			segments: []string{"On Things", "Hello."},
+		},
+		{
+			name:     "titles",
+			src:      "\\section{Introduction}\\label{sec:intro}\n\\subsection*[Short]{A {nested} title}\n",
+			segments: []string{"Introduction", "A {nested} title"},
+		},
+		{
+			name:     "commands",
+			src:      "\\centering\n\\includegraphics{fig.png}\n\\caption{A figure}\n",
+			segments: []string{"A figure"},
+		},
+		{
+			name:     "items",
+			src:      "\\begin{itemize}\n  \\item First item\n  \\item[b)] Second item\n\\end{itemize}\n",
+			segments: []string{"First item", "Second item"},
+		},
+		{
+			name:     "raw environments",
+			src:      "\\begin{equation}\nE = mc^2\n\\end{equation}\n\\begin{verbatim}\nnot prose\n\\end{verbatim}\nProse.\n",
+			segments: []string{"Prose."},
+		},
+		{
+			name:     "display math",
+			src:      "Before.\n\\[\nx + y\n\\]\n$$a$$\nAfter.\n",
+			segments: []string{"Before.", "After."},
+		},
+		{
+			name:     "comments",
+			src:      "% a comment\nText % trailing\nmore text with 50\\% off.\n",
+			segments: []string{"Text", "more text with 50\\% off."},
+		},
+		{
+			name:     "no trailing newline",
+			src:      "\\section{Title}\nLast line.",
+			segments: []string{"Title", "Last line."},
+		},
+		{
+			name:     "crlf",
+			src:      "Hello.\r\n\r\nWorld.\r\n",
+			segments: []string{"Hello.", "World."},
+		},
+	})
+}
+
+func TestBraceEnd(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"abc}", 3},
+		{"a{b}c}", 5},
+		{`a\}b}`, 4},
+		{"abc", -1},
+	}
+	for _, tt := range tests {
+		if got := braceEnd(tt.s, 0); got != tt.want {
+			t.Errorf("braceEnd(%q, 0) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
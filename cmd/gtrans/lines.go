@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/minodisk/gtrans"
+)
+
+// coalesceWait is how long lines of -jsonl and -lines input are waited for
+// to fill a batch before sending it.
+const coalesceWait = 50 * time.Millisecond
+
+// passLine is a line of -lines input not matching -match, which is written
+// as it is.
+type passLine string
+
+// lineBatch is a batch of -lines input and the results of its lines to
+// translate.
+type lineBatch struct {
+	lines   []interface{}
+	results []*gtrans.Result
+}
+
+// runLines translates each line of r separately and writes a result per line
+// to w in the order of the lines, so that list-like input keeps its lines.
+// Lines are sent in batches, and -jobs batches are translated concurrently.
+// Errors of each line are reported to STDERR, writing an empty line instead.
+// With -match, only the lines matching it are translated, and the others are
+// written as they are, or left out with -match-only.
+func runLines(r io.Reader, w io.Writer, targetLang string) error {
+	var match *regexp.Regexp
+	if matchPattern != "" {
+		var err error
+		if match, err = regexp.Compile(matchPattern); err != nil {
+			return fmt.Errorf("invalid -match: %v", err)
+		}
+	} else if matchOnly {
+		return errors.New("-match-only requires -match")
+	}
+	color, err := newColorizer(colorMode, w)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	total := 0
+	if match == nil {
+		total = countRecords(r)
+	}
+	prog, err := newProgress(progressMode, os.Stderr, total)
+	if err != nil {
+		return err
+	}
+	lines := make(chan interface{})
+	var readErr error
+	go func() {
+		defer close(lines)
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil && err != io.EOF {
+				readErr = err
+				return
+			}
+			if line != "" {
+				line = strings.TrimRight(line, "\r\n")
+				switch {
+				case match == nil || match.MatchString(line):
+					lines <- line
+				case !matchOnly:
+					lines <- passLine(line)
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	}()
+	ctx := context.Background()
+	failed := 0
+	err = orderedWorkers(jobs, coalesce(lines, batchSize, coalesceWait), func(item interface{}) interface{} {
+		batch := item.([]interface{})
+		var reqs []gtrans.Request
+		for _, item := range batch {
+			if line, ok := item.(string); ok {
+				reqs = append(reqs, gtrans.Request{Text: line, TargetLang: targetLang})
+			}
+		}
+		results := c.TranslateBatch(ctx, reqs)
+		for _, r := range results {
+			if strings.TrimSpace(r.Source) != "" {
+				prog.Add(1, charsSent(r))
+			}
+		}
+		return &lineBatch{lines: batch, results: results}
+	}, func(item interface{}) error {
+		b := item.(*lineBatch)
+		results := b.results
+		for _, line := range b.lines {
+			if line, ok := line.(passLine); ok {
+				if _, err := fmt.Fprintln(w, string(line)); err != nil {
+					return err
+				}
+				continue
+			}
+			r := results[0]
+			results = results[1:]
+			err := r.Err
+			if err == nil {
+				err = checkQuality(r)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "gtrans: %s: %v\n", planSnippet(r.Source), err)
+				failed++
+				fmt.Fprintln(w)
+				continue
+			}
+			if err := writeResult(w, outputFormat, color, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	prog.Finish()
+	if err != nil {
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if err := c.Close(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("fail to translate %d lines", failed)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runListen transcribes the speech recorded from the microphone or in
+// -audio, and translates the transcript.
+func runListen(w io.Writer, targetLang string) error {
+	ctx := context.Background()
+	path := audioFile
+	if path == "" {
+		dir, err := ioutil.TempDir("", "gtrans-listen")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		path = filepath.Join(dir, "listen.wav")
+		fmt.Fprintf(os.Stderr, "gtrans: listening for up to %v. Speak now\n", listenFor)
+		if err := recordSpeech(ctx, path, listenFor); err != nil {
+			return err
+		}
+	}
+	audio, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	text, err := transcribe(ctx, speechEngine, path, audio, speechLang)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(text) == "" {
+		if onEmpty == "fail" {
+			return errors.New("no speech is recognized")
+		}
+		return nil
+	}
+	return runTranslation(w, targetLang, text)
+}
+
+// speechRecorders are the commands recording speech from the microphone into
+// a WAV file, tried in order. rec and sox stop at 1.5 seconds of silence after
+// the speech.
+var speechRecorders = []struct {
+	name string
+	args func(path, secs string) []string
+}{
+	{"rec", func(path, secs string) []string {
+		return append([]string{"-q", "-r", "16000", "-c", "1", "-b", "16", path}, soxEffects(secs)...)
+	}},
+	{"sox", func(path, secs string) []string {
+		return append([]string{"-q", "-d", "-r", "16000", "-c", "1", "-b", "16", path}, soxEffects(secs)...)
+	}},
+	{"arecord", func(path, secs string) []string {
+		return []string{"-q", "-f", "S16_LE", "-r", "16000", "-c", "1", "-d", secs, path}
+	}},
+	{"ffmpeg", func(path, secs string) []string {
+		input := []string{"-f", "pulse", "-i", "default"}
+		switch runtime.GOOS {
+		case "darwin":
+			input = []string{"-f", "avfoundation", "-i", ":0"}
+		case "windows":
+			input = []string{"-f", "dshow", "-i", "audio=default"}
+		}
+		args := append([]string{"-loglevel", "error", "-y"}, input...)
+		return append(args, "-t", secs, "-ac", "1", "-ar", "16000", path)
+	}},
+}
+
+func soxEffects(secs string) []string {
+	return []string{"silence", "1", "0.1", "1%", "1", "1.5", "1%", "trim", "0", secs}
+}
+
+// recordSpeech records speech from the microphone into the WAV file at path
+// for up to d.
+func recordSpeech(ctx context.Context, path string, d time.Duration) error {
+	secs := strconv.Itoa(int((d + time.Second - 1) / time.Second))
+	var names []string
+	for _, r := range speechRecorders {
+		names = append(names, r.name)
+		bin, err := lookCommand(r.name)
+		if err != nil {
+			continue
+		}
+		var stderr bytes.Buffer
+		if err := runCommand(ctx, bin, r.args(path, secs), nil, ioutil.Discard, &stderr); err != nil {
+			return fmt.Errorf("fail to record with %s: %v: %s", r.name, err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+	return fmt.Errorf("no recorder is found. Install one of %s, or give an audio file by -audio", strings.Join(names, ", "))
+}
+
+// transcribe returns the transcript of the speech in audio read from path
+// with the speech-to-text engine.
+func transcribe(ctx context.Context, engine, path string, audio []byte, lang string) (string, error) {
+	if engine == "auto" {
+		engine = "google"
+		if credential("openai", "OPENAI_API_KEY") != "" {
+			engine = "openai"
+		}
+	}
+	switch engine {
+	case "google":
+		key := credential("google", "GOOGLE_TRANSLATE_API_KEY")
+		if key == "" {
+			return "", errors.New("GOOGLE_TRANSLATE_API_KEY is not set. Export it or run 'gtrans auth google'")
+		}
+		if lang == "" {
+			lang = "en-US"
+		}
+		return googleTranscribe(ctx, flagHTTPClient(), "https://speech.googleapis.com/v1/speech:recognize", key, audio, lang)
+	case "openai":
+		key := credential("openai", "OPENAI_API_KEY")
+		if key == "" {
+			return "", errors.New("OPENAI_API_KEY is not set. Export it or run 'gtrans auth openai'")
+		}
+		baseURL := "https://api.openai.com/v1"
+		if u := os.Getenv("OPENAI_BASE_URL"); u != "" {
+			baseURL = strings.TrimRight(u, "/")
+		}
+		return openAITranscribe(ctx, flagHTTPClient(), baseURL+"/audio/transcriptions", key, filepath.Base(path), audio, lang)
+	}
+	return "", fmt.Errorf("invalid -stt %q: must be auto, google or openai", engine)
+}
+
+// googleTranscribe transcribes audio with Cloud Speech-to-Text API, which
+// reads the encoding from the header of WAV and FLAC files.
+// https://cloud.google.com/speech-to-text/docs/reference/rest/v1/speech/recognize
+func googleTranscribe(ctx context.Context, hc *http.Client, endpoint, key string, audio []byte, lang string) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"config": map[string]interface{}{"languageCode": lang, "enableAutomaticPunctuation": true},
+		"audio":  map[string]string{"content": base64.StdEncoding.EncodeToString(audio)},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint+"?key="+url.QueryEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fail to call Speech-to-Text API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fail to call Speech-to-Text API: %s", resp.Status)
+	}
+	var result struct {
+		Results []struct {
+			Alternatives []struct {
+				Transcript string `json:"transcript"`
+			} `json:"alternatives"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("fail to decode Speech-to-Text API response: %v", err)
+	}
+	var texts []string
+	for _, r := range result.Results {
+		if len(r.Alternatives) > 0 {
+			texts = append(texts, strings.TrimSpace(r.Alternatives[0].Transcript))
+		}
+	}
+	return strings.Join(texts, " "), nil
+}
+
+// openAITranscribe transcribes audio with OpenAI Audio API, which accepts
+// most audio formats.
+// https://platform.openai.com/docs/api-reference/audio/createTranscription
+func openAITranscribe(ctx context.Context, hc *http.Client, endpoint, key, name string, audio []byte, lang string) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		return "", err
+	}
+	fw.Write(audio)
+	mw.WriteField("model", "whisper-1")
+	if lang != "" {
+		// The language is in ISO-639-1, e.g. "en" of "en-US".
+		mw.WriteField("language", strings.SplitN(lang, "-", 2)[0])
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fail to call OpenAI Audio API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fail to call OpenAI Audio API: %s", resp.Status)
+	}
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("fail to decode OpenAI Audio API response: %v", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	openbrowser "github.com/haya14busa/go-openbrowser"
+	"github.com/minodisk/gtrans"
+)
+
+func main() {
+	flag.Usage = usage
+	cfg, err := loadConfig(configPath())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	userConfig = cfg
+	if err := cfg.applyFlags(flag.CommandLine); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// -i takes its suffix without "=" like sed -i.
+	os.Args = append(os.Args[:1], expandInPlaceFlag(os.Args[1:])...)
+	flag.Parse()
+	if err := tuneTransport(gtrans.SharedTransport()); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	run, args := translateArgs, flag.Args()
+	// Arguments after "--" are input text even if they start with a command
+	// name, e.g. gtrans -- detect.
+	consumed := len(os.Args) - 1 - len(args)
+	dashes := consumed > 0 && os.Args[consumed] == "--"
+	if cmd := lookupCommand(flag.Arg(0)); cmd != nil && !dashes {
+		run, args = cmd.run, args[1:]
+	}
+	if err := run(args); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func openURL(u string) error {
+	return openbrowser.Start(u)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const manUsageMessage = "" +
+	`Usage:	gtrans man [flags] [section] <name>
+	gtrans man translates the man page of name, e.g. a command, keeping its
+	sections, the tags of options and examples, and shows it with man or
+	mandoc, which pages it on a terminal. -raw writes the translated source
+	instead, which can be installed as a localized man page, e.g. into
+	/usr/local/share/man/ja/man1.
+`
+
+// manSoRe matches a man page including another page, e.g. an alias.
+var manSoRe = regexp.MustCompile(`^\.so\s+(\S+)\s*$`)
+
+func runMan(w io.Writer, args []string) error {
+	fs := flagSetWithGlobals("man")
+	raw := fs.Bool("raw", false, "write the translated roff source instead of showing it")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), manUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("the name of a man page is required")
+	}
+	target := targetLang
+	if target == "" {
+		if target, err = detectTargetLang(); err != nil {
+			return err
+		}
+	}
+	path, src, err := readManPage(context.Background(), args)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	prog, err := newProgress(progressMode, os.Stderr, 0)
+	if err != nil {
+		return err
+	}
+	f := &docFile{path: path, rel: filepath.Base(path), format: roffFormat}
+	translated, err := f.format.translate(src, c.segmentTranslator(context.Background(), f, target, prog))
+	prog.Finish()
+	if err != nil {
+		return err
+	}
+	if err := c.Close(); err != nil {
+		return err
+	}
+	if *raw {
+		_, err := w.Write(translated)
+		return err
+	}
+	return showManPage(w, translated)
+}
+
+// readManPage finds the man page of the arguments of gtrans man with man -w,
+// and returns its path and its decompressed source.
+func readManPage(ctx context.Context, args []string) (string, []byte, error) {
+	bin, err := lookCommand("man")
+	if err != nil {
+		return "", nil, errors.New("man is not found")
+	}
+	var stdout, stderr bytes.Buffer
+	if err := runCommand(ctx, bin, append([]string{"-w"}, args...), nil, &stdout, &stderr); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", nil, errors.New(msg)
+		}
+		return "", nil, fmt.Errorf("no man page of %s: %v", strings.Join(args, " "), err)
+	}
+	path := strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0])
+	src, err := readManFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	// Aliases include their pages relatively to the root of the manual.
+	if sub := manSoRe.FindSubmatch(bytes.TrimSpace(src)); sub != nil {
+		path = filepath.Join(filepath.Dir(filepath.Dir(path)), string(sub[1]))
+		if src, err = readManFile(path); err != nil {
+			return "", nil, err
+		}
+	}
+	return path, src, nil
+}
+
+// readManFile reads the man page at path, which may be compressed with gzip
+// or bzip2, or be the path without the extension of compression.
+func readManFile(path string) ([]byte, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		for _, ext := range []string{".gz", ".bz2"} {
+			if _, err := os.Stat(path + ext); err == nil {
+				path += ext
+				break
+			}
+		}
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch filepath.Ext(path) {
+	case ".gz":
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	case ".bz2":
+		return ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(b)))
+	case ".xz", ".lzma", ".Z", ".zst":
+		return nil, fmt.Errorf("%s is compressed with %s, which is not supported. Decompress it and run 'gtrans file'", path, filepath.Ext(path))
+	}
+	return b, nil
+}
+
+// showManPage formats the man page src with mandoc, or man of man-db, which
+// page it when w is a terminal.
+func showManPage(w io.Writer, src []byte) error {
+	formatters := []struct {
+		name string
+		args []string
+	}{
+		{"mandoc", []string{"-a"}},
+		{"man", []string{"-l", "-"}},
+	}
+	for _, f := range formatters {
+		bin, err := lookCommand(f.name)
+		if err != nil {
+			continue
+		}
+		args := f.args
+		if f.name == "mandoc" && !isTerminal(w) {
+			args = nil
+		}
+		return runCommand(context.Background(), bin, args, bytes.NewReader(src), w, os.Stderr)
+	}
+	return errors.New("neither mandoc nor man is found. Use -raw to write the translated source")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// notebookFormat translates the Markdown cells of Jupyter notebooks, keeping
+// code cells, outputs and metadata as is. The notebook is written with sorted
+// keys and an indent of a space like Jupyter.
+var notebookFormat = &docFormat{
+	name:      "notebook",
+	exts:      []string{".ipynb"},
+	protect:   markdownInlineRules,
+	translate: translateNotebook,
+}
+
+func translateNotebook(src []byte, tr segmentTranslator) ([]byte, error) {
+	var nb map[string]json.RawMessage
+	if err := json.Unmarshal(src, &nb); err != nil {
+		return nil, fmt.Errorf("fail to parse notebook: %v", err)
+	}
+	var cells []map[string]json.RawMessage
+	if err := json.Unmarshal(nb["cells"], &cells); err != nil {
+		return nil, fmt.Errorf("fail to parse notebook cells: %v", err)
+	}
+
+	// The parts of all the Markdown cells are translated at once.
+	var (
+		parts []docPart
+		md    []int  // indices of Markdown cells
+		ends  []int  // end of the parts of each Markdown cell
+		lines []bool // whether the source of each cell is a list of lines
+		// whether the source of each cell ends with a newline, which
+		// is added to paragraphs by markdownParts
+		newline []bool
+	)
+	for i, cell := range cells {
+		var typ string
+		if err := json.Unmarshal(cell["cell_type"], &typ); err != nil || typ != "markdown" {
+			continue
+		}
+		// The source is a string or a list of lines.
+		var (
+			text  string
+			split []string
+		)
+		if err := json.Unmarshal(cell["source"], &text); err != nil {
+			if err := json.Unmarshal(cell["source"], &split); err != nil {
+				return nil, fmt.Errorf("fail to parse source of cell %d: %v", i, err)
+			}
+			text = strings.Join(split, "")
+		}
+		parts = append(parts, markdownParts(text)...)
+		md, ends, lines = append(md, i), append(ends, len(parts)), append(lines, split != nil)
+		newline = append(newline, strings.HasSuffix(text, "\n"))
+	}
+	if len(md) == 0 {
+		return src, nil
+	}
+	texts, err := translatePartTexts(parts, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	start := 0
+	for k, i := range md {
+		text := strings.Join(texts[start:ends[k]], "")
+		start = ends[k]
+		if !newline[k] {
+			text = strings.TrimSuffix(text, "\n")
+		}
+		var source interface{} = text
+		if lines[k] {
+			split := strings.SplitAfter(text, "\n")
+			if split[len(split)-1] == "" {
+				split = split[:len(split)-1]
+			}
+			source = split
+		}
+		b, err := marshalNotebook(source)
+		if err != nil {
+			return nil, err
+		}
+		cells[i]["source"] = b
+	}
+	b, err := marshalNotebook(cells)
+	if err != nil {
+		return nil, err
+	}
+	nb["cells"] = b
+	b, err = marshalNotebook(nb)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, b, "", " "); err != nil {
+		return nil, err
+	}
+	return append(out.Bytes(), '\n'), nil
+}
+
+// marshalNotebook encodes v without escaping HTML characters, which are common
+// in Markdown cells.
+func marshalNotebook(v interface{}) (json.RawMessage, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
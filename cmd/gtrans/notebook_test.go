@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestNotebookFormat(t *testing.T) {
+	checkFormats(t, notebookFormat, []formatTest{
+		{
+			name: "cells",
+			src: `{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": [
+    "# Title\n",
+    "\n",
+    "Some <b>text</b>."
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": null,
+   "metadata": {},
+   "outputs": [],
+   "source": [
+    "# A comment\n",
+    "print(1)"
+   ]
+  },
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": "One line.\n"
+  }
+ ],
+ "metadata": {},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}
+`,
+			segments: []string{"Title", "Some <b>text</b>.", "One line."},
+		},
+		{
+			name: "no markdown cells",
+			src:  `{"cells": [], "nbformat": 4}`,
+		},
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// serveOpenAPI serves the OpenAPI document of the server generated from
+// routes, from which clients in other languages can be generated. It's served
+// without an API key.
+func (s *server) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	b, err := json.Marshal(s.openAPIDocument())
+	if err != nil {
+		status, body := errorResponse(r, err)
+		writeJSON(w, status, body)
+		return
+	}
+	writeJSON(w, http.StatusOK, append(b, '\n'))
+}
+
+func (s *server) openAPIDocument() map[string]interface{} {
+	schemas := map[string]interface{}{
+		"Error": map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"error": map[string]interface{}{"type": "string"}},
+			"required":   []string{"error"},
+		},
+	}
+	paths := map[string]interface{}{}
+	for _, rt := range routes {
+		op := map[string]interface{}{
+			"operationId": rt.id,
+			"summary":     rt.summary,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content":     jsonContent(schemaOf(reflect.TypeOf(rt.response), schemas)),
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content":     jsonContent(schemaRef("Error")),
+				},
+			},
+		}
+		var params []interface{}
+		for _, q := range rt.query {
+			params = append(params, map[string]interface{}{
+				"name":        q.name,
+				"in":          "query",
+				"description": q.description,
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		if rt.request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaOf(reflect.TypeOf(rt.request), schemas)),
+			}
+		}
+		if rt.method == "POST" && s.idempotency != nil {
+			params = append(params, map[string]interface{}{
+				"name":        "Idempotency-Key",
+				"in":          "header",
+				"description": "retries with the same key get the same response",
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		paths[rt.path] = map[string]interface{}{strings.ToLower(rt.method): op}
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "gtrans",
+			"version": "1",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+	if s.keys != nil {
+		doc["components"].(map[string]interface{})["securitySchemes"] = map[string]interface{}{
+			"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+		}
+		doc["security"] = []interface{}{
+			map[string]interface{}{"bearer": []string{}},
+			map[string]interface{}{"apiKey": []string{}},
+		}
+	}
+	return doc
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// schemaOf returns the JSON schema of t as encoding/json marshals it. Named
+// structs are added to schemas and referred to by their names.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := t.Name()
+		if name != "" {
+			// Exported names read better in generated clients.
+			name = strings.ToUpper(name[:1]) + name[1:]
+			if _, ok := schemas[name]; ok {
+				return schemaRef(name)
+			}
+			// Reserve the name for recursive types.
+			schemas[name] = nil
+		}
+		props := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if f.PkgPath != "" || tag == "-" {
+				continue
+			}
+			key := strings.Split(tag, ",")[0]
+			if key == "" {
+				key = f.Name
+			}
+			props[key] = schemaOf(f.Type, schemas)
+			if !strings.Contains(tag, ",omitempty") && f.Type.Kind() != reflect.Ptr {
+				required = append(required, key)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": props}
+		if required != nil {
+			schema["required"] = required
+		}
+		if name == "" {
+			return schema
+		}
+		schemas[name] = schema
+		return schemaRef(name)
+	}
+	// Interfaces may be any value.
+	return map[string]interface{}{}
+}
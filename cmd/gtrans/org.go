@@ -0,0 +1,167 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// Org inline markup which must not be translated: code, verbatim, the targets
+// of links (whose descriptions are translated), timestamps, footnote
+// references and macros.
+var orgInlineRules = []gtrans.ProtectRule{
+	{Kind: "markup", Pattern: regexp.MustCompile(`\B[~=][^\s~=][^~=\n]*[~=]\B|src_[A-Za-z0-9-]+(?:\[[^\]]*\])?\{[^}]*\}`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`\[\[[^\]\n]+\]\[|\[\[[^\]\n]+\]\]|\]\]`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`[<\[]\d{4}-\d{2}-\d{2}[^>\]\n]*[>\]]|\[fn:[^\]\n]*\]|\{\{\{[^}\n]+\}\}\}|https?://[^\s\[\]<>]+`)},
+}
+
+var (
+	orgHeadingRe  = regexp.MustCompile(`^(\*+\s+)(.*?)(\s+:[[:alnum:]_@#%:]+:)?\s*$`)
+	orgPriorityRe = regexp.MustCompile(`^\[#[A-Za-z0-9]\]\s*`)
+	orgKeywordRe  = regexp.MustCompile(`^(\s*#\+([A-Za-z_]+)(?:\[[^\]]*\])?:\s*)(.*)$`)
+	orgBeginRe    = regexp.MustCompile(`(?i)^\s*#\+begin_([a-z]+)`)
+	orgDrawerRe   = regexp.MustCompile(`^\s*:[A-Za-z_-]+:\s*$`)
+	orgPlanningRe = regexp.MustCompile(`^\s*(?:SCHEDULED|DEADLINE|CLOSED):`)
+	orgListRe     = regexp.MustCompile(`^(\s*(?:[-+]|\s\*|\d+[.)]|[A-Za-z][.)])\s+(?:\[[ xX-]\]\s+)?)(.*)$`)
+	orgTermRe     = regexp.MustCompile(`^(.*?)(\s+::\s+|\s+::$)(.*)$`)
+	orgTableSepRe = regexp.MustCompile(`^\s*\|[-+|]+\|?\s*$`)
+	orgTodoLineRe = regexp.MustCompile(`(?im)^#\+(?:seq_|typ_)?todo:(.*)$`)
+)
+
+// orgTodoKeywords are the default TODO keywords of headings, which are
+// extended by #+TODO: lines.
+var orgTodoKeywords = []string{"TODO", "DONE", "NEXT", "WAITING", "HOLD", "CANCELLED", "CANCELED"}
+
+// orgProseKeywords are the keywords whose values are translated.
+var orgProseKeywords = map[string]bool{"title": true, "subtitle": true, "description": true, "caption": true}
+
+// orgRawBlocks are the blocks whose contents are kept as is.
+var orgRawBlocks = map[string]bool{"src": true, "example": true, "export": true, "comment": true}
+
+// orgFormat translates headings, paragraphs, list items, table cells and
+// titles of Emacs Org documents, keeping TODO keywords, priorities and tags of
+// headings, property drawers, planning lines, source blocks, comments and
+// links as is.
+var orgFormat = &docFormat{
+	name:    "org",
+	exts:    []string{".org"},
+	protect: orgInlineRules,
+	comment: func(s string) string { return "# " + s },
+	translate: func(src []byte, tr segmentTranslator) ([]byte, error) {
+		return translateParts(orgParts(string(src)), tr)
+	},
+}
+
+func orgParts(src string) []docPart {
+	var (
+		parts    []docPart
+		para     []string // lines of the current paragraph
+		prefix   string   // list marker of the paragraph
+		rawBlock string   // name of the block kept as is
+		drawer   bool     // whether in a drawer
+		newline  string   // line ending of the last line of the paragraph
+	)
+	keywords := append([]string(nil), orgTodoKeywords...)
+	for _, m := range orgTodoLineRe.FindAllStringSubmatch(src, -1) {
+		keywords = append(keywords, strings.Fields(strings.Replace(m[1], "|", " ", -1))...)
+	}
+	raw := func(s string) { parts = append(parts, docPart{text: s}) }
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		raw(prefix)
+		parts = append(parts, docPart{text: strings.Join(para, " "), translate: true})
+		raw(newline)
+		para, prefix = nil, ""
+	}
+	for _, line := range strings.SplitAfter(src, "\n") {
+		body := strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimSpace(body)
+		nl := line[len(body):]
+		switch {
+		case rawBlock != "":
+			raw(line)
+			if strings.HasPrefix(strings.ToLower(trimmed), "#+end_"+rawBlock) {
+				rawBlock = ""
+			}
+		case drawer:
+			raw(line)
+			if strings.EqualFold(trimmed, ":END:") {
+				drawer = false
+			}
+		case trimmed == "":
+			flush()
+			raw(line)
+		case orgHeadingRe.MatchString(body):
+			flush()
+			m := orgHeadingRe.FindStringSubmatchIndex(body)
+			start, end := m[4], m[5]
+			// TODO keyword and priority
+			title := body[start:end]
+			for _, k := range keywords {
+				if title == k || strings.HasPrefix(title, k+" ") {
+					title = strings.TrimLeft(title[len(k):], " ")
+					break
+				}
+			}
+			title = strings.TrimPrefix(title, orgPriorityRe.FindString(title))
+			start = end - len(title)
+			raw(body[:start])
+			parts = append(parts, docPart{text: body[start:end], translate: true})
+			raw(body[end:] + line[len(body):])
+		case orgBeginRe.MatchString(body):
+			flush()
+			raw(line)
+			if name := strings.ToLower(orgBeginRe.FindStringSubmatch(body)[1]); orgRawBlocks[name] {
+				rawBlock = name
+			}
+		case orgKeywordRe.MatchString(body):
+			flush()
+			m := orgKeywordRe.FindStringSubmatch(body)
+			if !orgProseKeywords[strings.ToLower(m[2])] || m[3] == "" {
+				raw(line)
+				continue
+			}
+			raw(m[1])
+			parts = append(parts, docPart{text: m[3], translate: true})
+			raw(line[len(body):])
+		case strings.HasPrefix(trimmed, "#+"), trimmed == "#", strings.HasPrefix(trimmed, "# "),
+			trimmed == ":", strings.HasPrefix(trimmed, ": "), orgPlanningRe.MatchString(body):
+			// Other keywords, comments, fixed-width lines and planning
+			flush()
+			raw(line)
+		case orgDrawerRe.MatchString(body):
+			flush()
+			raw(line)
+			drawer = !strings.EqualFold(trimmed, ":END:")
+		case orgTableSepRe.MatchString(body):
+			flush()
+			raw(line)
+		case strings.HasPrefix(trimmed, "|"):
+			flush()
+			parts = append(parts, tableCells(line)...)
+		case orgListRe.MatchString(body):
+			flush()
+			m := orgListRe.FindStringSubmatch(body)
+			prefix, newline = m[1], nl
+			if t := orgTermRe.FindStringSubmatch(m[2]); t != nil {
+				// Description list
+				raw(prefix)
+				parts = append(parts, docPart{text: t[1], translate: true})
+				prefix, para = t[2], []string{t[3]}
+				if t[3] == "" {
+					raw(prefix + nl)
+					prefix, para = "", nil
+				}
+				continue
+			}
+			para = []string{m[2]}
+		default:
+			para, newline = append(para, trimmed), nl
+		}
+	}
+	flush()
+	return parts
+}
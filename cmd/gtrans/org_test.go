@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestOrgFormat(t *testing.T) {
+	checkFormats(t, orgFormat, []formatTest{
+		{
+			name:     "headings",
+			src:      "#+TITLE: My Notes\n* TODO [#A] Write the docs :work:\n** Section\n",
+			segments: []string{"My Notes", "Write the docs", "Section"},
+		},
+		{
+			name:     "paragraphs",
+			src:      "A sentence broken\nacross lines.\n\nAnother one.\n",
+			segments: []string{"A sentence broken across lines.", "Another one."},
+			want:     "A sentence broken across lines.\n\nAnother one.\n",
+		},
+		{
+			name:     "blocks and drawers",
+			src:      "* Task\n:PROPERTIES:\n:ID: 1\n:END:\n#+begin_src sh\nmake\n#+end_src\n",
+			segments: []string{"Task"},
+		},
+		{
+			name:     "description list",
+			src:      "- Term :: A definition.\n- Other ::\n",
+			segments: []string{"Term", "A definition.", "Other"},
+		},
+		{
+			name:     "no trailing newline",
+			src:      "* Title\nLast line.",
+			segments: []string{"Title", "Last line."},
+		},
+		{
+			name:     "crlf",
+			src:      "Hello.\r\n\r\nWorld.\r\n",
+			segments: []string{"Hello.", "World."},
+		},
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/minodisk/gtrans"
+)
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// parseOutputTemplate parses -template, which is a template text or @file.
+func parseOutputTemplate(text string) (*template.Template, error) {
+	if strings.HasPrefix(text, "@") {
+		b, err := ioutil.ReadFile(text[1:])
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+	t, err := template.New("output").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -template: %v", err)
+	}
+	return t, nil
+}
+
+// writeResult writes r to w in format, or executes -template if it's given.
+func writeResult(w io.Writer, format string, c *colorizer, r *gtrans.Result) error {
+	if outputTemplate != "" {
+		t, err := parseOutputTemplate(outputTemplate)
+		if err != nil {
+			return err
+		}
+		if err := t.Execute(w, r); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w)
+		return err
+	}
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(r)
+	case "tsv":
+		_, err := fmt.Fprintln(w, strings.Join([]string{
+			tsvEscape(r.Source),
+			tsvEscape(r.SourceLang),
+			tsvEscape(r.TargetLang),
+			tsvEscape(r.Translation),
+		}, "\t"))
+		return err
+	case "text", "":
+		if bilingual {
+			fmt.Fprintln(w, c.original(strings.TrimRight(r.Source, "\n")))
+		}
+		_, err := fmt.Fprintln(w, c.translation(r.Highlight(c.protected)))
+		return err
+	}
+	return fmt.Errorf("invalid -output-format %q: must be text, json or tsv", format)
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// tsvEscape escapes backslashes, tabs and newlines as in the text format of
+// PostgreSQL COPY, which spreadsheets and MySQL LOAD DATA also accept.
+func tsvEscape(s string) string {
+	return tsvEscaper.Replace(s)
+}
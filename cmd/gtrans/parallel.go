@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// orderedWorkers calls fn for each item received from in with n workers, and
+// calls emit with the results in the order the items were received. It
+// returns the first error returned by emit after in is drained.
+func orderedWorkers(n int, in <-chan interface{}, fn func(interface{}) interface{}, emit func(interface{}) error) error {
+	if n < 1 {
+		n = 1
+	}
+	type job struct {
+		item interface{}
+		done chan interface{}
+	}
+	work := make(chan *job)
+	// order bounds the number of items processed ahead of emit.
+	order := make(chan *job, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range work {
+				j.done <- fn(j.item)
+			}
+		}()
+	}
+	go func() {
+		for item := range in {
+			j := &job{item: item, done: make(chan interface{}, 1)}
+			order <- j
+			work <- j
+		}
+		close(work)
+		close(order)
+	}()
+	var err error
+	for j := range order {
+		result := <-j.done
+		if err == nil {
+			err = emit(result)
+		}
+	}
+	wg.Wait()
+	return err
+}
+
+// coalesce groups the items received from in into batches ([]interface{}) of
+// up to max items. A batch is sent as soon as it's full, or when no more items
+// arrive within wait, so that slow input, e.g. typed lines, isn't delayed.
+func coalesce(in <-chan interface{}, max int, wait time.Duration) <-chan interface{} {
+	if max < 1 {
+		max = 1
+	}
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		var batch []interface{}
+		timer := time.NewTimer(wait)
+		stop := func() {
+			if !timer.Stop() {
+				// Drain the expiration of the last batch, if any.
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+		stop()
+		flush := func() {
+			if len(batch) > 0 {
+				out <- batch
+				batch = nil
+			}
+		}
+		for {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				if batch = append(batch, item); len(batch) >= max {
+					stop()
+					flush()
+				} else if len(batch) == 1 {
+					stop()
+					timer.Reset(wait)
+				}
+			case <-timer.C:
+				flush()
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/minodisk/gtrans"
+)
+
+const enginesUsageMessage = "" +
+	`Usage:	gtrans engines [flags] [engine...]
+	gtrans engines list
+	gtrans engines reports whether the engines are configured and their credentials are valid by
+	calling their APIs, with the numbers of their languages, their features and limits.
+	gtrans engines list lists the built-in engines and the plugin engines found in PATH.
+`
+
+// isBuiltinEngine reports whether name is a built-in engine, which wins over
+// a plugin engine of the same name.
+func isBuiltinEngine(name string) bool {
+	for _, n := range gtrans.BuiltinEngineNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func runEngines(w io.Writer, args []string) error {
+	if len(args) != 1 || args[0] != "list" {
+		return runEngineCapabilities(w, args)
+	}
+	plugins := gtrans.PluginEngines()
+	for _, name := range gtrans.EngineNames() {
+		if path, ok := plugins[name]; ok && !isBuiltinEngine(name) {
+			fmt.Fprintf(w, "%s\t%s\n", name, path)
+		} else {
+			fmt.Fprintf(w, "%s\tbuilt-in\n", name)
+		}
+	}
+	return nil
+}
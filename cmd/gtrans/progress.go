@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progress reports the progress of long runs to STDERR.
+type progress struct {
+	mu    sync.Mutex
+	mode  string // "none", "bar" or "json"
+	w     io.Writer
+	total int // 0 if unknown
+	done  int
+	chars int // characters sent to the engine
+	file  string
+	start time.Time
+	last  time.Time
+}
+
+// newProgress returns a progress reporter of mode. An empty mode means "bar"
+// if w is a terminal and "none" otherwise.
+func newProgress(mode string, w io.Writer, total int) (*progress, error) {
+	switch mode {
+	case "":
+		mode = "none"
+		if isTerminal(w) {
+			mode = "bar"
+		}
+	case "none", "bar", "json":
+	default:
+		return nil, fmt.Errorf("invalid -progress %q: must be none, bar or json", mode)
+	}
+	return &progress{mode: mode, w: w, total: total, start: time.Now()}, nil
+}
+
+// SetFile sets the file being translated.
+func (p *progress) SetFile(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.file = name
+	p.report(false)
+}
+
+// Add records that segments are done and chars characters are sent.
+func (p *progress) Add(segments, chars int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += segments
+	p.chars += chars
+	p.report(false)
+}
+
+// Finish reports the final progress.
+func (p *progress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.report(true)
+	if p.mode == "bar" {
+		fmt.Fprintln(p.w)
+	}
+}
+
+func (p *progress) eta() time.Duration {
+	if p.total == 0 || p.done == 0 || p.done >= p.total {
+		return 0
+	}
+	elapsed := time.Since(p.start)
+	return time.Duration(float64(elapsed) / float64(p.done) * float64(p.total-p.done)).Round(time.Second)
+}
+
+const progressInterval = 100 * time.Millisecond
+
+func (p *progress) report(final bool) {
+	if p.mode == "none" || !final && time.Since(p.last) < progressInterval {
+		return
+	}
+	p.last = time.Now()
+	switch p.mode {
+	case "json":
+		json.NewEncoder(p.w).Encode(map[string]interface{}{
+			"done":        p.done,
+			"total":       p.total,
+			"chars":       p.chars,
+			"file":        p.file,
+			"elapsed_sec": int(time.Since(p.start).Seconds()),
+			"eta_sec":     int(p.eta().Seconds()),
+			"finished":    final,
+		})
+	case "bar":
+		const width = 30
+		var status string
+		if p.total > 0 {
+			n := width * p.done / p.total
+			status = fmt.Sprintf("[%s%s] %d/%d segments", strings.Repeat("=", n), strings.Repeat(" ", width-n), p.done, p.total)
+		} else {
+			status = fmt.Sprintf("%d segments", p.done)
+		}
+		status += fmt.Sprintf(", %d chars sent", p.chars)
+		if eta := p.eta(); eta > 0 {
+			status += ", ETA " + eta.String()
+		}
+		if p.file != "" {
+			status += ", " + p.file
+		}
+		// Clear the rest of the line as the status may get shorter.
+		fmt.Fprintf(p.w, "\r%s\x1b[K", status)
+	}
+}
+
+// countRecords returns the number of non-empty lines of r if r is a regular
+// file, rewinding it afterwards. It returns 0 for other readers, e.g. pipes.
+func countRecords(r io.Reader) int {
+	f, ok := r.(*os.File)
+	if !ok {
+		return 0
+	}
+	if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() {
+		return 0
+	}
+	n := 0
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for s.Scan() {
+		if strings.TrimSpace(s.Text()) != "" {
+			n++
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil || s.Err() != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var langCodeRe = regexp.MustCompile(`^[A-Za-z]{2,3}(?:-[A-Za-z0-9]{2,8})*$`)
+
+// promptSourceLang returns the confirmation of WithSourceConfirmation asking
+// the user on w
+// which language the input is written in, and reading the answer from r. An
+// empty answer accepts the guess.
+func promptSourceLang(r io.Reader, w io.Writer) func(text, guess string, confidence float64) (string, error) {
+	br := bufio.NewReader(r)
+	return func(text, guess string, confidence float64) (string, error) {
+		for {
+			fmt.Fprintf(w, "gtrans: the input seems to be written in %s (confidence %.2f). Press Enter if so, or type its language code: ", guess, confidence)
+			line, err := br.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return "", err
+			}
+			answer := strings.TrimSpace(line)
+			if answer == "" {
+				if err == io.EOF {
+					fmt.Fprintln(w)
+				}
+				return guess, nil
+			}
+			if langCodeRe.MatchString(answer) {
+				return answer, nil
+			}
+			fmt.Fprintf(w, "gtrans: %q is not a language code, e.g. en or pt-BR\n", answer)
+			if err == io.EOF {
+				return guess, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// The main article of a page is found by scoring paragraphs like Readability:
+// elements get the scores of the paragraphs in them by their length and
+// commas, weighted by their classes and IDs and discounted by the density of
+// links, and the best one is taken with its siblings scored nearly as well.
+
+var (
+	// unlikelyArticleRe matches the classes and IDs of elements which are
+	// unlikely to be a part of the article.
+	unlikelyArticleRe = regexp.MustCompile(`(?i)\bads?\b|ad-break|banner|breadcrumb|combx|comment|community|cookie|disqus|footer|gdpr|header|legends|menu|modal|nav|outbrain|pager|pagination|popup|promo|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|taboola|tweet|widget`)
+	// likelyArticleRe matches the classes and IDs of elements which are
+	// likely to be the article.
+	likelyArticleRe = regexp.MustCompile(`(?i)article|blog|body|column|content|entry|hentry|main|post|story|text`)
+)
+
+// nonArticleElements are the elements which are never a part of the article.
+var nonArticleElements = map[string]bool{
+	"nav": true, "header": true, "footer": true, "aside": true, "form": true,
+	"menu": true, "dialog": true,
+}
+
+// articleNodes returns the elements of the main article of doc in document
+// order, or nil if no paragraph is found.
+func articleNodes(doc *html.Node) []*html.Node {
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	addScore := func(n *html.Node, s float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = classWeight(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += s
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if skippedElements[n.Data] || nonArticleElements[n.Data] || unlikelyArticle(n) {
+				return
+			}
+			if n.Data == "p" || n.Data == "pre" || n.Data == "td" || n.Data == "blockquote" {
+				text := strings.TrimSpace(collapseSpace(nodeText(n)))
+				if utf8.RuneCountInString(text) >= 25 {
+					s := 1 + float64(strings.Count(text, ",")+strings.Count(text, "、")+strings.Count(text, "，"))
+					if l := float64(utf8.RuneCountInString(text)) / 100; l < 3 {
+						s += l
+					} else {
+						s += 3
+					}
+					addScore(n.Parent, s)
+					if n.Parent != nil {
+						addScore(n.Parent.Parent, s/2)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	var top *html.Node
+	for _, n := range candidates {
+		scores[n] *= 1 - linkDensity(n)
+		if top == nil || scores[n] > scores[top] {
+			top = n
+		}
+	}
+	if top == nil {
+		return nil
+	}
+	if top.Parent == nil {
+		return []*html.Node{top}
+	}
+	// Siblings of the top candidate, e.g. paragraphs split by ads, are a
+	// part of the article too.
+	threshold := scores[top] * 0.2
+	if threshold < 10 {
+		threshold = 10
+	}
+	var nodes []*html.Node
+	for c := top.Parent.FirstChild; c != nil; c = c.NextSibling {
+		if c == top {
+			nodes = append(nodes, c)
+			continue
+		}
+		if c.Type != html.ElementNode || unlikelyArticle(c) {
+			continue
+		}
+		if s, ok := scores[c]; ok && s >= threshold {
+			nodes = append(nodes, c)
+			continue
+		}
+		if c.Data == "p" {
+			text := strings.TrimSpace(collapseSpace(nodeText(c)))
+			if n := utf8.RuneCountInString(text); n > 80 && linkDensity(c) < 0.25 {
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	return nodes
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// unlikelyArticle returns true if the class or the ID of n tells it's not a
+// part of the article, e.g. a sidebar.
+func unlikelyArticle(n *html.Node) bool {
+	if n.Data == "body" || n.Data == "article" || n.Data == "main" {
+		return false
+	}
+	s := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyArticleRe.MatchString(s) && !likelyArticleRe.MatchString(s) ||
+		attr(n, "role") == "navigation" || attr(n, "aria-hidden") == "true" || attr(n, "hidden") != ""
+}
+
+// classWeight returns the initial score of n by its element, class and ID.
+func classWeight(n *html.Node) float64 {
+	var w float64
+	switch n.Data {
+	case "article", "main":
+		w += 10
+	case "div":
+		w += 5
+	case "pre", "td", "blockquote":
+		w += 3
+	case "ol", "ul", "dl", "dd", "dt", "li":
+		w -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		w -= 5
+	}
+	for _, s := range []string{attr(n, "class"), attr(n, "id")} {
+		if s == "" {
+			continue
+		}
+		if unlikelyArticleRe.MatchString(s) {
+			w -= 25
+		}
+		if likelyArticleRe.MatchString(s) {
+			w += 25
+		}
+	}
+	return w
+}
+
+// linkDensity returns the ratio of the text of links in n to its text.
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(strings.TrimSpace(collapseSpace(nodeText(n))))
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			links += utf8.RuneCountInString(strings.TrimSpace(collapseSpace(nodeText(n))))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(links) / float64(total)
+}
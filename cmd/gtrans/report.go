@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/minodisk/gtrans"
+)
+
+// report collects the results of a batch run to summarize them in -report
+// format at the end. A nil report collects nothing.
+type report struct {
+	mu    sync.Mutex
+	files map[string][]*gtrans.Result
+}
+
+// newReport returns a report if -report is given, or nil.
+func newReport() (*report, error) {
+	switch reportFormat {
+	case "":
+		return nil, nil
+	case "markdown":
+		return &report{files: map[string][]*gtrans.Result{}}, nil
+	}
+	return nil, fmt.Errorf("invalid -report %q: must be markdown", reportFormat)
+}
+
+// Add records a result of translating a segment of file.
+func (rp *report) Add(file string, r *gtrans.Result) {
+	if rp == nil || r == nil {
+		return
+	}
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.files[file] = append(rp.files[file], r)
+}
+
+// Save writes the report to -report-out, or STDERR if it's empty.
+func (rp *report) Save() error {
+	if rp == nil {
+		return nil
+	}
+	if reportOut == "" {
+		return rp.WriteMarkdown(os.Stderr)
+	}
+	f, err := os.Create(reportOut)
+	if err != nil {
+		return err
+	}
+	if err := rp.WriteMarkdown(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteMarkdown writes a summary of the run and a bilingual table per file as
+// a Markdown document, e.g. to attach to a pull request.
+func (rp *report) WriteMarkdown(w io.Writer) error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	names := make([]string, 0, len(rp.files))
+	for name := range rp.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var segs, chars, sent int
+	engines := map[string]int{}
+	var low []string
+	for _, name := range names {
+		for _, r := range rp.files[name] {
+			segs++
+			chars += utf8.RuneCountInString(r.Source)
+			sent += charsSent(r)
+			engines[r.Engine]++
+			if r.LowQuality {
+				low = append(low, fmt.Sprintf("| %s | %s | %s | %.2f |", markdownCell(name), markdownCell(r.Source), markdownCell(r.Translation), r.Confidence()))
+			}
+		}
+	}
+	var used []string
+	for name, n := range engines {
+		used = append(used, fmt.Sprintf("%s (%d)", name, n))
+	}
+	sort.Strings(used)
+
+	fmt.Fprintf(w, "# Translation report\n\n")
+	fmt.Fprintf(w, "| | |\n|---|---|\n")
+	fmt.Fprintf(w, "| Files | %d |\n", len(names))
+	fmt.Fprintf(w, "| Segments | %d |\n", segs)
+	fmt.Fprintf(w, "| Characters | %d (%d sent to engines) |\n", chars, sent)
+	fmt.Fprintf(w, "| Engines | %s |\n", strings.Join(used, ", "))
+
+	fmt.Fprintf(w, "\n## Low-confidence segments\n\n")
+	switch {
+	case !withQuality && minQuality <= 0:
+		fmt.Fprintf(w, "Quality was not estimated. Use -min-quality to flag low-confidence segments.\n")
+	case len(low) == 0:
+		fmt.Fprintf(w, "None below %.2f.\n", minQuality)
+	default:
+		fmt.Fprintf(w, "| File | Source | Translation | Quality |\n|---|---|---|---|\n")
+		for _, l := range low {
+			fmt.Fprintln(w, l)
+		}
+	}
+
+	for _, name := range names {
+		fmt.Fprintf(w, "\n## %s\n\n", name)
+		fmt.Fprintf(w, "| Source | Translation |\n|---|---|\n")
+		for _, r := range rp.files[name] {
+			fmt.Fprintf(w, "| %s | %s |\n", markdownCell(r.Source), markdownCell(r.Translation))
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+var markdownCellEscaper = strings.NewReplacer(`|`, `\|`, "\r\n", "<br>", "\n", "<br>")
+
+// markdownCell escapes s so that it fits in a cell of a Markdown table.
+func markdownCell(s string) string {
+	return markdownCellEscaper.Replace(strings.TrimSpace(s))
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// roffInlineRules protect the escapes of roff, and the bold and italic spans,
+// which are mostly commands, options and placeholders in man pages.
+var roffInlineRules = []gtrans.ProtectRule{
+	{Kind: "code", Pattern: regexp.MustCompile(`\\f[BI](?:[^\\]|\\[^f])*?\\f[RP]`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`\\(?:f(?:\[[^\]]*\]|\(..|.)|\*(?:\[[^\]]*\]|\(..|.)|\((?:..)|\[[^\]]*\]|s[-+]?[0-9]|[-e&|^%c~ 0])`)},
+}
+
+// roffFormat translates the paragraphs and the subsection titles of man pages
+// written with the man macros, keeping the other requests and macros, the
+// section titles, e.g. NAME, the SYNOPSIS, the tags of .TP and no-fill
+// blocks, e.g. examples, as is. Lines of the font macros, e.g. .B and .IR, are
+// translated in the paragraphs as font escapes, which are protected.
+var roffFormat = &docFormat{
+	name:    "man",
+	exts:    []string{".1", ".2", ".3", ".4", ".5", ".6", ".7", ".8", ".9", ".man"},
+	protect: roffInlineRules,
+	comment: func(s string) string { return `.\" ` + s },
+	translate: func(src []byte, tr segmentTranslator) ([]byte, error) {
+		return translateParts(roffParts(string(src)), tr)
+	},
+}
+
+// roffFontMacros are the font macros of the man macros and their fonts, each
+// of which is alternated between the arguments if there are two.
+var roffFontMacros = map[string][]string{
+	"B": {"B"}, "I": {"I"}, "SB": {"B"}, "SM": {"R"},
+	"BI": {"B", "I"}, "BR": {"B", "R"}, "IB": {"I", "B"},
+	"IR": {"I", "R"}, "RB": {"R", "B"}, "RI": {"R", "I"},
+}
+
+// roffNoFill are the macros starting no-fill blocks, and the ones ending them.
+var roffNoFill = map[string]string{"nf": "fi", "EX": "EE", "Bd": "Ed", "TS": "TE", "EQ": "EN"}
+
+func roffParts(src string) []docPart {
+	var (
+		parts  []docPart
+		para   []string // lines of the current paragraph
+		noFill string   // macro ending the current no-fill block, if any
+		tag    bool     // the next line is the tag of .TP
+		// synopsis is true in the SYNOPSIS section, whose lines are the
+		// syntax of commands.
+		synopsis bool
+	)
+	raw := func(s string) { parts = append(parts, docPart{text: s}) }
+	flush := func() {
+		if len(para) > 0 {
+			parts = append(parts, docPart{text: strings.Join(para, " ") + "\n", translate: true, quote: roffQuote})
+		}
+		para = nil
+	}
+	for _, line := range strings.SplitAfter(src, "\n") {
+		body := strings.TrimRight(line, "\r\n")
+		name, args := roffRequest(body)
+		switch {
+		case name == "SH" || name == "Sh":
+			// Section titles are kept, as man pages are searched for them.
+			flush()
+			raw(line)
+			synopsis = strings.EqualFold(strings.Join(roffArgs(args), " "), "SYNOPSIS")
+			noFill, tag = "", false
+		case synopsis:
+			raw(line)
+		case noFill != "":
+			raw(line)
+			if name == noFill {
+				noFill = ""
+			}
+		case tag:
+			raw(line)
+			// The tag may be given by a font macro on the next line.
+			tag = name != "" && roffFontMacros[name] == nil
+		case strings.TrimSpace(body) == "":
+			flush()
+			raw(line)
+		case name == "":
+			para = append(para, body)
+		case roffFontMacros[name] != nil && args != "":
+			para = append(para, roffFont(roffFontMacros[name], roffArgs(args)))
+		case name == "SS" || name == "Ss":
+			flush()
+			if args == "" {
+				raw(line)
+				continue
+			}
+			raw(body[:strings.Index(body, args)])
+			parts = append(parts, docPart{text: strings.Join(roffArgs(args), " "), translate: true, quote: roffQuoteArg})
+			raw(line[len(body):])
+		default:
+			flush()
+			raw(line)
+			if end, ok := roffNoFill[name]; ok {
+				noFill = end
+			}
+			tag = name == "TP"
+		}
+	}
+	flush()
+	return parts
+}
+
+// roffRequest returns the name and the arguments of the request or the macro
+// on the line, or an empty name for a text line.
+func roffRequest(line string) (string, string) {
+	if !strings.HasPrefix(line, ".") && !strings.HasPrefix(line, "'") {
+		return "", ""
+	}
+	s := strings.TrimLeft(line[1:], " \t")
+	if strings.HasPrefix(s, `\"`) {
+		return `\"`, ""
+	}
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i:])
+}
+
+// roffArgs splits the arguments of a macro, which may be quoted.
+func roffArgs(s string) []string {
+	var args []string
+	for s = strings.TrimLeft(s, " \t"); s != ""; s = strings.TrimLeft(s, " \t") {
+		if s[0] == '"' {
+			end := strings.Index(s[1:], `"`)
+			for end >= 0 && strings.HasPrefix(s[1+end:], `""`) {
+				// "" is a quote in a quoted argument.
+				next := strings.Index(s[1+end+2:], `"`)
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += 2 + next
+			}
+			if end < 0 {
+				args = append(args, strings.Replace(s[1:], `""`, `"`, -1))
+				break
+			}
+			args = append(args, strings.Replace(s[1:1+end], `""`, `"`, -1))
+			s = s[1+end+1:]
+			continue
+		}
+		i := strings.IndexAny(s, " \t")
+		if i < 0 {
+			args = append(args, s)
+			break
+		}
+		args = append(args, s[:i])
+		s = s[i:]
+	}
+	return args
+}
+
+// roffFont writes the arguments of a font macro with font escapes. One font
+// joins the arguments with spaces, and two fonts alternate between them
+// without spaces.
+func roffFont(fonts, args []string) string {
+	if len(fonts) == 1 {
+		if fonts[0] == "R" {
+			return strings.Join(args, " ")
+		}
+		return `\f` + fonts[0] + strings.Join(args, " ") + `\fR`
+	}
+	var b strings.Builder
+	for i, a := range args {
+		if f := fonts[i%2]; f == "R" {
+			b.WriteString(a)
+		} else {
+			b.WriteString(`\f` + f + a + `\fR`)
+		}
+	}
+	return b.String()
+}
+
+// roffQuote escapes the lines of the translated text starting with . or ',
+// which would be requests.
+func roffQuote(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, ".") || strings.HasPrefix(l, "'") {
+			lines[i] = `\&` + l
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// roffQuoteArg quotes the translated text as an argument of a macro.
+func roffQuoteArg(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoffFormat(t *testing.T) {
+	checkFormats(t, roffFormat, []formatTest{
+		{
+			name: "sections",
+			src: ".TH FOO 1\n" +
+				".SH NAME\n" +
+				"foo \\- do things\n" +
+				".SH SYNOPSIS\n" +
+				".B foo\n" +
+				"[\\fIoptions\\fR]\n" +
+				".SH DESCRIPTION\n" +
+				"Foo does things.\n" +
+				".SS Details\n" +
+				"More things.\n",
+			segments: []string{"foo \\- do things", "Foo does things.", "Details", "More things."},
+			want: ".TH FOO 1\n" +
+				".SH NAME\n" +
+				"foo \\- do things\n" +
+				".SH SYNOPSIS\n" +
+				".B foo\n" +
+				"[\\fIoptions\\fR]\n" +
+				".SH DESCRIPTION\n" +
+				"Foo does things.\n" +
+				".SS \"Details\"\n" +
+				"More things.\n",
+		},
+		{
+			name: "font macros",
+			src: ".SH OPTIONS\n" +
+				"Use\n" +
+				".B \\-v\n" +
+				"to be verbose.\n",
+			segments: []string{"Use \\fB\\-v\\fR to be verbose."},
+			want: ".SH OPTIONS\n" +
+				"Use \\fB\\-v\\fR to be verbose.\n",
+		},
+		{
+			name: "tagged paragraphs",
+			src: ".TP\n" +
+				".B \\-v\n" +
+				"Be verbose.\n",
+			segments: []string{"Be verbose."},
+		},
+		{
+			name: "no-fill blocks",
+			src: ".nf\n" +
+				"$ foo \\-v\n" +
+				".fi\n" +
+				"Done.\n",
+			segments: []string{"Done."},
+		},
+		{
+			name:     "requests in translations",
+			src:      "Text\n.br\n",
+			segments: []string{"Text"},
+		},
+	})
+}
+
+func TestRoffArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{`a b`, []string{"a", "b"}},
+		{`"a b" c`, []string{"a b", "c"}},
+		{`"say ""hi"""`, []string{`say "hi"`}},
+		{`"unclosed`, []string{"unclosed"}},
+	}
+	for _, tt := range tests {
+		if got := roffArgs(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("roffArgs(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/minodisk/gtrans"
+)
+
+// reStructuredText inline markup which must not be translated: inline
+// literals, roles and interpreted text, hyperlink, footnote and substitution
+// references, and URLs.
+var rstInlineRules = []gtrans.ProtectRule{
+	// In a regexp, so that backquotes of inline literals don't start
+	// interpreted text.
+	{Kind: "markup", Pattern: regexp.MustCompile("``[^`]+``|(?::[A-Za-z0-9_.+-]+(?::[A-Za-z0-9_.+-]+)*:)?`[^`]+`(?::[A-Za-z0-9_.+-]+:|__?)?")},
+	{Kind: "markup", Pattern: regexp.MustCompile(`\|[^|\s][^|]*\||\[(?:#[A-Za-z0-9_-]*|\*|[0-9]+|[A-Za-z][A-Za-z0-9_.-]*)\]_|\b[A-Za-z0-9][A-Za-z0-9_.-]*__?\b`)},
+	{Kind: "markup", Pattern: regexp.MustCompile(`https?://[^\s<>()]+`)},
+}
+
+var (
+	rstDirectiveRe = regexp.MustCompile(`^(\s*\.\.\s+([A-Za-z0-9_:.+-]+)::[ \t]*)(.*)$`)
+	rstFootnoteRe  = regexp.MustCompile(`^(\s*\.\.\s+\[[^\]]+\]\s+)(.*)$`)
+	rstFieldRe     = regexp.MustCompile(`^\s*:[^:\s][^:]*:(?:\s|$)`)
+	rstLineBlockRe = regexp.MustCompile(`^(\s*\|\s+)(.*)$`)
+	rstListRe      = regexp.MustCompile(`^(\s*(?:[-*+•]|\d+[.)]|#\.|\(\d+\))\s+)(.*)$`)
+	rstTableRe     = regexp.MustCompile(`^\s*(?:\+[-=+]+\+|=+(?:\s+=+)+)\s*$`)
+)
+
+// rstProseDirectives are the directives whose contents are prose to translate.
+// The contents of the others, e.g. code-block, math and toctree, are kept as
+// is.
+var rstProseDirectives = map[string]bool{
+	"note": true, "warning": true, "tip": true, "important": true, "caution": true,
+	"danger": true, "hint": true, "attention": true, "error": true, "admonition": true,
+	"seealso": true, "topic": true, "sidebar": true, "rubric": true,
+	"versionadded": true, "versionchanged": true, "deprecated": true,
+}
+
+// rstFormat translates paragraphs, section titles, list items and the contents
+// of admonitions of reStructuredText (e.g. Sphinx documents), keeping
+// directives, roles, literal blocks, tables, field lists and comments as is.
+// The adornments of section titles are fitted to the translated titles.
+var rstFormat = &docFormat{
+	name:    "rst",
+	exts:    []string{".rst", ".rest"},
+	protect: rstInlineRules,
+	comment: func(s string) string { return ".. " + s },
+	translate: func(src []byte, tr segmentTranslator) ([]byte, error) {
+		return translateParts(rstParts(string(src)), tr)
+	},
+}
+
+func rstParts(src string) []docPart {
+	var (
+		parts      []docPart
+		para       []string // lines of the current paragraph
+		prefix     string   // indentation or list marker of the paragraph
+		paraIndent int      // indentation of the lines of the paragraph
+		rawIndent  = -1     // lines indented more than this are kept as is
+		untilBlank bool     // whether lines are kept as is until a blank line
+		newline    string   // line ending of the last line of the paragraph
+	)
+	raw := func(s string) { parts = append(parts, docPart{text: s}) }
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		raw(prefix)
+		text := strings.Join(para, " ")
+		switch {
+		case text == "::":
+			raw(text)
+			rawIndent = paraIndent
+		case strings.HasSuffix(text, " ::"):
+			// The paragraph followed by a literal block
+			parts = append(parts, docPart{text: text[:len(text)-3], translate: true})
+			raw(" ::")
+			rawIndent = paraIndent
+		case strings.HasSuffix(text, "::"):
+			// "::" is written as ":" before a literal block.
+			parts = append(parts, docPart{text: text[:len(text)-1], translate: true, quote: rstLiteralColon})
+			rawIndent = paraIndent
+		default:
+			parts = append(parts, docPart{text: text, translate: true})
+		}
+		raw(newline)
+		para, prefix = nil, ""
+	}
+	lines := strings.SplitAfter(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		body := strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimSpace(body)
+		indent := len(body) - len(strings.TrimLeft(body, " \t"))
+		nl := line[len(body):]
+		if rawIndent >= 0 {
+			if trimmed == "" || indent > rawIndent {
+				raw(line)
+				continue
+			}
+			rawIndent = -1
+		}
+		if untilBlank && trimmed != "" {
+			raw(line)
+			continue
+		}
+		untilBlank = false
+		switch {
+		case trimmed == "":
+			flush()
+			raw(line)
+		case len(para) == 0 && i+2 < len(lines) && isRSTAdornment(body) &&
+			strings.TrimSpace(lines[i+1]) != "" && strings.TrimRight(lines[i+2], "\r\n") == body:
+			// Title with an overline
+			title := strings.TrimRight(lines[i+1], "\r\n")
+			parts = append(parts, docPart{text: title, translate: true, quote: rstTitle(body[0], true)})
+			raw(lines[i+2][len(body):])
+			i += 2
+		case len(para) == 0 && indent == 0 && i+1 < len(lines) && isRSTAdornment(strings.TrimRight(lines[i+1], "\r\n")) && !isRSTAdornment(body):
+			// Title with an underline
+			parts = append(parts, docPart{text: body, translate: true, quote: rstTitle(lines[i+1][0], false)})
+			raw(lines[i+1][len(strings.TrimRight(lines[i+1], "\r\n")):])
+			i++
+		case rstTableRe.MatchString(body):
+			flush()
+			raw(line)
+			untilBlank = true
+		case len(trimmed) >= 4 && isRSTAdornment(body):
+			// Transition
+			flush()
+			raw(line)
+		case strings.HasPrefix(trimmed, ">>>"):
+			// Doctest block
+			flush()
+			raw(line)
+			untilBlank = true
+		case rstDirectiveRe.MatchString(body):
+			flush()
+			m := rstDirectiveRe.FindStringSubmatch(body)
+			if !rstProseDirectives[m[2]] {
+				raw(line)
+				rawIndent = indent
+				continue
+			}
+			if strings.HasPrefix(m[2], "version") || strings.HasPrefix(m[2], "deprecated") || m[3] == "" {
+				raw(line)
+				continue
+			}
+			// The argument of an admonition, or the first paragraph
+			// of its contents
+			prefix, para, paraIndent, newline = m[1], []string{m[3]}, indent+3, nl
+		case rstFootnoteRe.MatchString(body):
+			flush()
+			m := rstFootnoteRe.FindStringSubmatch(body)
+			prefix, para, paraIndent, newline = m[1], []string{m[2]}, indent+3, nl
+		case trimmed == ".." || strings.HasPrefix(trimmed, ".. "):
+			// Comments, hyperlink targets and substitution definitions
+			flush()
+			raw(line)
+			rawIndent = indent
+		case rstFieldRe.MatchString(body):
+			flush()
+			raw(line)
+			rawIndent = indent
+		case rstLineBlockRe.MatchString(body):
+			flush()
+			m := rstLineBlockRe.FindStringSubmatch(body)
+			raw(m[1])
+			parts = append(parts, docPart{text: m[2], translate: true})
+			raw(nl)
+		case rstListRe.MatchString(body):
+			flush()
+			m := rstListRe.FindStringSubmatch(body)
+			prefix, para, paraIndent, newline = m[1], []string{m[2]}, len(m[1]), nl
+		case len(para) > 0 && indent == paraIndent:
+			para, newline = append(para, trimmed), nl
+		default:
+			flush()
+			prefix, para, paraIndent, newline = body[:indent], []string{trimmed}, indent, nl
+		}
+	}
+	flush()
+	return parts
+}
+
+// isRSTAdornment reports whether line is an overline or underline of a
+// section title, or a transition: a repeated punctuation character. "::" is
+// not, which introduces a literal block.
+func isRSTAdornment(line string) bool {
+	if len(line) < 2 || line == "::" || !strings.ContainsRune("=-`:'\"~^_*+#<>.", rune(line[0])) {
+		return false
+	}
+	return strings.Trim(line, line[:1]) == ""
+}
+
+// rstTitle returns the function writing a title adorned with c, which fits
+// the adornments to the width of the translated title.
+func rstTitle(c byte, overline bool) func(string) string {
+	return func(title string) string {
+		adornment := strings.Repeat(string(c), displayWidth(title))
+		if overline {
+			return adornment + "\n" + title + "\n" + adornment
+		}
+		return title + "\n" + adornment
+	}
+}
+
+// rstLiteralColon writes "::" at the end of a paragraph followed by a literal
+// block, which may be lost in translation.
+func rstLiteralColon(s string) string {
+	return strings.TrimRight(s, ":：") + "::"
+}
+
+// displayWidth returns the width of s in a terminal or a fixed-width font,
+// where wide characters of East Asian languages take two columns.
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul),
+			r >= 0x3000 && r <= 0x303f, r >= 0xff01 && r <= 0xff60, r >= 0xffe0 && r <= 0xffe6:
+			w += 2
+		case unicode.Is(unicode.Mn, r):
+		default:
+			w++
+		}
+	}
+	return w
+}
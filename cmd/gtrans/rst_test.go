@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestRSTFormat(t *testing.T) {
+	checkFormats(t, rstFormat, []formatTest{
+		{
+			name:     "paragraphs",
+			src:      "First paragraph.\n\nSecond paragraph.\n",
+			segments: []string{"First paragraph.", "Second paragraph."},
+		},
+		{
+			name:     "titles",
+			src:      "=====\nIntro\n=====\n\nUsage\n-----\n",
+			segments: []string{"Intro", "Usage"},
+		},
+		{
+			name:     "standalone literal marker",
+			src:      "Example:\n\n::\n\n    $ rm -rf build\n    $ make html\n",
+			segments: []string{"Example:"},
+		},
+		{
+			name:     "literal marker after a space",
+			src:      "Run it ::\n\n    make html\n",
+			segments: []string{"Run it"},
+		},
+		{
+			name:     "literal marker after text",
+			src:      "Run it::\n\n    make html\n",
+			segments: []string{"Run it:"},
+		},
+		{
+			name:     "transition",
+			src:      "Before.\n\n----\n\nAfter.\n",
+			segments: []string{"Before.", "After."},
+		},
+		{
+			name:     "directives",
+			src:      ".. note:: Be careful.\n\n.. code-block:: sh\n\n   make html\n",
+			segments: []string{"Be careful."},
+		},
+		{
+			name:     "lists",
+			src:      "- First item\n- Second item\n  continued.\n",
+			segments: []string{"First item", "Second item continued."},
+			want:     "- First item\n- Second item continued.\n",
+		},
+		{
+			name:     "no trailing newline",
+			src:      "Title\n=====\n\nLast line.",
+			segments: []string{"Title", "Last line."},
+		},
+		{
+			name:     "crlf",
+			src:      "Hello.\r\n\r\nWorld.\r\n",
+			segments: []string{"Hello.", "World."},
+		},
+	})
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/minodisk/gtrans"
+)
+
+const serveUsageMessage = "" +
+	`Usage:	gtrans serve [flags]
+	gtrans serve serves translation over HTTP with the engine, translation memory
+	and protection rules configured by flags.
+
+	POST /translate {"text": ..., "to": ..., "engine": ...}
+	                translates text (to defaults to -to, engine to -engine)
+	POST /detect    {"text": ...}  detects the language of text
+	GET  /languages[?display=lang&engine=name]
+	                lists the supported languages
+	GET  /engines   lists the engines requests can pick
+	GET  /openapi.json
+	                the OpenAPI document of the endpoints, without an API key
+	GET  /          serves the web UI with -ui
+
+	POST requests with an Idempotency-Key header are answered once for
+	-idempotency-ttl: retries get the same response.
+
+	With -api-keys, requests need one of the keys as a bearer token or an
+	X-API-Key header.
+
+	With -webhooks, JSON payloads posted to the paths of the webhooks, e.g.
+	GitHub issue events, are translated and forwarded to their URLs.
+
+	Browser apps on -cors-origins can call it by CORS.
+
+	On SIGTERM or SIGINT, it stops accepting requests, waits for those in
+	flight for up to -shutdown-timeout, and saves the caches before exiting.
+`
+
+// route is an endpoint of the server. handle returns the value written as the
+// JSON response. The OpenAPI document at /openapi.json is generated from
+// routes, so request, response and query describe what handle reads and
+// returns.
+type route struct {
+	method  string
+	path    string
+	id      string // operationId
+	summary string
+	handle  func(s *server, r *http.Request) (interface{}, error)
+	// request is a value of the type of the JSON request body, or nil.
+	request interface{}
+	// response is a value of the type of the JSON response.
+	response interface{}
+	query    []queryParam
+}
+
+// queryParam is a query parameter of a route.
+type queryParam struct {
+	name, description string
+}
+
+var routes = []*route{
+	{
+		method: "POST", path: "/translate", id: "translate", summary: "Translate text",
+		handle:  (*server).translate,
+		request: serveRequest{}, response: gtrans.Result{},
+	},
+	{
+		method: "POST", path: "/detect", id: "detect", summary: "Detect the language of text",
+		handle:  (*server).detect,
+		request: serveRequest{}, response: detectResponse{},
+	},
+	{
+		method: "GET", path: "/languages", id: "listLanguages", summary: "List the supported languages",
+		handle:   (*server).languages,
+		response: []gtrans.Language{},
+		query: []queryParam{
+			{"display", "language of the names of languages, -to by default"},
+			{"engine", "engine to list the languages of, -engine by default"},
+		},
+	},
+	{
+		method: "GET", path: "/engines", id: "listEngines", summary: "List the engines requests can pick",
+		handle:   (*server).listEngines,
+		response: enginesResponse{},
+	},
+}
+
+// httpError is an error with the status code of the response.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+func badRequest(format string, a ...interface{}) error {
+	return &httpError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, a...)}
+}
+
+type server struct {
+	c           *client
+	targetLang  string
+	idempotency *idempotencyStore // nil ignores Idempotency-Key
+	// keys are the API keys by their hashes, or nil to allow anyone.
+	keys map[[sha256.Size]byte]*serveKey
+	ui   bool // serve the web UI at /
+	// webhooks translate payloads posted to their paths.
+	webhooks []*webhook
+	forward  *http.Client // client of the webhooks forwarding payloads
+
+	enginesOnce sync.Once
+	engines     []string // configured engines, the default first
+}
+
+// serveRequest is the request body of /translate and /detect.
+type serveRequest struct {
+	Text string `json:"text"`
+	// To is the target language of /translate, -to by default.
+	To string `json:"to,omitempty"`
+	// Engine is one of the configured engines to translate with instead of
+	// the default.
+	Engine string `json:"engine,omitempty"`
+}
+
+// flagSetWithGlobals returns a FlagSet which has the global flags too, so that
+// they can be given after the command name.
+func flagSetWithGlobals(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	flag.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	return fs
+}
+
+func runServe(args []string) error {
+	fs := flagSetWithGlobals("serve")
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	keysPath := fs.String("api-keys", "", "file of API keys required by requests as \"Authorization: Bearer <key>\" or \"X-API-Key: <key>\", one per line optionally followed by the requests a minute allowed with it")
+	corsOrigins := fs.String("cors-origins", "", "comma separated origins of browser apps allowed to call the server, e.g. https://wiki.example.com, or * for any")
+	corsMethods := fs.String("cors-methods", "GET, POST", "comma separated methods allowed for -cors-origins")
+	corsHeaders := fs.String("cors-headers", "Content-Type, Authorization, X-API-Key, Idempotency-Key", "comma separated request headers allowed for -cors-origins")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 30*time.Second, "on SIGTERM or SIGINT, wait for requests in flight for up to `duration` before exiting")
+	webhooksPath := fs.String("webhooks", "", "JSON file of webhooks translating the fields of payloads posted to their paths and forwarding them to URLs")
+	ui := fs.Bool("ui", false, "serve a web UI at / for colleagues who don't use the command line")
+	idempotencyTTL := fs.Duration("idempotency-ttl", 24*time.Hour, "keep the responses of requests with an Idempotency-Key header for `duration`, answering retries of them without translating again. Zero ignores the header")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), serveUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	target := targetLang
+	if target == "" {
+		var err error
+		if target, err = detectTargetLang(); err != nil {
+			return err
+		}
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	// Nobody is there to confirm large input.
+	c.usage.confirmAt = 0
+	s := &server{c: c, targetLang: target, ui: *ui}
+	if *keysPath != "" {
+		if s.keys, err = loadServeKeys(*keysPath); err != nil {
+			return err
+		}
+	} else if !isLoopback(*addr) {
+		fmt.Fprintf(os.Stderr, "gtrans: anyone reaching %s can translate with your API keys. Give -api-keys to require keys of requests\n", *addr)
+	}
+	if *webhooksPath != "" {
+		if s.webhooks, err = loadWebhooks(*webhooksPath, s.keys != nil); err != nil {
+			return err
+		}
+		s.forward = newForwardClient()
+	}
+	if *idempotencyTTL > 0 {
+		s.idempotency = &idempotencyStore{ttl: *idempotencyTTL}
+	}
+	defer c.SaveTMEvery(tmSaveInterval)()
+	fmt.Fprintf(os.Stderr, "gtrans: listening on %s\n", *addr)
+	cors := newCORSPolicy(*corsOrigins, *corsMethods, *corsHeaders)
+	return listenAndServe(&http.Server{Addr: *addr, Handler: cors.wrap(s.handler())}, c, *shutdownTimeout)
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range routes {
+		rt := rt
+		mux.HandleFunc(rt.path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != rt.method {
+				w.Header().Set("Allow", rt.method)
+				writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+				return
+			}
+			if !s.authorize(w, r) {
+				return
+			}
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" || r.Method != "POST" || s.idempotency == nil {
+				status, body := s.respond(rt, r)
+				writeJSON(w, status, body)
+				return
+			}
+			res, err := s.idempotency.begin(r, key)
+			if err != nil {
+				status, body := errorResponse(r, err)
+				writeJSON(w, status, body)
+				return
+			}
+			if res != nil {
+				select {
+				case <-res.done:
+				case <-r.Context().Done():
+					return
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				writeJSON(w, res.status, res.body)
+				return
+			}
+			status, body := s.respond(rt, r)
+			s.idempotency.finish(r, key, status, body)
+			writeJSON(w, status, body)
+		})
+	}
+	mux.HandleFunc("/openapi.json", s.serveOpenAPI)
+	for _, h := range s.webhooks {
+		mux.HandleFunc(h.Path, s.serveWebhook(h))
+	}
+	if s.ui {
+		mux.HandleFunc("/", serveUI)
+	}
+	return mux
+}
+
+// respond returns the status and the JSON body of the response of rt to r.
+func (s *server) respond(rt *route, r *http.Request) (int, []byte) {
+	v, err := rt.handle(s, r)
+	if err != nil {
+		return errorResponse(r, err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errorResponse(r, err)
+	}
+	return http.StatusOK, append(b, '\n')
+}
+
+// errorResponse returns the status and the JSON body of the response of err.
+// Errors other than httpError are internal errors, which are logged.
+func errorResponse(r *http.Request, err error) (int, []byte) {
+	status := http.StatusInternalServerError
+	var he *httpError
+	if errors.As(err, &he) {
+		status = he.status
+	} else {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	b, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return status, append(b, '\n')
+}
+
+func writeJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func decodeServeRequest(r *http.Request) (*serveRequest, error) {
+	var req serveRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20)).Decode(&req); err != nil {
+		return nil, badRequest("invalid request: %v", err)
+	}
+	if req.Text == "" {
+		return nil, badRequest("text is empty")
+	}
+	return &req, nil
+}
+
+// translate translates the text of the request. The translation memory is
+// saved every tmSaveInterval and on shutdown.
+func (s *server) translate(r *http.Request) (interface{}, error) {
+	req, err := decodeServeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	to := s.targetLang
+	if req.To != "" {
+		to = req.To
+	}
+	ctx := r.Context()
+	if req.Engine != "" {
+		if !s.hasEngine(req.Engine) {
+			return nil, badRequest("engine %s is not configured", req.Engine)
+		}
+		ctx = gtrans.ContextWithEngine(ctx, req.Engine)
+	}
+	res, err := s.c.Translate(ctx, req.Text, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkQuality(res); err != nil {
+		return nil, &httpError{status: http.StatusUnprocessableEntity, msg: err.Error()}
+	}
+	return res, nil
+}
+
+func (s *server) detect(r *http.Request) (interface{}, error) {
+	req, err := decodeServeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	if !allowSecrets {
+		if err := gtrans.CheckSecrets(req.Text); err != nil {
+			return nil, badRequest("%v", err)
+		}
+	}
+	engine, err := s.c.Engine("")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := engine.(gtrans.Detector); !ok {
+		return nil, &httpError{status: http.StatusNotImplemented, msg: fmt.Sprintf("engine %s doesn't support language detection", engine.Name())}
+	}
+	lang, err := s.c.Detect(r.Context(), req.Text)
+	if err != nil {
+		return nil, err
+	}
+	return &detectResponse{SourceLang: lang}, nil
+}
+
+// detectResponse is the response of /detect.
+type detectResponse struct {
+	SourceLang string `json:"source_lang"`
+}
+
+// enginesResponse is the response of /engines.
+type enginesResponse struct {
+	Default string   `json:"default"`
+	Engines []string `json:"engines"`
+}
+
+func (s *server) languages(r *http.Request) (interface{}, error) {
+	display := r.URL.Query().Get("display")
+	if display == "" {
+		display = s.targetLang
+	}
+	name := r.URL.Query().Get("engine")
+	if name != "" && !s.hasEngine(name) {
+		return nil, badRequest("engine %s is not configured", name)
+	}
+	engine, err := s.c.Engine(name)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := engine.(gtrans.LanguageLister)
+	if !ok {
+		return nil, &httpError{status: http.StatusNotImplemented, msg: fmt.Sprintf("engine %s doesn't support listing languages", engine.Name())}
+	}
+	return l.Languages(r.Context(), display)
+}
+
+// listEngines lists the engines requests can pick, the default first.
+func (s *server) listEngines(r *http.Request) (interface{}, error) {
+	return &enginesResponse{Default: s.c.EngineName(), Engines: s.configuredEngines()}, nil
+}
+
+// configuredEngines returns the default engine and the others whose
+// credentials are set.
+func (s *server) configuredEngines() []string {
+	s.enginesOnce.Do(func() {
+		s.engines = []string{s.c.EngineName()}
+		if s.c.Offline() {
+			return
+		}
+		for _, name := range gtrans.EngineNames() {
+			if name == s.c.EngineName() {
+				continue
+			}
+			if _, err := gtrans.NewEngine(name, flagEngineOptions()...); err == nil {
+				s.engines = append(s.engines, name)
+			}
+		}
+	})
+	return s.engines
+}
+
+func (s *server) hasEngine(name string) bool {
+	for _, e := range s.configuredEngines() {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopback returns true if addr listens only on the loopback interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
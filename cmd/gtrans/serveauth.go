@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// serveKey is an API key of the server, which may be limited to a number of
+// requests a minute.
+type serveKey struct {
+	limiter *gtrans.RateLimiter // nil is unlimited
+}
+
+// loadServeKeys loads the API keys of the server from the file at path, which
+// has a key and optionally the requests a minute allowed with it per line,
+// e.g. "s3cr3t 60". Lines starting with # are comments. Keys are indexed by
+// their hashes so that they aren't compared byte by byte.
+func loadServeKeys(path string) (map[[sha256.Size]byte]*serveKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keys := map[[sha256.Size]byte]*serveKey{}
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		k := &serveKey{}
+		if len(fields) > 1 {
+			perMinute, err := strconv.Atoi(fields[1])
+			if err != nil || perMinute < 1 || len(fields) > 2 {
+				return nil, fmt.Errorf("%s:%d: must be a key and optionally the requests a minute", path, n)
+			}
+			k.limiter = gtrans.NewRateLimiter(float64(perMinute)/60, perMinute)
+		}
+		keys[sha256.Sum256([]byte(fields[0]))] = k
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no API key in %s", path)
+	}
+	return keys, nil
+}
+
+// authorize checks the API key of r given by "Authorization: Bearer <key>"
+// or "X-API-Key: <key>", and its rate limit. It writes the error response and
+// returns false if r is not allowed.
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if s.keys == nil {
+		return true
+	}
+	key := apiKey(r)
+	k, ok := s.keys[sha256.Sum256([]byte(key))]
+	if key == "" || !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gtrans"`)
+		writeJSONError(w, http.StatusUnauthorized, "missing or invalid API key")
+		return false
+	}
+	if !k.limiter.Allow() {
+		w.Header().Set("Retry-After", "60")
+		writeJSONError(w, http.StatusTooManyRequests, "rate limit of the API key is exceeded")
+		return false
+	}
+	return true
+}
+
+// apiKey returns the API key of r given by "Authorization: Bearer <key>" or
+// "X-API-Key: <key>", or "".
+func apiKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return r.Header.Get("X-API-Key")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// listenAndServe serves srv until SIGTERM or SIGINT, e.g. by systemd or
+// Kubernetes, and then shuts it down gracefully: it stops accepting requests,
+// waits for those in flight for up to timeout, and closes c, which updates
+// the cache and saves the translation memory and the usage.
+func listenAndServe(srv *http.Server, c *client, timeout time.Duration) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sig)
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		c.Close()
+		return err
+	case s := <-sig:
+		fmt.Fprintf(os.Stderr, "gtrans: %v received. Waiting for requests in flight for up to %v\n", s, timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		err = fmt.Errorf("fail to wait for requests in flight: %v", err)
+		// The rest of them are canceled.
+		srv.Close()
+	}
+	if cerr := c.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const siteUsageMessage = "" +
+	`Usage:	gtrans site [flags] <sitemap URL>
+	gtrans site downloads the pages listed in a sitemap, translates them as
+	-html of gtrans url does, and writes them under -out as a static site at
+	the paths of the pages, e.g. /docs/intro as docs/intro.html and /docs/ as
+	docs/index.html. Sitemap indexes and gzipped sitemaps are followed, and
+	pages on other hosts than the sitemap are skipped.
+
+	Pages are downloaded one at a time every -delay, or every Crawl-delay of
+	robots.txt if it's longer, and ones disallowed by robots.txt are skipped.
+	-jobs pages are translated concurrently. Pages which are not modified
+	since they were translated, by their <lastmod> in the sitemap or their
+	content, are skipped unless -force is given.
+`
+
+// maxSitemapDepth is the maximum depth of nested sitemap indexes.
+const maxSitemapDepth = 3
+
+// sitePage is a page listed in a sitemap.
+type sitePage struct {
+	url     *url.URL
+	lastmod time.Time
+}
+
+// siteCrawler downloads the pages of a site politely.
+type siteCrawler struct {
+	host    string
+	robots  *robotsRules
+	tick    <-chan time.Time // for each request
+	timeout time.Duration    // of a request
+	maxSize int64
+}
+
+// siteResult is the result of translating a page.
+type siteResult struct {
+	page *sitePage
+	skip string // reason why the page is skipped, if any
+	err  error
+}
+
+func runSite(args []string) error {
+	fs := flagSetWithGlobals("site")
+	delay := fs.Duration("delay", time.Second, "interval between requests to the site")
+	maxPages := fs.Int("max-pages", 0, "translate at most this many pages of the sitemap. Zero is unlimited")
+	obeyRobots := fs.Bool("robots", true, "skip the pages disallowed by robots.txt of the site and obey its Crawl-delay")
+	maxSize := byteSize(5 << 20)
+	fs.Var(&maxSize, "max-size", "skip pages and fail sitemaps larger than `size`, e.g. 5M")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of downloading a page")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), siteUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("a sitemap URL is required")
+	}
+	if outPath == "" {
+		return errors.New("-out is required to write the translated site")
+	}
+	u, err := url.Parse(args[0])
+	if err != nil {
+		return err
+	}
+	target := targetLang
+	if target == "" {
+		if target, err = detectTargetLang(); err != nil {
+			return err
+		}
+	}
+	cr := &siteCrawler{host: u.Host, timeout: *timeout, maxSize: int64(maxSize)}
+	if *obeyRobots {
+		if cr.robots, err = cr.fetchRobots(u); err != nil {
+			return err
+		}
+		if cr.robots.delay > *delay {
+			*delay = cr.robots.delay
+		}
+	}
+	if *delay <= 0 {
+		*delay = time.Millisecond
+	}
+	ticker := time.NewTicker(*delay)
+	defer ticker.Stop()
+	cr.tick = ticker.C
+	pages, err := cr.sitemap(u.String(), 0)
+	if err != nil {
+		return err
+	}
+	pages = cr.filter(pages)
+	if *maxPages > 0 && len(pages) > *maxPages {
+		pages = pages[:*maxPages]
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("no page to translate in %s", u)
+	}
+	m, err := loadHashManifest(outPath)
+	if err != nil {
+		return err
+	}
+	startUndoJournal()
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	prog, err := newProgress(progressMode, os.Stderr, 0)
+	if err != nil {
+		return err
+	}
+	in := make(chan interface{})
+	go func() {
+		defer close(in)
+		for _, p := range pages {
+			in <- p
+		}
+	}()
+	failed := 0
+	err = orderedWorkers(jobs, in, func(item interface{}) interface{} {
+		p := item.(*sitePage)
+		skip, err := cr.translatePage(context.Background(), c, m, p, target, prog)
+		return &siteResult{page: p, skip: skip, err: err}
+	}, func(result interface{}) error {
+		r := result.(*siteResult)
+		if r.err != nil {
+			fmt.Fprintf(os.Stderr, "gtrans: %s: %v\n", r.page.url, r.err)
+			failed++
+		} else if r.skip != "" {
+			fmt.Fprintf(os.Stderr, "gtrans: skip %s (%s)\n", r.page.url, r.skip)
+		}
+		return nil
+	})
+	prog.Finish()
+	if err != nil {
+		return err
+	}
+	if err := m.Save(); err != nil {
+		return err
+	}
+	if err := c.report.Save(); err != nil {
+		return err
+	}
+	if err := c.Close(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("fail to translate %d pages", failed)
+	}
+	return nil
+}
+
+// sitemap returns the pages listed in the sitemap or the sitemap index at
+// rawurl, which is depth indexes deep.
+func (cr *siteCrawler) sitemap(rawurl string, depth int) ([]*sitePage, error) {
+	<-cr.tick
+	ctx, cancel := context.WithTimeout(context.Background(), cr.timeout)
+	b, _, err := download(ctx, rawurl, "application/xml, text/xml", cr.maxSize)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if b, err = ioutil.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+	var sm struct {
+		URLs []struct {
+			Loc     string `xml:"loc"`
+			Lastmod string `xml:"lastmod"`
+		} `xml:"url"`
+		Sitemaps []struct {
+			Loc string `xml:"loc"`
+		} `xml:"sitemap"`
+	}
+	if err := xml.Unmarshal(b, &sm); err != nil {
+		return nil, fmt.Errorf("fail to parse sitemap %s: %v", rawurl, err)
+	}
+	var pages []*sitePage
+	for _, s := range sm.URLs {
+		u, err := url.Parse(strings.TrimSpace(s.Loc))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gtrans: skip %q in %s: %v\n", s.Loc, rawurl, err)
+			continue
+		}
+		pages = append(pages, &sitePage{url: u, lastmod: parseLastmod(s.Lastmod)})
+	}
+	for _, s := range sm.Sitemaps {
+		if depth >= maxSitemapDepth {
+			return nil, fmt.Errorf("sitemap indexes are nested too deep at %s", rawurl)
+		}
+		sub, err := cr.sitemap(strings.TrimSpace(s.Loc), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, sub...)
+	}
+	return pages, nil
+}
+
+// parseLastmod parses <lastmod> of a sitemap in W3C Datetime, or returns the
+// zero time.
+func parseLastmod(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// filter drops the pages on other hosts, disallowed by robots.txt, or
+// written to the same path as a page before them.
+func (cr *siteCrawler) filter(pages []*sitePage) []*sitePage {
+	var filtered []*sitePage
+	paths := map[string]bool{}
+	for _, p := range pages {
+		switch {
+		case p.url.Scheme != "http" && p.url.Scheme != "https":
+			fmt.Fprintf(os.Stderr, "gtrans: skip %s (not http or https)\n", p.url)
+		case p.url.Host != cr.host:
+			fmt.Fprintf(os.Stderr, "gtrans: skip %s (on another host)\n", p.url)
+		case cr.robots != nil && !cr.robots.allows(p.url.RequestURI()):
+			fmt.Fprintf(os.Stderr, "gtrans: skip %s (disallowed by robots.txt)\n", p.url)
+		case paths[sitePath(p.url)]:
+			fmt.Fprintf(os.Stderr, "gtrans: skip %s (duplicate of %s)\n", p.url, sitePath(p.url))
+		default:
+			paths[sitePath(p.url)] = true
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// sitePath returns the slash-separated path of the file of the page at u in
+// the translated site.
+func sitePath(u *url.URL) string {
+	p := path.Clean("/" + u.Path)
+	switch {
+	case p == "/" || strings.HasSuffix(u.Path, "/"):
+		p = path.Join(p, "index.html")
+	case path.Ext(p) == "":
+		p += ".html"
+	}
+	return strings.TrimPrefix(p, "/")
+}
+
+// translatePage downloads and translates the page p into the file under
+// -out, and returns why it's skipped if it is.
+func (cr *siteCrawler) translatePage(ctx context.Context, c *client, m *hashManifest, p *sitePage, target string, prog *progress) (string, error) {
+	rel := sitePath(p.url)
+	out := filepath.Join(outPath, filepath.FromSlash(rel))
+	if !force && !p.lastmod.IsZero() {
+		if fi, err := os.Stat(out); err == nil && fi.ModTime().After(p.lastmod) {
+			return "not modified", nil
+		}
+	}
+	<-cr.tick
+	fctx, cancel := context.WithTimeout(ctx, cr.timeout)
+	page, err := fetchPage(fctx, p.url.String(), cr.maxSize)
+	cancel()
+	if err != nil {
+		return "", err
+	}
+	if page.doc == nil {
+		return "not an HTML page", nil
+	}
+	f := &docFile{path: p.url.String(), rel: rel, out: out, format: htmlFormat, hash: sourceHash([]byte(page.src), target)}
+	if !force && m.outputHash(out) == f.hash {
+		return "unchanged", nil
+	}
+	prog.SetFile(f.rel)
+	translated, err := f.format.translate([]byte(page.src), c.segmentTranslator(ctx, f, target, prog))
+	if err != nil {
+		return "", err
+	}
+	translated = f.format.setLang(translated, target)
+	return "", writeFile(out, m.embed(f.format, out, translated, f.hash))
+}
+
+// robotsRules are the rules of robots.txt for gtrans.
+type robotsRules struct {
+	allow, disallow []string // path prefixes
+	delay           time.Duration
+}
+
+// fetchRobots downloads and parses robots.txt of the site of u. A missing
+// robots.txt allows everything.
+func (cr *siteCrawler) fetchRobots(u *url.URL) (*robotsRules, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cr.timeout)
+	defer cancel()
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	b, res, err := download(ctx, robotsURL.String(), "text/plain", cr.maxSize)
+	if err != nil {
+		if res != nil && res.StatusCode >= 400 && res.StatusCode < 500 {
+			return &robotsRules{}, nil
+		}
+		return nil, err
+	}
+	return parseRobots(string(b)), nil
+}
+
+// parseRobots parses the group of robots.txt for gtrans, or for any user
+// agent if there's none.
+func parseRobots(s string) *robotsRules {
+	var (
+		groups  = map[string]*robotsRules{}
+		current []*robotsRules
+		rules   bool // the lines of current are rules
+	)
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		if key == "user-agent" {
+			if rules {
+				current, rules = nil, false
+			}
+			agent := strings.ToLower(value)
+			if groups[agent] == nil {
+				groups[agent] = &robotsRules{}
+			}
+			current = append(current, groups[agent])
+			continue
+		}
+		rules = true
+		for _, r := range current {
+			switch key {
+			case "allow":
+				if value != "" {
+					r.allow = append(r.allow, value)
+				}
+			case "disallow":
+				if value != "" {
+					r.disallow = append(r.disallow, value)
+				}
+			case "crawl-delay":
+				if d, err := strconv.ParseFloat(value, 64); err == nil {
+					r.delay = time.Duration(d * float64(time.Second))
+				}
+			}
+		}
+	}
+	if r := groups["gtrans"]; r != nil {
+		return r
+	}
+	if r := groups["*"]; r != nil {
+		return r
+	}
+	return &robotsRules{}
+}
+
+// allows returns true if the path isn't disallowed. The longest matching rule
+// wins, and Allow wins a tie.
+func (r *robotsRules) allows(p string) bool {
+	longest := func(prefixes []string) int {
+		n := -1
+		for _, prefix := range prefixes {
+			if robotsMatch(prefix, p) && len(prefix) > n {
+				n = len(prefix)
+			}
+		}
+		return n
+	}
+	return longest(r.allow) >= longest(r.disallow)
+}
+
+// robotsMatch returns true if the path matches the pattern of a rule, which
+// may have * matching any characters and end with $ anchoring it.
+func robotsMatch(pattern, p string) bool {
+	re := "^" + strings.Replace(regexp.QuoteMeta(strings.TrimSuffix(pattern, "$")), `\*`, ".*", -1)
+	if strings.HasSuffix(pattern, "$") {
+		re += "$"
+	}
+	ok, _ := regexp.MatchString(re, p)
+	return ok
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+const slackBotUsageMessage = "" +
+	`Usage:	gtrans slack-bot [flags]
+	gtrans slack-bot connects to Slack by Socket Mode, and translates messages
+	with the engine, translation memory and glossary configured by flags.
+
+	Reacting to a message with an emoji of -reactions replies the translation
+	in its thread, and a slash command, e.g. /translate <text>, answers the
+	translation of the text in the channel.
+
+	export SLACK_BOT_TOKEN=<xoxb- token with channels:history, groups:history, reactions:read, chat:write and commands>
+	export SLACK_APP_TOKEN=<xapp- token with connections:write>
+`
+
+const slackAPI = "https://slack.com/api/"
+
+// slackBot translates Slack messages reacted to with the emojis of
+// reactions, and the text of slash commands.
+type slackBot struct {
+	*chatBot
+	botToken string
+	appToken string
+	// reactions are the target languages by emoji name, which are empty
+	// for the default target language.
+	reactions map[string]string
+}
+
+// slackEnvelope is a message of Socket Mode.
+type slackEnvelope struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Type       string          `json:"type"`
+	Reason     string          `json:"reason"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// slackMessage is a message of a channel.
+type slackMessage struct {
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+	Text     string `json:"text"`
+}
+
+func runSlackBot(args []string) error {
+	fs := flagSetWithGlobals("slack-bot")
+	reactions := fs.String("reactions", "globe_with_meridians", "comma separated emojis replying the translation of the message reacted to with them, each optionally followed by = and the target language, e.g. flag-us=en")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), slackBotUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	b := &slackBot{
+		botToken:  credential("slack-bot", "SLACK_BOT_TOKEN"),
+		appToken:  credential("slack-app", "SLACK_APP_TOKEN"),
+		reactions: parseBotTargets(*reactions),
+	}
+	if b.botToken == "" || b.appToken == "" {
+		return errors.New("SLACK_BOT_TOKEN and SLACK_APP_TOKEN are required")
+	}
+	var err error
+	if b.chatBot, err = newChatBot(); err != nil {
+		return err
+	}
+	return b.run("slack", b.connect)
+}
+
+// connect opens a Socket Mode connection and handles the events until it's
+// closed.
+func (b *slackBot) connect(ctx context.Context) error {
+	var open struct {
+		URL string `json:"url"`
+	}
+	if err := b.call(ctx, "apps.connections.open", b.appToken, nil, &open); err != nil {
+		return err
+	}
+	ws, err := websocket.Dial(open.URL, "", "https://slack.com")
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		ws.Close()
+	}()
+	for {
+		var env slackEnvelope
+		if err := websocket.JSON.Receive(ws, &env); err != nil {
+			return err
+		}
+		if env.EnvelopeID != "" {
+			// Events are acknowledged at once, or Slack sends them again.
+			if err := websocket.JSON.Send(ws, map[string]string{"envelope_id": env.EnvelopeID}); err != nil {
+				return err
+			}
+		}
+		switch env.Type {
+		case "disconnect":
+			return fmt.Errorf("disconnected: %s", env.Reason)
+		case "events_api":
+			go b.handleEvent(ctx, env.Payload)
+		case "slash_commands":
+			go b.handleCommand(ctx, env.Payload)
+		}
+	}
+}
+
+// handleEvent replies the translation of the message reacted to with an emoji
+// of reactions in its thread.
+func (b *slackBot) handleEvent(ctx context.Context, payload json.RawMessage) {
+	var p struct {
+		Event struct {
+			Type     string `json:"type"`
+			Reaction string `json:"reaction"`
+			Item     struct {
+				Type    string `json:"type"`
+				Channel string `json:"channel"`
+				TS      string `json:"ts"`
+			} `json:"item"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		log.Printf("slack: invalid event: %v", err)
+		return
+	}
+	e := p.Event
+	to, ok := b.reactions[e.Reaction]
+	if e.Type != "reaction_added" || e.Item.Type != "message" || !ok {
+		return
+	}
+	if !b.once(e.Item.Channel + "/" + e.Item.TS + "/" + to) {
+		return
+	}
+	msg, err := b.message(ctx, e.Item.Channel, e.Item.TS)
+	if err != nil {
+		log.Printf("slack: fail to get message %s in %s: %v", e.Item.TS, e.Item.Channel, err)
+		return
+	}
+	if strings.TrimSpace(msg.Text) == "" {
+		return
+	}
+	text, err := b.translate(ctx, msg.Text, to)
+	if err != nil {
+		log.Printf("slack: fail to translate message %s in %s: %v", msg.TS, e.Item.Channel, err)
+		return
+	}
+	thread := msg.ThreadTS
+	if thread == "" {
+		thread = msg.TS
+	}
+	params := url.Values{"channel": {e.Item.Channel}, "thread_ts": {thread}, "text": {text}}
+	if err := b.call(ctx, "chat.postMessage", b.botToken, params, nil); err != nil {
+		log.Printf("slack: fail to reply to message %s in %s: %v", msg.TS, e.Item.Channel, err)
+	}
+}
+
+// message returns the message of ts in channel, which may be a reply in a
+// thread.
+func (b *slackBot) message(ctx context.Context, channel, ts string) (*slackMessage, error) {
+	var res struct {
+		Messages []*slackMessage `json:"messages"`
+	}
+	params := url.Values{"channel": {channel}, "latest": {ts}, "oldest": {ts}, "inclusive": {"true"}, "limit": {"1"}}
+	if err := b.call(ctx, "conversations.history", b.botToken, params, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Messages) == 0 || res.Messages[0].TS != ts {
+		// Replies in threads are not in the history of the channel.
+		params = url.Values{"channel": {channel}, "ts": {ts}}
+		if err := b.call(ctx, "conversations.replies", b.botToken, params, &res); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range res.Messages {
+		if m.TS == ts {
+			return m, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+// handleCommand answers the translation of the text of a slash command in the
+// channel.
+func (b *slackBot) handleCommand(ctx context.Context, payload json.RawMessage) {
+	var p struct {
+		Command     string `json:"command"`
+		Text        string `json:"text"`
+		ResponseURL string `json:"response_url"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		log.Printf("slack: invalid slash command: %v", err)
+		return
+	}
+	res := map[string]string{"response_type": "in_channel"}
+	if strings.TrimSpace(p.Text) == "" {
+		res["response_type"], res["text"] = "ephemeral", fmt.Sprintf("Usage: %s <text>", p.Command)
+	} else if text, err := b.translate(ctx, p.Text, ""); err != nil {
+		log.Printf("slack: fail to translate the text of %s: %v", p.Command, err)
+		res["response_type"], res["text"] = "ephemeral", "gtrans: fail to translate the text, see the log of the bot"
+	} else {
+		res["text"] = text
+	}
+	body, _ := json.Marshal(res)
+	req, err := http.NewRequest("POST", p.ResponseURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("slack: invalid response URL of %s: %v", p.Command, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	r, err := b.http.Do(req.WithContext(ctx))
+	if err != nil {
+		log.Printf("slack: fail to answer %s: %v", p.Command, err)
+		return
+	}
+	r.Body.Close()
+}
+
+// call calls the Web API method with token, and decodes the response into
+// out if it's not nil.
+func (b *slackBot) call(ctx context.Context, method, token string, params url.Values, out interface{}) error {
+	req, err := http.NewRequest("POST", slackAPI+method, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := b.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("%s: %s", method, res.Status)
+	}
+	if !status.OK {
+		return fmt.Errorf("%s: %s", method, status.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// speechCacheDir returns the directory to cache synthesized speech in, which
+// is in the cache directory of -cache, or "" if the cache is disabled.
+func speechCacheDir() string {
+	dir := cacheLocation
+	if strings.Contains(dir, "://") {
+		// Audio is too large to share in Redis.
+		dir = defaultCacheDir()
+	}
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "speech")
+}
+
+// speak reads text in lang aloud with the text-to-speech engine. Synthesized
+// speech is cached by the text, the voice and the speed, so that repeated
+// phrases are played without synthesizing them again.
+func speak(ctx context.Context, text, lang string) error {
+	engine := ttsEngine
+	if engine == "auto" {
+		engine = "google"
+		if credential("openai", "OPENAI_API_KEY") != "" {
+			engine = "openai"
+		}
+	}
+	var path string
+	if dir := speechCacheDir(); dir != "" {
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\x00%s\x00%g\x00%s\x00%s", engine, voiceName, speechSpeed, lang, text)
+		key := hex.EncodeToString(h.Sum(nil))
+		path = filepath.Join(dir, key[:2], key+".mp3")
+		if _, err := os.Stat(path); err == nil {
+			now := time.Now()
+			os.Chtimes(path, now, now)
+			return playAudio(ctx, path)
+		}
+	}
+	audio, err := synthesize(ctx, engine, text, lang)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		f, err := ioutil.TempFile("", "gtrans-speak*.mp3")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		f.Close()
+		path = f.Name()
+	}
+	if err := writeSpeechFile(path, audio); err != nil {
+		return err
+	}
+	return playAudio(ctx, path)
+}
+
+// writeSpeechFile writes b to path through a temporary file, so that a
+// partially written file is never played from the cache.
+func writeSpeechFile(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".speech")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// synthesize returns the speech of text in lang in MP3 by the engine.
+func synthesize(ctx context.Context, engine, text, lang string) ([]byte, error) {
+	if speechSpeed < 0.25 || speechSpeed > 4 {
+		return nil, fmt.Errorf("invalid -speed %g: must be between 0.25 and 4", speechSpeed)
+	}
+	switch engine {
+	case "google":
+		key := credential("google", "GOOGLE_TRANSLATE_API_KEY")
+		if key == "" {
+			return nil, errors.New("GOOGLE_TRANSLATE_API_KEY is not set. Export it or run 'gtrans auth google'")
+		}
+		return googleSynthesize(ctx, flagHTTPClient(), "https://texttospeech.googleapis.com/v1/text:synthesize", key, text, lang)
+	case "openai":
+		key := credential("openai", "OPENAI_API_KEY")
+		if key == "" {
+			return nil, errors.New("OPENAI_API_KEY is not set. Export it or run 'gtrans auth openai'")
+		}
+		baseURL := "https://api.openai.com/v1"
+		if u := os.Getenv("OPENAI_BASE_URL"); u != "" {
+			baseURL = strings.TrimRight(u, "/")
+		}
+		return openAISynthesize(ctx, flagHTTPClient(), baseURL+"/audio/speech", key, text)
+	}
+	return nil, fmt.Errorf("invalid -tts %q: must be auto, google or openai", engine)
+}
+
+// googleSynthesize synthesizes text with Cloud Text-to-Speech API in the
+// voice of -voice, e.g. ja-JP-Neural2-B, or the default voice of lang.
+// https://cloud.google.com/text-to-speech/docs/reference/rest/v1/text/synthesize
+func googleSynthesize(ctx context.Context, hc *http.Client, endpoint, key, text, lang string) ([]byte, error) {
+	voice := map[string]string{"languageCode": lang}
+	if voiceName != "" {
+		voice["name"] = voiceName
+		// The language of the voice is the prefix of its name.
+		if parts := strings.SplitN(voiceName, "-", 3); len(parts) == 3 {
+			voice["languageCode"] = parts[0] + "-" + parts[1]
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"input":       map[string]string{"text": text},
+		"voice":       voice,
+		"audioConfig": map[string]interface{}{"audioEncoding": "MP3", "speakingRate": speechSpeed},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint+"?key="+url.QueryEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to call Text-to-Speech API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fail to call Text-to-Speech API: %s", resp.Status)
+	}
+	var result struct {
+		AudioContent string `json:"audioContent"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("fail to decode Text-to-Speech API response: %v", err)
+	}
+	return base64.StdEncoding.DecodeString(result.AudioContent)
+}
+
+// openAISynthesize synthesizes text with OpenAI Audio API in the voice of
+// -voice (default: alloy), which speaks the language of text.
+// https://platform.openai.com/docs/api-reference/audio/createSpeech
+func openAISynthesize(ctx context.Context, hc *http.Client, endpoint, key, text string) ([]byte, error) {
+	voice := voiceName
+	if voice == "" {
+		voice = "alloy"
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"model":           "tts-1",
+		"input":           text,
+		"voice":           voice,
+		"speed":           speechSpeed,
+		"response_format": "mp3",
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to call OpenAI Audio API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fail to call OpenAI Audio API: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// audioPlayers are the commands playing MP3 files, tried in order.
+var audioPlayers = [][]string{
+	{"afplay"},
+	{"mpv", "--really-quiet", "--no-video"},
+	{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet"},
+	{"mpg123", "-q"},
+	{"play", "-q"},
+}
+
+// playAudio plays the MP3 file at path with the first player found.
+func playAudio(ctx context.Context, path string) error {
+	var names []string
+	for _, p := range audioPlayers {
+		names = append(names, p[0])
+		bin, err := lookCommand(p[0])
+		if err != nil {
+			continue
+		}
+		var stderr bytes.Buffer
+		if err := runCommand(ctx, bin, append(p[1:len(p):len(p)], path), nil, ioutil.Discard, &stderr); err != nil {
+			return fmt.Errorf("fail to play with %s: %v: %s", p[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+	return fmt.Errorf("no audio player is found. Install one of %s", strings.Join(names, ", "))
+}
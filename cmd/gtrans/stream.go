@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/minodisk/gtrans"
+)
+
+// runStreamTranslation translates the text read from r in chunks, writing
+// each translated chunk to w as soon as it's translated.
+func runStreamTranslation(r io.Reader, w io.Writer, targetLang string) error {
+	color, err := newColorizer(colorMode, w)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	last, translated := "", false
+	err = c.TranslateChunks(context.Background(), r, &gtrans.StreamOptions{TargetLang: targetLang}, func(lead string, res *gtrans.Result, trail string) error {
+		fmt.Fprint(w, lead)
+		if res != nil {
+			if err := checkQuality(res); err != nil {
+				return err
+			}
+			fmt.Fprint(w, color.translation(res.Highlight(color.protected)))
+			translated = true
+		}
+		_, err := fmt.Fprint(w, trail)
+		if last = trail; res == nil {
+			last = lead
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !translated {
+		// White spaces are written as they are.
+		if err := emptyInput(); err != nil {
+			return err
+		}
+		return c.Close()
+	}
+	if !strings.HasSuffix(last, "\n") {
+		fmt.Fprintln(w)
+	}
+	return c.Close()
+}
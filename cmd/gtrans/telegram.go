@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minodisk/gtrans"
+)
+
+const telegramBotUsageMessage = "" +
+	`Usage:	gtrans telegram-bot [flags]
+	gtrans telegram-bot polls Telegram for the messages sent or forwarded to the
+	bot, and replies their translations with the engine, translation memory
+	and glossary configured by flags.
+
+	Messages are translated into -to, or into GOOGLE_TRANSLATE_SECOND_LANG if
+	they're already written in -to.
+
+	export TELEGRAM_BOT_TOKEN=<token of the bot given by @BotFather>
+`
+
+const (
+	telegramAPI = "https://api.telegram.org/bot"
+	// telegramMaxLen is the maximum length of a message in characters.
+	telegramMaxLen = 4096
+	// telegramPollTimeout is the timeout of long polling in seconds.
+	telegramPollTimeout = 50
+)
+
+// telegramBot translates the messages sent or forwarded to the bot.
+type telegramBot struct {
+	*chatBot
+	token string
+	// users are the IDs and the usernames of the users allowed to use the
+	// bot, or nil to allow anyone.
+	users  map[string]bool
+	offset int64
+}
+
+// telegramMessage is a message sent to the bot.
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	From      *struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text    string `json:"text"`
+	Caption string `json:"caption"`
+}
+
+func runTelegramBot(args []string) error {
+	fs := flagSetWithGlobals("telegram-bot")
+	users := fs.String("users", "", "comma separated IDs or usernames of the users allowed to use the bot (default: anyone)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), telegramBotUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	b := &telegramBot{token: credential("telegram-bot", "TELEGRAM_BOT_TOKEN")}
+	if b.token == "" {
+		return errors.New("TELEGRAM_BOT_TOKEN is required")
+	}
+	if *users != "" {
+		b.users = map[string]bool{}
+		for name := range parseBotTargets(*users) {
+			b.users[strings.TrimPrefix(name, "@")] = true
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "gtrans: anyone finding the bot can translate with your API keys. Give -users to allow only them")
+	}
+	var err error
+	if b.chatBot, err = newChatBot(); err != nil {
+		return err
+	}
+	// Long polling waits for updates before the timeout.
+	b.http = &http.Client{
+		Transport: gtrans.SharedTransport(),
+		Timeout:   telegramPollTimeout*time.Second + botTimeout,
+	}
+	return b.run("telegram", b.poll)
+}
+
+// poll gets the updates of the bot by long polling, and replies the
+// translations of the messages until an error occurs.
+func (b *telegramBot) poll(ctx context.Context) error {
+	for {
+		var updates []struct {
+			UpdateID int64            `json:"update_id"`
+			Message  *telegramMessage `json:"message"`
+		}
+		params := map[string]interface{}{
+			"offset":          b.offset,
+			"timeout":         telegramPollTimeout,
+			"allowed_updates": []string{"message"},
+		}
+		if err := b.call(ctx, "getUpdates", params, &updates); err != nil {
+			return err
+		}
+		for _, u := range updates {
+			// Updates before the offset are confirmed, and not sent again.
+			b.offset = u.UpdateID + 1
+			if u.Message != nil {
+				go b.handleMessage(ctx, u.Message)
+			}
+		}
+	}
+}
+
+func (b *telegramBot) allows(m *telegramMessage) bool {
+	if b.users == nil {
+		return true
+	}
+	return m.From != nil && (b.users[strconv.FormatInt(m.From.ID, 10)] || m.From.Username != "" && b.users[m.From.Username])
+}
+
+// handleMessage replies the translation of the text or the caption of m.
+func (b *telegramBot) handleMessage(ctx context.Context, m *telegramMessage) {
+	if !b.allows(m) {
+		log.Printf("telegram: message from a user not in -users is ignored")
+		return
+	}
+	text := m.Text
+	if text == "" {
+		text = m.Caption
+	}
+	if text == "/start" || text == "/help" {
+		text = "Send or forward me messages, and I reply their translations."
+		b.send(ctx, m, text)
+		return
+	}
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	translated, err := b.translate(ctx, text, "")
+	if err != nil {
+		log.Printf("telegram: fail to translate message %d: %v", m.MessageID, err)
+		translated = "gtrans: fail to translate the message, see the log of the bot"
+	}
+	b.send(ctx, m, translated)
+}
+
+// send replies text to m.
+func (b *telegramBot) send(ctx context.Context, m *telegramMessage, text string) {
+	for _, t := range splitMessage(text, telegramMaxLen) {
+		params := map[string]interface{}{
+			"chat_id":             m.Chat.ID,
+			"text":                t,
+			"reply_to_message_id": m.MessageID,
+		}
+		if err := b.call(ctx, "sendMessage", params, nil); err != nil {
+			log.Printf("telegram: fail to reply to message %d: %v", m.MessageID, err)
+			return
+		}
+	}
+}
+
+// call calls the Bot API method, and decodes the result into out if it's not
+// nil.
+func (b *telegramBot) call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", telegramAPI+b.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := b.http.Do(req.WithContext(ctx))
+	if err != nil {
+		// The URL has the token.
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var r struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(resBody, &r); err != nil {
+		return fmt.Errorf("%s: %s", method, res.Status)
+	}
+	if !r.OK {
+		return fmt.Errorf("%s: %s", method, r.Description)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, out)
+}
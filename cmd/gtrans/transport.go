@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// tlsVersions are the values of -tls-min-version.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tuneTransport sets the limits of connections, the minimum TLS version and
+// the dial timeout of -max-idle-conns, -max-conns-per-host, -tls-min-version
+// and -dial-timeout to t, e.g. for a strict proxy or a flaky link.
+func tuneTransport(t *http.Transport) error {
+	if maxIdleConns < 0 {
+		return fmt.Errorf("invalid -max-idle-conns %d: must not be negative", maxIdleConns)
+	}
+	if connsPerHost < 0 {
+		return fmt.Errorf("invalid -max-conns-per-host %d: must not be negative", connsPerHost)
+	}
+	if dialTimeout < 0 {
+		return fmt.Errorf("invalid -dial-timeout %v: must not be negative", dialTimeout)
+	}
+	version, ok := tlsVersions[tlsMinVersion]
+	if !ok {
+		return fmt.Errorf("invalid -tls-min-version %q: must be 1.0, 1.1, 1.2 or 1.3", tlsMinVersion)
+	}
+	t.MaxIdleConnsPerHost = maxIdleConns
+	if t.MaxIdleConns < maxIdleConns {
+		t.MaxIdleConns = maxIdleConns
+	}
+	t.MaxConnsPerHost = connsPerHost
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.MinVersion = version
+	t.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	return nil
+}
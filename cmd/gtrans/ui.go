@@ -0,0 +1,28 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// uiHTML is the web UI of gtrans serve -ui: a page with the source and the
+// translation side by side, which calls the endpoints of the server. The
+// history of translations is kept in the browser.
+//
+//go:embed ui.html
+var uiHTML []byte
+
+func serveUI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeJSONError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'unsafe-inline'; script-src 'unsafe-inline'")
+	w.Write(uiHTML)
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An undo journal records the previous contents of the files a run of -file
+// -out, -dir, -i or gtrans site writes, so that `gtrans undo` can restore
+// them. A journal is a directory containing journal.json and a file of the
+// previous content of each file which existed.
+//
+// Only the last maxUndoRuns journals are kept.
+const maxUndoRuns = 10
+
+// undoJournal is the journal of a run.
+type undoJournal struct {
+	ID      string       `json:"id"`
+	Command string       `json:"command"`
+	Created time.Time    `json:"created"`
+	Entries []*undoEntry `json:"entries"`
+
+	mu    sync.Mutex
+	dir   string
+	paths map[string]*undoEntry
+}
+
+// undoEntry is a file written by a run.
+type undoEntry struct {
+	Path string `json:"path"`
+	// Backup is the name of the file of the previous content in the journal,
+	// or empty if the run created the file.
+	Backup string      `json:"backup,omitempty"`
+	Mode   os.FileMode `json:"mode,omitempty"`
+	// Written is the hash of the content the run wrote, which tells whether
+	// the file is modified after the run.
+	Written string `json:"written,omitempty"`
+}
+
+// journal is the undo journal of the run, or nil if the run doesn't record
+// one.
+var journal *undoJournal
+
+func undoDir() string {
+	return filepath.Join(gtransDataDir(), "undo")
+}
+
+// startUndoJournal starts recording the files the run writes in a journal,
+// unless -undo=false is given.
+func startUndoJournal() {
+	if !undoEnabled || journal != nil {
+		return
+	}
+	b := make([]byte, 2)
+	rand.Read(b)
+	now := time.Now()
+	journal = &undoJournal{
+		ID:      now.Format("20060102-150405-") + hex.EncodeToString(b),
+		Command: strings.Join(os.Args, " "),
+		Created: now,
+		paths:   map[string]*undoEntry{},
+	}
+	journal.dir = filepath.Join(undoDir(), journal.ID)
+}
+
+// record saves the content of the file at path before the run writes it for
+// the first time. It does nothing if j is nil.
+func (j *undoJournal) record(path string) error {
+	if j == nil {
+		return nil
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.paths[path] != nil {
+		return nil
+	}
+	if len(j.Entries) == 0 {
+		if err := os.MkdirAll(j.dir, 0700); err != nil {
+			return err
+		}
+		pruneUndoJournals()
+	}
+	e := &undoEntry{Path: path}
+	if fi, err := os.Stat(path); err == nil {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		e.Backup = strconv.Itoa(len(j.Entries))
+		e.Mode = fi.Mode().Perm()
+		if err := ioutil.WriteFile(filepath.Join(j.dir, e.Backup), src, 0600); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	j.paths[path] = e
+	j.Entries = append(j.Entries, e)
+	return j.save()
+}
+
+// wrote records the content b written to the file at path. It does nothing
+// if j is nil.
+func (j *undoJournal) wrote(path string, b []byte) error {
+	if j == nil {
+		return nil
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e := j.paths[path]
+	if e == nil {
+		return nil
+	}
+	e.Written = contentHash(b)
+	return j.save()
+}
+
+func (j *undoJournal) save() error {
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(j.dir, "journal.json"), append(b, '\n'), 0600)
+}
+
+func contentHash(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// loadUndoJournals returns the journals from the oldest.
+func loadUndoJournals() ([]*undoJournal, error) {
+	entries, err := ioutil.ReadDir(undoDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var journals []*undoJournal
+	for _, e := range entries {
+		dir := filepath.Join(undoDir(), e.Name())
+		b, err := ioutil.ReadFile(filepath.Join(dir, "journal.json"))
+		if err != nil {
+			continue
+		}
+		j := &undoJournal{dir: dir}
+		if json.Unmarshal(b, j) == nil {
+			journals = append(journals, j)
+		}
+	}
+	sort.Slice(journals, func(i, k int) bool { return journals[i].Created.Before(journals[k].Created) })
+	return journals, nil
+}
+
+// pruneUndoJournals removes the oldest journals but the last maxUndoRuns - 1,
+// making room for a new one.
+func pruneUndoJournals() {
+	journals, err := loadUndoJournals()
+	if err != nil {
+		return
+	}
+	for len(journals) >= maxUndoRuns {
+		os.RemoveAll(journals[0].dir)
+		journals = journals[1:]
+	}
+}
+
+const undoUsageMessage = "" +
+	`Usage:	gtrans undo [flags] [run-id|list]
+	gtrans undo restores the files written by the last run of -file -out, -dir,
+	-i or gtrans site, or by the run of the ID, to their previous contents, and
+	removes the files it created. 'gtrans undo list' lists the runs which can
+	be undone, from the latest.
+
+	Files modified after the run are not restored unless -force is given.
+	-plan lists what would be restored without changing anything.
+`
+
+func runUndo(w io.Writer, args []string) error {
+	fs := flagSetWithGlobals("undo")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), undoUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return errors.New("usage: gtrans undo [run-id|list]")
+	}
+	journals, err := loadUndoJournals()
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 && args[0] == "list" {
+		for i := len(journals) - 1; i >= 0; i-- {
+			j := journals[i]
+			fmt.Fprintf(w, "%s\t%s\t%d files\t%s\n", j.ID, j.Created.Format("2006-01-02 15:04:05"), len(j.Entries), j.Command)
+		}
+		return nil
+	}
+	if len(journals) == 0 {
+		return errors.New("no run to undo")
+	}
+	j := journals[len(journals)-1]
+	if len(args) == 1 {
+		j = nil
+		for _, jj := range journals {
+			if jj.ID == args[0] {
+				j = jj
+			}
+		}
+		if j == nil {
+			return fmt.Errorf("run %s is not found. See 'gtrans undo list'", args[0])
+		}
+	}
+	return j.undo(w)
+}
+
+// undo restores the files of the journal, and removes the journal if all of
+// them are restored.
+func (j *undoJournal) undo(w io.Writer) error {
+	var modified []*undoEntry
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		e := j.Entries[i]
+		current, err := ioutil.ReadFile(e.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if e.Written != "" && !force && (err != nil || contentHash(current) != e.Written) {
+			fmt.Fprintf(os.Stderr, "gtrans: skip %s (modified after the run). Use -force to restore it\n", e.Path)
+			modified = append([]*undoEntry{e}, modified...)
+			continue
+		}
+		if e.Backup == "" {
+			if plan {
+				fmt.Fprintf(w, "remove\t%s\n", e.Path)
+				continue
+			}
+			if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if plan {
+			fmt.Fprintf(w, "restore\t%s\n", e.Path)
+			continue
+		}
+		prev, err := ioutil.ReadFile(filepath.Join(j.dir, e.Backup))
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(e.Path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(e.Path, prev, e.Mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(e.Path, e.Mode); err != nil {
+			return err
+		}
+	}
+	if plan {
+		return nil
+	}
+	if len(modified) > 0 {
+		// The restored files are not restored again.
+		j.Entries = modified
+		if err := j.save(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%d files are not restored. The run can be undone again with -force", len(modified))
+	}
+	return os.RemoveAll(j.dir)
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const urlUsageMessage = "" +
+	`Usage:	gtrans url [flags] <url>
+	gtrans url downloads a web page, extracts the text of its main article and
+	writes the translation of it as paragraphs of plain text. Navigation,
+	sidebars, footers and ads are left out unless -full-page is given.
+
+	With -html, it writes the whole page as an HTML document instead, keeping
+	its structure with the text translated, so that it can be opened in a
+	browser. Relative links and images keep working by <base> added to it.
+
+	The charset of the page is detected from the Content-Type header, the
+	<meta> tags or the content.
+`
+
+// webPage is a downloaded web page.
+type webPage struct {
+	url  *url.URL // after redirects
+	doc  *html.Node
+	src  string // HTML of the page decoded into UTF-8
+	text string // content of text/plain pages
+}
+
+func runURL(w io.Writer, args []string) error {
+	fs := flagSetWithGlobals("url")
+	maxSize := byteSize(5 << 20)
+	fs.Var(&maxSize, "max-size", "fail if the page is larger than `size`, e.g. 5M")
+	fullPage := fs.Bool("full-page", false, "translate all the text of the page instead of its main article")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of downloading the page")
+	asHTML := fs.Bool("html", false, "write the translated page as an HTML document instead of plain text")
+	base := fs.Bool("base", true, "add <base> of the URL of the page to the HTML document of -html, so that its relative links keep working")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), urlUsageMessage)
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("a URL is required")
+	}
+	target := targetLang
+	if target == "" {
+		if target, err = detectTargetLang(); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	page, err := fetchPage(ctx, args[0], int64(maxSize))
+	cancel()
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	prog, err := newProgress(progressMode, os.Stderr, 0)
+	if err != nil {
+		return err
+	}
+	f := &docFile{path: page.url.String(), rel: page.url.String(), format: plainTextFormat}
+	src := page.plainText(*fullPage)
+	if *asHTML && page.doc != nil {
+		f.format, src = htmlFormat, page.src
+	}
+	translated, err := f.format.translate([]byte(src), c.segmentTranslator(context.Background(), f, target, prog))
+	prog.Finish()
+	if err != nil {
+		return err
+	}
+	if f.format == htmlFormat {
+		baseURL := ""
+		if *base {
+			baseURL = page.url.String()
+		}
+		translated = []byte(rewriteHTMLHead(string(translated), target, baseURL))
+	}
+	if _, err := w.Write(translated); err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// fetchPage downloads the HTML or plain text page at rawurl, which must not
+// be larger than maxSize bytes, and decodes it into UTF-8.
+func fetchPage(ctx context.Context, rawurl string, maxSize int64) (*webPage, error) {
+	b, res, err := download(ctx, rawurl, "text/html, text/plain;q=0.9", maxSize)
+	if err != nil {
+		return nil, err
+	}
+	ct := res.Header.Get("Content-Type")
+	mt, _, _ := mime.ParseMediaType(ct)
+	if mt == "" {
+		mt = http.DetectContentType(b)
+		mt, _, _ = mime.ParseMediaType(mt)
+	}
+	if mt != "text/html" && mt != "application/xhtml+xml" && mt != "text/plain" {
+		return nil, fmt.Errorf("%s is %s, not a web page", rawurl, mt)
+	}
+	r, err := charset.NewReader(bytes.NewReader(b), ct)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = ioutil.ReadAll(r); err != nil {
+		return nil, err
+	}
+	page := &webPage{url: res.Request.URL}
+	if mt == "text/plain" {
+		page.text = string(b)
+		return page, nil
+	}
+	page.src = string(b)
+	if page.doc, err = html.Parse(strings.NewReader(page.src)); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// download gets the content at rawurl, which must not be larger than maxSize
+// bytes.
+func download(ctx context.Context, rawurl, accept string, maxSize int64) ([]byte, *http.Response, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, nil, fmt.Errorf("invalid URL %q: must be http or https", rawurl)
+	}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	ua := userAgent
+	if ua == "" {
+		ua = "gtrans"
+	}
+	req.Header.Set("User-Agent", ua)
+	req.Header.Set("Accept", accept)
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, res, fmt.Errorf("fail to download %s: %s", rawurl, res.Status)
+	}
+	if res.ContentLength > maxSize {
+		return nil, res, fmt.Errorf("%s is larger than -max-size %v", rawurl, (*byteSize)(&maxSize))
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return nil, res, err
+	}
+	if int64(len(b)) > maxSize {
+		return nil, res, fmt.Errorf("%s is larger than -max-size %v", rawurl, (*byteSize)(&maxSize))
+	}
+	return b, res, nil
+}
+
+// plainText returns the title and the paragraphs of the main article of the
+// page, or of all the text of it if fullPage is true, separated by blank
+// lines.
+func (p *webPage) plainText(fullPage bool) string {
+	if p.doc == nil {
+		return p.text
+	}
+	nodes := []*html.Node{p.doc}
+	if !fullPage {
+		if article := articleNodes(p.doc); article != nil {
+			nodes = article
+		}
+	}
+	var paras []string
+	for _, n := range nodes {
+		paras = append(paras, pageParagraphs(n, !fullPage)...)
+	}
+	if t := pageTitle(p.doc); t != "" {
+		paras = append([]string{t}, paras...)
+	}
+	return strings.Join(paras, "\n\n") + "\n"
+}
+
+// pageTitle returns the text of the <title> of the document.
+func pageTitle(doc *html.Node) string {
+	var title string
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "title" {
+			title = collapseSpace(nodeText(n))
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "body" {
+			return
+		}
+		for c := n.FirstChild; c != nil && title == ""; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	return title
+}
+
+// skippedElements are the elements whose text isn't a part of the page.
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "canvas": true, "iframe": true, "object": true, "button": true,
+	"select": true, "textarea": true,
+}
+
+// blockElements are the elements separating paragraphs.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true,
+	"details": true, "dialog": true, "div": true, "dl": true, "dt": true,
+	"fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "summary": true, "table": true,
+	"td": true, "th": true, "tr": true, "ul": true, "body": true,
+}
+
+// pageParagraphs returns the paragraphs of the text under n. Spaces are
+// collapsed except in <pre>, and <br> breaks lines. If article is true,
+// elements which aren't a part of the article, e.g. share buttons in it, are
+// left out.
+func pageParagraphs(n *html.Node, article bool) []string {
+	var (
+		paras []string
+		b     strings.Builder
+	)
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" {
+			paras = append(paras, s)
+		}
+		b.Reset()
+	}
+	var walk func(n *html.Node, pre bool)
+	walk = func(n *html.Node, pre bool) {
+		switch n.Type {
+		case html.TextNode:
+			if pre {
+				b.WriteString(n.Data)
+			} else {
+				b.WriteString(collapseSpace(n.Data))
+			}
+			return
+		case html.ElementNode:
+			if skippedElements[n.Data] || article && (nonArticleElements[n.Data] || unlikelyArticle(n)) {
+				return
+			}
+			if n.Data == "br" {
+				b.WriteString("\n")
+				return
+			}
+			if n.Data == "pre" {
+				pre = true
+			}
+		}
+		block := n.Type == html.ElementNode && blockElements[n.Data]
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, pre)
+		}
+		if block {
+			flush()
+		}
+	}
+	walk(n, false)
+	flush()
+	return paras
+}
+
+// nodeText returns the text under n as it is.
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
+
+// collapseSpace replaces runs of white space in s with a space.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
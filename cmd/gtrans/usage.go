@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minodisk/gtrans"
+)
+
+// usagePath returns the path of the usage log of -usage-log.
+func usagePath() string {
+	return filepath.Join(gtransDataDir(), "usage.json")
+}
+
+// parseSince parses the start of the period of 'gtrans stats': a number of
+// days or weeks ago, e.g. 30d or 4w, a duration, e.g. 72h, or a date.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	if n := len(s) - 1; n > 0 && (s[n] == 'd' || s[n] == 'w') {
+		if days, err := strconv.Atoi(s[:n]); err == nil && days >= 0 {
+			if s[n] == 'w' {
+				days *= 7
+			}
+			y, m, d := now.Date()
+			return time.Date(y, m, d-days+1, 0, 0, 0, 0, now.Location()), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -since %q: must be like 30d, 4w, 72h or 2006-01-02", s)
+}
+
+func runStatsCommand(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	since := fs.String("since", "30d", "start of the period: days or weeks ago (e.g. 30d or 4w), a duration (e.g. 72h) or a date (2006-01-02)")
+	format := fs.String("format", "table", "output format: table or json")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return errors.New("usage: gtrans stats [-since 30d] [-format table|json]")
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid -format %q: must be table or json", *format)
+	}
+	start, err := parseSince(*since, time.Now())
+	if err != nil {
+		return err
+	}
+	all, err := gtrans.LoadUsage(usagePath())
+	if err != nil {
+		return err
+	}
+	from := start.Format("2006-01-02")
+	var days []*gtrans.UsageDay
+	total := &gtrans.UsageDay{Engines: map[string]*gtrans.UsageCount{}, Pairs: map[string]int{}}
+	for date, d := range all {
+		if date < from {
+			continue
+		}
+		days = append(days, d)
+		total.Runs += d.Runs
+		total.Texts += d.Texts
+		total.Chars += d.Chars
+		total.Calls += d.Calls
+		for name, c := range d.Engines {
+			t := total.Engines[name]
+			if t == nil {
+				t = &gtrans.UsageCount{}
+				total.Engines[name] = t
+			}
+			t.Calls += c.Calls
+			t.Chars += c.Chars
+		}
+		for pair, n := range d.Pairs {
+			total.Pairs[pair] += n
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{"since": from, "days": days, "total": total})
+	}
+	if len(days) == 0 {
+		fmt.Fprintf(w, "no usage since %s\n", from)
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tRUNS\tTEXTS\tCHARS\tCALLS")
+	for _, d := range days {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", d.Date, d.Runs, d.Texts, d.Chars, d.Calls)
+	}
+	fmt.Fprintf(tw, "total\t%d\t%d\t%d\t%d\n", total.Runs, total.Texts, total.Chars, total.Calls)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(total.Engines) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "ENGINE\tCALLS\tCHARS")
+		names := make([]string, 0, len(total.Engines))
+		for name := range total.Engines {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(tw, "%s\t%d\t%d\n", name, total.Engines[name].Calls, total.Engines[name].Chars)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(total.Pairs) > 0 {
+		fmt.Fprintln(w)
+		pairs := make([]string, 0, len(total.Pairs))
+		for pair := range total.Pairs {
+			pairs = append(pairs, pair)
+		}
+		// The most used first
+		sort.Slice(pairs, func(i, j int) bool {
+			a, b := total.Pairs[pairs[i]], total.Pairs[pairs[j]]
+			return a > b || a == b && pairs[i] < pairs[j]
+		})
+		tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "PAIR\tTEXTS")
+		for _, pair := range pairs {
+			fmt.Fprintf(tw, "%s\t%d\n", pair, total.Pairs[pair])
+		}
+		return tw.Flush()
+	}
+	return nil
+}
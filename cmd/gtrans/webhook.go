@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/minodisk/gtrans"
+)
+
+// webhook translates the fields of JSON payloads posted to Path, e.g. the
+// events of GitHub issues and comments, and forwards the translated payloads,
+// or the requests rendered from them by Template, to Forward. Webhooks are
+// configured by the file of -webhooks, a JSON array of them.
+type webhook struct {
+	Path string `json:"path"`
+	// Fields are the paths of the fields to translate separated by dots,
+	// e.g. "issue.title" or "commits.*.message". "*" matches any element of
+	// an array.
+	Fields []string `json:"fields"`
+	To     string   `json:"to,omitempty"` // -to by default
+	// Forward is the URL to forward to, which is a Go text/template executed
+	// with the translated payload as well, e.g.
+	// https://api.github.com/repos/{{.repository.full_name}}/issues/{{.issue.number}}/comments.
+	// Its scheme and host must be literal, so that payloads can't send the
+	// headers anywhere else.
+	Forward string `json:"forward"`
+	Method  string `json:"method,omitempty"` // POST by default
+	// Headers are the headers of the forwarded requests. $VAR in the values
+	// is replaced with the environment variable, e.g. "Bearer $GITHUB_TOKEN".
+	Headers map[string]string `json:"headers,omitempty"`
+	// Template is a Go text/template of the forwarded body executed with the
+	// translated payload, e.g. {"body": {{json .comment.body}}}.
+	Template string `json:"template,omitempty"`
+	// Secret verifies the HMAC-SHA256 signature of payloads in the
+	// X-Hub-Signature-256 header as GitHub signs them. $VAR is replaced
+	// with the environment variable. Without it, payloads need an API key of
+	// -api-keys, and webhooks without either are rejected.
+	Secret string `json:"secret,omitempty"`
+	// Skip are values of fields by path whose payloads are accepted without
+	// translating them, e.g. {"sender.login": "translator-bot"} not to
+	// translate the comments posted by the webhook itself.
+	Skip map[string]string `json:"skip,omitempty"`
+
+	fields  [][]string
+	origin  *url.URL // the scheme and the host of Forward
+	forward *template.Template
+	tmpl    *template.Template
+}
+
+// loadWebhooks loads the webhooks configured by the file at path. Unless
+// requests need API keys, every webhook needs a secret.
+func loadWebhooks(path string, keys bool) ([]*webhook, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []*webhook
+	if err := json.Unmarshal(b, &hooks); err != nil {
+		return nil, fmt.Errorf("fail to read webhooks %s: %v", path, err)
+	}
+	seen := map[string]bool{}
+	for _, rt := range routes {
+		seen[rt.path] = true
+	}
+	for _, h := range hooks {
+		if !strings.HasPrefix(h.Path, "/") || seen[h.Path] || h.Path == "/" || h.Path == "/openapi.json" {
+			return nil, fmt.Errorf("%s: invalid path %q of a webhook: must start with / and be unique", path, h.Path)
+		}
+		seen[h.Path] = true
+		if len(h.Fields) == 0 || h.Forward == "" {
+			return nil, fmt.Errorf("%s: webhook %s needs fields and forward", path, h.Path)
+		}
+		for _, f := range h.Fields {
+			h.fields = append(h.fields, strings.Split(f, "."))
+		}
+		if h.Method == "" {
+			h.Method = "POST"
+		}
+		h.Secret = os.ExpandEnv(h.Secret)
+		if h.Secret == "" && !keys {
+			return nil, fmt.Errorf("%s: webhook %s needs secret unless -api-keys is given, or anyone can forward payloads with its headers", path, h.Path)
+		}
+		if h.origin, err = forwardOrigin(h.Forward); err != nil {
+			return nil, fmt.Errorf("%s: webhook %s: %v", path, h.Path, err)
+		}
+		if h.forward, err = template.New(h.Path).Parse(h.Forward); err != nil {
+			return nil, fmt.Errorf("%s: webhook %s: %v", path, h.Path, err)
+		}
+		if h.Template != "" {
+			if h.tmpl, err = template.New(h.Path).Funcs(template.FuncMap{"json": webhookJSON}).Parse(h.Template); err != nil {
+				return nil, fmt.Errorf("%s: webhook %s: %v", path, h.Path, err)
+			}
+		}
+	}
+	return hooks, nil
+}
+
+// forwardOrigin returns the scheme and the host of the URL template forward,
+// which must be written before any action.
+func forwardOrigin(forward string) (*url.URL, error) {
+	literal := forward
+	templated := false
+	if i := strings.Index(forward, "{{"); i >= 0 {
+		literal, templated = forward[:i], true
+	}
+	u, err := url.Parse(literal)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid forward %q: must start with http:// or https:// and a host", forward)
+	}
+	// The host is complete only if something follows it.
+	rest := literal[len(u.Scheme)+len("://"):]
+	if templated && strings.IndexAny(rest, "/?#") < 0 {
+		return nil, fmt.Errorf("invalid forward %q: the host must not be a template", forward)
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host}, nil
+}
+
+func webhookJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// serveWebhook returns the handler of the payloads of h, which answers with
+// the status of the forwarded request once it's done.
+func (s *server) serveWebhook(h *webhook) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		if h.Secret != "" {
+			if !h.verify(body, r.Header.Get("X-Hub-Signature-256")) {
+				writeJSONError(w, http.StatusUnauthorized, "invalid signature")
+				return
+			}
+		} else if !s.authorize(w, r) {
+			return
+		}
+		var payload interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid payload: %v", err))
+			return
+		}
+		skipped := []byte("{\"skipped\":true}\n")
+		if h.skips(payload) {
+			writeJSON(w, http.StatusOK, skipped)
+			return
+		}
+		status, err := s.bridge(r.Context(), h, body)
+		if err != nil {
+			status, body := errorResponse(r, err)
+			writeJSON(w, status, body)
+			return
+		}
+		if status == 0 {
+			writeJSON(w, http.StatusOK, skipped)
+			return
+		}
+		b, _ := json.Marshal(map[string]int{"forwarded": status})
+		writeJSON(w, http.StatusOK, append(b, '\n'))
+	}
+}
+
+// verify returns true if sig is "sha256=" and the HMAC-SHA256 of body with
+// the secret of h in hex.
+func (h *webhook) verify(body []byte, sig string) bool {
+	if !strings.HasPrefix(sig, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(h.Secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// skips returns true if a field of payload has the value in Skip.
+func (h *webhook) skips(payload interface{}) bool {
+	for path, want := range h.Skip {
+		v := payload
+		for _, key := range strings.Split(path, ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				v = nil
+				break
+			}
+			v = m[key]
+		}
+		if v != nil && fmt.Sprint(v) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// bridge translates the fields of the payload body, and forwards it to the
+// URL of h. It returns the status of the forwarded request, and an error if
+// it's not successful. Payloads without the fields, e.g. GitHub's ping, are
+// not forwarded, and 0 is returned. Texts of the fields are Markdown, as in
+// GitHub.
+func (s *server) bridge(ctx context.Context, h *webhook, body []byte) (int, error) {
+	to := h.To
+	if to == "" {
+		to = s.targetLang
+	}
+	parts, err := jsonFieldParts(body, h.fields)
+	if err != nil {
+		return 0, badRequest("invalid payload: %v", err)
+	}
+	found := false
+	for _, p := range parts {
+		found = found || p.translate
+	}
+	if !found {
+		return 0, nil
+	}
+	translated, err := translateParts(parts, func(segs []string) ([]string, error) {
+		texts := make([]string, len(segs))
+		for i, seg := range segs {
+			res, err := s.c.TranslateProtected(ctx, seg, to, markdownInlineRules)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkQuality(res); err != nil {
+				return nil, &httpError{status: http.StatusUnprocessableEntity, msg: err.Error()}
+			}
+			texts[i] = res.Translation
+		}
+		return texts, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var payload interface{}
+	if err := json.Unmarshal(translated, &payload); err != nil {
+		return 0, err
+	}
+	var u strings.Builder
+	if err := h.forward.Execute(&u, payload); err != nil {
+		return 0, err
+	}
+	if h.tmpl != nil {
+		var b bytes.Buffer
+		if err := h.tmpl.Execute(&b, payload); err != nil {
+			return 0, err
+		}
+		translated = b.Bytes()
+	}
+	req, err := http.NewRequest(h.Method, u.String(), bytes.NewReader(translated))
+	if err != nil {
+		return 0, badRequest("invalid forward URL: %v", err)
+	}
+	if req.URL.Scheme != h.origin.Scheme || req.URL.Host != h.origin.Host {
+		return 0, badRequest("forward URL is not on %s", h.origin)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gtrans")
+	for name, v := range h.Headers {
+		req.Header.Set(name, os.ExpandEnv(v))
+	}
+	res, err := s.forward.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, &httpError{status: http.StatusBadGateway, msg: fmt.Sprintf("fail to forward: %v", err)}
+	}
+	defer res.Body.Close()
+	ioutil.ReadAll(res.Body)
+	if res.StatusCode >= 300 {
+		return res.StatusCode, &httpError{status: http.StatusBadGateway, msg: fmt.Sprintf("%s responded %s", req.URL.Redacted(), res.Status)}
+	}
+	return res.StatusCode, nil
+}
+
+// webhookForwardTimeout is the timeout of forwarding a payload.
+const webhookForwardTimeout = 30 * time.Second
+
+// newForwardClient returns the client forwarding payloads, which shares the
+// transport with the engines but none of their headers, since only the headers
+// of the webhook are for the services.
+func newForwardClient() *http.Client {
+	return &http.Client{Transport: gtrans.SharedTransport(), Timeout: webhookForwardTimeout}
+}
@@ -0,0 +1,19 @@
+package gtrans
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Commands can't be run in browsers, so plugin and exec engines are not
+// available on js/wasm.
+var errNoCommand = errors.New("commands are not supported on js/wasm")
+
+func runCommand(ctx context.Context, path string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return errNoCommand
+}
+
+func lookCommand(name string) (string, error) {
+	return "", errNoCommand
+}
@@ -0,0 +1,24 @@
+//go:build !js
+// +build !js
+
+package gtrans
+
+import (
+	"context"
+	"io"
+	"os/exec"
+)
+
+// runCommand runs the command at path with args, connecting its standard
+// streams to stdin, stdout and stderr. The command is killed if ctx is done.
+func runCommand(ctx context.Context, path string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+func lookCommand(name string) (string, error) {
+	return exec.LookPath(name)
+}
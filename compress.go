@@ -0,0 +1,108 @@
+package gtrans
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// gzipTransport compresses request bodies of at least minSize bytes with gzip,
+// e.g. large batches, for the APIs accepting them. If an API rejects a
+// compressed request as unsupported (415), or as bad (400) for its encoding,
+// the request is sent again uncompressed, and later requests to the host are
+// not compressed.
+// Responses are compressed by the APIs supporting it anyway, because the base
+// transport asks for gzip and decodes it.
+type gzipTransport struct {
+	base    http.RoundTripper // nil is http.DefaultTransport
+	minSize int64
+
+	mu       sync.Mutex
+	rejected map[string]bool // by host
+}
+
+func (t *gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.GetBody == nil || req.ContentLength < t.minSize || req.Header.Get("Content-Encoding") != "" || t.isRejected(req.URL.Host) {
+		return base.RoundTrip(req)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(b)
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) >= req.ContentLength {
+		return base.RoundTrip(req)
+	}
+	req.Body.Close()
+	zreq := req.Clone(req.Context())
+	zreq.Body = ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
+	zreq.ContentLength = int64(buf.Len())
+	zreq.GetBody = nil
+	zreq.Header.Set("Content-Encoding", "gzip")
+	resp, err := base.RoundTrip(zreq)
+	if err != nil {
+		return nil, err
+	}
+	rejected, err := rejectsGzip(resp)
+	if err != nil {
+		return nil, err
+	}
+	if !rejected {
+		return resp, nil
+	}
+	resp.Body.Close()
+	t.mu.Lock()
+	if t.rejected == nil {
+		t.rejected = map[string]bool{}
+	}
+	t.rejected[req.URL.Host] = true
+	t.mu.Unlock()
+	retry := req.Clone(req.Context())
+	if retry.Body, err = req.GetBody(); err != nil {
+		return nil, err
+	}
+	return base.RoundTrip(retry)
+}
+
+// rejectsGzip reports whether resp rejects the encoding of a compressed
+// request. Other bad requests, e.g. of invalid parameters, are not retried,
+// and the body read to tell is restored.
+func rejectsGzip(resp *http.Response) (bool, error) {
+	if resp.StatusCode == http.StatusUnsupportedMediaType {
+		return true, nil
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		return false, nil
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	s := strings.ToLower(string(b))
+	return strings.Contains(s, "gzip") || strings.Contains(s, "encoding"), nil
+}
+
+func (t *gzipTransport) isRejected(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rejected[host]
+}
@@ -0,0 +1,78 @@
+package gtrans
+
+import (
+	"context"
+)
+
+// flight is a text being translated by an engine, whose result is shared by
+// the requests of the same text.
+type flight struct {
+	done        chan struct{}
+	translation *Translation
+	err         error
+}
+
+// dedupMiddleware sends each text into a language once to the engine, while
+// it's in flight: duplicates in a batch, e.g. the same labels in a file of UI
+// strings, and requests of other goroutines, e.g. files translated in parallel,
+// share the translation of the first one instead of calling the API again.
+func (c *Client) dedupMiddleware(next Handler) Handler {
+	return func(ctx context.Context, reqs []*HookRequest) {
+		var leaders []*HookRequest
+		flights := make([]*flight, len(reqs))
+		owned := map[*flight]*HookRequest{}
+		c.flightMu.Lock()
+		if c.flights == nil {
+			c.flights = map[string]*flight{}
+		}
+		for i, req := range reqs {
+			key := req.Engine + "\x00" + req.TargetLang + "\x00" + req.Text
+			f, ok := c.flights[key]
+			if !ok {
+				f = &flight{done: make(chan struct{})}
+				c.flights[key] = f
+				owned[f] = req
+				leaders = append(leaders, req)
+			}
+			flights[i] = f
+		}
+		c.flightMu.Unlock()
+		if n := len(reqs) - len(leaders); n > 0 {
+			c.logf("dedup: %d of %d texts share translations in flight", n, len(reqs))
+		}
+
+		if len(leaders) > 0 {
+			next(ctx, leaders)
+		}
+		c.flightMu.Lock()
+		for f, req := range owned {
+			f.translation, f.err = req.Translation, req.Err
+			delete(c.flights, req.Engine+"\x00"+req.TargetLang+"\x00"+req.Text)
+			close(f.done)
+		}
+		c.flightMu.Unlock()
+
+		for i, req := range reqs {
+			f := flights[i]
+			if owned[f] == req {
+				continue
+			}
+			select {
+			case <-f.done:
+			case <-ctx.Done():
+				req.Err = ctx.Err()
+				continue
+			}
+			if f.err != nil {
+				req.Err = f.err
+				continue
+			}
+			if f.translation != nil {
+				// The translation is modified by the middlewares of each
+				// request, e.g. restoring protected parts.
+				t := *f.translation
+				req.Translation = &t
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package gtrans
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DeepL translates texts with DeepL API.
+// https://developers.deepl.com/docs/api-reference/translate
+type DeepL struct {
+	authKey string
+	baseURL string // overrides the endpoints if it's set
+	client  *http.Client
+}
+
+func newDeepLEngine(o *engineOptions) (Engine, error) {
+	authKey := o.credential("deepl", "DEEPL_AUTH_KEY")
+	if authKey == "" {
+		return nil, errors.New("DEEPL_AUTH_KEY is not set. Export it or run 'gtrans auth deepl'")
+	}
+	return &DeepL{authKey: authKey, baseURL: strings.TrimRight(o.endpointOr(""), "/"), client: o.client()}, nil
+}
+
+func (d *DeepL) Name() string { return "deepl" }
+
+// endpoint returns the endpoint of the API method for free API keys, which
+// end with ":fx", or Pro API keys.
+func (d *DeepL) endpoint(method string) string {
+	if d.baseURL != "" {
+		return d.baseURL + "/" + method
+	}
+	if strings.HasSuffix(d.authKey, ":fx") {
+		return "https://api-free.deepl.com/v2/" + method
+	}
+	return "https://api.deepl.com/v2/" + method
+}
+
+func (d *DeepL) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	ts, err := d.TranslateBatch(ctx, []string{text}, target)
+	if err != nil {
+		return nil, err
+	}
+	return ts[0], nil
+}
+
+// deeplMaxBatch is the maximum number of texts in a request.
+const deeplMaxBatch = 50
+
+// deeplMaxBytes is the maximum size of texts in a request, whose body is
+// limited to 128 KiB. Form encoding may triple the size of non-ASCII texts.
+const deeplMaxBytes = 128 * 1024 / 3
+
+func (d *DeepL) Limits() Limits {
+	return Limits{MaxTexts: deeplMaxBatch, MaxBytes: deeplMaxBytes}
+}
+
+func (d *DeepL) TranslateBatch(ctx context.Context, texts []string, target string) ([]*Translation, error) {
+	ts := make([]*Translation, 0, len(texts))
+	for len(texts) > 0 {
+		n := len(texts)
+		if n > deeplMaxBatch {
+			n = deeplMaxBatch
+		}
+		t, err := d.translate(ctx, texts[:n], target)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t...)
+		texts = texts[n:]
+	}
+	return ts, nil
+}
+
+func (d *DeepL) translate(ctx context.Context, texts []string, target string) ([]*Translation, error) {
+	form := url.Values{"text": texts, "target_lang": {deeplTargetLang(target)}}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.endpoint("translate"), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+d.authKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to call DeepL API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fail to call DeepL API: %s", resp.Status)
+	}
+	var result struct {
+		Translations []struct {
+			DetectedSourceLanguage string `json:"detected_source_language"`
+			Text                   string `json:"text"`
+		} `json:"translations"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("fail to decode DeepL API response: %v", err)
+	}
+	if len(result.Translations) != len(texts) {
+		return nil, fmt.Errorf("DeepL API returned %d translations for %d texts", len(result.Translations), len(texts))
+	}
+	ts := make([]*Translation, len(texts))
+	for i, t := range result.Translations {
+		ts[i] = &Translation{Text: t.Text, SourceLang: strings.ToLower(t.DetectedSourceLanguage)}
+	}
+	return ts, nil
+}
+
+// Languages returns the target languages of DeepL. Their names are always in
+// English.
+func (d *DeepL) Languages(ctx context.Context, display string) ([]Language, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.endpoint("languages")+"?type=target", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+d.authKey)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to call DeepL API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fail to call DeepL API: %s", resp.Status)
+	}
+	var result []struct {
+		Language string `json:"language"`
+		Name     string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("fail to decode DeepL API response: %v", err)
+	}
+	langs := make([]Language, len(result))
+	for i, l := range result {
+		langs[i] = Language{Code: strings.ToLower(l.Language), Name: l.Name}
+	}
+	return langs, nil
+}
+
+// deeplTargetLang converts a language code used by Google Translate into a
+// DeepL target language code.
+func deeplTargetLang(lang string) string {
+	switch strings.ToLower(lang) {
+	case "en":
+		return "EN-US"
+	case "pt":
+		return "PT-PT"
+	case "zh", "zh-cn":
+		return "ZH-HANS"
+	case "zh-tw":
+		return "ZH-HANT"
+	}
+	return strings.ToUpper(lang)
+}
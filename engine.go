@@ -0,0 +1,155 @@
+package gtrans
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Engine is a machine translation backend.
+type Engine interface {
+	// Name returns the name of the engine given to WithEngineName.
+	Name() string
+	// Translate translates text into target language. The source language
+	// is detected automatically. ctx cancels the request to the backend.
+	Translate(ctx context.Context, text, target string) (*Translation, error)
+}
+
+// Detector is implemented by engines which can detect the language of a text
+// without translating it.
+type Detector interface {
+	Detect(ctx context.Context, text string) (string, error)
+}
+
+// ConfidenceDetector is implemented by detectors which tell the confidence
+// (0-1) of the detected language.
+type ConfidenceDetector interface {
+	DetectConfidence(ctx context.Context, text string) (string, float64, error)
+}
+
+// StreamTranslator is implemented by engines which can stream translated text
+// as it is generated, such as LLMs.
+type StreamTranslator interface {
+	TranslateStream(ctx context.Context, text, target string, onChunk func(string)) (*Translation, error)
+}
+
+// ChunkSizer is implemented by engines which translate texts larger than the
+// default chunk size of Client.TranslateStream at once, e.g. with a long
+// context.
+type ChunkSizer interface {
+	MaxChunkSize() int
+}
+
+// Limiter is implemented by engines whose APIs limit requests. Requests of
+// batches are packed within the limits, and sent at the rate.
+type Limiter interface {
+	Limits() Limits
+}
+
+// Limits are limits of requests of an engine. Zero is unlimited.
+type Limits struct {
+	// MaxTexts is the maximum number of texts in a request.
+	MaxTexts int
+	// MaxChars is the maximum number of characters of texts in a request.
+	MaxChars int
+	// MaxBytes is the maximum number of bytes of texts in a request.
+	MaxBytes int
+	// PerSecond is the maximum number of requests per second.
+	PerSecond float64
+}
+
+// LanguageLister is implemented by engines which can list the languages they
+// can translate into.
+type LanguageLister interface {
+	// Languages returns the supported target languages with their names in
+	// display language.
+	Languages(ctx context.Context, display string) ([]Language, error)
+}
+
+// Language is a language supported by an engine.
+type Language struct {
+	Code string `json:"code"`
+	Name string `json:"name,omitempty"`
+}
+
+// BatchTranslator is implemented by engines which can translate multiple
+// texts in a request. TranslateBatch returns the translations in the order of
+// texts, splitting them into as few requests as the limits of the API allow.
+type BatchTranslator interface {
+	TranslateBatch(ctx context.Context, texts []string, target string) ([]*Translation, error)
+}
+
+// Translation is a translated text with the source language detected by the
+// engine. SourceLang is empty if the engine doesn't report it, and Confidence
+// is zero if the engine doesn't report confidence of the translation.
+type Translation struct {
+	Text       string
+	SourceLang string
+	Confidence float64
+}
+
+// engines maps engine names to their constructors, which read credentials
+// from the options or environment variables.
+var engines = map[string]func(*engineOptions) (Engine, error){
+	"google":   newGoogleEngine,
+	"deepl":    newDeepLEngine,
+	"openai":   newOpenAIEngine,
+	"exec":     newExecEngine,
+	"local":    newLocalEngine,
+	"apertium": newApertiumEngine,
+	"ollama":   newOllamaEngine,
+	"claude":   newClaudeEngine,
+	"gemini":   newGeminiEngine,
+	"papago":   newPapagoEngine,
+	"yandex":   newYandexEngine,
+	"baidu":    newBaiduEngine,
+}
+
+// EngineKeyEnvs maps engine names to the environment variables of their API
+// keys.
+var EngineKeyEnvs = map[string]string{
+	"google": "GOOGLE_TRANSLATE_API_KEY",
+	"deepl":  "DEEPL_AUTH_KEY",
+	"openai": "OPENAI_API_KEY",
+	"claude": "ANTHROPIC_API_KEY",
+	"gemini": "GEMINI_API_KEY",
+	"papago": "PAPAGO_API_KEY", // <client id>:<client secret>
+	"yandex": "YANDEX_API_KEY",
+	"baidu":  "BAIDU_API_KEY", // <app id>:<secret key>
+}
+
+// newEngine returns the built-in engine, or the plugin engine in PATH named
+// name. o may be nil.
+func newEngine(name string, o *engineOptions) (Engine, error) {
+	if newFunc, ok := engines[name]; ok {
+		return newFunc(o)
+	}
+	if e, err := newPluginEngine(name); err == nil {
+		return e, nil
+	}
+	return nil, fmt.Errorf("unknown engine %q. Available engines: %s", name, strings.Join(EngineNames(), ", "))
+}
+
+// EngineNames returns the names of the built-in engines and the plugin
+// engines in PATH.
+func EngineNames() []string {
+	names := BuiltinEngineNames()
+	for name := range PluginEngines() {
+		if engines[name] == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinEngineNames returns the names of the built-in engines.
+func BuiltinEngineNames() []string {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,195 @@
+package gtrans
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Gemini translates texts with Gemini API, which is a model rather than Cloud
+// Translation. GEMINI_CONTEXT hints what the texts are, and GEMINI_STYLE
+// instructs the style of translations.
+// https://ai.google.dev/api/generate-content
+type Gemini struct {
+	apiKey        string
+	baseURL       string
+	model         string
+	context       string
+	style         string
+	client        *http.Client
+	maskProfanity bool
+}
+
+func newGeminiEngine(opts *engineOptions) (Engine, error) {
+	apiKey := opts.credential("gemini", "GEMINI_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("GEMINI_API_KEY is not set. Export it or run 'gtrans auth gemini'")
+	}
+	g := &Gemini{
+		apiKey:  apiKey,
+		baseURL: "https://generativelanguage.googleapis.com/v1beta",
+		model:   "gemini-2.0-flash",
+		context: os.Getenv("GEMINI_CONTEXT"),
+		style:   os.Getenv("GEMINI_STYLE"),
+		client:  opts.client(),
+	}
+	if u := opts.endpointOr(os.Getenv("GEMINI_BASE_URL")); u != "" {
+		g.baseURL = strings.TrimRight(u, "/")
+	}
+	if m := os.Getenv("GEMINI_MODEL"); m != "" {
+		g.model = m
+	}
+	return g, nil
+}
+
+func (g *Gemini) Name() string { return "gemini" }
+
+// MaskProfanity makes the model mask profanity in translations.
+func (g *Gemini) MaskProfanity() { g.maskProfanity = true }
+
+func (g *Gemini) prompt(target string) string {
+	prompt := translationPrompt(target)
+	if g.context != "" {
+		prompt += " Context of the text: " + strings.TrimSuffix(g.context, ".") + "."
+	}
+	if g.style != "" {
+		prompt += " Style of the translation: " + strings.TrimSuffix(g.style, ".") + "."
+	}
+	if g.maskProfanity {
+		prompt += " Replace all but the first letter of profane words with asterisks."
+	}
+	return prompt
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// geminiResponse is a response, or an event of a streamed response.
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// text returns the generated text, or an error if the prompt is blocked.
+func (r *geminiResponse) text() (string, error) {
+	if r.PromptFeedback.BlockReason != "" {
+		return "", fmt.Errorf("Gemini API blocked the text: %s", r.PromptFeedback.BlockReason)
+	}
+	// Only the first candidate is requested.
+	var b strings.Builder
+	if len(r.Candidates) > 0 {
+		for _, p := range r.Candidates[0].Content.Parts {
+			b.WriteString(p.Text)
+		}
+	}
+	return b.String(), nil
+}
+
+// call sends a request generating the translation of text by method and
+// returns the response, whose status is checked.
+func (g *Gemini) call(ctx context.Context, method, text, target string) (*http.Response, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"systemInstruction": geminiContent{Parts: []geminiPart{{g.prompt(target)}}},
+		"contents":          []geminiContent{{Role: "user", Parts: []geminiPart{{text}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", g.baseURL+"/models/"+g.model+":"+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-goog-api-key", g.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to call Gemini API: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var r geminiResponse
+		if json.NewDecoder(resp.Body).Decode(&r) == nil && r.Error != nil {
+			return nil, fmt.Errorf("fail to call Gemini API: %s: %s", resp.Status, r.Error.Message)
+		}
+		return nil, fmt.Errorf("fail to call Gemini API: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func (g *Gemini) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	resp, err := g.call(ctx, "generateContent", text, target)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var r geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("fail to decode Gemini API response: %v", err)
+	}
+	translated, err := r.text()
+	if err != nil {
+		return nil, err
+	}
+	return &Translation{Text: strings.TrimSpace(translated)}, nil
+}
+
+// TranslateStream translates text, calling onChunk with each piece of the
+// translated text as it is generated. The response is a stream of server-sent
+// events.
+func (g *Gemini) TranslateStream(ctx context.Context, text, target string, onChunk func(string)) (*Translation, error) {
+	resp, err := g.call(ctx, "streamGenerateContent?alt=sse", text, target)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var b strings.Builder
+	s := bufio.NewScanner(resp.Body)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		data := strings.TrimPrefix(s.Text(), "data: ")
+		if data == s.Text() || data == "" {
+			continue
+		}
+		var r geminiResponse
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			return nil, fmt.Errorf("fail to decode Gemini API response: %v", err)
+		}
+		chunk, err := r.text()
+		if err != nil {
+			return nil, err
+		}
+		// Skip leading white spaces, as Translate trims them.
+		if b.Len() == 0 {
+			chunk = strings.TrimLeftFunc(chunk, unicode.IsSpace)
+		}
+		if chunk != "" {
+			b.WriteString(chunk)
+			onChunk(chunk)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("fail to read Gemini API response: %v", err)
+	}
+	return &Translation{Text: strings.TrimRightFunc(b.String(), unicode.IsSpace)}, nil
+}
@@ -0,0 +1,138 @@
+package gtrans
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GlossaryEntry is a term which must be translated to Target.
+type GlossaryEntry struct {
+	Source        string
+	Target        string
+	CaseSensitive bool
+	Regexp        bool // Source is a regular expression
+}
+
+// LoadGlossary reads a glossary file. The file is TSV if its extension is .tsv
+// and CSV otherwise, with the columns:
+//
+//	source,target[,case_sensitive][,regex]
+//
+// An optional header row starting with "source" is skipped.
+func LoadGlossary(path string) ([]*GlossaryEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	comma := ','
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		comma = '\t'
+	}
+	entries, err := readGlossary(f, comma)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read glossary %s: %v", path, err)
+	}
+	return entries, nil
+}
+
+func readGlossary(r io.Reader, comma rune) ([]*GlossaryEntry, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	if comma == '\t' {
+		cr.LazyQuotes = true
+	}
+	var entries []*GlossaryEntry
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(record[0], "source") {
+			continue
+		}
+		if len(record) < 2 || record[0] == "" {
+			return nil, fmt.Errorf("line %d: need at least source and target columns", line)
+		}
+		e := &GlossaryEntry{Source: record[0], Target: record[1]}
+		if e.CaseSensitive, err = parseGlossaryBool(record, 2); err != nil {
+			return nil, fmt.Errorf("line %d: case_sensitive: %v", line, err)
+		}
+		if e.Regexp, err = parseGlossaryBool(record, 3); err != nil {
+			return nil, fmt.Errorf("line %d: regex: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func parseGlossaryBool(record []string, i int) (bool, error) {
+	if i >= len(record) || strings.TrimSpace(record[i]) == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(strings.TrimSpace(record[i]))
+}
+
+// compile returns the regexp matching the entry in source texts. Plain terms
+// only match whole words.
+func (e *GlossaryEntry) compile() (*regexp.Regexp, error) {
+	expr := e.Source
+	if !e.Regexp {
+		expr = regexp.QuoteMeta(e.Source)
+		if isWordByte(e.Source[0]) {
+			expr = `\b` + expr
+		}
+		if isWordByte(e.Source[len(e.Source)-1]) {
+			expr = expr + `\b`
+		}
+	}
+	if !e.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// WithGlossary translates the terms of entries consistently, keeping them out
+// of the texts sent to the engine and restoring them as their target terms.
+func WithGlossary(entries []*GlossaryEntry) Option {
+	return func(c *Client) error {
+		return c.protector.addGlossary(entries)
+	}
+}
+
+// addGlossary adds rules to p which protect glossary terms and restore them as
+// their target terms.
+func (p *protector) addGlossary(entries []*GlossaryEntry) error {
+	for _, e := range entries {
+		re, err := e.compile()
+		if err != nil {
+			return fmt.Errorf("glossary term %q: %v", e.Source, err)
+		}
+		target := e.Target
+		replace := func(string) string { return target }
+		if e.Regexp {
+			// Allow $1 etc. in the target of regex entries.
+			replace = func(m string) string {
+				return re.ReplaceAllString(m, target)
+			}
+		}
+		p.add(protectRule{kind: "glossary", re: re, replace: replace})
+	}
+	return nil
+}
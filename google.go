@@ -0,0 +1,113 @@
+package gtrans
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi/transport"
+	translate "google.golang.org/api/translate/v2"
+)
+
+type Gtrans struct {
+	srv *translate.Service
+}
+
+func newGoogleEngine(o *engineOptions) (Engine, error) {
+	apiKey := o.credential("google", "GOOGLE_TRANSLATE_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("GOOGLE_TRANSLATE_API_KEY is not set. Export it or run 'gtrans auth google'")
+	}
+	service, err := translate.New(oauthClient(context.Background(), apiKey, o.client()))
+	if err != nil {
+		return nil, err
+	}
+	service.BasePath = o.endpointOr(service.BasePath)
+	return &Gtrans{srv: service}, nil
+}
+
+func (gtrans *Gtrans) Name() string { return "google" }
+
+func (gtrans *Gtrans) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	ts, err := gtrans.TranslateBatch(ctx, []string{text}, target)
+	if err != nil {
+		return nil, err
+	}
+	return ts[0], nil
+}
+
+// googleMaxBatch is the maximum number of texts in a request.
+const googleMaxBatch = 128
+
+// googleMaxBytes is the maximum size of texts in a request.
+const googleMaxBytes = 204800
+
+func (gtrans *Gtrans) Limits() Limits {
+	return Limits{MaxTexts: googleMaxBatch, MaxBytes: googleMaxBytes}
+}
+
+func (gtrans *Gtrans) TranslateBatch(ctx context.Context, texts []string, target string) ([]*Translation, error) {
+	ts := make([]*Translation, 0, len(texts))
+	for len(texts) > 0 {
+		n := len(texts)
+		if n > googleMaxBatch {
+			n = googleMaxBatch
+		}
+		call := gtrans.srv.Translations.List(texts[:n], target)
+		call = call.Format("text")
+		resp, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("fail to call translate API: %v", err)
+		}
+		if len(resp.Translations) != n {
+			return nil, fmt.Errorf("translate API returned %d translations for %d texts", len(resp.Translations), n)
+		}
+		for _, t := range resp.Translations {
+			ts = append(ts, &Translation{Text: t.TranslatedText, SourceLang: t.DetectedSourceLanguage})
+		}
+		texts = texts[n:]
+	}
+	return ts, nil
+}
+
+func (gtrans *Gtrans) Detect(ctx context.Context, text string) (string, error) {
+	lang, _, err := gtrans.DetectConfidence(ctx, text)
+	return lang, err
+}
+
+func (gtrans *Gtrans) DetectConfidence(ctx context.Context, text string) (string, float64, error) {
+	call := gtrans.srv.Detections.List([]string{text})
+	resp, err := call.Context(ctx).Do()
+	if err != nil {
+		return "", 0, fmt.Errorf("fail to call detection API: %v", err)
+	}
+	d := resp.Detections[0][0]
+	return d.Language, d.Confidence, nil
+}
+
+func (gtrans *Gtrans) Languages(ctx context.Context, display string) ([]Language, error) {
+	resp, err := gtrans.srv.Languages.List().Target(display).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("fail to call languages API: %v", err)
+	}
+	langs := make([]Language, len(resp.Languages))
+	for i, l := range resp.Languages {
+		langs[i] = Language{Code: l.Language, Name: l.Name}
+	}
+	return langs, nil
+}
+
+// oauthClient returns a client sending apiKey with requests through the
+// transport of base.
+func oauthClient(ctx context.Context, apiKey string, base *http.Client) *http.Client {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{
+		Transport: &transport.APIKey{Key: apiKey, Transport: base.Transport},
+		Timeout:   base.Timeout,
+	})
+	oauthConfig := &oauth2.Config{}
+	token := &oauth2.Token{AccessToken: apiKey}
+	httpClient := oauthConfig.Client(ctx, token)
+	return httpClient
+}
@@ -0,0 +1,30 @@
+package gtrans
+
+import (
+	"net/http"
+)
+
+// headerTransport sets the User-Agent and extra headers of requests to the
+// APIs of engines, e.g. for an API gateway requiring them, replacing the
+// headers of the same names set by the engines.
+type headerTransport struct {
+	base      http.RoundTripper // nil is http.DefaultTransport
+	userAgent string
+	headers   http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request.
+	req = req.Clone(req.Context())
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
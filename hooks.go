@@ -0,0 +1,306 @@
+package gtrans
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// HookRequest is a text on its way to the engine through the middlewares.
+// Middlewares may rewrite Text and TargetLang before calling the next handler,
+// and Translation after it. A middleware can also answer on behalf of the
+// engine by setting Translation or Err without calling the next handler.
+type HookRequest struct {
+	Text        string
+	TargetLang  string
+	Engine      string // name of the engine
+	Translation *Translation
+	Err         error
+
+	rules []protectRule // extra protection rules, e.g. of a document format
+	raw   string        // translation before restoring placeholders
+	ps    placeholders  // placeholders in raw
+	// passed is true if the text is passed through untranslated in offline
+	// mode.
+	passed bool
+}
+
+// Handler translates reqs, setting their Translation or Err.
+type Handler func(ctx context.Context, reqs []*HookRequest)
+
+// Middleware wraps the next handler in the chain to the engine.
+type Middleware func(next Handler) Handler
+
+// WithMiddleware adds m to the chain to the engine. Middlewares are called in
+// the order they are added, after placeholders of protected parts are
+// inserted and before the cache is looked up.
+func WithMiddleware(m Middleware) Option {
+	return func(c *Client) error {
+		c.middlewares = append(c.middlewares, m)
+		return nil
+	}
+}
+
+// WithHooks adds a middleware calling before for every request before it's
+// sent to the engine, and after for every request after the response. Either
+// may be nil. before can skip the request by setting its Translation or Err.
+func WithHooks(before, after func(ctx context.Context, req *HookRequest)) Option {
+	return WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, reqs []*HookRequest) {
+			pending := reqs
+			if before != nil {
+				pending = nil
+				for _, req := range reqs {
+					before(ctx, req)
+					if req.Translation == nil && req.Err == nil {
+						pending = append(pending, req)
+					}
+				}
+			}
+			if len(pending) > 0 {
+				next(ctx, pending)
+			}
+			if after != nil {
+				for _, req := range reqs {
+					after(ctx, req)
+				}
+			}
+		}
+	})
+}
+
+// handler returns the chain to engine: protection by redaction and glossary,
+// masking profanity, preserving casing, the middlewares given by options, the
+// cache, the deduplication of texts in flight, the circuit breaker and the
+// quota, in this order.
+func (c *Client) handler(engine Engine) Handler {
+	h := c.engineHandler(engine)
+	if c.quota != nil {
+		h = c.quotaMiddleware(engine, h)
+	}
+	if c.circuitErrors > 0 {
+		h = c.circuitMiddleware(engine, h)
+	}
+	h = c.dedupMiddleware(h)
+	if c.offline {
+		h = c.offlineHandler
+	}
+	if c.cache != nil && engine.Name() != "exec" {
+		// Responses of the exec engine aren't cached, as its command may
+		// change between runs.
+		h = c.cacheMiddleware(h)
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+	if c.preserveCase {
+		h = casingMiddleware(h)
+	}
+	if _, ok := engine.(ProfanityMasker); !ok && c.maskProfanity {
+		h = c.profanityMiddleware(h)
+	}
+	h = c.protectMiddleware(h)
+	if c.postHook != "" {
+		h = c.postHookMiddleware(h)
+	}
+	if c.preHook != "" {
+		h = c.preHookMiddleware(h)
+	}
+	return h
+}
+
+// run translates reqs through the chain to engine.
+func (c *Client) run(ctx context.Context, engine Engine, reqs []*HookRequest) {
+	for _, req := range reqs {
+		req.Engine = engine.Name()
+	}
+	c.stats.translate(reqs)
+	c.handler(engine)(ctx, reqs)
+	c.stats.translated(reqs)
+}
+
+// protectMiddleware replaces the parts protected by the rules of the Client
+// and the request with placeholders, and restores them in the translation.
+func (c *Client) protectMiddleware(next Handler) Handler {
+	return func(ctx context.Context, reqs []*HookRequest) {
+		for _, req := range reqs {
+			p := c.protector
+			if len(req.rules) > 0 {
+				p.rules = append(append([]protectRule(nil), p.rules...), req.rules...)
+			}
+			req.Text, req.ps = p.Protect(req.Text)
+		}
+		next(ctx, reqs)
+		for _, req := range reqs {
+			if req.Translation != nil {
+				req.raw = req.Translation.Text
+				req.Translation.Text = req.ps.Restore(req.raw)
+			}
+		}
+	}
+}
+
+// casingMiddleware restores the casing of the text in the translation.
+func casingMiddleware(next Handler) Handler {
+	return func(ctx context.Context, reqs []*HookRequest) {
+		texts := make([]string, len(reqs))
+		for i, req := range reqs {
+			// Placeholder tokens are removed so that they don't look
+			// like capitalized words.
+			texts[i] = placeholderRe.ReplaceAllString(req.Text, "")
+		}
+		next(ctx, reqs)
+		for i, req := range reqs {
+			if req.Translation != nil {
+				req.Translation.Text = preserveCasing(texts[i], req.Translation.Text)
+			}
+		}
+	}
+}
+
+// profanityMiddleware masks profanity in the translation, for engines which
+// can't mask it by themselves.
+func (c *Client) profanityMiddleware(next Handler) Handler {
+	return func(ctx context.Context, reqs []*HookRequest) {
+		next(ctx, reqs)
+		for _, req := range reqs {
+			if req.Translation == nil {
+				continue
+			}
+			f, err := c.profanityFilter(req.TargetLang)
+			if err != nil {
+				req.Translation, req.Err = nil, err
+				continue
+			}
+			req.Translation.Text = f.Mask(req.Translation.Text)
+		}
+	}
+}
+
+// cacheMiddleware answers requests with the cached responses if any, and
+// caches the responses of the others. Responses older than the TTL are
+// misses. Errors of the cache are reported to STDERR but don't fail the
+// translation.
+func (c *Client) cacheMiddleware(next Handler) Handler {
+	return func(ctx context.Context, reqs []*HookRequest) {
+		var misses []*HookRequest
+		for _, req := range reqs {
+			e, err := c.cache.Get(req.Engine, req.Text, req.TargetLang)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "gtrans: fail to read cache: %v\n", err)
+			}
+			if e == nil || (c.cacheTTL > 0 && time.Since(e.Created) > c.cacheTTL) {
+				atomic.AddInt64(&c.cacheMisses, 1)
+				misses = append(misses, req)
+				continue
+			}
+			atomic.AddInt64(&c.cacheHits, 1)
+			c.logf("engine %s: cache hit for %d chars into %s", req.Engine, utf8.RuneCountInString(req.Text), req.TargetLang)
+			req.Translation = &Translation{Text: e.Translation, SourceLang: e.SourceLang, Confidence: e.Confidence}
+		}
+		if len(misses) == 0 {
+			return
+		}
+		next(ctx, misses)
+		for _, req := range misses {
+			if req.Translation == nil || req.passed {
+				continue
+			}
+			err := c.cache.Put(&cacheEntry{
+				Engine:      req.Engine,
+				TargetLang:  req.TargetLang,
+				Source:      req.Text,
+				Translation: req.Translation.Text,
+				SourceLang:  req.Translation.SourceLang,
+				Confidence:  req.Translation.Confidence,
+			}, c.cacheTTL)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "gtrans: fail to write cache: %v\n", err)
+			}
+		}
+	}
+}
+
+// engineHandler returns the handler sending requests to engine at the end of
+// the chain. Requests into the same language are packed into batches within
+// the limits of the engine if it supports them.
+func (c *Client) engineHandler(engine Engine) Handler {
+	rate := c.engineRate(engine)
+	breaker := c.breaker(engine.Name())
+	wait := func(ctx context.Context) error {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		return rate.Wait(ctx)
+	}
+	return func(ctx context.Context, reqs []*HookRequest) {
+		b, ok := engine.(BatchTranslator)
+		if !ok || len(reqs) == 1 {
+			for _, req := range reqs {
+				if req.Err = wait(ctx); req.Err != nil {
+					continue
+				}
+				start := time.Now()
+				req.Translation, req.Err = engine.Translate(ctx, req.Text, req.TargetLang)
+				c.stats.call(engine.Name(), []string{req.Text}, time.Since(start), req.Err)
+				breaker.record(req.Err)
+				c.logf("engine %s: translate %d chars into %s in %v%s", engine.Name(), utf8.RuneCountInString(req.Text), req.TargetLang, time.Since(start).Round(time.Millisecond), errSuffix(req.Err))
+			}
+			return
+		}
+		var limits Limits
+		if l, ok := engine.(Limiter); ok {
+			limits = l.Limits()
+		}
+		var targets []string
+		byTarget := map[string][]*HookRequest{}
+		for _, req := range reqs {
+			if byTarget[req.TargetLang] == nil {
+				targets = append(targets, req.TargetLang)
+			}
+			byTarget[req.TargetLang] = append(byTarget[req.TargetLang], req)
+		}
+		for _, target := range targets {
+			all := byTarget[target]
+			texts := make([]string, len(all))
+			for i, req := range all {
+				texts[i] = req.Text
+			}
+			for _, batch := range PackBatches(texts, limits) {
+				group := make([]*HookRequest, len(batch))
+				texts := make([]string, len(batch))
+				chars := 0
+				for i, k := range batch {
+					group[i], texts[i] = all[k], all[k].Text
+					chars += utf8.RuneCountInString(texts[i])
+				}
+				err := wait(ctx)
+				var ts []*Translation
+				if err == nil {
+					start := time.Now()
+					ts, err = b.TranslateBatch(ctx, texts, target)
+					c.stats.call(engine.Name(), texts, time.Since(start), err)
+					breaker.record(err)
+					c.logf("engine %s: translate %d texts (%d chars) into %s in %v%s", engine.Name(), len(texts), chars, target, time.Since(start).Round(time.Millisecond), errSuffix(err))
+				}
+				for i, req := range group {
+					if err != nil {
+						req.Err = err
+					} else {
+						req.Translation = ts[i]
+					}
+				}
+			}
+		}
+	}
+}
+
+func errSuffix(err error) string {
+	if err == nil {
+		return ""
+	}
+	return ": " + err.Error()
+}
@@ -0,0 +1,187 @@
+package gtrans
+
+import (
+	"strings"
+	"unicode"
+)
+
+// WithLocalDetect makes the Client detect the source languages of texts
+// locally without calling the engine, unless it's unsure, e.g. for
+// WithSecondLang and routes of WithRoutes.
+func WithLocalDetect() Option {
+	return func(c *Client) error {
+		c.localDetect = true
+		return nil
+	}
+}
+
+// WithSourceConfirmation makes the Client call confirm with the source
+// language guessed for a text less confidently (0-1) than below, which returns
+// the confirmed language, or "" if it's unknown.
+func WithSourceConfirmation(below float64, confirm func(text, guess string, confidence float64) (string, error)) Option {
+	return func(c *Client) error {
+		c.confirmSource, c.confirmBelow = confirm, below
+		return nil
+	}
+}
+
+// localDetectMinConfidence is the minimum confidence of detectLocal to trust
+// it instead of calling the engine.
+const localDetectMinConfidence = 0.5
+
+// stopwords are frequent function words of languages written in Latin
+// script, which tell them apart.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "that", "this", "with", "for", "are", "was", "it", "you", "have", "not", "be", "from", "by", "what", "which"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "mit", "sich", "auf", "für", "ich", "den", "dem", "des", "auch", "wird", "sind", "zu"},
+	"fr": {"le", "la", "les", "et", "est", "une", "des", "du", "que", "qui", "pas", "pour", "dans", "sur", "avec", "ce", "il", "sont", "au", "nous"},
+	"es": {"el", "los", "las", "y", "es", "una", "del", "que", "por", "con", "para", "se", "no", "como", "su", "al", "lo", "pero", "más", "está"},
+	"it": {"il", "gli", "della", "che", "di", "è", "una", "per", "non", "sono", "con", "del", "anche", "come", "questo", "nel", "alla", "più", "ma", "ho"},
+	"pt": {"os", "as", "uma", "não", "que", "do", "da", "em", "para", "com", "são", "por", "mais", "como", "foi", "ao", "dos", "das", "você", "é"},
+	"nl": {"de", "het", "een", "en", "van", "niet", "dat", "op", "te", "zijn", "met", "voor", "ook", "maar", "dit", "wordt", "naar", "bij", "heeft", "ik"},
+}
+
+// stopwordLangs maps stopwords to their languages.
+var stopwordLangs = func() map[string][]string {
+	m := map[string][]string{}
+	for lang, words := range stopwords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+	return m
+}()
+
+// letterLangs are letters specific to a language among the languages of its
+// script.
+var letterLangs = map[rune]string{
+	'ß': "de", 'ñ': "es", 'ã': "pt", 'õ': "pt", 'ç': "fr",
+	'ы': "ru", 'э': "ru", 'ё': "ru", 'і': "uk", 'ї': "uk", 'є': "uk", 'ґ': "uk",
+	'ў': "be", 'ђ': "sr", 'ћ': "sr", 'џ': "sr", 'љ': "sr", 'њ': "sr",
+	'پ': "fa", 'چ': "fa", 'ژ': "fa", 'گ': "fa", 'ٹ': "ur", 'ڈ': "ur", 'ڑ': "ur", 'ں': "ur", 'ے': "ur",
+}
+
+// scriptLangs are the languages of scripts, which are confident if
+// the script is used only by the language.
+var scriptLangs = []struct {
+	table     *unicode.RangeTable
+	lang      string
+	confident bool
+}{
+	{unicode.Hangul, "ko", true},
+	{unicode.Thai, "th", true},
+	{unicode.Greek, "el", true},
+	{unicode.Hebrew, "he", true},
+	{unicode.Georgian, "ka", true},
+	{unicode.Armenian, "hy", true},
+	{unicode.Arabic, "ar", false},
+	{unicode.Cyrillic, "ru", false},
+	{unicode.Devanagari, "hi", false},
+}
+
+// detectLocal detects the language of text without calling any API, and
+// returns it with the confidence (0-1). It tells languages apart by their
+// scripts, the letters specific to them and their frequent words, so short
+// texts in Latin script are detected with low confidence.
+func detectLocal(text string) (string, float64) {
+	var letters, kana, han int
+	scripts := make([]int, len(scriptLangs))
+	specific := map[string]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+			continue
+		case unicode.Is(unicode.Han, r):
+			han++
+			continue
+		}
+		if lang, ok := letterLangs[unicode.ToLower(r)]; ok {
+			specific[lang]++
+		}
+		for i, s := range scriptLangs {
+			if unicode.Is(s.table, r) {
+				scripts[i]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "", 0
+	}
+	if kana > 0 {
+		return "ja", float64(kana+han) / float64(letters)
+	}
+	if han*2 > letters {
+		// Simplified or traditional Chinese, or Japanese without kana.
+		return "zh", 0.3
+	}
+	for i, s := range scriptLangs {
+		if scripts[i]*2 <= letters {
+			continue
+		}
+		confidence := float64(scripts[i]) / float64(letters)
+		if s.confident {
+			return s.lang, confidence
+		}
+		// The language with specific letters, or the major language of
+		// the script only if no letters of others are used.
+		lang, n := s.lang, 0
+		for l, c := range specific {
+			if c > n {
+				lang, n = l, c
+			}
+		}
+		if n == 0 {
+			confidence *= 0.4
+		}
+		return lang, confidence
+	}
+	return detectLatin(strings.ToLower(text), specific)
+}
+
+// detectLatin detects the language of lower-cased text in Latin script by the
+// frequent words and specific letters of languages.
+func detectLatin(text string, specific map[string]int) (string, float64) {
+	hits := map[string]int{}
+	for lang, n := range specific {
+		hits[lang] += n
+	}
+	for _, w := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for _, lang := range stopwordLangs[w] {
+			hits[lang]++
+		}
+	}
+	lang, best, second := "", 0, 0
+	for l, n := range hits {
+		switch {
+		case n > best || n == best && l < lang:
+			if l != lang {
+				second = best
+			}
+			lang, best = l, n
+		case n > second:
+			second = n
+		}
+	}
+	if best == 0 {
+		return "", 0
+	}
+	// The lead over the other languages, discounted for a few hits.
+	confidence := float64(best-second) / float64(best)
+	if best < 3 {
+		confidence *= float64(best) / 3
+	}
+	return lang, confidence
+}
+
+// sameLang reports whether languages a and b are the same, where a code
+// without a region matches the codes of the language with regions.
+func sameLang(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	return a != "" && (langMatches(a, b) || langMatches(b, a))
+}
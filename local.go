@@ -0,0 +1,119 @@
+package gtrans
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// localEngine translates texts on the device with Argos Translate, which runs
+// OpenNMT models offline. The model of a language pair is downloaded when it's
+// needed first. Argos can't detect languages, so the source language is given
+// by GTRANS_LOCAL_FROM (en by default).
+type localEngine struct {
+	from string
+
+	mu        sync.Mutex
+	installed map[string]bool // packages, e.g. translate-en_ja
+}
+
+func newLocalEngine(*engineOptions) (Engine, error) {
+	if _, err := lookCommand("argos-translate"); err != nil {
+		return nil, errors.New("engine local requires Argos Translate. Install it by 'pip install argostranslate'")
+	}
+	from := os.Getenv("GTRANS_LOCAL_FROM")
+	if from == "" {
+		from = "en"
+	}
+	return &localEngine{from: localLang(from)}, nil
+}
+
+func (e *localEngine) Name() string { return "local" }
+
+// localLang returns the language code of Argos, which has no regions, e.g. pt
+// for pt-BR.
+func localLang(lang string) string {
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	return strings.ToLower(lang)
+}
+
+func (e *localEngine) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	to := localLang(target)
+	if to == e.from {
+		return &Translation{Text: text, SourceLang: e.from}, nil
+	}
+	if err := e.install(ctx, e.from, to); err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	err := runCommand(ctx, "argos-translate", []string{"--from-lang", e.from, "--to-lang", to}, strings.NewReader(text), &stdout, &stderr)
+	if err != nil {
+		return nil, localError("argos-translate", err, &stderr)
+	}
+	return &Translation{Text: strings.TrimSuffix(stdout.String(), "\n"), SourceLang: e.from}, nil
+}
+
+// install downloads the model from from into to unless it's installed. Argos
+// translates through English if there is no model of the pair, so the models
+// from and into English are installed instead then.
+func (e *localEngine) install(ctx context.Context, from, to string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.installed == nil {
+		var stdout, stderr bytes.Buffer
+		if err := runCommand(ctx, "argospm", []string{"list"}, nil, &stdout, &stderr); err != nil {
+			return localError("argospm list", err, &stderr)
+		}
+		e.installed = map[string]bool{}
+		s := bufio.NewScanner(&stdout)
+		for s.Scan() {
+			e.installed[strings.TrimSpace(s.Text())] = true
+		}
+	}
+	pkg := "translate-" + from + "_" + to
+	if e.installed[pkg] {
+		return nil
+	}
+	if e.installed["translate-"+from+"_en"] && e.installed["translate-en_"+to] {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "gtrans: downloading the model of %s to %s\n", from, to)
+	var stderr bytes.Buffer
+	if err := runCommand(ctx, "argospm", []string{"update"}, nil, os.Stderr, &stderr); err != nil {
+		return localError("argospm update", err, &stderr)
+	}
+	stderr.Reset()
+	if err := runCommand(ctx, "argospm", []string{"install", pkg}, nil, os.Stderr, &stderr); err == nil {
+		e.installed[pkg] = true
+		return nil
+	}
+	if from == "en" || to == "en" {
+		return fmt.Errorf("engine local: no model of %s to %s: %s", from, to, strings.TrimSpace(stderr.String()))
+	}
+	for _, p := range []string{"translate-" + from + "_en", "translate-en_" + to} {
+		if e.installed[p] {
+			continue
+		}
+		stderr.Reset()
+		if err := runCommand(ctx, "argospm", []string{"install", p}, nil, os.Stderr, &stderr); err != nil {
+			return fmt.Errorf("engine local: no model of %s to %s: %s", from, to, strings.TrimSpace(stderr.String()))
+		}
+		e.installed[p] = true
+	}
+	return nil
+}
+
+// localError returns the error of the command including its STDERR.
+func localError(command string, err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("engine local: %s: %v: %s", command, err, msg)
+	}
+	return fmt.Errorf("engine local: %s: %v", command, err)
+}
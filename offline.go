@@ -0,0 +1,53 @@
+package gtrans
+
+import (
+	"context"
+	"errors"
+)
+
+// errNotCached is the error of a text which isn't found in the cache or the
+// translation memory in offline mode.
+var errNotCached = errors.New("not found in the cache or the translation memory (offline)")
+
+// WithOffline makes the Client answer only from the cache and the translation
+// memory without calling the engine. Texts which aren't found fail with an
+// error, or are passed through untranslated if passThrough is true.
+func WithOffline(passThrough bool) Option {
+	return func(c *Client) error {
+		c.offline = true
+		c.passThrough = passThrough
+		return nil
+	}
+}
+
+// Offline reports whether the Client answers only from the cache and the
+// translation memory, given by WithOffline.
+func (c *Client) Offline() bool {
+	return c.offline
+}
+
+// offlineEngine is the engine in offline mode, which is never called but has
+// the name of the configured engine to look up its cached responses. It's
+// used instead of creating the engine, which may require credentials.
+type offlineEngine struct {
+	name string
+}
+
+func (e *offlineEngine) Name() string { return e.name }
+
+func (e *offlineEngine) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	return nil, errNotCached
+}
+
+// offlineHandler is the end of the chain in offline mode instead of the
+// engine, which the requests missed in the cache reach.
+func (c *Client) offlineHandler(ctx context.Context, reqs []*HookRequest) {
+	for _, req := range reqs {
+		if !c.passThrough {
+			req.Err = errNotCached
+			continue
+		}
+		req.Translation = &Translation{Text: req.Text}
+		req.passed = true
+	}
+}
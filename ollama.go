@@ -0,0 +1,165 @@
+package gtrans
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Ollama translates texts with a model served by Ollama on the machine, so
+// that texts stay private. The model and the system prompt are configurable by
+// OLLAMA_MODEL and OLLAMA_PROMPT.
+// https://github.com/ollama/ollama/blob/main/docs/api.md#generate-a-chat-completion
+type Ollama struct {
+	baseURL       string
+	model         string
+	prompt        *template.Template
+	client        *http.Client
+	maskProfanity bool
+}
+
+func newOllamaEngine(opts *engineOptions) (Engine, error) {
+	o := &Ollama{
+		baseURL: "http://localhost:11434",
+		model:   "llama3.2",
+		client:  opts.client(),
+	}
+	if u := opts.endpointOr(os.Getenv("OLLAMA_HOST")); u != "" {
+		if !strings.Contains(u, "://") {
+			// OLLAMA_HOST of Ollama itself may be host:port.
+			u = "http://" + u
+		}
+		o.baseURL = strings.TrimRight(u, "/")
+	}
+	if m := os.Getenv("OLLAMA_MODEL"); m != "" {
+		o.model = m
+	}
+	if p := os.Getenv("OLLAMA_PROMPT"); p != "" {
+		t, err := parsePrompt(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OLLAMA_PROMPT: %v", err)
+		}
+		o.prompt = t
+	}
+	return o, nil
+}
+
+func (o *Ollama) Name() string { return "ollama" }
+
+// MaskProfanity makes the model mask profanity in translations.
+func (o *Ollama) MaskProfanity() { o.maskProfanity = true }
+
+// ollamaResponse is a response, or a line of a streamed response, of the chat
+// API.
+type ollamaResponse struct {
+	Message openAIMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error"`
+}
+
+// call sends a chat request translating text and returns the response, whose
+// status is checked.
+func (o *Ollama) call(ctx context.Context, text, target string, stream bool) (*http.Response, error) {
+	prompt, err := systemPrompt(o.prompt, target)
+	if err != nil {
+		return nil, err
+	}
+	if o.maskProfanity {
+		prompt += " Replace all but the first letter of profane words with asterisks."
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"model": o.model,
+		"messages": []openAIMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: text},
+		},
+		"stream": stream,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to call Ollama API (is 'ollama serve' running?): %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var r ollamaResponse
+		if json.NewDecoder(resp.Body).Decode(&r) == nil && r.Error != "" {
+			// e.g. model "llama3.2" not found, try pulling it first
+			return nil, fmt.Errorf("fail to call Ollama API: %s: %s", resp.Status, r.Error)
+		}
+		return nil, fmt.Errorf("fail to call Ollama API: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func (o *Ollama) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	resp, err := o.call(ctx, text, target, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("fail to decode Ollama API response: %v", err)
+	}
+	if result.Error != "" {
+		return nil, errors.New("Ollama API: " + result.Error)
+	}
+	return &Translation{Text: strings.TrimSpace(result.Message.Content)}, nil
+}
+
+// TranslateStream translates text, calling onChunk with each piece of the
+// translated text as it is generated. The response is a JSON object per line.
+func (o *Ollama) TranslateStream(ctx context.Context, text, target string, onChunk func(string)) (*Translation, error) {
+	resp, err := o.call(ctx, text, target, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var b strings.Builder
+	s := bufio.NewScanner(resp.Body)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var r ollamaResponse
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("fail to decode Ollama API response: %v", err)
+		}
+		if r.Error != "" {
+			return nil, errors.New("Ollama API: " + r.Error)
+		}
+		// Skip leading white spaces, as Translate trims them.
+		chunk := r.Message.Content
+		if b.Len() == 0 {
+			chunk = strings.TrimLeftFunc(chunk, unicode.IsSpace)
+		}
+		if chunk != "" {
+			b.WriteString(chunk)
+			onChunk(chunk)
+		}
+		if r.Done {
+			break
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("fail to read Ollama API response: %v", err)
+	}
+	return &Translation{Text: strings.TrimRightFunc(b.String(), unicode.IsSpace)}, nil
+}
@@ -0,0 +1,192 @@
+package gtrans
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// OpenAI translates texts with OpenAI Chat Completions API.
+// https://platform.openai.com/docs/api-reference/chat
+type OpenAI struct {
+	apiKey        string
+	baseURL       string
+	model         string
+	client        *http.Client
+	maskProfanity bool
+}
+
+func newOpenAIEngine(opts *engineOptions) (Engine, error) {
+	apiKey := opts.credential("openai", "OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("OPENAI_API_KEY is not set. Export it or run 'gtrans auth openai'")
+	}
+	o := &OpenAI{
+		apiKey:  apiKey,
+		baseURL: "https://api.openai.com/v1",
+		model:   "gpt-4o-mini",
+		client:  opts.client(),
+	}
+	if u := opts.endpointOr(os.Getenv("OPENAI_BASE_URL")); u != "" {
+		o.baseURL = strings.TrimRight(u, "/")
+	}
+	if m := os.Getenv("OPENAI_MODEL"); m != "" {
+		o.model = m
+	}
+	return o, nil
+}
+
+func (o *OpenAI) Name() string { return "openai" }
+
+// MaskProfanity makes the model mask profanity in translations.
+func (o *OpenAI) MaskProfanity() { o.maskProfanity = true }
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func translationPrompt(target string) string {
+	return fmt.Sprintf("You are a professional translator. Translate the text given by the user into the language whose code is %q. "+
+		"Keep placeholders like __GT0__ as they are. Reply with the translated text only.", target)
+}
+
+// parsePrompt parses a template of a prompt, which is executed with .Target,
+// the code of the target language, and .Text, the text to translate.
+func parsePrompt(text string) (*template.Template, error) {
+	t, err := template.New("prompt").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	// Check the fields before any request.
+	if _, err := executePrompt(t, "en", ""); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func executePrompt(t *template.Template, target, text string) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, struct{ Target, Text string }{target, text}); err != nil {
+		return "", fmt.Errorf("fail to execute prompt: %v", err)
+	}
+	return b.String(), nil
+}
+
+// systemPrompt returns the system prompt given by t, or translationPrompt.
+func systemPrompt(t *template.Template, target string) (string, error) {
+	if t == nil {
+		return translationPrompt(target), nil
+	}
+	return executePrompt(t, target, "")
+}
+
+// call sends a chat completion request translating text and returns the
+// response, whose status is checked.
+func (o *OpenAI) call(ctx context.Context, text, target string, stream bool) (*http.Response, error) {
+	prompt := translationPrompt(target)
+	if o.maskProfanity {
+		prompt += " Replace all but the first letter of profane words with asterisks."
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"model": o.model,
+		"messages": []openAIMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: text},
+		},
+		"stream": stream,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to call OpenAI API: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fail to call OpenAI API: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func (o *OpenAI) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	resp, err := o.call(ctx, text, target, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Choices []struct {
+			Message openAIMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("fail to decode OpenAI API response: %v", err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, errors.New("OpenAI API returned no choices")
+	}
+	return &Translation{Text: strings.TrimSpace(result.Choices[0].Message.Content)}, nil
+}
+
+// TranslateStream translates text, calling onChunk with each piece of the
+// translated text as it is generated. The response is a stream of server-sent
+// events.
+func (o *OpenAI) TranslateStream(ctx context.Context, text, target string, onChunk func(string)) (*Translation, error) {
+	resp, err := o.call(ctx, text, target, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var b strings.Builder
+	s := bufio.NewScanner(resp.Body)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		data := strings.TrimPrefix(s.Text(), "data: ")
+		if data == s.Text() || data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+		var event struct {
+			Choices []struct {
+				Delta openAIMessage `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("fail to decode OpenAI API response: %v", err)
+		}
+		for _, c := range event.Choices {
+			if c.Delta.Content == "" {
+				continue
+			}
+			// Skip leading white spaces, as Translate trims them.
+			chunk := c.Delta.Content
+			if b.Len() == 0 {
+				chunk = strings.TrimLeftFunc(chunk, unicode.IsSpace)
+			}
+			b.WriteString(chunk)
+			onChunk(chunk)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("fail to read OpenAI API response: %v", err)
+	}
+	return &Translation{Text: b.String()}, nil
+}
@@ -0,0 +1,391 @@
+package gtrans
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Option configures a Client created by NewClient.
+type Option func(*Client) error
+
+// WithEngine makes the Client translate with e.
+func WithEngine(e Engine) Option {
+	return func(c *Client) error {
+		c.engine = e
+		return nil
+	}
+}
+
+// WithEngineName makes the Client translate with the engine named name, which
+// is created when it is needed first. It's "google" by default.
+func WithEngineName(name string) Option {
+	return func(c *Client) error {
+		c.engineName = name
+		return nil
+	}
+}
+
+// WithRoutes makes the Client select the engine of each translation by the
+// pair of its languages with routes, which map rules like "ja<->ko", "*->de"
+// and "ja->*" to engine names. The engine of "default" replaces the one given
+// by WithEngineName.
+func WithRoutes(routes map[string]string) Option {
+	return func(c *Client) error {
+		r, err := parseRoutes(routes)
+		if err != nil {
+			return err
+		}
+		if r.fallback != "" {
+			c.engineName = r.fallback
+		}
+		c.routes = r
+		return nil
+	}
+}
+
+// WithCredential sets the API key of the engine named engine, which takes
+// precedence over its environment variable and WithFallbackCredentials.
+func WithCredential(engine, key string) Option {
+	return func(c *Client) error {
+		if c.engineOpts.credentials == nil {
+			c.engineOpts.credentials = map[string]string{}
+		}
+		c.engineOpts.credentials[engine] = key
+		return nil
+	}
+}
+
+// WithFallbackCredentials sets the API keys of engines by engine name, which
+// are used if neither WithCredential nor their environment variables give
+// them, e.g. keys stored in a config file.
+func WithFallbackCredentials(keys map[string]string) Option {
+	return func(c *Client) error {
+		c.engineOpts.fallbacks = keys
+		return nil
+	}
+}
+
+// WithEndpoint sets the base URL of the API of the engine, e.g. a proxy or an
+// OpenAI compatible server.
+func WithEndpoint(url string) Option {
+	return func(c *Client) error {
+		c.engineOpts.endpoint = url
+		return nil
+	}
+}
+
+// WithHTTPClient makes the engine send requests with hc instead of
+// http.DefaultClient, e.g. a client with a timeout or a cookie jar. If hc has
+// no transport, SharedTransport is used.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) error {
+		c.engineOpts.httpClient = hc
+		return nil
+	}
+}
+
+// WithTransport makes the engine send requests through rt, e.g. a transport
+// with client certificates, retries or a test double. The timeout of the
+// client given by WithHTTPClient is kept.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) error {
+		c.engineOpts.transport = rt
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent of requests to the API of the engine.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) error {
+		c.engineOpts.userAgent = ua
+		return nil
+	}
+}
+
+// WithHeader adds a header to requests to the API of the engine, e.g. one
+// required by an API gateway in front of it.
+func WithHeader(name, value string) Option {
+	return func(c *Client) error {
+		if c.engineOpts.headers == nil {
+			c.engineOpts.headers = http.Header{}
+		}
+		c.engineOpts.headers.Add(name, value)
+		return nil
+	}
+}
+
+// WithCompression compresses request bodies of at least minSize bytes to the
+// API of the engine with gzip, unless it rejects them. Zero disables it.
+func WithCompression(minSize int64) Option {
+	return func(c *Client) error {
+		if minSize < 0 {
+			return errors.New("minimum size to compress must not be negative")
+		}
+		c.engineOpts.compressMin = minSize
+		return nil
+	}
+}
+
+// WithCacheDir caches responses of the engine in dir.
+func WithCacheDir(dir string) Option {
+	return func(c *Client) error {
+		c.cache = &fileCache{dir: dir}
+		return nil
+	}
+}
+
+// WithCache caches responses of the engine in location, which is a directory
+// or the URL of a Redis server, redis://[user:password@]host[:port][/db]
+// (rediss:// for TLS), shared with other machines.
+func WithCache(location string) Option {
+	return func(c *Client) error {
+		cache, err := openCache(location)
+		if err != nil {
+			return err
+		}
+		c.cache = cache
+		return nil
+	}
+}
+
+// WithCacheLimits makes cached responses expire after ttl, and evicts the least
+// recently used ones while the cache is larger than maxSize bytes when the
+// Client is closed. Zero means no limit. A Redis server evicts responses by
+// its own maxmemory-policy instead of maxSize.
+func WithCacheLimits(ttl time.Duration, maxSize int64) Option {
+	return func(c *Client) error {
+		if ttl < 0 || maxSize < 0 {
+			return errors.New("cache limits must not be negative")
+		}
+		c.cacheTTL, c.cacheMaxSize = ttl, maxSize
+		return nil
+	}
+}
+
+// WithRateLimit limits requests to the engine to perSecond on average,
+// allowing bursts of burst requests.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(c *Client) error {
+		if perSecond <= 0 || burst < 1 {
+			return errors.New("rate limit must be positive")
+		}
+		c.limiter = NewRateLimiter(perSecond, burst)
+		return nil
+	}
+}
+
+// WithCircuitBreaker skips requests to an engine for openFor after n
+// consecutive errors of it, falling back from an engine selected by routes
+// to the default one.
+func WithCircuitBreaker(n int, openFor time.Duration) Option {
+	return func(c *Client) error {
+		if n < 1 || openFor <= 0 {
+			return errors.New("circuit breaker errors and duration must be positive")
+		}
+		c.circuitErrors, c.circuitOpenFor = n, openFor
+		return nil
+	}
+}
+
+// WithSecondLang makes the Client translate texts already written in the
+// target language into lang instead.
+func WithSecondLang(lang string) Option {
+	return func(c *Client) error {
+		c.secondLang = lang
+		return nil
+	}
+}
+
+// WithOnSameLang sets what to do with texts already written in the target
+// language, which are detected locally with WithLocalDetect: "skip" echoes
+// them, "notice" echoes them with a notice on STDERR, and "translate" (the
+// default) translates them.
+func WithOnSameLang(action string) Option {
+	return func(c *Client) error {
+		switch action {
+		case "skip", "notice":
+			c.onSameLang = action
+		case "translate":
+			c.onSameLang = ""
+		default:
+			return fmt.Errorf("invalid action %q for texts in the target language: must be skip, notice or translate", action)
+		}
+		return nil
+	}
+}
+
+// WithLogger makes the Client log requests to the engine and the cache to l.
+func WithLogger(l *log.Logger) Option {
+	return func(c *Client) error {
+		c.logger = l
+		return nil
+	}
+}
+
+// engineOptions are options to create engines given by Client options. The
+// zero value uses credentials from the environment, default endpoints and
+// sharedTransport. Engines given by WithEngine don't use them.
+type engineOptions struct {
+	credentials map[string]string
+	fallbacks   map[string]string // used if the environment doesn't give them
+	endpoint    string
+	httpClient  *http.Client
+	transport   http.RoundTripper
+	userAgent   string
+	headers     http.Header
+	compressMin int64 // 0 doesn't compress requests
+	execCommand string
+}
+
+// credential returns the API key of engine given by WithCredential, the
+// environment variable env, or the key given by WithFallbackCredentials.
+func (o *engineOptions) credential(engine, env string) string {
+	if o != nil && o.credentials[engine] != "" {
+		return o.credentials[engine]
+	}
+	if key := os.Getenv(env); key != "" {
+		return key
+	}
+	if o != nil {
+		return o.fallbacks[engine]
+	}
+	return ""
+}
+
+// endpointOr returns the endpoint given by WithEndpoint, or def.
+func (o *engineOptions) endpointOr(def string) string {
+	if o != nil && o.endpoint != "" {
+		return o.endpoint
+	}
+	return def
+}
+
+// client returns the client to send requests to the API of the engine, which
+// shares the connections of sharedTransport unless the options give another
+// transport.
+func (o *engineOptions) client() *http.Client {
+	hc := http.DefaultClient
+	if o != nil && o.httpClient != nil {
+		hc = o.httpClient
+	}
+	c := *hc
+	if c.Transport == nil {
+		c.Transport = sharedTransport
+	}
+	if o == nil {
+		return &c
+	}
+	if o.transport != nil {
+		c.Transport = o.transport
+	}
+	if o.compressMin > 0 {
+		c.Transport = &gzipTransport{base: c.Transport, minSize: o.compressMin}
+	}
+	if o.userAgent != "" || len(o.headers) > 0 {
+		c.Transport = &headerTransport{base: c.Transport, userAgent: o.userAgent, headers: o.headers}
+	}
+	return &c
+}
+
+// NewEngine returns the engine named name, a built-in engine or a plugin
+// engine in PATH, configured by the engine options of opts, e.g. WithEndpoint
+// or WithHeader. It's for calling the engine directly without a Client.
+func NewEngine(name string, opts ...Option) (Engine, error) {
+	c, err := NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newEngine(name, &c.engineOpts)
+}
+
+// NewHTTPClient returns the HTTP client engines created with opts send
+// requests with, which shares their transport, e.g. to call other APIs of
+// the providers of the engines.
+func NewHTTPClient(opts ...Option) (*http.Client, error) {
+	c, err := NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c.engineOpts.client(), nil
+}
+
+// RateLimiter is a token bucket limiting requests to an engine.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // to add a token
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+// NewRateLimiter returns a limiter allowing perSecond requests on average,
+// and bursts of burst requests.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// Allow takes a token and returns true if a request is allowed now, or returns
+// false without waiting. A nil limiter allows all requests.
+func (l *RateLimiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Wait blocks until a request is allowed or ctx is done. A nil limiter allows
+// all requests.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	// Take the token now, even if it's not available yet, so that waiting
+	// requests are served in order.
+	l.tokens--
+	wait := time.Duration(-l.tokens * float64(l.interval))
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,126 @@
+package gtrans
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Papago translates texts with Papago Translation API of NAVER Cloud Platform,
+// which is good at Korean and Japanese.
+// https://api.ncloud-docs.com/docs/en/ai-naver-papagonmt-translation
+type Papago struct {
+	clientID     string
+	clientSecret string
+	baseURL      string
+	client       *http.Client
+}
+
+func newPapagoEngine(o *engineOptions) (Engine, error) {
+	id, secret := pairCredential(o, "papago", "PAPAGO_API_KEY", "PAPAGO_CLIENT_ID", "PAPAGO_CLIENT_SECRET")
+	if id == "" || secret == "" {
+		return nil, errors.New("PAPAGO_CLIENT_ID and PAPAGO_CLIENT_SECRET are not set. Export them or run 'gtrans auth papago' with '<client id>:<client secret>'")
+	}
+	return &Papago{
+		clientID:     id,
+		clientSecret: secret,
+		baseURL:      strings.TrimRight(o.endpointOr("https://papago.apigw.ntruss.com"), "/"),
+		client:       o.client(),
+	}, nil
+}
+
+// pairCredential returns the ID and the secret of engine, which are given by
+// the environment variables idEnv and secretEnv, or joined by a colon as the
+// credential of engine, e.g. stored by 'gtrans auth'.
+func pairCredential(o *engineOptions, engine, env, idEnv, secretEnv string) (string, string) {
+	if id, secret := os.Getenv(idEnv), os.Getenv(secretEnv); id != "" && secret != "" {
+		return id, secret
+	}
+	key := o.credential(engine, env)
+	if i := strings.Index(key, ":"); i > 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", ""
+}
+
+func (p *Papago) Name() string { return "papago" }
+
+func (p *Papago) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	var result struct {
+		Message struct {
+			Result struct {
+				SrcLangType    string `json:"srcLangType"`
+				TranslatedText string `json:"translatedText"`
+			} `json:"result"`
+		} `json:"message"`
+	}
+	form := url.Values{"source": {"auto"}, "target": {papagoLang(target)}, "text": {text}}
+	if err := p.call(ctx, "/nmt/v1/translation", form, &result); err != nil {
+		return nil, err
+	}
+	return &Translation{
+		Text:       result.Message.Result.TranslatedText,
+		SourceLang: strings.ToLower(result.Message.Result.SrcLangType),
+	}, nil
+}
+
+func (p *Papago) Detect(ctx context.Context, text string) (string, error) {
+	var result struct {
+		LangCode string `json:"langCode"`
+	}
+	if err := p.call(ctx, "/langs/v1/dect", url.Values{"query": {text}}, &result); err != nil {
+		return "", err
+	}
+	return strings.ToLower(result.LangCode), nil
+}
+
+// call posts form to the API at path and decodes the JSON response into v.
+func (p *Papago) call(ctx context.Context, path string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-NCP-APIGW-API-KEY-ID", p.clientID)
+	req.Header.Set("X-NCP-APIGW-API-KEY", p.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fail to call Papago API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+			ErrorMessage string `json:"errorMessage"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil {
+			if msg := e.Error.Message + e.ErrorMessage; msg != "" {
+				return fmt.Errorf("fail to call Papago API: %s: %s", resp.Status, msg)
+			}
+		}
+		return fmt.Errorf("fail to call Papago API: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("fail to decode Papago API response: %v", err)
+	}
+	return nil
+}
+
+// papagoLang converts a language code used by Google Translate into a Papago
+// language code.
+func papagoLang(lang string) string {
+	switch strings.ToLower(lang) {
+	case "zh", "zh-cn":
+		return "zh-CN"
+	case "zh-tw":
+		return "zh-TW"
+	}
+	return strings.ToLower(lang)
+}
@@ -0,0 +1,128 @@
+package gtrans
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// piiRules returns rules protecting personally identifiable information, so
+// that it is never sent to the API. Rules for more specific patterns come
+// first since the rule added first wins on overlapping matches.
+func piiRules() []protectRule {
+	return []protectRule{
+		{
+			kind: "pii",
+			re:   regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+		},
+		{
+			// Credit card numbers
+			kind:   "pii",
+			re:     regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+			accept: func(m string) bool { return luhn(digits(m)) },
+		},
+		{
+			// US Social Security numbers
+			kind: "pii",
+			re:   regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+		},
+		{
+			// UK National Insurance numbers
+			kind: "pii",
+			re:   regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`),
+		},
+		{
+			// Japanese Individual Numbers (My Number)
+			kind:   "pii",
+			re:     regexp.MustCompile(`\b\d{4}[ -]?\d{4}[ -]?\d{4}\b`),
+			accept: func(m string) bool { return isMyNumber(digits(m)) },
+		},
+		{
+			// Phone numbers. Numbers without a country code or separators
+			// are ignored so that plain numbers aren't redacted.
+			kind: "pii",
+			re:   regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)[ .-]?)?\d{2,4}(?:[ .-]\d{2,4}){1,4}\b|\+\d{9,15}\b`),
+			accept: func(m string) bool {
+				n := len(digits(m))
+				return 9 <= n && n <= 15
+			},
+		},
+	}
+}
+
+// WithRedaction redacts the kinds of sensitive information, a comma separated
+// list, before texts are sent to the engine, and restores it afterwards:
+// "pii" is emails, phone, credit card and national ID numbers.
+func WithRedaction(kinds string) Option {
+	return func(c *Client) error {
+		return c.protector.addRedaction(kinds)
+	}
+}
+
+// addRedaction adds rules for kinds of sensitive information to redact,
+// specified as comma separated list.
+func (p *protector) addRedaction(kinds string) error {
+	for _, kind := range strings.Split(kinds, ",") {
+		switch strings.TrimSpace(kind) {
+		case "pii":
+			for _, r := range piiRules() {
+				p.add(r)
+			}
+		case "":
+		default:
+			return fmt.Errorf("invalid -redact %q: must be pii", kind)
+		}
+	}
+	return nil
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if '0' <= r && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// luhn reports whether the number passes the Luhn checksum used by credit
+// card numbers.
+func luhn(number string) bool {
+	if len(number) < 13 || len(number) > 19 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// isMyNumber reports whether the 12 digit number has a valid check digit of
+// Japanese Individual Number.
+func isMyNumber(number string) bool {
+	if len(number) != 12 {
+		return false
+	}
+	sum := 0
+	for n := 1; n <= 11; n++ {
+		p := int(number[11-n] - '0')
+		q := n + 1
+		if n >= 7 {
+			q = n - 5
+		}
+		sum += p * q
+	}
+	check := 0
+	if r := sum % 11; r > 1 {
+		check = 11 - r
+	}
+	return int(number[11]-'0') == check
+}
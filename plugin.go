@@ -0,0 +1,147 @@
+package gtrans
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// pluginPrefix is the prefix of executables in PATH which are used as engines
+// named after the rest of their names, e.g. gtrans-engine-foo for the engine foo.
+const pluginPrefix = "gtrans-engine-"
+
+// pluginRequest is written as JSON to STDIN of a plugin command, which writes
+// a pluginResponse as JSON to STDOUT and exits. Method is "translate".
+type pluginRequest struct {
+	Method string `json:"method"`
+	Text   string `json:"text"`
+	Target string `json:"target,omitempty"`
+}
+
+// pluginResponse is the response of a plugin command. Error is set if the
+// request fails.
+type pluginResponse struct {
+	Text       string  `json:"text"`
+	SourceLang string  `json:"source_lang,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// commandEngine translates texts by running a command per request which
+// speaks the plugin protocol.
+type commandEngine struct {
+	name string
+	path string
+	args []string
+}
+
+func (e *commandEngine) Name() string { return e.name }
+
+func (e *commandEngine) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	resp, err := e.call(ctx, &pluginRequest{Method: "translate", Text: text, Target: target})
+	if err != nil {
+		return nil, err
+	}
+	return &Translation{Text: resp.Text, SourceLang: resp.SourceLang, Confidence: resp.Confidence}, nil
+}
+
+// call runs the command with req and returns its response. STDERR of the
+// command is included in the error if it fails. The command is killed if ctx
+// is done.
+func (e *commandEngine) call(ctx context.Context, req *pluginRequest) (*pluginResponse, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	if err := runCommand(ctx, e.path, e.args, bytes.NewReader(append(in, '\n')), &stdout, &stderr); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("engine %s: %v: %s", e.name, err, msg)
+		}
+		return nil, fmt.Errorf("engine %s: %v", e.name, err)
+	}
+	var resp pluginResponse
+	if err := json.NewDecoder(&stdout).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("engine %s: invalid response: %v", e.name, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("engine %s: %s", e.name, resp.Error)
+	}
+	return &resp, nil
+}
+
+// PluginEngines returns the paths of plugin executables in PATH by engine
+// name. The first one in PATH wins as the shell does.
+func PluginEngines() map[string]string {
+	plugins := map[string]string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			name := info.Name()
+			if !strings.HasPrefix(name, pluginPrefix) || info.IsDir() {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				if !strings.EqualFold(filepath.Ext(name), ".exe") {
+					continue
+				}
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			} else if info.Mode()&0111 == 0 {
+				continue
+			}
+			name = strings.TrimPrefix(name, pluginPrefix)
+			if _, ok := plugins[name]; !ok && name != "" {
+				plugins[name] = filepath.Join(dir, info.Name())
+			}
+		}
+	}
+	return plugins
+}
+
+// WithExecCommand sets the shell command of the exec engine, which reads a
+// JSON request from STDIN and writes a JSON response as plugin engines do.
+// It's $GTRANS_EXEC_COMMAND by default.
+func WithExecCommand(command string) Option {
+	return func(c *Client) error {
+		c.engineOpts.execCommand = command
+		return nil
+	}
+}
+
+// newExecEngine returns the engine running the command of WithExecCommand (or
+// $GTRANS_EXEC_COMMAND) with the shell, which speaks the plugin protocol.
+func newExecEngine(o *engineOptions) (Engine, error) {
+	var command string
+	if o != nil {
+		command = o.execCommand
+	}
+	if command == "" {
+		command = os.Getenv("GTRANS_EXEC_COMMAND")
+	}
+	if command == "" {
+		return nil, errors.New("-exec-command or GTRANS_EXEC_COMMAND is required for engine exec")
+	}
+	path, args := shellCommand(command)
+	return &commandEngine{name: "exec", path: path, args: args}, nil
+}
+
+func newPluginEngine(name string) (Engine, error) {
+	path, err := lookCommand(pluginPrefix + name)
+	if err != nil {
+		return nil, err
+	}
+	return &commandEngine{name: name, path: path}, nil
+}
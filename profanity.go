@@ -0,0 +1,116 @@
+package gtrans
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ProfanityMasker is implemented by engines which can mask profanity in
+// translations by themselves.
+type ProfanityMasker interface {
+	MaskProfanity()
+}
+
+// profanityWords is the built-in wordlist for each target language.
+var profanityWords = map[string][]string{
+	"en": {
+		"arse", "arsehole", "ass", "asshole", "assholes", "bastard", "bastards",
+		"bitch", "bitches", "bollocks", "bullshit", "cock", "cocks", "crap",
+		"cunt", "cunts", "damn", "dick", "dicks", "dickhead", "fuck", "fucked",
+		"fucker", "fuckers", "fucking", "fucks", "motherfucker", "motherfuckers",
+		"piss", "pissed", "prick", "shit", "shits", "shitty", "slut", "sluts",
+		"twat", "wanker", "wankers", "whore", "whores",
+	},
+}
+
+// WithProfanityMask masks profanity in translations, by the engine if it's a
+// ProfanityMasker, or with the built-in wordlist of the target language and
+// the words in the file list, one per line, if it's not empty.
+func WithProfanityMask(list string) Option {
+	return func(c *Client) error {
+		c.maskProfanity, c.profanityList = true, list
+		return nil
+	}
+}
+
+// profanityFilter masks words in a wordlist.
+type profanityFilter struct {
+	re *regexp.Regexp
+}
+
+// newProfanityFilter returns a filter of the built-in wordlist for lang and
+// the words in file, one per line. Lines starting with # are ignored.
+func newProfanityFilter(lang, file string) (*profanityFilter, error) {
+	words := append([]string(nil), profanityWords[strings.ToLower(lang)]...)
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if w := strings.TrimSpace(s.Text()); w != "" && !strings.HasPrefix(w, "#") {
+				words = append(words, w)
+			}
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(words) == 0 {
+		return &profanityFilter{}, nil
+	}
+	exprs := make([]string, len(words))
+	for i, w := range words {
+		// Words in scripts without spaces, e.g. Japanese, can't be matched on
+		// word boundaries.
+		expr := regexp.QuoteMeta(w)
+		if isWordByte(w[0]) {
+			expr = `\b` + expr
+		}
+		if isWordByte(w[len(w)-1]) {
+			expr = expr + `\b`
+		}
+		exprs[i] = expr
+	}
+	re, err := regexp.Compile(`(?i)(?:` + strings.Join(exprs, "|") + `)`)
+	if err != nil {
+		return nil, err
+	}
+	return &profanityFilter{re: re}, nil
+}
+
+// profanityFilter returns the filter of the list given by WithProfanityMask
+// for lang, which is loaded once.
+func (c *Client) profanityFilter(lang string) (*profanityFilter, error) {
+	c.filterMu.Lock()
+	defer c.filterMu.Unlock()
+	lang = strings.ToLower(lang)
+	if f := c.filters[lang]; f != nil {
+		return f, nil
+	}
+	f, err := newProfanityFilter(lang, c.profanityList)
+	if err != nil {
+		return nil, err
+	}
+	if c.filters == nil {
+		c.filters = map[string]*profanityFilter{}
+	}
+	c.filters[lang] = f
+	return f, nil
+}
+
+// Mask replaces all but the first letter of profane words with asterisks.
+func (f *profanityFilter) Mask(text string) string {
+	if f.re == nil {
+		return text
+	}
+	return f.re.ReplaceAllStringFunc(text, func(w string) string {
+		_, size := utf8.DecodeRuneInString(w)
+		return w[:size] + strings.Repeat("*", utf8.RuneCountInString(w)-1)
+	})
+}
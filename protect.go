@@ -0,0 +1,183 @@
+package gtrans
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// WithProtect keeps the matches of the regular expression pattern as they are
+// in translations, e.g. ticket IDs, replacing them with placeholders of the
+// class kind, which is "custom" if empty. The rules given first win when
+// matches overlap, including those of WithRedaction and WithGlossary.
+func WithProtect(kind, pattern string) Option {
+	return func(c *Client) error {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid protect pattern %q: %v", pattern, err)
+		}
+		if kind == "" {
+			kind = "custom"
+		}
+		c.protector.add(protectRule{kind: kind, re: re})
+		return nil
+	}
+}
+
+// protector replaces parts of a text which must survive translation with
+// placeholders before the text is sent to the API, so that they can be
+// restored afterwards.
+type protector struct {
+	rules []protectRule
+}
+
+// protectRule protects the matches of re for which accept returns true (or all
+// matches if accept is nil). replace returns the text restored in place of a
+// match; if it is nil, the match is restored as is.
+type protectRule struct {
+	kind    string
+	re      *regexp.Regexp
+	accept  func(match string) bool
+	replace func(match string) string
+}
+
+type placeholder struct {
+	kind  string
+	value string
+}
+
+// placeholders holds the values replaced by protector.Protect, indexed by the
+// number in their placeholder token.
+type placeholders []placeholder
+
+// Placeholder tokens look like __GT0__. Google Translate leaves them alone, but
+// may add spaces inside them.
+var placeholderRe = regexp.MustCompile(`(?i)__\s*GT\s*(\d+)\s*__`)
+
+func placeholderToken(i int) string {
+	return "__GT" + strconv.Itoa(i) + "__"
+}
+
+func (p *protector) add(rule protectRule) {
+	p.rules = append(p.rules, rule)
+}
+
+// Protect returns text with protected parts replaced by placeholder tokens.
+// When matches of rules overlap, the rule added first wins.
+func (p *protector) Protect(text string) (string, placeholders) {
+	type match struct {
+		start, end int
+		rule       int
+	}
+	var matches []match
+	for i, r := range p.rules {
+		for _, loc := range r.re.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] || r.accept != nil && !r.accept(text[loc[0]:loc[1]]) {
+				continue
+			}
+			matches = append(matches, match{start: loc[0], end: loc[1], rule: i})
+		}
+	}
+	if len(matches) == 0 {
+		return text, nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].rule != matches[j].rule {
+			return matches[i].rule < matches[j].rule
+		}
+		return matches[i].start < matches[j].start
+	})
+	var picked []match
+	for _, m := range matches {
+		overlapped := false
+		for _, q := range picked {
+			if m.start < q.end && q.start < m.end {
+				overlapped = true
+				break
+			}
+		}
+		if !overlapped {
+			picked = append(picked, m)
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].start < picked[j].start })
+
+	var (
+		out  []byte
+		ps   placeholders
+		last int
+	)
+	for _, m := range picked {
+		r := p.rules[m.rule]
+		value := text[m.start:m.end]
+		if r.replace != nil {
+			value = r.replace(value)
+		}
+		out = append(out, text[last:m.start]...)
+		out = append(out, placeholderToken(len(ps))...)
+		ps = append(ps, placeholder{kind: r.kind, value: value})
+		last = m.end
+	}
+	out = append(out, text[last:]...)
+	return string(out), ps
+}
+
+// Restore replaces placeholder tokens in translated text with their values.
+func (ps placeholders) Restore(text string) string {
+	return ps.RestoreFunc(text, func(p placeholder) string { return p.value })
+}
+
+// RestoreFunc replaces placeholder tokens in translated text with the result
+// of f, e.g. their highlighted values.
+func (ps placeholders) RestoreFunc(text string, f func(p placeholder) string) string {
+	if len(ps) == 0 {
+		return text
+	}
+	return placeholderRe.ReplaceAllStringFunc(text, func(token string) string {
+		sub := placeholderRe.FindStringSubmatch(token)
+		i, err := strconv.Atoi(sub[1])
+		if err != nil || i >= len(ps) {
+			return token
+		}
+		return f(ps[i])
+	})
+}
+
+// partialPlaceholderRe matches a placeholder token which may be incomplete at
+// the end of a text.
+var partialPlaceholderRe = regexp.MustCompile(`(?i)_(?:_\s*(?:G\s*(?:T\s*(?:\d+\s*_?)?)?)?)?$`)
+
+// streamRestorer restores placeholder tokens in translated text streamed in
+// chunks, which may split tokens. Text which may be a part of a token is held
+// back until the next chunk.
+type streamRestorer struct {
+	ps   placeholders
+	emit func(string)
+	buf  string
+}
+
+func (s *streamRestorer) Write(chunk string) {
+	s.buf += chunk
+	cut := len(s.buf)
+	if loc := partialPlaceholderRe.FindStringIndex(s.buf); loc != nil {
+		cut = loc[0]
+		for _, t := range placeholderRe.FindAllStringIndex(s.buf, -1) {
+			if t[0] <= cut && cut < t[1] {
+				cut = t[1]
+			}
+		}
+	}
+	if cut > 0 {
+		s.emit(s.ps.Restore(s.buf[:cut]))
+		s.buf = s.buf[cut:]
+	}
+}
+
+// Flush emits the text held back.
+func (s *streamRestorer) Flush() {
+	if s.buf != "" {
+		s.emit(s.ps.Restore(s.buf))
+		s.buf = ""
+	}
+}
@@ -0,0 +1,42 @@
+package gtrans
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// WithQuality estimates the quality of each translation in Result.Quality, by
+// back-translation unless the engine reports its confidence, which costs
+// another API call. Translations below min are flagged by Result.LowQuality
+// and not recorded in the translation memory.
+func WithQuality(min float64) Option {
+	return func(c *Client) error {
+		c.quality, c.minQuality = true, min
+		return nil
+	}
+}
+
+// estimateQuality returns a rough quality score of translated in [0, 1]. It
+// uses the confidence reported by the engine if available. Otherwise, the
+// translated text is translated back into the source language and compared
+// with text, which costs another API call.
+func estimateQuality(ctx context.Context, engine Engine, text string, translated *Translation) (float64, error) {
+	if translated.Confidence > 0 {
+		return translated.Confidence, nil
+	}
+	if translated.SourceLang == "" {
+		return 0, errors.New("cannot estimate quality: source language is unknown")
+	}
+	back, err := engine.Translate(ctx, translated.Text, translated.SourceLang)
+	if err != nil {
+		return 0, err
+	}
+	return similarity(normalizeSpace(text), normalizeSpace(back.Text)), nil
+}
+
+// normalizeSpace lowercases s and collapses runs of white spaces, so that
+// back-translations aren't penalized for trivial differences.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
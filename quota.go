@@ -0,0 +1,31 @@
+package gtrans
+
+import "context"
+
+// WithQuota makes the Client call allow with the texts before sending them to
+// an engine, failing them if it returns an error, e.g. to confirm or limit the
+// characters sent to paid engines. Texts found in the cache or the
+// translation memory are not sent.
+func WithQuota(allow func(engine string, texts []string) error) Option {
+	return func(c *Client) error {
+		c.quota = allow
+		return nil
+	}
+}
+
+// quotaMiddleware fails requests to engine which the quota doesn't allow.
+func (c *Client) quotaMiddleware(engine Engine, next Handler) Handler {
+	return func(ctx context.Context, reqs []*HookRequest) {
+		texts := make([]string, len(reqs))
+		for i, req := range reqs {
+			texts[i] = req.Text
+		}
+		if err := c.quota(engine.Name(), texts); err != nil {
+			for _, req := range reqs {
+				req.Err = err
+			}
+			return
+		}
+		next(ctx, reqs)
+	}
+}
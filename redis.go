@@ -0,0 +1,331 @@
+package gtrans
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisKeyPrefix is the prefix of the keys of cached responses in Redis.
+const redisKeyPrefix = "gtrans:cache:"
+
+// Keys of the statistics in Redis.
+const (
+	redisHitsKey   = "gtrans:stats:hits"
+	redisMissesKey = "gtrans:stats:misses"
+)
+
+// redisCache caches responses of engines in a Redis server (or a compatible
+// one), so that machines and servers can share a cache. It speaks RESP over a
+// single connection, which is opened when it is needed first and reopened
+// after a network error.
+type redisCache struct {
+	u *url.URL
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newRedisCache(rawurl string) (*redisCache, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %v", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid Redis URL %q: host is empty", rawurl)
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if _, err := strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid Redis URL %q: database must be a number", rawurl)
+		}
+	}
+	return &redisCache{u: u}, nil
+}
+
+func (rc *redisCache) Get(engine, text, target string) (*cacheEntry, error) {
+	v, err := rc.do("GET", redisKeyPrefix+cacheKey(engine, text, target))
+	if err != nil || v == nil {
+		return nil, err
+	}
+	var e cacheEntry
+	if err := json.Unmarshal([]byte(v.(string)), &e); err != nil {
+		// A broken entry is a cache miss; it's overwritten by Put.
+		return nil, nil
+	}
+	return &e, nil
+}
+
+// Put caches e, which Redis removes when it expires.
+func (rc *redisCache) Put(e *cacheEntry, ttl time.Duration) error {
+	if e.Created.IsZero() {
+		e.Created = time.Now().UTC()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	args := []string{"SET", redisKeyPrefix + cacheKey(e.Engine, e.Source, e.TargetLang), string(b)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(int64(ttl/time.Millisecond), 10))
+	}
+	_, err = rc.do(args...)
+	return err
+}
+
+// Prune does nothing, as Redis removes expired entries by itself and evicts
+// entries by its maxmemory-policy, e.g. allkeys-lru.
+func (rc *redisCache) Prune(ttl time.Duration, maxSize int64) error {
+	return nil
+}
+
+func (rc *redisCache) Count(hits, misses int64) error {
+	for _, c := range []struct {
+		key string
+		n   int64
+	}{{redisHitsKey, hits}, {redisMissesKey, misses}} {
+		if c.n == 0 {
+			continue
+		}
+		if _, err := rc.do("INCRBY", c.key, strconv.FormatInt(c.n, 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rc *redisCache) Stats() (*CacheStats, error) {
+	var s CacheStats
+	for _, c := range []struct {
+		key string
+		n   *int64
+	}{{redisHitsKey, &s.Hits}, {redisMissesKey, &s.Misses}} {
+		v, err := rc.do("GET", c.key)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			*c.n, _ = strconv.ParseInt(v.(string), 10, 64)
+		}
+	}
+	err := rc.scan(func(keys []string) error {
+		s.Entries += len(keys)
+		for _, k := range keys {
+			v, err := rc.do("STRLEN", k)
+			if err != nil {
+				return err
+			}
+			n, _ := v.(int64)
+			s.Bytes += n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Each calls f with every cached response. Broken ones are skipped.
+func (rc *redisCache) Each(f func(e *cacheEntry) error) error {
+	return rc.scan(func(keys []string) error {
+		for _, k := range keys {
+			v, err := rc.do("GET", k)
+			if err != nil {
+				return err
+			}
+			s, ok := v.(string)
+			if !ok {
+				// Expired after the scan.
+				continue
+			}
+			var e cacheEntry
+			if json.Unmarshal([]byte(s), &e) != nil {
+				continue
+			}
+			if err := f(&e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Clear removes all cached responses and the statistics, leaving other keys in
+// the database.
+func (rc *redisCache) Clear() error {
+	err := rc.scan(func(keys []string) error {
+		_, err := rc.do(append([]string{"DEL"}, keys...)...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = rc.do("DEL", redisHitsKey, redisMissesKey)
+	return err
+}
+
+// scan calls f with the keys of cached responses, some at a time.
+func (rc *redisCache) scan(f func(keys []string) error) error {
+	cursor := "0"
+	for {
+		v, err := rc.do("SCAN", cursor, "MATCH", redisKeyPrefix+"*", "COUNT", "1000")
+		if err != nil {
+			return err
+		}
+		reply, ok := v.([]interface{})
+		if !ok || len(reply) != 2 {
+			return errors.New("redis: unexpected reply to SCAN")
+		}
+		cursor, _ = reply[0].(string)
+		vs, _ := reply[1].([]interface{})
+		if len(vs) > 0 {
+			keys := make([]string, len(vs))
+			for i, v := range vs {
+				keys[i], _ = v.(string)
+			}
+			if err := f(keys); err != nil {
+				return err
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// String returns the URL of the server without the password.
+func (rc *redisCache) String() string {
+	u := *rc.u
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// do sends a command and returns its reply: a string, an int64, a nil or a
+// []interface{} of them.
+func (rc *redisCache) do(args ...string) (interface{}, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.conn == nil {
+		if err := rc.dial(); err != nil {
+			return nil, err
+		}
+	}
+	v, err := rc.roundTrip(args)
+	var re redisError
+	if err != nil && !errors.As(err, &re) {
+		// The connection may be out of sync with the replies.
+		rc.conn.Close()
+		rc.conn = nil
+	}
+	return v, err
+}
+
+func (rc *redisCache) dial() error {
+	d := &net.Dialer{Timeout: 5 * time.Second}
+	host := rc.u.Host
+	if rc.u.Port() == "" {
+		host = net.JoinHostPort(rc.u.Hostname(), "6379")
+	}
+	var conn net.Conn
+	var err error
+	if rc.u.Scheme == "rediss" {
+		conn, err = tls.DialWithDialer(d, "tcp", host, &tls.Config{ServerName: rc.u.Hostname()})
+	} else {
+		conn, err = d.Dial("tcp", host)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to connect to Redis: %v", err)
+	}
+	rc.conn, rc.r = conn, bufio.NewReader(conn)
+	var setup [][]string
+	if pass, ok := rc.u.User.Password(); ok {
+		if user := rc.u.User.Username(); user != "" {
+			setup = append(setup, []string{"AUTH", user, pass})
+		} else {
+			setup = append(setup, []string{"AUTH", pass})
+		}
+	}
+	if db := strings.TrimPrefix(rc.u.Path, "/"); db != "" && db != "0" {
+		setup = append(setup, []string{"SELECT", db})
+	}
+	for _, args := range setup {
+		if _, err := rc.roundTrip(args); err != nil {
+			conn.Close()
+			rc.conn = nil
+			return fmt.Errorf("fail to connect to Redis: %v", err)
+		}
+	}
+	return nil
+}
+
+func (rc *redisCache) roundTrip(args []string) (interface{}, error) {
+	rc.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(rc.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(rc.r)
+}
+
+// redisError is an error reply of Redis.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, redisError(line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		vs := make([]interface{}, n)
+		for i := range vs {
+			if vs[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return vs, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply %q", line)
+}
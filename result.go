@@ -0,0 +1,38 @@
+package gtrans
+
+// Result is the result of translating a text.
+type Result struct {
+	Source      string   `json:"source"`
+	Translation string   `json:"translation"`
+	SourceLang  string   `json:"source_lang,omitempty"`
+	TargetLang  string   `json:"target_lang"`
+	Engine      string   `json:"engine"`
+	Quality     *float64 `json:"quality,omitempty"`
+	LowQuality  bool     `json:"low_quality,omitempty"`
+
+	// Err is the error of translating Source by Client.TranslateBatch, in
+	// which case the other fields but TargetLang are empty.
+	Err error `json:"-"`
+
+	raw string       // translation before restoring placeholders
+	ps  placeholders // placeholders in raw
+}
+
+// Highlight returns the translation with the protected parts, e.g. glossary
+// terms, replaced with the results of f, which is called with the class of
+// each part and its value, e.g. to colorize them.
+func (r *Result) Highlight(f func(kind, value string) string) string {
+	if r.raw == "" {
+		return r.Translation
+	}
+	return r.ps.RestoreFunc(r.raw, func(p placeholder) string { return f(p.kind, p.value) })
+}
+
+// Confidence returns the estimated quality of the translation, or 0 if it's
+// not estimated, e.g. for templates.
+func (r *Result) Confidence() float64 {
+	if r.Quality == nil {
+		return 0
+	}
+	return *r.Quality
+}
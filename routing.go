@@ -0,0 +1,221 @@
+package gtrans
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// engineRoute is a routing rule which selects the engine for the pairs of the source
+// and the target languages matching it. "*" matches any language.
+type engineRoute struct {
+	rule     string
+	from, to string
+	both     bool // the pair matches in both directions
+	engine   string
+}
+
+// router selects engines by the language pair of each translation. It's
+// configured by the routes in the config, e.g.
+//
+//	"ja<->ko": "papago", "*->de": "deepl", "default": "google"
+type router struct {
+	routes   []engineRoute
+	fallback string // engine of "default"
+}
+
+// parseRoutes parses routes, which map rules to engine names.
+func parseRoutes(routes map[string]string) (*router, error) {
+	r := &router{}
+	for rule, engine := range routes {
+		if engine == "" {
+			return nil, fmt.Errorf("invalid route %q: no engine", rule)
+		}
+		if rule == "default" {
+			r.fallback = engine
+			continue
+		}
+		rt, err := parseRoute(rule)
+		if err != nil {
+			return nil, err
+		}
+		rt.engine = engine
+		r.routes = append(r.routes, rt)
+	}
+	// Rules are tried in a fixed order, as maps have none.
+	sort.Slice(r.routes, func(i, j int) bool { return r.routes[i].rule < r.routes[j].rule })
+	return r, nil
+}
+
+// parseRoute parses a rule of the form <from>-><to> or <lang><-><lang>.
+func parseRoute(rule string) (engineRoute, error) {
+	rt := engineRoute{rule: rule}
+	sep := "->"
+	if strings.Contains(rule, "<->") {
+		sep, rt.both = "<->", true
+	}
+	i := strings.Index(rule, sep)
+	if i < 0 {
+		return rt, fmt.Errorf("invalid route %q: must be <from>-><to>, <lang><-><lang> or default", rule)
+	}
+	rt.from = strings.ToLower(strings.TrimSpace(rule[:i]))
+	rt.to = strings.ToLower(strings.TrimSpace(rule[i+len(sep):]))
+	if rt.from == "" || rt.to == "" || strings.Contains(rt.to, "->") {
+		return rt, fmt.Errorf("invalid route %q: must be <from>-><to>, <lang><-><lang> or default", rule)
+	}
+	return rt, nil
+}
+
+// langMatches reports whether lang matches the language of a rule. A rule
+// without a region matches all regions of the language, e.g. zh matches
+// zh-TW.
+func langMatches(rule, lang string) bool {
+	if rule == "*" {
+		return true
+	}
+	lang = strings.ToLower(lang)
+	if rule == lang {
+		return true
+	}
+	i := strings.IndexAny(lang, "-_")
+	return i > 0 && !strings.ContainsAny(rule, "-_") && rule == lang[:i]
+}
+
+// specificity returns how specific the match of the route is. A rule of the
+// target language is more specific than a rule of the source language.
+func (rt *engineRoute) specificity() int {
+	n := 0
+	if rt.from != "*" {
+		n++
+	}
+	if rt.to != "*" {
+		n += 2
+	}
+	return n
+}
+
+func (rt *engineRoute) matches(source, target string) bool {
+	if rt.from != "*" && source == "" {
+		return false
+	}
+	if langMatches(rt.from, source) && langMatches(rt.to, target) {
+		return true
+	}
+	return rt.both && langMatches(rt.to, source) && langMatches(rt.from, target)
+}
+
+// engine returns the engine of the most specific route matching the pair of
+// source and target, or the default engine if none matches. source is empty
+// if it's unknown, which matches only rules of any source language.
+func (r *router) engine(source, target string) string {
+	best, name := -1, r.fallback
+	for i := range r.routes {
+		rt := &r.routes[i]
+		if s := rt.specificity(); s > best && rt.matches(source, target) {
+			best, name = s, rt.engine
+		}
+	}
+	return name
+}
+
+// needsSource reports whether the source language of texts into target has
+// to be detected to select their engine. r may be nil.
+func (r *router) needsSource(target string) bool {
+	if r == nil {
+		return false
+	}
+	for _, rt := range r.routes {
+		if rt.from == "*" {
+			continue
+		}
+		if langMatches(rt.to, target) || rt.both && langMatches(rt.from, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Engine returns the engine named name, or the default engine if name is
+// empty, which is created when it is needed first.
+func (c *Client) Engine(name string) (Engine, error) {
+	return c.engineNamed(name)
+}
+
+// EngineName returns the name of the default engine.
+func (c *Client) EngineName() string {
+	return c.engineName
+}
+
+// engineNamed returns the engine named name, which is created when it is
+// needed first like the default engine.
+func (c *Client) engineNamed(name string) (Engine, error) {
+	if name == "" || name == c.engineName {
+		return c.getEngine()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.routed[name]; ok {
+		return e, nil
+	}
+	var engine Engine = &offlineEngine{name: name}
+	if !c.offline {
+		var err error
+		if engine, err = newEngine(name, &c.engineOpts); err != nil {
+			return nil, err
+		}
+		if m, ok := engine.(ProfanityMasker); ok && c.maskProfanity {
+			m.MaskProfanity()
+		}
+	}
+	if c.routed == nil {
+		c.routed = map[string]Engine{}
+	}
+	c.routed[name] = engine
+	return engine, nil
+}
+
+// engineKey is the context key of the engine name given by ContextWithEngine.
+type engineKey struct{}
+
+// ContextWithEngine returns a copy of ctx translating with the engine named
+// name instead of the routed or the default one, e.g. the engine picked by a
+// user.
+func ContextWithEngine(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, engineKey{}, name)
+}
+
+// routeEngine returns the engine given by ContextWithEngine to ctx, the engine routed
+// for the pair of source and target, or the default engine without routes.
+// source is empty if it's unknown.
+func (c *Client) routeEngine(ctx context.Context, source, target string) (Engine, error) {
+	if name, ok := ctx.Value(engineKey{}).(string); ok && name != "" {
+		return c.engineNamed(name)
+	}
+	if c.routes == nil {
+		return c.getEngine()
+	}
+	name := c.routes.engine(source, target)
+	if name != c.engineName && c.breaker(name).isOpen() {
+		c.logf("engine %s: circuit is open, falling back to %s", name, c.engineName)
+		name = c.engineName
+	}
+	engine, err := c.engineNamed(name)
+	if err != nil {
+		return nil, fmt.Errorf("route to %s: %v", name, err)
+	}
+	return engine, nil
+}
+
+// route returns the engine routed for text into target, detecting the source
+// language if a route depends on it.
+func (c *Client) route(ctx context.Context, text, target string) (Engine, error) {
+	source := ""
+	if c.routes.needsSource(target) {
+		var err error
+		if source, _, err = c.detectSource(ctx, text); err != nil {
+			return nil, err
+		}
+	}
+	return c.routeEngine(ctx, source, target)
+}
@@ -0,0 +1,126 @@
+package gtrans
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// WithPreHook runs the shell command for every text before it's translated,
+// with the text on its STDIN, and translates its STDOUT instead, e.g. to
+// strip tracking codes or normalize markup. The target language and the name
+// of the engine are given to the command as $1 and $2. The hook runs before
+// protected parts are replaced with placeholders, and the cache is looked up
+// by the replaced text.
+func WithPreHook(command string) Option {
+	return func(c *Client) error {
+		c.preHook = command
+		return nil
+	}
+}
+
+// preHookMiddleware replaces the texts of reqs with the outputs of the
+// pre-hook. Requests whose hook fails fail.
+func (c *Client) preHookMiddleware(next Handler) Handler {
+	return func(ctx context.Context, reqs []*HookRequest) {
+		var pending []*HookRequest
+		for _, req := range reqs {
+			text, err := runHook(ctx, c.preHook, req.Text, req.TargetLang, req.Engine)
+			if err != nil {
+				req.Err = fmt.Errorf("pre-hook: %v", err)
+				continue
+			}
+			req.Text = text
+			pending = append(pending, req)
+		}
+		if len(pending) > 0 {
+			next(ctx, pending)
+		}
+	}
+}
+
+// WithPostHook runs the shell command for every translated text, with the
+// translation on its STDIN, and uses its STDOUT instead, e.g. to fix the style
+// or check the terms. The target language and the name of the engine are given
+// to the command as $1 and $2. The hook runs after protected parts are
+// restored, including for translations found in the cache.
+func WithPostHook(command string) Option {
+	return func(c *Client) error {
+		c.postHook = command
+		return nil
+	}
+}
+
+// WithPostFileHook runs the shell command for every translated file before
+// it's written, with the translation on its STDIN, and writes its STDOUT
+// instead. The target language and the path of the file relative to the
+// translated directory are given to the command as $1 and $2.
+func WithPostFileHook(command string) Option {
+	return func(c *Client) error {
+		c.postFileHook = command
+		return nil
+	}
+}
+
+// postHookMiddleware replaces the translations of reqs with the outputs of
+// the post-hook. Requests whose hook fails fail.
+func (c *Client) postHookMiddleware(next Handler) Handler {
+	return func(ctx context.Context, reqs []*HookRequest) {
+		next(ctx, reqs)
+		for _, req := range reqs {
+			if req.Err != nil || req.Translation == nil {
+				continue
+			}
+			text, err := runHook(ctx, c.postHook, req.Translation.Text, req.TargetLang, req.Engine)
+			if err != nil {
+				req.Err = fmt.Errorf("post-hook: %v", err)
+				continue
+			}
+			req.Translation.Text = text
+		}
+	}
+}
+
+// RunPostFileHook returns the output of the command of WithPostFileHook for
+// the translation of the file rel, or translated as is if it isn't set.
+func (c *Client) RunPostFileHook(ctx context.Context, translated []byte, targetLang, rel string) ([]byte, error) {
+	if c.postFileHook == "" {
+		return translated, nil
+	}
+	out, err := runHook(ctx, c.postFileHook, string(translated), targetLang, rel)
+	if err != nil {
+		return nil, fmt.Errorf("post-file-hook: %v", err)
+	}
+	return []byte(out), nil
+}
+
+// runHook runs the shell command of a hook with text on its STDIN and args as
+// its positional parameters, and returns its STDOUT. A newline added at the
+// end of the output, e.g. by sed, is removed.
+func runHook(ctx context.Context, command, text string, args ...string) (string, error) {
+	path, shellArgs := shellCommand(command, args...)
+	var stdout, stderr bytes.Buffer
+	if err := runCommand(ctx, path, shellArgs, strings.NewReader(text), &stdout, &stderr); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	out := stdout.String()
+	if !strings.HasSuffix(text, "\n") {
+		out = strings.TrimSuffix(strings.TrimSuffix(out, "\n"), "\r")
+	}
+	return out, nil
+}
+
+// shellCommand returns the path and the arguments running command with the
+// shell, giving args as its positional parameters. cmd of Windows doesn't
+// take them.
+func shellCommand(command string, args ...string) (string, []string) {
+	if runtime.GOOS == "windows" {
+		return "cmd", []string{"/C", command}
+	}
+	return "/bin/sh", append([]string{"-c", command, "gtrans"}, args...)
+}
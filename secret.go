@@ -0,0 +1,51 @@
+package gtrans
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// secretPatterns are patterns of credentials which must not be sent to
+// third-party APIs by accident.
+var secretPatterns = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----`)},
+	{"AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS secret access key", regexp.MustCompile(`(?i)aws_secret_access_key\s*[=:]\s*["']?[A-Za-z0-9/+]{40}`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"OpenAI/Anthropic API key", regexp.MustCompile(`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}`)},
+	{"JSON Web Token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"credential assignment", regexp.MustCompile(`(?i)\b(?:api[_-]?key|secret|token|passwd|password)\b["']?\s*[:=]\s*["']?[A-Za-z0-9/+_.-]{16,}`)},
+}
+
+// WithAllowSecrets makes the Client send texts even if they look like they
+// contain API keys, private keys or tokens, which are refused by default.
+func WithAllowSecrets() Option {
+	return func(c *Client) error {
+		c.allowSecrets = true
+		return nil
+	}
+}
+
+// CheckSecrets returns an error describing where text seems to contain
+// secrets. The secrets themselves are not included in the error.
+func CheckSecrets(text string) error {
+	var found []string
+	for i, line := range strings.Split(text, "\n") {
+		for _, p := range secretPatterns {
+			if p.re.MatchString(line) {
+				found = append(found, fmt.Sprintf("%s on line %d", p.name, i+1))
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	return fmt.Errorf("refusing to send input which looks like it contains secrets (%s). Use -allow-secrets to send it anyway", strings.Join(found, ", "))
+}
@@ -0,0 +1,173 @@
+package gtrans
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// runStats are the statistics of a run written by WithStats and recorded in
+// the usage log.
+type runStats struct {
+	mu      sync.Mutex
+	start   time.Time
+	texts   int // translated by engines or the cache
+	chars   int
+	tmHits  int
+	engines map[string]*engineStats
+	pairs   map[string]int // texts by language pairs, e.g. "en->ja"
+}
+
+// engineStats are the statistics of API calls to an engine in a run.
+type engineStats struct {
+	Engine string `json:"engine"`
+	Calls  int    `json:"calls"`
+	Texts  int    `json:"texts"`
+	Chars  int    `json:"chars"`
+	Errors int    `json:"errors"`
+	// TimeMS is the total time of the calls in milliseconds.
+	TimeMS int64 `json:"time_ms"`
+}
+
+// WithStats makes Close write the statistics of the run to w in format, text
+// or json: the texts and characters translated, the API calls, the cache hits
+// and the elapsed time, with the breakdown by engine.
+func WithStats(w io.Writer, format string) Option {
+	return func(c *Client) error {
+		if format != "text" && format != "json" {
+			return fmt.Errorf("invalid format of statistics %q: must be text or json", format)
+		}
+		if c.stats == nil {
+			c.stats = newRunStats()
+		}
+		c.statsOut, c.statsFormat = w, format
+		return nil
+	}
+}
+
+func newRunStats() *runStats {
+	return &runStats{start: time.Now(), engines: map[string]*engineStats{}, pairs: map[string]int{}}
+}
+
+// translate counts the texts of reqs requested through the chain to engines.
+func (s *runStats) translate(reqs []*HookRequest) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, req := range reqs {
+		s.texts++
+		s.chars += utf8.RuneCountInString(req.Text)
+	}
+}
+
+// translated counts the language pairs of the translated reqs.
+func (s *runStats) translated(reqs []*HookRequest) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, req := range reqs {
+		if req.Translation != nil && req.Err == nil {
+			s.pairs[langPair(req.Translation.SourceLang, req.TargetLang)]++
+		}
+	}
+}
+
+// langPair returns the pair of the languages, where an unknown source is "?".
+func langPair(source, target string) string {
+	if source == "" {
+		source = "?"
+	}
+	return source + "->" + target
+}
+
+// tmHit counts a text found in the translation memory.
+func (s *runStats) tmHit() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.tmHits++
+	s.mu.Unlock()
+}
+
+// call counts an API call to the engine named name translating texts.
+func (s *runStats) call(name string, texts []string, d time.Duration, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.engines[name]
+	if e == nil {
+		e = &engineStats{Engine: name}
+		s.engines[name] = e
+	}
+	e.Calls++
+	e.Texts += len(texts)
+	for _, text := range texts {
+		e.Chars += utf8.RuneCountInString(text)
+	}
+	if err != nil {
+		e.Errors++
+	}
+	e.TimeMS += d.Milliseconds()
+}
+
+// write writes the statistics with the numbers of cache hits and misses to w
+// in format, text or json.
+func (s *runStats) write(w io.Writer, format string, cacheHits, cacheMisses int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	engines := make([]*engineStats, 0, len(s.engines))
+	calls := 0
+	for _, e := range s.engines {
+		engines = append(engines, e)
+		calls += e.Calls
+	}
+	sort.Slice(engines, func(i, j int) bool { return engines[i].Engine < engines[j].Engine })
+	elapsed := time.Since(s.start)
+	if format == "json" {
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"elapsed_ms":   elapsed.Milliseconds(),
+			"texts":        s.texts,
+			"chars":        s.chars,
+			"tm_hits":      s.tmHits,
+			"cache_hits":   cacheHits,
+			"cache_misses": cacheMisses,
+			"api_calls":    calls,
+			"engines":      engines,
+		})
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "elapsed\t%v\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "translated\t%d texts (%d chars)\n", s.texts, s.chars)
+	fmt.Fprintf(tw, "tm hits\t%d\n", s.tmHits)
+	if lookups := cacheHits + cacheMisses; lookups > 0 {
+		fmt.Fprintf(tw, "cache hits\t%d of %d (%.1f%%)\n", cacheHits, lookups, float64(cacheHits)*100/float64(lookups))
+	} else {
+		fmt.Fprintf(tw, "cache hits\t0\n")
+	}
+	fmt.Fprintf(tw, "api calls\t%d\n", calls)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(engines) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENGINE\tCALLS\tTEXTS\tCHARS\tERRORS\tTIME")
+	for _, e := range engines {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%v\n", e.Engine, e.Calls, e.Texts, e.Chars, e.Errors, time.Duration(e.TimeMS)*time.Millisecond)
+	}
+	return tw.Flush()
+}
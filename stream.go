@@ -0,0 +1,115 @@
+package gtrans
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// defaultChunkSize is the default maximum number of bytes translated at once
+// by Client.TranslateStream.
+const defaultChunkSize = 4096
+
+// StreamOptions are options of Client.TranslateStream.
+type StreamOptions struct {
+	// TargetLang is the language to translate into.
+	TargetLang string
+	// ChunkSize is the maximum number of bytes of a chunk translated at
+	// once. Chunks end at paragraphs if possible, or at lines otherwise, so a
+	// chunk of a longer line is longer. It's 4096, or the size of the engine
+	// if it's a ChunkSizer, if zero.
+	ChunkSize int
+}
+
+// WithStreamOutput makes the Client call emit with each piece of translated
+// text as it arrives, if the engine is a StreamTranslator. Casing is not
+// preserved, and the middlewares are bypassed, since the translated text has
+// already been emitted when it is complete.
+func WithStreamOutput(emit func(piece string)) Option {
+	return func(c *Client) error {
+		c.stream = emit
+		return nil
+	}
+}
+
+// TranslateStream translates the text read from r in chunks and writes each
+// translated chunk to w as soon as it's translated, so that the whole text is
+// never buffered. White spaces around chunks are written as they are.
+func (c *Client) TranslateStream(ctx context.Context, r io.Reader, w io.Writer, opts *StreamOptions) error {
+	return c.TranslateChunks(ctx, r, opts, func(lead string, res *Result, trail string) error {
+		translation := ""
+		if res != nil {
+			translation = res.Translation
+		}
+		_, err := io.WriteString(w, lead+translation+trail)
+		return err
+	})
+}
+
+// TranslateChunks translates the text read from r in chunks as
+// TranslateStream, calling emit with the result of each chunk and the white
+// spaces around it instead of writing them. res is nil for a chunk of white
+// spaces.
+func (c *Client) TranslateChunks(ctx context.Context, r io.Reader, opts *StreamOptions, emit func(lead string, res *Result, trail string) error) error {
+	size := opts.ChunkSize
+	if size <= 0 {
+		size = defaultChunkSize
+		if engine, err := c.getEngine(); err == nil {
+			if cs, ok := engine.(ChunkSizer); ok {
+				size = cs.MaxChunkSize()
+			}
+		}
+	}
+	var chunk strings.Builder
+	lastBreak := 0 // end of the last paragraph in chunk
+	flush := func(n int) error {
+		text := chunk.String()
+		rest := text[n:]
+		chunk.Reset()
+		chunk.WriteString(rest)
+		lastBreak = 0
+		text = text[:n]
+		body := strings.TrimLeftFunc(text, unicode.IsSpace)
+		lead := text[:len(text)-len(body)]
+		body = strings.TrimRightFunc(body, unicode.IsSpace)
+		trail := text[len(lead)+len(body):]
+		if body == "" {
+			return emit(text, nil, "")
+		}
+		res, err := c.Translate(ctx, body, opts.TargetLang)
+		if err != nil {
+			return err
+		}
+		return emit(lead, res, trail)
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if chunk.Len() > 0 && chunk.Len()+len(line) > size {
+			n := lastBreak
+			if n == 0 {
+				n = chunk.Len()
+			}
+			if err := flush(n); err != nil {
+				return err
+			}
+		}
+		chunk.WriteString(line)
+		if strings.TrimSpace(line) == "" {
+			lastBreak = chunk.Len()
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if chunk.Len() == 0 {
+		return nil
+	}
+	return flush(chunk.Len())
+}
@@ -0,0 +1,355 @@
+package gtrans
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+)
+
+// TranslationUnit is a pair of a source segment and its translation.
+type TranslationUnit struct {
+	SourceLang string
+	TargetLang string
+	Source     string
+	Target     string
+	Created    time.Time
+}
+
+// TranslationMemory is a local store of translated segments persisted as a
+// TMX file.
+type TranslationMemory struct {
+	path  string
+	Units []*TranslationUnit
+	// pairs are the indexes of Units by the language pair and the source
+	// text, and exact are the first Units by the target language and the
+	// source text. They're built when they're needed first.
+	pairs map[string]int
+	exact map[string]*TranslationUnit
+}
+
+// WithTranslationMemory makes the Client reuse the translations in tm which
+// are at least as similar (0-1) to texts as threshold, and record new ones in
+// it, which are saved by Close.
+func WithTranslationMemory(tm *TranslationMemory, threshold float64) Option {
+	return func(c *Client) error {
+		c.tm, c.tmThreshold = tm, threshold
+		return nil
+	}
+}
+
+// LoadTranslationMemory loads the translation memory at path. A missing file
+// is treated as an empty memory.
+func LoadTranslationMemory(path string) (*TranslationMemory, error) {
+	tm := &TranslationMemory{path: path}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return tm, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if tm.Units, err = readTMX(f); err != nil {
+		return nil, fmt.Errorf("fail to read translation memory %s: %v", path, err)
+	}
+	return tm, nil
+}
+
+// Add stores a translation, replacing any existing unit with the same source
+// text and language pair.
+func (tm *TranslationMemory) Add(u *TranslationUnit) {
+	if u.Created.IsZero() {
+		u.Created = time.Now().UTC()
+	}
+	tm.index()
+	key := exactKey(u.TargetLang, u.Source)
+	if i, ok := tm.pairs[pairKey(u)]; ok {
+		if tm.exact[key] == tm.Units[i] {
+			tm.exact[key] = u
+		}
+		tm.Units[i] = u
+		return
+	}
+	tm.pairs[pairKey(u)] = len(tm.Units)
+	if tm.exact[key] == nil {
+		tm.exact[key] = u
+	}
+	tm.Units = append(tm.Units, u)
+}
+
+// Lookup returns the unit whose source text is exactly text and which is
+// translated into targetLang, or nil.
+func (tm *TranslationMemory) Lookup(text, targetLang string) *TranslationUnit {
+	tm.index()
+	return tm.exact[exactKey(targetLang, text)]
+}
+
+// index builds the indexes of the units unless they're built.
+func (tm *TranslationMemory) index() {
+	if tm.pairs != nil {
+		return
+	}
+	tm.pairs = make(map[string]int, len(tm.Units))
+	tm.exact = make(map[string]*TranslationUnit, len(tm.Units))
+	for i, u := range tm.Units {
+		if _, ok := tm.pairs[pairKey(u)]; !ok {
+			tm.pairs[pairKey(u)] = i
+		}
+		if key := exactKey(u.TargetLang, u.Source); tm.exact[key] == nil {
+			tm.exact[key] = u
+		}
+	}
+}
+
+func pairKey(u *TranslationUnit) string {
+	return u.SourceLang + "\x00" + u.TargetLang + "\x00" + u.Source
+}
+
+func exactKey(targetLang, text string) string {
+	return targetLang + "\x00" + text
+}
+
+// Match returns the unit translated into targetLang whose source text is the
+// most similar to text, along with its similarity in [0, 1]. Units less
+// similar than threshold are ignored. It returns nil if there is no such unit.
+func (tm *TranslationMemory) Match(text, targetLang string, threshold float64) (*TranslationUnit, float64) {
+	if u := tm.Lookup(text, targetLang); u != nil {
+		return u, 1
+	}
+	if threshold >= 1 {
+		// Only exact matches count.
+		return nil, 0
+	}
+	var best *TranslationUnit
+	bestScore := threshold
+	n := utf8.RuneCountInString(text)
+	for _, u := range tm.Units {
+		if u.TargetLang != targetLang {
+			continue
+		}
+		// The edit distance is at least the difference of the lengths, so
+		// the similarity is at most the ratio of the lengths.
+		m := utf8.RuneCountInString(u.Source)
+		if shorter, longer := minMax(n, m); longer > 0 && float64(shorter)/float64(longer) < bestScore {
+			continue
+		}
+		if score := similarity(text, u.Source); score >= bestScore && score > 0 {
+			best, bestScore = u, score
+		}
+	}
+	if best == nil {
+		return nil, 0
+	}
+	return best, bestScore
+}
+
+// similarity returns 1 - (edit distance / length of the longer text), so 1
+// means identical text.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	n := len(ra)
+	if len(rb) > n {
+		n = len(rb)
+	}
+	if n == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(n)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minMax(a, b int) (int, int) {
+	if a > b {
+		return b, a
+	}
+	return a, b
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Import merges units of the TMX document read from r and returns the number
+// of imported units.
+func (tm *TranslationMemory) Import(r io.Reader) (int, error) {
+	units, err := readTMX(r)
+	if err != nil {
+		return 0, err
+	}
+	for _, u := range units {
+		tm.Add(u)
+	}
+	return len(units), nil
+}
+
+// Export writes the whole memory to w as a TMX document.
+func (tm *TranslationMemory) Export(w io.Writer) error {
+	return writeTMX(w, tm.Units)
+}
+
+// Save writes the memory back to its file.
+func (tm *TranslationMemory) Save() error {
+	if err := os.MkdirAll(filepath.Dir(tm.path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(tm.path), ".memory.tmx")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := tm.Export(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), tm.path)
+}
+
+// TMX 1.4: https://www.gala-global.org/tmx-14b
+const tmxTimeFormat = "20060102T150405Z"
+
+type tmxDocument struct {
+	XMLName xml.Name  `xml:"tmx"`
+	Version string    `xml:"version,attr"`
+	Header  tmxHeader `xml:"header"`
+	Units   []tmxUnit `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTMF                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tmxUnit struct {
+	SrcLang      string       `xml:"srclang,attr,omitempty"`
+	CreationDate string       `xml:"creationdate,attr,omitempty"`
+	Variants     []tmxVariant `xml:"tuv"`
+}
+
+type tmxVariant struct {
+	XMLLang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Lang    string `xml:"lang,attr,omitempty"` // TMX 1.1
+	Seg     string `xml:"seg"`
+}
+
+func (v tmxVariant) lang() string {
+	if v.XMLLang != "" {
+		return v.XMLLang
+	}
+	return v.Lang
+}
+
+func readTMX(r io.Reader) ([]*TranslationUnit, error) {
+	var doc tmxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var units []*TranslationUnit
+	for _, tu := range doc.Units {
+		srcLang := tu.SrcLang
+		if srcLang == "" {
+			srcLang = doc.Header.SrcLang
+		}
+		// Pick the source variant, falling back to the first one when the
+		// source language is "*all*" or unspecified.
+		src := -1
+		for i, v := range tu.Variants {
+			if v.lang() == srcLang {
+				src = i
+				break
+			}
+		}
+		if src == -1 {
+			src = 0
+		}
+		created, _ := time.Parse(tmxTimeFormat, tu.CreationDate)
+		for i, v := range tu.Variants {
+			if i == src {
+				continue
+			}
+			units = append(units, &TranslationUnit{
+				SourceLang: tu.Variants[src].lang(),
+				TargetLang: v.lang(),
+				Source:     tu.Variants[src].Seg,
+				Target:     v.Seg,
+				Created:    created,
+			})
+		}
+	}
+	return units, nil
+}
+
+func writeTMX(w io.Writer, units []*TranslationUnit) error {
+	doc := tmxDocument{
+		Version: "1.4",
+		Header: tmxHeader{
+			CreationTool:        "gtrans",
+			CreationToolVersion: "1",
+			SegType:             "paragraph",
+			OTMF:                "gtrans",
+			AdminLang:           "en",
+			SrcLang:             "*all*",
+			DataType:            "plaintext",
+		},
+	}
+	for _, u := range units {
+		tu := tmxUnit{
+			SrcLang: u.SourceLang,
+			Variants: []tmxVariant{
+				{XMLLang: u.SourceLang, Seg: u.Source},
+				{XMLLang: u.TargetLang, Seg: u.Target},
+			},
+		}
+		if !u.Created.IsZero() {
+			tu.CreationDate = u.Created.UTC().Format(tmxTimeFormat)
+		}
+		doc.Units = append(doc.Units, tu)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
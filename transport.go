@@ -0,0 +1,30 @@
+package gtrans
+
+import (
+	"net/http"
+	"time"
+)
+
+// sharedTransport is the transport of requests to the APIs of all engines
+// unless WithHTTPClient or WithTransport gives another, so that a run reuses
+// the keep-alive connections, over HTTP/2 where the APIs support it, for all
+// of its batches, files and engines instead of a TLS handshake per request.
+var sharedTransport = newSharedTransport()
+
+// SharedTransport returns the transport of requests to the APIs of engines
+// unless WithHTTPClient or WithTransport gives another, e.g. to tune its
+// limits of connections before any request is sent.
+func SharedTransport() *http.Transport {
+	return sharedTransport
+}
+
+func newSharedTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ForceAttemptHTTP2 = true
+	// The default of 2 idle connections per host closes the connections of
+	// concurrent requests to an API as soon as they are done.
+	t.MaxIdleConns = 256
+	t.MaxIdleConnsPerHost = 64
+	t.IdleConnTimeout = 90 * time.Second
+	return t
+}
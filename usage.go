@@ -0,0 +1,106 @@
+package gtrans
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// The usage log is a local file of daily counts of translations, e.g. read by
+// 'gtrans stats'. It's never sent anywhere.
+
+// UsageDay are the counts of a day in the usage log.
+type UsageDay struct {
+	Date    string                 `json:"date"`
+	Runs    int                    `json:"runs"`
+	Texts   int                    `json:"texts"`
+	Chars   int                    `json:"chars"`
+	Calls   int                    `json:"calls"`
+	Engines map[string]*UsageCount `json:"engines,omitempty"`
+	Pairs   map[string]int         `json:"pairs,omitempty"`
+}
+
+// UsageCount are the API calls to an engine and the characters sent.
+type UsageCount struct {
+	Calls int `json:"calls"`
+	Chars int `json:"chars"`
+}
+
+// WithUsageLog makes Close add the counts of the run to the usage log at
+// path, unless nothing is translated.
+func WithUsageLog(path string) Option {
+	return func(c *Client) error {
+		if c.stats == nil {
+			c.stats = newRunStats()
+		}
+		c.usageLog = path
+		return nil
+	}
+}
+
+// LoadUsage returns the days of the usage log at path by date, e.g.
+// "2006-01-02". A missing log has no days.
+func LoadUsage(path string) (map[string]*UsageDay, error) {
+	days := map[string]*UsageDay{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return days, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &days); err != nil {
+		return nil, fmt.Errorf("fail to read usage log %s: %v", path, err)
+	}
+	return days, nil
+}
+
+// recordUsage adds the counts of the run s to the day of now in the usage log
+// at path. Runs translating nothing aren't recorded. Counts of processes
+// writing at the same time may be lost.
+func recordUsage(path string, s *runStats, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.texts == 0 && s.tmHits == 0 {
+		return nil
+	}
+	days, err := LoadUsage(path)
+	if err != nil {
+		return err
+	}
+	date := now.Format("2006-01-02")
+	d := days[date]
+	if d == nil {
+		d = &UsageDay{Date: date}
+		days[date] = d
+	}
+	if d.Engines == nil {
+		d.Engines = map[string]*UsageCount{}
+	}
+	if d.Pairs == nil {
+		d.Pairs = map[string]int{}
+	}
+	d.Runs++
+	d.Texts += s.texts + s.tmHits
+	d.Chars += s.chars
+	for name, e := range s.engines {
+		c := d.Engines[name]
+		if c == nil {
+			c = &UsageCount{}
+			d.Engines[name] = c
+		}
+		c.Calls += e.Calls
+		c.Chars += e.Chars
+		d.Calls += e.Calls
+	}
+	for pair, n := range s.pairs {
+		d.Pairs[pair] += n
+	}
+	b, err := json.MarshalIndent(days, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeCacheFile(path, b)
+}
@@ -0,0 +1,160 @@
+package gtrans
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Yandex translates texts with Yandex Cloud Translate API, which is good at
+// Russian and other CIS languages.
+// https://yandex.cloud/en/docs/translate/api-ref/Translation/
+type Yandex struct {
+	apiKey   string
+	folderID string // required for service accounts with IAM tokens
+	baseURL  string
+	client   *http.Client
+}
+
+func newYandexEngine(o *engineOptions) (Engine, error) {
+	apiKey := o.credential("yandex", "YANDEX_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("YANDEX_API_KEY is not set. Export it or run 'gtrans auth yandex'")
+	}
+	return &Yandex{
+		apiKey:   apiKey,
+		folderID: os.Getenv("YANDEX_FOLDER_ID"),
+		baseURL:  strings.TrimRight(o.endpointOr("https://translate.api.cloud.yandex.net/translate/v2"), "/"),
+		client:   o.client(),
+	}, nil
+}
+
+func (y *Yandex) Name() string { return "yandex" }
+
+func (y *Yandex) Translate(ctx context.Context, text, target string) (*Translation, error) {
+	ts, err := y.TranslateBatch(ctx, []string{text}, target)
+	if err != nil {
+		return nil, err
+	}
+	return ts[0], nil
+}
+
+// yandexMaxChars is the maximum number of characters of texts in a request.
+const yandexMaxChars = 10000
+
+// Limits returns the limits of Yandex Cloud Translate API, which also allows
+// 20 requests per second.
+func (y *Yandex) Limits() Limits {
+	return Limits{MaxChars: yandexMaxChars, PerSecond: 20}
+}
+
+func (y *Yandex) TranslateBatch(ctx context.Context, texts []string, target string) ([]*Translation, error) {
+	ts := make([]*Translation, 0, len(texts))
+	for len(texts) > 0 {
+		// At least a text is sent, which fails if it's too long by itself.
+		n, chars := 1, utf8.RuneCountInString(texts[0])
+		for n < len(texts) && chars+utf8.RuneCountInString(texts[n]) <= yandexMaxChars {
+			chars += utf8.RuneCountInString(texts[n])
+			n++
+		}
+		var result struct {
+			Translations []struct {
+				Text                 string `json:"text"`
+				DetectedLanguageCode string `json:"detectedLanguageCode"`
+			} `json:"translations"`
+		}
+		req := map[string]interface{}{"targetLanguageCode": yandexLang(target), "texts": texts[:n]}
+		if err := y.call(ctx, "translate", req, &result); err != nil {
+			return nil, err
+		}
+		if len(result.Translations) != n {
+			return nil, fmt.Errorf("Yandex API returned %d translations for %d texts", len(result.Translations), n)
+		}
+		for _, t := range result.Translations {
+			ts = append(ts, &Translation{Text: t.Text, SourceLang: t.DetectedLanguageCode})
+		}
+		texts = texts[n:]
+	}
+	return ts, nil
+}
+
+func (y *Yandex) Detect(ctx context.Context, text string) (string, error) {
+	var result struct {
+		LanguageCode string `json:"languageCode"`
+	}
+	if err := y.call(ctx, "detect", map[string]interface{}{"text": text}, &result); err != nil {
+		return "", err
+	}
+	return result.LanguageCode, nil
+}
+
+// Languages returns the languages of Yandex. Their names are in the languages
+// themselves.
+func (y *Yandex) Languages(ctx context.Context, display string) ([]Language, error) {
+	var result struct {
+		Languages []struct {
+			Code string `json:"code"`
+			Name string `json:"name"`
+		} `json:"languages"`
+	}
+	if err := y.call(ctx, "languages", map[string]interface{}{}, &result); err != nil {
+		return nil, err
+	}
+	langs := make([]Language, len(result.Languages))
+	for i, l := range result.Languages {
+		langs[i] = Language{Code: l.Code, Name: l.Name}
+	}
+	return langs, nil
+}
+
+// call posts req with the folder ID to the API method and decodes the JSON
+// response into v.
+func (y *Yandex) call(ctx context.Context, method string, req map[string]interface{}, v interface{}) error {
+	if y.folderID != "" {
+		req["folderId"] = y.folderID
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, "POST", y.baseURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", "Api-Key "+y.apiKey)
+	r.Header.Set("Content-Type", "application/json")
+	resp, err := y.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("fail to call Yandex API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Message != "" {
+			return fmt.Errorf("fail to call Yandex API: %s: %s", resp.Status, e.Message)
+		}
+		return fmt.Errorf("fail to call Yandex API: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("fail to decode Yandex API response: %v", err)
+	}
+	return nil
+}
+
+// yandexLang converts a language code used by Google Translate into a Yandex
+// language code, which has no regions.
+func yandexLang(lang string) string {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	return lang
+}